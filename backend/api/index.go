@@ -1,16 +1,29 @@
 package api
 
 import (
+	"log/slog"
 	"net/http"
 
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/d-rk/checkin-system/pkg/jobs"
 	"github.com/d-rk/checkin-system/pkg/server"
+	"github.com/d-rk/checkin-system/pkg/websocket"
 )
 
 // Handler is the entrypoint for the vercel serverless function.
 func Handler(w http.ResponseWriter, r *http.Request) {
-	db := server.NewDB(false)
+
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("invalid configuration", "error", err)
+		http.Error(w, "invalid configuration", http.StatusInternalServerError)
+		return
+	}
+
+	db := server.NewDB(cfg.Database, false)
 	defer db.Close()
 
-	router := server.NewRouter(r.Context(), db)
+	// serverless functions don't live long enough for the background jobs, so the runner is never started
+	router := server.NewRouter(cfg, db, jobs.NewRunner(), &websocket.Server{})
 	router.ServeHTTP(w, r)
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/d-rk/checkin-system/pkg/backup"
+	"github.com/d-rk/checkin-system/pkg/server"
+)
+
+const backupUsage = `usage: checkin-system backup [-o file]
+
+backs up the sqlite database to BACKUP_DIR and removes the backups exceeding BACKUP_GENERATIONS, like the backup
+job of the server. With -o the backup is written to the file instead, "-" writes it to stdout.
+`
+
+// runBackup runs the backup subcommand on the configured database and returns the exit code.
+func runBackup(args []string) int {
+
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, backupUsage) }
+	output := flags.String("o", "", "file to write the backup to (default: a new file in BACKUP_DIR)")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+
+	cfg, ok := loadConfig()
+	if !ok {
+		return 1
+	}
+
+	db := server.NewDB(cfg.Database, true)
+	defer db.Close()
+
+	backupService := backup.NewService(backup.NewRepo(db), cfg.Backup)
+	ctx := context.Background()
+
+	if *output == "" {
+		path, err := backupService.CreateLocalBackup(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "backup failed: %v\n", err)
+			return 1
+		}
+		fmt.Printf("created backup %s\n", path)
+		return 0
+	}
+
+	snapshot, err := backupService.Snapshot(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backup failed: %v\n", err)
+		return 1
+	}
+	defer snapshot.Close()
+
+	path := *output
+	if path == "-" {
+		path = ""
+	}
+
+	if err = writeOutput(path, func(w io.Writer) error {
+		_, copyErr := io.Copy(w, snapshot)
+		return copyErr
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "backup failed: %v\n", err)
+		return 1
+	}
+
+	if path != "" {
+		fmt.Printf("created backup %s\n", path)
+	}
+
+	return 0
+}
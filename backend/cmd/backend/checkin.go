@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/checkin"
+	"github.com/d-rk/checkin-system/pkg/server"
+
+	"github.com/gocarina/gocsv"
+	"gopkg.in/guregu/null.v4"
+)
+
+const checkinUsage = `usage: checkin-system checkin export [-tenant slug] [-from date] [-to date] [-location id]
+                                      [-format csv|json] [-o file]
+
+writes the check-ins of the days from -from up to and including -to (default: all up to today) to the file, or to
+stdout. The dates are formatted as 2006-01-02.
+`
+
+const retentionUsage = `usage: checkin-system retention run [-dry-run]
+
+deletes the check-ins of all tenants older than CHECKIN_RETENTION_DAYS, like the daily retention job of the server.
+`
+
+// runCheckin runs the checkin subcommand on the configured database and returns the exit code.
+func runCheckin(args []string) int {
+
+	if len(args) == 0 || args[0] != "export" {
+		fmt.Fprint(os.Stderr, checkinUsage)
+		return 2
+	}
+
+	flags := flag.NewFlagSet("checkin export", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, checkinUsage) }
+	tenantSlug := flags.String("tenant", "", "slug of the tenant (default: the default tenant)")
+	from := flags.String("from", "", "first day of the export")
+	to := flags.String("to", time.Now().Format(time.DateOnly), "last day of the export")
+	locationID := flags.Int64("location", 0, "id of the location of the check-ins (default: all)")
+	format := flags.String("format", "csv", "format of the export, csv or json")
+	output := flags.String("o", "", "file to write the export to (default: stdout)")
+
+	if err := flags.Parse(args[1:]); err != nil || flags.NArg() > 0 || (*format != "csv" && *format != "json") {
+		flags.Usage()
+		return 2
+	}
+
+	var fromDay, toDay time.Time
+	var err error
+
+	if *from != "" {
+		fromDay, err = time.Parse(time.DateOnly, *from)
+	}
+	if err == nil {
+		toDay, err = time.Parse(time.DateOnly, *to)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid date: %v\n", err)
+		return 2
+	}
+
+	cfg, ok := loadConfig()
+	if !ok {
+		return 1
+	}
+
+	db := server.NewDB(cfg.Database, true)
+	defer db.Close()
+
+	s, err := newServices(cfg, db)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "checkin export failed: %v\n", err)
+		return 1
+	}
+
+	ctx, err := s.withTenant(context.Background(), *tenantSlug)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "checkin export failed: %v\n", err)
+		return 1
+	}
+
+	filter := checkin.Filter{}
+	if *locationID != 0 {
+		filter.LocationID = null.IntFrom(*locationID)
+	}
+
+	checkIns, err := s.checkin.ListCheckInsByDates(ctx, fromDay, toDay, filter)
+	if err == nil {
+		err = writeOutput(*output, func(w io.Writer) error { return writeCheckIns(w, *format, checkIns) })
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "checkin export failed: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d check-ins\n", len(checkIns))
+
+	return 0
+}
+
+func writeCheckIns(w io.Writer, format string, checkIns []checkin.WithUser) error {
+
+	if format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(checkIns)
+	}
+
+	return gocsv.Marshal(checkIns, w)
+}
+
+// writeOutput writes to the file, or to stdout if the path is empty.
+func writeOutput(path string, write func(w io.Writer) error) error {
+
+	if path == "" {
+		return write(os.Stdout)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = write(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// runRetention runs the retention subcommand on the configured database and returns the exit code.
+func runRetention(args []string) int {
+
+	if len(args) == 0 || args[0] != "run" {
+		fmt.Fprint(os.Stderr, retentionUsage)
+		return 2
+	}
+
+	flags := flag.NewFlagSet("retention run", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, retentionUsage) }
+	dryRun := flags.Bool("dry-run", false, "only report the number of check-ins which would be deleted")
+
+	if err := flags.Parse(args[1:]); err != nil || flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+
+	cfg, ok := loadConfig()
+	if !ok {
+		return 1
+	}
+
+	db := server.NewDB(cfg.Database, true)
+	defer db.Close()
+
+	s, err := newServices(cfg, db)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "retention run failed: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+
+	count, err := s.checkin.CountOldCheckIns(ctx)
+	if err == nil && !*dryRun {
+		err = s.checkin.DeleteOldCheckIns(ctx)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "retention run failed: %v\n", err)
+		return 1
+	}
+
+	if *dryRun {
+		fmt.Printf("would delete %d check-ins older than %d days\n", count, cfg.Checkin.RetentionDays)
+	} else {
+		fmt.Printf("deleted %d check-ins older than %d days\n", count, cfg.Checkin.RetentionDays)
+	}
+
+	return 0
+}
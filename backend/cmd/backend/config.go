@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const configUsage = `usage: checkin-system config print
+
+prints the configuration loaded from CONFIG_FILE and the environment as YAML, with the values of secrets masked.
+The output can be used as config file.
+`
+
+// runConfig runs the config subcommand and returns the exit code.
+func runConfig(args []string) int {
+
+	if len(args) != 1 || args[0] != "print" {
+		fmt.Fprint(os.Stderr, configUsage)
+		return 2
+	}
+
+	cfg, ok := loadConfig()
+	if !ok {
+		return 1
+	}
+
+	if err := cfg.Print(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "config print failed: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
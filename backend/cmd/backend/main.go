@@ -1,11 +1,98 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
+	"github.com/d-rk/checkin-system/pkg/config"
 	"github.com/d-rk/checkin-system/pkg/server"
 )
 
 //go:generate go tool oapi-codegen --config=../../open-api-conf.yaml ../../open-api-spec.yaml
 
+const usage = `usage: checkin-system [command]
+
+commands:
+  serve      serve the api, the default without a command
+  migrate    apply, roll back or list the database migrations
+  user       create, list and change users
+  checkin    export check-ins
+  retention  delete the check-ins older than CHECKIN_RETENTION_DAYS
+  backup     back up the sqlite database
+  export     export the data of all tenants
+  import     import an export
+  config     print the configuration
+
+run "checkin-system <command> -h" for the usage of a command.
+`
+
 func main() {
-	server.Run()
+
+	command := "serve"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	var args []string
+	if len(os.Args) > 2 {
+		args = os.Args[2:]
+	}
+
+	switch command {
+	case "serve":
+		os.Exit(runServe(args))
+	case "migrate":
+		os.Exit(runMigrate(args))
+	case "user":
+		os.Exit(runUser(args))
+	case "checkin":
+		os.Exit(runCheckin(args))
+	case "retention":
+		os.Exit(runRetention(args))
+	case "backup":
+		os.Exit(runBackup(args))
+	case "export":
+		os.Exit(runExport(args))
+	case "import":
+		os.Exit(runImport(args))
+	case "config":
+		os.Exit(runConfig(args))
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stdout, usage)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// runServe serves the api until it is stopped and returns the exit code.
+func runServe(args []string) int {
+
+	if len(args) > 0 {
+		fmt.Fprint(os.Stderr, "usage: checkin-system serve\n")
+		return 2
+	}
+
+	cfg, ok := loadConfig()
+	if !ok {
+		return 1
+	}
+
+	if err := server.Run(cfg); err != nil {
+		return 1
+	}
+
+	return 0
+}
+
+// loadConfig loads the configuration and reports the invalid settings.
+func loadConfig() (*config.Config, bool) {
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, false
+	}
+
+	return cfg, true
 }
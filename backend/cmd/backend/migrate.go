@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/server"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const migrateUsage = `usage: checkin-system migrate <command> [-limit n]
+
+commands:
+  up      apply the pending migrations
+  down    roll back the last migration, or the last n migrations with -limit
+  status  list the migrations and when they were applied
+  redo    roll back the last migration and apply it again
+`
+
+// runMigrate runs the migrate subcommand on the configured database and returns the exit code.
+func runMigrate(args []string) int {
+
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	command := args[0]
+
+	flags := flag.NewFlagSet("migrate "+command, flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, migrateUsage) }
+	limit := flags.Int("limit", -1, "number of migrations, 0 for all (default: all for up, 1 for down)")
+
+	if err := flags.Parse(args[1:]); err != nil || flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+
+	switch command {
+	case "up", "down", "redo", "status":
+	default:
+		flags.Usage()
+		return 2
+	}
+
+	cfg, ok := loadConfig()
+	if !ok {
+		return 1
+	}
+
+	db := server.NewDB(cfg.Database, false)
+	defer db.Close()
+
+	ctx := context.Background()
+
+	var err error
+
+	switch command {
+	case "up":
+		var n int
+		if n, err = database.MigrateUp(ctx, db, max(*limit, 0)); err == nil {
+			fmt.Printf("applied %d migrations\n", n)
+		}
+	case "down":
+		if *limit < 0 {
+			*limit = 1
+		}
+		var n int
+		if n, err = database.MigrateDown(ctx, db, *limit); err == nil {
+			fmt.Printf("rolled back %d migrations\n", n)
+		}
+	case "redo":
+		var id string
+		if id, err = database.RedoMigration(ctx, db); err == nil {
+			fmt.Printf("reapplied %s\n", id)
+		}
+	case "status":
+		err = printMigrations(db)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate %s failed: %v\n", command, err)
+		return 1
+	}
+
+	return 0
+}
+
+func printMigrations(db *sqlx.DB) error {
+
+	migrations, err := database.Migrations(db)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MIGRATION\tAPPLIED")
+
+	for _, m := range migrations {
+		applied := "no"
+		if m.AppliedAt != nil {
+			applied = m.AppliedAt.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%s\t%s\n", m.ID, applied)
+	}
+
+	return w.Flush()
+}
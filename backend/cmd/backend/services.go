@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/d-rk/checkin-system/pkg/announcement"
+	"github.com/d-rk/checkin-system/pkg/audit"
+	"github.com/d-rk/checkin-system/pkg/checkin"
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/group"
+	"github.com/d-rk/checkin-system/pkg/location"
+	"github.com/d-rk/checkin-system/pkg/password"
+	"github.com/d-rk/checkin-system/pkg/tenant"
+	"github.com/d-rk/checkin-system/pkg/user"
+	"github.com/d-rk/checkin-system/pkg/websocket"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// services are the services of the admin commands. They are the ones of the api, so that the same rules apply.
+type services struct {
+	transactor database.Transactor
+	user       user.Service
+	checkin    checkin.Service
+	tenant     tenant.Service
+}
+
+func newServices(cfg *config.Config, db *sqlx.DB) (*services, error) {
+
+	passwordHasher, err := password.NewHasher(cfg.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	passwordPolicy, err := password.NewPolicy(cfg.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	// the commands have no websocket clients to notify
+	ws := &websocket.Server{}
+	transactor := database.NewDB(db)
+
+	userService := user.NewService(user.NewRepo(db), passwordHasher, passwordPolicy, ws,
+		audit.NewService(audit.NewRepo(db)), transactor, cfg.Users)
+	checkinService := checkin.NewService(checkin.NewRepo(db), userService,
+		announcement.NewService(announcement.NewRepo(db), ws), group.NewService(group.NewRepo(db)),
+		location.NewService(location.NewRepo(db)), ws, transactor, cfg.Checkin)
+
+	return &services{
+		transactor: transactor,
+		user:       userService,
+		checkin:    checkinService,
+		tenant:     tenant.NewService(tenant.NewRepo(db), cfg.Tenant),
+	}, nil
+}
+
+// withTenant returns a context for the tenant with the slug, or for the default tenant if the slug is empty.
+func (s *services) withTenant(ctx context.Context, slug string) (context.Context, error) {
+
+	if slug == "" {
+		return tenant.WithID(ctx, tenant.DefaultID), nil
+	}
+
+	t, err := s.tenant.GetTenantBySlug(ctx, slug)
+	if err != nil {
+		return nil, fmt.Errorf("unknown tenant %s: %w", slug, err)
+	}
+
+	return tenant.WithID(ctx, t.ID), nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/d-rk/checkin-system/pkg/group"
+	"github.com/d-rk/checkin-system/pkg/server"
+	"github.com/d-rk/checkin-system/pkg/user"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+const userUsage = `usage: checkin-system user <command> [-tenant slug] [flags] [args]
+
+commands:
+  create <name>             create a user, the flags set its -role (default USER), -email, -member-id and
+                            -rfid-uid. With -password-stdin its password is read from stdin
+  list                      list the users
+  set-password <name>       set the password of the user to the one read from stdin
+  set-role <name> <role>    set the role of the user
+
+the roles are SUPER_ADMIN, ADMIN, TRAINER, USER and SYNC (edge instances). The commands apply to the default
+tenant, or to the tenant with the slug of -tenant.
+`
+
+var roles = []string{user.RoleSuperAdmin, user.RoleAdmin, user.RoleTrainer, user.RoleUser, user.RoleSync}
+
+// runUser runs the user subcommand on the configured database and returns the exit code.
+func runUser(args []string) int {
+
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, userUsage)
+		return 2
+	}
+
+	command := args[0]
+
+	flags := flag.NewFlagSet("user "+command, flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, userUsage) }
+	tenantSlug := flags.String("tenant", "", "slug of the tenant (default: the default tenant)")
+
+	newUser := &user.User{}
+	passwordStdin := false
+
+	var nArgs int
+
+	switch command {
+	case "create":
+		flags.StringVar(&newUser.Role, "role", user.RoleUser, "role of the user")
+		flags.Func("email", "email of the user", nullStringFlag(&newUser.Email))
+		flags.Func("member-id", "member id of the user", nullStringFlag(&newUser.MemberID))
+		flags.Func("rfid-uid", "uid of the rfid card of the user", nullStringFlag(&newUser.RFIDuid))
+		flags.BoolVar(&passwordStdin, "password-stdin", false, "read the password of the user from stdin")
+		nArgs = 1
+	case "list":
+		nArgs = 0
+	case "set-password":
+		nArgs = 1
+	case "set-role":
+		nArgs = 2
+	default:
+		flags.Usage()
+		return 2
+	}
+
+	if err := flags.Parse(args[1:]); err != nil || flags.NArg() != nArgs {
+		flags.Usage()
+		return 2
+	}
+
+	cfg, ok := loadConfig()
+	if !ok {
+		return 1
+	}
+
+	db := server.NewDB(cfg.Database, true)
+	defer db.Close()
+
+	s, err := newServices(cfg, db)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "user %s failed: %v\n", command, err)
+		return 1
+	}
+
+	ctx, err := s.withTenant(context.Background(), *tenantSlug)
+	if err == nil {
+		switch command {
+		case "create":
+			newUser.Name = flags.Arg(0)
+			err = createUser(ctx, s, newUser, passwordStdin)
+		case "list":
+			err = listUsers(ctx, s)
+		case "set-password":
+			err = setUserPassword(ctx, s, flags.Arg(0))
+		case "set-role":
+			err = setUserRole(ctx, s, flags.Arg(0), flags.Arg(1))
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "user %s failed: %v\n", command, err)
+		return 1
+	}
+
+	return 0
+}
+
+// createUser creates the user and sets its password within one transaction, so that no user is left without the
+// password if it does not fulfill the password policy.
+func createUser(ctx context.Context, s *services, newUser *user.User, passwordStdin bool) error {
+
+	if err := checkRole(newUser.Role); err != nil {
+		return err
+	}
+
+	var password string
+	if passwordStdin {
+		var err error
+		if password, err = readPassword(os.Stdin); err != nil {
+			return err
+		}
+	}
+
+	var created *user.User
+
+	err := s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+
+		var err error
+		if created, err = s.user.CreateUser(ctx, newUser); err != nil {
+			return err
+		}
+
+		if passwordStdin {
+			return s.user.UpdateUserPassword(ctx, created.ID, password)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("created user %s with id %d\n", created.Name, created.ID)
+	return nil
+}
+
+func listUsers(ctx context.Context, s *services) error {
+
+	users, err := s.user.ListUsers(ctx, group.Scope{})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tROLE\tGROUP\tMEMBER ID\tRFID UID\tEMAIL")
+
+	for _, u := range users {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", u.ID, u.Name, u.Role, u.Group.ValueOrZero(),
+			u.MemberID.ValueOrZero(), u.RFIDuid.ValueOrZero(), u.Email.ValueOrZero())
+	}
+
+	return w.Flush()
+}
+
+func setUserPassword(ctx context.Context, s *services, name string) error {
+
+	u, err := s.user.GetUserByName(ctx, name)
+	if err != nil {
+		return fmt.Errorf("user %s: %w", name, err)
+	}
+
+	password, err := readPassword(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	if err = s.user.UpdateUserPassword(ctx, u.ID, password); err != nil {
+		return err
+	}
+
+	fmt.Printf("set password of user %s\n", u.Name)
+	return nil
+}
+
+func setUserRole(ctx context.Context, s *services, name string, role string) error {
+
+	if err := checkRole(role); err != nil {
+		return err
+	}
+
+	u, err := s.user.GetUserByName(ctx, name)
+	if err != nil {
+		return fmt.Errorf("user %s: %w", name, err)
+	}
+
+	u.Role = role
+
+	if _, err = s.user.UpdateUser(ctx, u); err != nil {
+		return err
+	}
+
+	fmt.Printf("set role of user %s to %s\n", u.Name, u.Role)
+	return nil
+}
+
+func checkRole(role string) error {
+	if !slices.Contains(roles, role) {
+		return fmt.Errorf("unknown role %s, expected one of %s", role, strings.Join(roles, ", "))
+	}
+	return nil
+}
+
+// readPassword reads the password from the first line of the reader, e.g. piped in from a password manager.
+func readPassword(r io.Reader) (string, error) {
+
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("no password on stdin")
+	}
+
+	return password, nil
+}
+
+// nullStringFlag sets the value of a flag which is null unless the flag is given.
+func nullStringFlag(value *null.String) func(string) error {
+	return func(s string) error {
+		*value = null.StringFrom(s)
+		return nil
+	}
+}
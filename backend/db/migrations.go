@@ -0,0 +1,9 @@
+// Package db embeds the database migrations into the binary, so that they are found from any working directory.
+package db
+
+import "embed"
+
+// Migrations contains a folder with the migrations of every driver, e.g. migrations/postgres.
+//
+//go:embed migrations
+var Migrations embed.FS
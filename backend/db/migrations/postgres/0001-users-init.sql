@@ -7,3 +7,6 @@ create table users
     name       varchar(255) not null constraint users_name_key unique,
     rfid_uid   varchar(255) not null constraint users_name_rfid unique
 );
+
+-- +migrate Down
+DROP TABLE users;
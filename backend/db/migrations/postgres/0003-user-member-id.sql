@@ -1,3 +1,7 @@
 -- +migrate Up
 ALTER TABLE users
-ADD COLUMN member_id varchar(255) constraint users_member_id unique;
\ No newline at end of file
+ADD COLUMN member_id varchar(255) constraint users_member_id unique;
+
+-- +migrate Down
+ALTER TABLE users
+DROP COLUMN member_id;
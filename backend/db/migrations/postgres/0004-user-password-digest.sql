@@ -6,3 +6,16 @@ ALTER TABLE users
 ALTER COLUMN rfid_uid DROP NOT NULL;
 
 INSERT INTO users (created_at, name) VALUES (current_timestamp, 'admin');
+
+-- +migrate Down
+DELETE FROM checkins WHERE user_id IN (SELECT id FROM users WHERE name = 'admin' AND rfid_uid IS NULL);
+DELETE FROM users WHERE name = 'admin' AND rfid_uid IS NULL;
+
+-- users without card get a placeholder, as the rfid_uid was mandatory
+UPDATE users SET rfid_uid = 'unassigned-' || id WHERE rfid_uid IS NULL;
+
+ALTER TABLE users
+ALTER COLUMN rfid_uid SET NOT NULL;
+
+ALTER TABLE users
+DROP COLUMN password_digest;
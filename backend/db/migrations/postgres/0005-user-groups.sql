@@ -11,3 +11,12 @@ ALTER COLUMN role drop default;
 UPDATE users SET role='ADMIN', updated_at=current_timestamp where name = 'admin';
 
 CREATE INDEX idx_user_groups ON users(group_name);
+
+-- +migrate Down
+DROP INDEX idx_user_groups;
+
+ALTER TABLE users
+DROP COLUMN role;
+
+ALTER TABLE users
+DROP COLUMN group_name;
@@ -0,0 +1,18 @@
+-- +migrate Up
+create table announcements
+(
+    id          bigserial    not null constraint announcements_pkey primary key,
+    created_at  timestamp with time zone not null,
+    updated_at  timestamp with time zone,
+    title       varchar(255) not null,
+    message     text,
+    valid_from  timestamp with time zone,
+    valid_until timestamp with time zone,
+    group_name  varchar(50),
+    user_id     bigint       constraint fk_announcements_user references users on delete cascade
+);
+
+CREATE INDEX idx_announcements_user ON announcements(user_id);
+
+-- +migrate Down
+DROP TABLE announcements;
@@ -0,0 +1,11 @@
+-- +migrate Up
+create table signing_keys
+(
+    id          varchar(64) not null constraint signing_keys_pkey primary key,
+    algorithm   varchar(16) not null,
+    private_key text        not null,
+    created_at  timestamp with time zone not null
+);
+
+-- +migrate Down
+DROP TABLE signing_keys;
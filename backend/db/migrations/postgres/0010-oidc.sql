@@ -0,0 +1,21 @@
+-- +migrate Up
+create table user_identities
+(
+    issuer     varchar(255) not null,
+    subject    varchar(255) not null,
+    user_id    bigint       not null constraint fk_user_identities_user references users on delete cascade,
+    created_at timestamp with time zone not null,
+    constraint user_identities_pkey primary key (issuer, subject)
+);
+
+create table oidc_login_states
+(
+    state         varchar(64)  not null constraint oidc_login_states_pkey primary key,
+    nonce         varchar(64)  not null,
+    code_verifier varchar(128) not null,
+    created_at    timestamp with time zone not null
+);
+
+-- +migrate Down
+DROP TABLE oidc_login_states;
+DROP TABLE user_identities;
@@ -0,0 +1,20 @@
+-- +migrate Up
+ALTER TABLE users
+ADD COLUMN email varchar(255);
+
+create table password_tokens
+(
+    id           bigserial    not null constraint password_tokens_pkey primary key,
+    user_id      bigint       not null constraint fk_password_tokens_user references users on delete cascade,
+    purpose      varchar(16)  not null,
+    token_digest varchar(64)  not null constraint password_tokens_digest_key unique,
+    created_at   timestamp with time zone not null,
+    expires_at   timestamp with time zone not null,
+    used_at      timestamp with time zone
+);
+
+-- +migrate Down
+DROP TABLE password_tokens;
+
+ALTER TABLE users
+DROP COLUMN email;
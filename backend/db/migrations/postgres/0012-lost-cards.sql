@@ -0,0 +1,13 @@
+-- +migrate Up
+create table lost_cards
+(
+    id          bigserial    not null constraint lost_cards_pkey primary key,
+    user_id     bigint       not null constraint fk_lost_cards_user references users on delete cascade,
+    rfid_uid    varchar(255) not null,
+    reported_at timestamp with time zone not null
+);
+
+create index lost_cards_rfid_uid_idx on lost_cards (rfid_uid);
+
+-- +migrate Down
+DROP TABLE lost_cards;
@@ -0,0 +1,72 @@
+-- +migrate Up
+create table groups
+(
+    id          bigserial    not null constraint groups_pkey primary key,
+    created_at  timestamp with time zone not null,
+    updated_at  timestamp with time zone,
+    name        varchar(50)  not null constraint groups_name_key unique,
+    description text,
+    colour      varchar(7),
+    capacity    integer,
+    schedule    varchar(255)
+);
+
+create table group_trainers
+(
+    group_id bigint not null constraint fk_group_trainers_group references groups on delete cascade,
+    user_id  bigint not null constraint fk_group_trainers_user references users on delete cascade,
+    constraint group_trainers_pkey primary key (group_id, user_id)
+);
+
+CREATE INDEX idx_group_trainers_user ON group_trainers(user_id);
+
+INSERT INTO groups (created_at, name)
+SELECT current_timestamp, name FROM (
+    SELECT group_name AS name FROM users WHERE group_name IS NOT NULL
+    UNION
+    SELECT group_name AS name FROM announcements WHERE group_name IS NOT NULL
+) AS group_names;
+
+ALTER TABLE users
+ADD COLUMN group_id bigint constraint fk_users_group references groups on delete set null;
+
+UPDATE users SET group_id = (SELECT id FROM groups WHERE groups.name = users.group_name);
+
+DROP INDEX idx_user_groups;
+
+ALTER TABLE users
+DROP COLUMN group_name;
+
+CREATE INDEX idx_users_group ON users(group_id);
+
+ALTER TABLE announcements
+ADD COLUMN group_id bigint constraint fk_announcements_group references groups on delete cascade;
+
+UPDATE announcements SET group_id = (SELECT id FROM groups WHERE groups.name = announcements.group_name);
+
+ALTER TABLE announcements
+DROP COLUMN group_name;
+
+-- +migrate Down
+ALTER TABLE announcements
+ADD COLUMN group_name varchar(50);
+
+UPDATE announcements SET group_name = (SELECT name FROM groups WHERE groups.id = announcements.group_id);
+
+ALTER TABLE announcements
+DROP COLUMN group_id;
+
+ALTER TABLE users
+ADD COLUMN group_name varchar(50);
+
+UPDATE users SET group_name = (SELECT name FROM groups WHERE groups.id = users.group_id);
+
+DROP INDEX idx_users_group;
+
+ALTER TABLE users
+DROP COLUMN group_id;
+
+CREATE INDEX idx_user_groups ON users(group_name);
+
+DROP TABLE group_trainers;
+DROP TABLE groups;
@@ -0,0 +1,32 @@
+-- +migrate Up
+create table locations
+(
+    id          bigserial    not null constraint locations_pkey primary key,
+    created_at  timestamp with time zone not null,
+    updated_at  timestamp with time zone,
+    name        varchar(50)  not null constraint locations_name_key unique,
+    description text,
+    capacity    integer
+);
+
+create table location_readers
+(
+    reader_id   varchar(100) not null constraint location_readers_pkey primary key,
+    location_id bigint       not null constraint fk_location_readers_location references locations on delete cascade
+);
+
+CREATE INDEX idx_location_readers_location ON location_readers(location_id);
+
+ALTER TABLE checkins
+    ADD COLUMN location_id bigint constraint fk_checkins_location references locations on delete set null;
+
+CREATE INDEX idx_checkins_location ON checkins(location_id);
+
+-- +migrate Down
+DROP INDEX idx_checkins_location;
+
+ALTER TABLE checkins
+    DROP COLUMN location_id;
+
+DROP TABLE location_readers;
+DROP TABLE locations;
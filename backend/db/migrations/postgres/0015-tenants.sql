@@ -0,0 +1,78 @@
+-- +migrate Up
+create table tenants
+(
+    id         bigserial    not null constraint tenants_pkey primary key,
+    created_at timestamp with time zone not null,
+    updated_at timestamp with time zone,
+    name       varchar(100) not null,
+    slug       varchar(63)  not null constraint tenants_slug_key unique
+);
+
+-- all existing data belongs to the default tenant, which gets the id 1
+INSERT INTO tenants (created_at, name, slug) VALUES (current_timestamp, 'default', 'default');
+
+ALTER TABLE users ADD COLUMN tenant_id bigint not null default 1 constraint fk_users_tenant references tenants;
+ALTER TABLE users DROP CONSTRAINT users_name_key;
+ALTER TABLE users DROP CONSTRAINT users_name_rfid;
+ALTER TABLE users DROP CONSTRAINT users_member_id;
+ALTER TABLE users ADD CONSTRAINT users_tenant_name_key unique (tenant_id, name);
+ALTER TABLE users ADD CONSTRAINT users_tenant_rfid_uid_key unique (tenant_id, rfid_uid);
+ALTER TABLE users ADD CONSTRAINT users_tenant_member_id_key unique (tenant_id, member_id);
+
+ALTER TABLE groups ADD COLUMN tenant_id bigint not null default 1 constraint fk_groups_tenant references tenants;
+ALTER TABLE groups DROP CONSTRAINT groups_name_key;
+ALTER TABLE groups ADD CONSTRAINT groups_tenant_name_key unique (tenant_id, name);
+
+ALTER TABLE locations ADD COLUMN tenant_id bigint not null default 1 constraint fk_locations_tenant references tenants;
+ALTER TABLE locations DROP CONSTRAINT locations_name_key;
+ALTER TABLE locations ADD CONSTRAINT locations_tenant_name_key unique (tenant_id, name);
+
+-- reader ids are only unique within a tenant, as they default to the host name of the reader
+ALTER TABLE location_readers
+    ADD COLUMN tenant_id bigint not null default 1 constraint fk_location_readers_tenant references tenants;
+ALTER TABLE location_readers DROP CONSTRAINT location_readers_pkey;
+ALTER TABLE location_readers ADD CONSTRAINT location_readers_pkey primary key (tenant_id, reader_id);
+
+ALTER TABLE checkins ADD COLUMN tenant_id bigint not null default 1 constraint fk_checkins_tenant references tenants;
+CREATE INDEX idx_checkins_tenant_date ON checkins(tenant_id, date);
+
+ALTER TABLE announcements
+    ADD COLUMN tenant_id bigint not null default 1 constraint fk_announcements_tenant references tenants;
+CREATE INDEX idx_announcements_tenant ON announcements(tenant_id);
+
+-- +migrate Down
+-- the data of other tenants cannot be kept, as it would violate the unique constraints
+DELETE FROM checkins WHERE tenant_id != 1;
+DELETE FROM announcements WHERE tenant_id != 1;
+DELETE FROM location_readers WHERE tenant_id != 1;
+DELETE FROM users WHERE tenant_id != 1;
+DELETE FROM groups WHERE tenant_id != 1;
+DELETE FROM locations WHERE tenant_id != 1;
+
+DROP INDEX idx_announcements_tenant;
+ALTER TABLE announcements DROP COLUMN tenant_id;
+
+DROP INDEX idx_checkins_tenant_date;
+ALTER TABLE checkins DROP COLUMN tenant_id;
+
+ALTER TABLE location_readers DROP CONSTRAINT location_readers_pkey;
+ALTER TABLE location_readers DROP COLUMN tenant_id;
+ALTER TABLE location_readers ADD CONSTRAINT location_readers_pkey primary key (reader_id);
+
+ALTER TABLE locations DROP CONSTRAINT locations_tenant_name_key;
+ALTER TABLE locations DROP COLUMN tenant_id;
+ALTER TABLE locations ADD CONSTRAINT locations_name_key unique (name);
+
+ALTER TABLE groups DROP CONSTRAINT groups_tenant_name_key;
+ALTER TABLE groups DROP COLUMN tenant_id;
+ALTER TABLE groups ADD CONSTRAINT groups_name_key unique (name);
+
+ALTER TABLE users DROP CONSTRAINT users_tenant_name_key;
+ALTER TABLE users DROP CONSTRAINT users_tenant_rfid_uid_key;
+ALTER TABLE users DROP CONSTRAINT users_tenant_member_id_key;
+ALTER TABLE users DROP COLUMN tenant_id;
+ALTER TABLE users ADD CONSTRAINT users_name_key unique (name);
+ALTER TABLE users ADD CONSTRAINT users_name_rfid unique (rfid_uid);
+ALTER TABLE users ADD CONSTRAINT users_member_id unique (member_id);
+
+DROP TABLE tenants;
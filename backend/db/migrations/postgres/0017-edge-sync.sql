@@ -0,0 +1,11 @@
+-- +migrate Up
+create table synced_checkins
+(
+    checkin_id bigint      not null constraint synced_checkins_pkey primary key,
+    user_id    bigint      not null,
+    synced_at  timestamp with time zone not null,
+    outcome    varchar(20) not null
+);
+
+-- +migrate Down
+DROP TABLE synced_checkins;
@@ -15,3 +15,6 @@ create table users
 INSERT INTO users (created_at, name, role) VALUES (current_timestamp, 'admin', 'ADMIN');
 
 CREATE INDEX idx_user_groups ON users(group_name);
+
+-- +migrate Down
+DROP TABLE users;
@@ -8,4 +8,7 @@ create table checkins
     UNIQUE             (date, user_id)
 );
 
-CREATE INDEX idx_checkin_date ON checkins(date);
\ No newline at end of file
+CREATE INDEX idx_checkin_date ON checkins(date);
+
+-- +migrate Down
+DROP TABLE checkins;
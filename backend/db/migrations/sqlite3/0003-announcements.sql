@@ -0,0 +1,18 @@
+-- +migrate Up
+create table announcements
+(
+    id          integer      not null constraint announcements_pkey primary key,
+    created_at  timestamp    not null,
+    updated_at  timestamp,
+    title       varchar(255) not null,
+    message     text,
+    valid_from  timestamp,
+    valid_until timestamp,
+    group_name  varchar(50),
+    user_id     bigint       constraint fk_announcements_user references users on delete cascade
+);
+
+CREATE INDEX idx_announcements_user ON announcements(user_id);
+
+-- +migrate Down
+DROP TABLE announcements;
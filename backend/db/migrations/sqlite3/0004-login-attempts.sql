@@ -0,0 +1,11 @@
+-- +migrate Up
+create table login_attempts
+(
+    attempt_key     varchar(255) not null constraint login_attempts_pkey primary key,
+    failures        integer      not null,
+    last_failure_at timestamp    not null,
+    locked_until    timestamp
+);
+
+-- +migrate Down
+DROP TABLE login_attempts;
@@ -0,0 +1,23 @@
+-- +migrate Up
+create table user_totp
+(
+    user_id        bigint       not null constraint user_totp_pkey primary key
+                                constraint fk_user_totp_user references users on delete cascade,
+    secret         varchar(64)  not null,
+    created_at     timestamp    not null,
+    confirmed_at   timestamp,
+    last_used_step bigint       not null default 0
+);
+
+create table recovery_codes
+(
+    id          integer      not null constraint recovery_codes_pkey primary key,
+    user_id     bigint       not null constraint fk_recovery_codes_user references users on delete cascade,
+    code_digest varchar(64)  not null,
+    used_at     timestamp,
+    UNIQUE      (user_id, code_digest)
+);
+
+-- +migrate Down
+DROP TABLE recovery_codes;
+DROP TABLE user_totp;
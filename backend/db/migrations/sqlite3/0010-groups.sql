@@ -0,0 +1,97 @@
+-- +migrate Up
+create table groups
+(
+    id          integer      not null constraint groups_pkey primary key,
+    created_at  timestamp    not null,
+    updated_at  timestamp,
+    name        varchar(50)  not null constraint groups_name_key unique,
+    description text,
+    colour      varchar(7),
+    capacity    integer,
+    schedule    varchar(255)
+);
+
+create table group_trainers
+(
+    group_id bigint not null constraint fk_group_trainers_group references groups on delete cascade,
+    user_id  bigint not null constraint fk_group_trainers_user references users on delete cascade,
+    constraint group_trainers_pkey primary key (group_id, user_id)
+);
+
+CREATE INDEX idx_group_trainers_user ON group_trainers(user_id);
+
+INSERT INTO groups (created_at, name)
+SELECT current_timestamp, name FROM (
+    SELECT group_name AS name FROM users WHERE group_name IS NOT NULL
+    UNION
+    SELECT group_name AS name FROM announcements WHERE group_name IS NOT NULL
+) AS group_names;
+
+ALTER TABLE users
+ADD COLUMN group_id bigint constraint fk_users_group references groups on delete set null;
+
+UPDATE users SET group_id = (SELECT id FROM groups WHERE groups.name = users.group_name);
+
+DROP INDEX idx_user_groups;
+
+ALTER TABLE users
+DROP COLUMN group_name;
+
+CREATE INDEX idx_users_group ON users(group_id);
+
+ALTER TABLE announcements
+ADD COLUMN group_id bigint constraint fk_announcements_group references groups on delete cascade;
+
+UPDATE announcements SET group_id = (SELECT id FROM groups WHERE groups.name = announcements.group_name);
+
+ALTER TABLE announcements
+DROP COLUMN group_name;
+
+-- +migrate Down
+-- sqlite cannot drop columns with foreign keys, so the tables are rebuilt
+create table announcements_groups
+(
+    id          integer      not null constraint announcements_pkey primary key,
+    created_at  timestamp    not null,
+    updated_at  timestamp,
+    title       varchar(255) not null,
+    message     text,
+    valid_from  timestamp,
+    valid_until timestamp,
+    group_name  varchar(50),
+    user_id     bigint       constraint fk_announcements_user references users on delete cascade
+);
+
+INSERT INTO announcements_groups (id, created_at, updated_at, title, message, valid_from, valid_until, group_name,
+                                  user_id)
+SELECT id, created_at, updated_at, title, message, valid_from, valid_until,
+       (SELECT name FROM groups WHERE groups.id = announcements.group_id), user_id FROM announcements;
+
+DROP TABLE announcements;
+ALTER TABLE announcements_groups RENAME TO announcements;
+CREATE INDEX idx_announcements_user ON announcements(user_id);
+
+create table users_groups
+(
+    id         integer      not null constraint users_pkey primary key,
+    created_at timestamp not null,
+    updated_at timestamp,
+    name       varchar(255) not null constraint users_name_key unique,
+    rfid_uid   varchar(255) constraint users_name_rfid unique,
+    member_id  varchar(255) constraint users_member_id unique,
+    password_digest varchar(255),
+    group_name varchar(50),
+    role       varchar(50) not null,
+    email      varchar(255)
+);
+
+INSERT INTO users_groups (id, created_at, updated_at, name, rfid_uid, member_id, password_digest, group_name, role, email)
+SELECT id, created_at, updated_at, name, rfid_uid, member_id, password_digest,
+       (SELECT name FROM groups WHERE groups.id = users.group_id), role, email FROM users;
+
+DROP TABLE users;
+ALTER TABLE users_groups RENAME TO users;
+CREATE INDEX idx_user_groups ON users(group_name);
+
+DROP TABLE group_trainers;
+DROP TABLE groups;
@@ -0,0 +1,43 @@
+-- +migrate Up
+create table locations
+(
+    id          integer      not null constraint locations_pkey primary key,
+    created_at  timestamp    not null,
+    updated_at  timestamp,
+    name        varchar(50)  not null constraint locations_name_key unique,
+    description text,
+    capacity    integer
+);
+
+create table location_readers
+(
+    reader_id   varchar(100) not null constraint location_readers_pkey primary key,
+    location_id bigint       not null constraint fk_location_readers_location references locations on delete cascade
+);
+
+CREATE INDEX idx_location_readers_location ON location_readers(location_id);
+
+ALTER TABLE checkins
+    ADD COLUMN location_id bigint constraint fk_checkins_location references locations on delete set null;
+
+CREATE INDEX idx_checkins_location ON checkins(location_id);
+
+-- +migrate Down
+-- sqlite cannot drop columns with foreign keys, so the table is rebuilt
+create table checkins_locations
+(
+    id                 bigserial not null constraint checkin_pkey primary key,
+    date               date not null,
+    timestamp          timestamp with time zone,
+    user_id            bigint    not null constraint fk_checkins_user references users,
+    UNIQUE             (date, user_id)
+);
+
+INSERT INTO checkins_locations (id, date, timestamp, user_id) SELECT id, date, timestamp, user_id FROM checkins;
+
+DROP TABLE checkins;
+ALTER TABLE checkins_locations RENAME TO checkins;
+CREATE INDEX idx_checkin_date ON checkins(date);
+
+DROP TABLE location_readers;
+DROP TABLE locations;
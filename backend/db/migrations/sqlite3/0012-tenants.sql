@@ -0,0 +1,216 @@
+-- +migrate Up
+create table tenants
+(
+    id         integer      not null constraint tenants_pkey primary key,
+    created_at timestamp    not null,
+    updated_at timestamp,
+    name       varchar(100) not null,
+    slug       varchar(63)  not null constraint tenants_slug_key unique
+);
+
+-- all existing data belongs to the default tenant, which gets the id 1
+INSERT INTO tenants (created_at, name, slug) VALUES (current_timestamp, 'default', 'default');
+
+-- sqlite cannot drop the unique constraints on the names, so the tables are rebuilt
+create table users_tenant
+(
+    id              integer      not null constraint users_pkey primary key,
+    created_at      timestamp    not null,
+    updated_at      timestamp,
+    tenant_id       bigint       not null default 1 constraint fk_users_tenant references tenants,
+    name            varchar(255) not null,
+    rfid_uid        varchar(255),
+    member_id       varchar(255),
+    password_digest varchar(255),
+    role            varchar(50)  not null,
+    email           varchar(255),
+    group_id        bigint       constraint fk_users_group references groups on delete set null,
+    constraint users_tenant_name_key unique (tenant_id, name),
+    constraint users_tenant_rfid_uid_key unique (tenant_id, rfid_uid),
+    constraint users_tenant_member_id_key unique (tenant_id, member_id)
+);
+
+INSERT INTO users_tenant (id, created_at, updated_at, name, rfid_uid, member_id, password_digest, role, email, group_id)
+SELECT id, created_at, updated_at, name, rfid_uid, member_id, password_digest, role, email, group_id FROM users;
+
+DROP TABLE users;
+ALTER TABLE users_tenant RENAME TO users;
+CREATE INDEX idx_users_group ON users(group_id);
+
+create table groups_tenant
+(
+    id          integer      not null constraint groups_pkey primary key,
+    created_at  timestamp    not null,
+    updated_at  timestamp,
+    tenant_id   bigint       not null default 1 constraint fk_groups_tenant references tenants,
+    name        varchar(50)  not null,
+    description text,
+    colour      varchar(7),
+    capacity    integer,
+    schedule    varchar(255),
+    constraint groups_tenant_name_key unique (tenant_id, name)
+);
+
+INSERT INTO groups_tenant (id, created_at, updated_at, name, description, colour, capacity, schedule)
+SELECT id, created_at, updated_at, name, description, colour, capacity, schedule FROM groups;
+
+DROP TABLE groups;
+ALTER TABLE groups_tenant RENAME TO groups;
+
+create table locations_tenant
+(
+    id          integer      not null constraint locations_pkey primary key,
+    created_at  timestamp    not null,
+    updated_at  timestamp,
+    tenant_id   bigint       not null default 1 constraint fk_locations_tenant references tenants,
+    name        varchar(50)  not null,
+    description text,
+    capacity    integer,
+    constraint locations_tenant_name_key unique (tenant_id, name)
+);
+
+INSERT INTO locations_tenant (id, created_at, updated_at, name, description, capacity)
+SELECT id, created_at, updated_at, name, description, capacity FROM locations;
+
+DROP TABLE locations;
+ALTER TABLE locations_tenant RENAME TO locations;
+
+-- reader ids are only unique within a tenant, as they default to the host name of the reader
+create table location_readers_tenant
+(
+    tenant_id   bigint       not null default 1 constraint fk_location_readers_tenant references tenants,
+    reader_id   varchar(100) not null,
+    location_id bigint       not null constraint fk_location_readers_location references locations on delete cascade,
+    constraint location_readers_pkey primary key (tenant_id, reader_id)
+);
+
+INSERT INTO location_readers_tenant (reader_id, location_id) SELECT reader_id, location_id FROM location_readers;
+
+DROP TABLE location_readers;
+ALTER TABLE location_readers_tenant RENAME TO location_readers;
+CREATE INDEX idx_location_readers_location ON location_readers(location_id);
+
+ALTER TABLE checkins ADD COLUMN tenant_id bigint not null default 1 constraint fk_checkins_tenant references tenants;
+CREATE INDEX idx_checkins_tenant_date ON checkins(tenant_id, date);
+
+ALTER TABLE announcements
+    ADD COLUMN tenant_id bigint not null default 1 constraint fk_announcements_tenant references tenants;
+CREATE INDEX idx_announcements_tenant ON announcements(tenant_id);
+
+-- +migrate Down
+-- the data of other tenants cannot be kept, as it would violate the unique constraints
+DELETE FROM checkins WHERE tenant_id != 1;
+DELETE FROM announcements WHERE tenant_id != 1;
+DELETE FROM location_readers WHERE tenant_id != 1;
+DELETE FROM users WHERE tenant_id != 1;
+DELETE FROM groups WHERE tenant_id != 1;
+DELETE FROM locations WHERE tenant_id != 1;
+
+-- sqlite cannot drop columns with foreign keys, so the tables are rebuilt
+create table announcements_default
+(
+    id          integer      not null constraint announcements_pkey primary key,
+    created_at  timestamp    not null,
+    updated_at  timestamp,
+    title       varchar(255) not null,
+    message     text,
+    valid_from  timestamp,
+    valid_until timestamp,
+    user_id     bigint       constraint fk_announcements_user references users on delete cascade,
+    group_id    bigint       constraint fk_announcements_group references groups on delete cascade
+);
+
+INSERT INTO announcements_default (id, created_at, updated_at, title, message, valid_from, valid_until, user_id,
+                                   group_id)
+SELECT id, created_at, updated_at, title, message, valid_from, valid_until, user_id, group_id FROM announcements;
+
+DROP TABLE announcements;
+ALTER TABLE announcements_default RENAME TO announcements;
+CREATE INDEX idx_announcements_user ON announcements(user_id);
+
+create table checkins_default
+(
+    id                 bigserial not null constraint checkin_pkey primary key,
+    date               date not null,
+    timestamp          timestamp with time zone,
+    user_id            bigint    not null constraint fk_checkins_user references users,
+    location_id        bigint    constraint fk_checkins_location references locations on delete set null,
+    UNIQUE             (date, user_id)
+);
+
+INSERT INTO checkins_default (id, date, timestamp, user_id, location_id)
+SELECT id, date, timestamp, user_id, location_id FROM checkins;
+
+DROP TABLE checkins;
+ALTER TABLE checkins_default RENAME TO checkins;
+CREATE INDEX idx_checkin_date ON checkins(date);
+CREATE INDEX idx_checkins_location ON checkins(location_id);
+
+create table location_readers_default
+(
+    reader_id   varchar(100) not null constraint location_readers_pkey primary key,
+    location_id bigint       not null constraint fk_location_readers_location references locations on delete cascade
+);
+
+INSERT INTO location_readers_default (reader_id, location_id) SELECT reader_id, location_id FROM location_readers;
+
+DROP TABLE location_readers;
+ALTER TABLE location_readers_default RENAME TO location_readers;
+CREATE INDEX idx_location_readers_location ON location_readers(location_id);
+
+create table locations_default
+(
+    id          integer      not null constraint locations_pkey primary key,
+    created_at  timestamp    not null,
+    updated_at  timestamp,
+    name        varchar(50)  not null constraint locations_name_key unique,
+    description text,
+    capacity    integer
+);
+
+INSERT INTO locations_default (id, created_at, updated_at, name, description, capacity)
+SELECT id, created_at, updated_at, name, description, capacity FROM locations;
+
+DROP TABLE locations;
+ALTER TABLE locations_default RENAME TO locations;
+
+create table groups_default
+(
+    id          integer      not null constraint groups_pkey primary key,
+    created_at  timestamp    not null,
+    updated_at  timestamp,
+    name        varchar(50)  not null constraint groups_name_key unique,
+    description text,
+    colour      varchar(7),
+    capacity    integer,
+    schedule    varchar(255)
+);
+
+INSERT INTO groups_default (id, created_at, updated_at, name, description, colour, capacity, schedule)
+SELECT id, created_at, updated_at, name, description, colour, capacity, schedule FROM groups;
+
+DROP TABLE groups;
+ALTER TABLE groups_default RENAME TO groups;
+
+create table users_default
+(
+    id              integer      not null constraint users_pkey primary key,
+    created_at      timestamp    not null,
+    updated_at      timestamp,
+    name            varchar(255) not null constraint users_name_key unique,
+    rfid_uid        varchar(255) constraint users_name_rfid unique,
+    member_id       varchar(255) constraint users_member_id unique,
+    password_digest varchar(255),
+    role            varchar(50)  not null,
+    email           varchar(255),
+    group_id        bigint       constraint fk_users_group references groups on delete set null
+);
+
+INSERT INTO users_default (id, created_at, updated_at, name, rfid_uid, member_id, password_digest, role, email, group_id)
+SELECT id, created_at, updated_at, name, rfid_uid, member_id, password_digest, role, email, group_id FROM users;
+
+DROP TABLE users;
+ALTER TABLE users_default RENAME TO users;
+CREATE INDEX idx_users_group ON users(group_id);
+
+DROP TABLE tenants;
@@ -0,0 +1,46 @@
+-- +migrate Up
+-- bigserial is no alias of the rowid in sqlite, so ids were never assigned and timestamps with time zone were not
+-- parsed by the driver, the table is rebuilt with the types sqlite understands
+create table checkins_rowid
+(
+    id          integer   not null constraint checkin_pkey primary key,
+    date        date      not null,
+    timestamp   timestamp,
+    user_id     bigint    not null constraint fk_checkins_user references users,
+    location_id bigint    constraint fk_checkins_location references locations on delete set null,
+    tenant_id   bigint    not null default 1 constraint fk_checkins_tenant references tenants,
+    UNIQUE      (date, user_id)
+);
+
+INSERT INTO checkins_rowid (id, date, timestamp, user_id, location_id, tenant_id)
+SELECT id, date, timestamp, user_id, location_id, tenant_id FROM checkins;
+
+DROP TABLE checkins;
+ALTER TABLE checkins_rowid RENAME TO checkins;
+
+CREATE INDEX idx_checkin_date ON checkins(date);
+CREATE INDEX idx_checkins_location ON checkins(location_id);
+CREATE INDEX idx_checkins_tenant_date ON checkins(tenant_id, date);
+
+-- +migrate Down
+-- restores the previous definition of the table
+create table checkins_bigserial
+(
+    id                 bigserial not null constraint checkin_pkey primary key,
+    date               date not null,
+    timestamp          timestamp with time zone,
+    user_id            bigint    not null constraint fk_checkins_user references users,
+    location_id        bigint    constraint fk_checkins_location references locations on delete set null,
+    tenant_id          bigint    not null default 1 constraint fk_checkins_tenant references tenants,
+    UNIQUE             (date, user_id)
+);
+
+INSERT INTO checkins_bigserial (id, date, timestamp, user_id, location_id, tenant_id)
+SELECT id, date, timestamp, user_id, location_id, tenant_id FROM checkins;
+
+DROP TABLE checkins;
+ALTER TABLE checkins_bigserial RENAME TO checkins;
+
+CREATE INDEX idx_checkin_date ON checkins(date);
+CREATE INDEX idx_checkins_location ON checkins(location_id);
+CREATE INDEX idx_checkins_tenant_date ON checkins(tenant_id, date);
@@ -0,0 +1,15 @@
+-- +migrate Up
+create table audit_log
+(
+    id         integer     not null constraint audit_log_pkey primary key,
+    tenant_id  bigint      not null default 1 constraint fk_audit_log_tenant references tenants,
+    created_at timestamp   not null,
+    actor_id   bigint,
+    action     varchar(50) not null,
+    subject_id bigint
+);
+
+CREATE INDEX idx_audit_log_tenant ON audit_log(tenant_id, created_at);
+
+-- +migrate Down
+DROP TABLE audit_log;
@@ -0,0 +1,19 @@
+-- +migrate Up
+-- the foreign keys were not enforced before, so that deleting users, groups and locations left the rows referencing
+-- them behind. They are removed like the actions of the foreign keys would have.
+DELETE FROM announcements WHERE user_id IS NOT NULL AND user_id NOT IN (SELECT id FROM users);
+DELETE FROM announcements WHERE group_id IS NOT NULL AND group_id NOT IN (SELECT id FROM groups);
+DELETE FROM user_totp WHERE user_id NOT IN (SELECT id FROM users);
+DELETE FROM recovery_codes WHERE user_id NOT IN (SELECT id FROM users);
+DELETE FROM user_identities WHERE user_id NOT IN (SELECT id FROM users);
+DELETE FROM password_tokens WHERE user_id NOT IN (SELECT id FROM users);
+DELETE FROM lost_cards WHERE user_id NOT IN (SELECT id FROM users);
+DELETE FROM group_trainers WHERE user_id NOT IN (SELECT id FROM users) OR group_id NOT IN (SELECT id FROM groups);
+UPDATE users SET group_id = NULL WHERE group_id IS NOT NULL AND group_id NOT IN (SELECT id FROM groups);
+DELETE FROM location_readers WHERE location_id NOT IN (SELECT id FROM locations);
+UPDATE checkins SET location_id = NULL WHERE location_id IS NOT NULL AND location_id NOT IN (SELECT id FROM locations);
+DELETE FROM checkins WHERE user_id NOT IN (SELECT id FROM users);
+
+-- +migrate Down
+-- the removed rows referenced rows which no longer existed
+SELECT 1;
@@ -0,0 +1,42 @@
+package announcement
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// Announcement is a notice shown on the check-in kiosk.
+//
+// Announcements with a UserID are personal notes which are only shown when that user checks in.
+// Announcements with a Group are only relevant for members of that group.
+type Announcement struct {
+	ID         int64       `db:"id"          json:"id"`
+	TenantID   int64       `db:"tenant_id"   json:"-"`
+	CreatedAt  time.Time   `db:"created_at"  json:"created_at"`
+	UpdatedAt  null.Time   `db:"updated_at"  json:"updated_at"`
+	Title      string      `db:"title"       json:"title"`
+	Message    null.String `db:"message"     json:"message"`
+	ValidFrom  null.Time   `db:"valid_from"  json:"valid_from"`
+	ValidUntil null.Time   `db:"valid_until" json:"valid_until"`
+	GroupID    null.Int    `db:"group_id"    json:"group_id"`
+	Group      null.String `db:"group_name"  json:"group"` // name of the group, read only
+	UserID     null.Int    `db:"user_id"     json:"user_id"`
+}
+
+// ActiveAt returns true if the given time lies within the validity window of the announcement.
+func (a *Announcement) ActiveAt(t time.Time) bool {
+	if a.ValidFrom.Valid && t.Before(a.ValidFrom.Time) {
+		return false
+	}
+	return !a.ExpiredAt(t)
+}
+
+// ExpiredAt returns true if the validity window of the announcement ended before the given time.
+func (a *Announcement) ExpiredAt(t time.Time) bool {
+	return a.ValidUntil.Valid && !t.Before(a.ValidUntil.Time)
+}
+
+type WebsocketMessage struct {
+	Announcements []Announcement `json:"announcements"`
+}
@@ -0,0 +1,161 @@
+package announcement
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/group"
+	"github.com/d-rk/checkin-system/pkg/tenant"
+
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/guregu/null.v4"
+)
+
+type Repository interface {
+	ListAnnouncements(ctx context.Context, scope group.Scope) ([]Announcement, error)
+	ListPublicAnnouncements(ctx context.Context) ([]Announcement, error)
+	ListUserAnnouncements(ctx context.Context, userID int64, groupID null.Int) ([]Announcement, error)
+	GetAnnouncementByID(ctx context.Context, id int64) (*Announcement, error)
+	SaveAnnouncement(ctx context.Context, announcement *Announcement) (*Announcement, error)
+	UpdateAnnouncement(ctx context.Context, announcement *Announcement) (*Announcement, error)
+	DeleteAnnouncement(ctx context.Context, id int64) error
+}
+
+// selectAnnouncements selects announcements along with the name of their group.
+const selectAnnouncements = `SELECT announcements.*, groups.name AS group_name FROM announcements
+			LEFT JOIN groups ON groups.id = announcements.group_id`
+
+// scopeCondition restricts announcements to the groups of a trainer and to the personal notes of their members. The
+// TrainerID of the scope is passed as $1.
+const scopeCondition = `(CAST($1 AS bigint) = 0
+			OR announcements.group_id IN (SELECT group_id FROM group_trainers WHERE user_id = CAST($1 AS bigint))
+			OR announcements.user_id IN (SELECT users.id FROM users WHERE ` + group.ScopeCondition + `))`
+
+type repository struct {
+	db *database.DB
+}
+
+func NewRepo(db *sqlx.DB) Repository {
+	return &repository{database.NewDB(db)}
+}
+
+func (r *repository) ListAnnouncements(ctx context.Context, scope group.Scope) ([]Announcement, error) {
+
+	announcements := make([]Announcement, 0)
+
+	if err := r.db.SelectContext(ctx, &announcements, selectAnnouncements+" WHERE "+scopeCondition+`
+			AND announcements.tenant_id = $2 ORDER BY announcements.created_at DESC`,
+		scope.TrainerID, tenant.ID(ctx)); err != nil {
+		return nil, fmt.Errorf("failed to list announcements: %w", err)
+	}
+
+	return announcements, nil
+}
+
+func (r *repository) ListPublicAnnouncements(ctx context.Context) ([]Announcement, error) {
+
+	announcements := make([]Announcement, 0)
+
+	if err := r.db.SelectContext(ctx, &announcements, selectAnnouncements+`
+			WHERE announcements.user_id IS NULL AND announcements.tenant_id = $1
+			ORDER BY announcements.created_at DESC`, tenant.ID(ctx)); err != nil {
+		return nil, fmt.Errorf("failed to list announcements: %w", err)
+	}
+
+	return announcements, nil
+}
+
+func (r *repository) ListUserAnnouncements(ctx context.Context, userID int64,
+	groupID null.Int) ([]Announcement, error) {
+
+	announcements := make([]Announcement, 0)
+
+	if err := r.db.SelectContext(ctx, &announcements, selectAnnouncements+`
+			WHERE (announcements.user_id = $1 OR (announcements.user_id IS NULL AND announcements.group_id = $2))
+			AND announcements.tenant_id = $3
+			ORDER BY announcements.created_at DESC`, userID, groupID, tenant.ID(ctx)); err != nil {
+		return nil, fmt.Errorf("failed to list announcements of user: %w", err)
+	}
+
+	return announcements, nil
+}
+
+func (r *repository) GetAnnouncementByID(ctx context.Context, id int64) (*Announcement, error) {
+
+	announcement := Announcement{}
+
+	if err := r.db.GetContext(ctx, &announcement,
+		selectAnnouncements+" WHERE announcements.id = $1 AND announcements.tenant_id = $2", id,
+		tenant.ID(ctx)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, app.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &announcement, nil
+}
+
+func (r *repository) SaveAnnouncement(ctx context.Context, announcement *Announcement) (*Announcement, error) {
+
+	announcement.CreatedAt = time.Now()
+	announcement.TenantID = tenant.ID(ctx)
+
+	insertStatement, err := r.db.PrepareNamedContext(ctx, `INSERT INTO announcements
+			(created_at, tenant_id, title, message, valid_from, valid_until, group_id, user_id) VALUES
+			(:created_at, :tenant_id, :title, :message, :valid_from, :valid_until, :group_id, :user_id) RETURNING id`)
+	if err != nil {
+		return nil, err
+	}
+	defer insertStatement.Close()
+
+	row := insertStatement.QueryRowContext(ctx, announcement)
+
+	if row.Err() != nil {
+		return nil, row.Err()
+	}
+
+	if err = row.Scan(&announcement.ID); err != nil {
+		return nil, err
+	}
+
+	return announcement, nil
+}
+
+func (r *repository) UpdateAnnouncement(ctx context.Context, announcement *Announcement) (*Announcement, error) {
+
+	announcement.UpdatedAt = null.TimeFrom(time.Now())
+	announcement.TenantID = tenant.ID(ctx)
+
+	updateStatement, err := r.db.PrepareNamedContext(ctx, `UPDATE announcements SET
+			(updated_at, title, message, valid_from, valid_until, group_id, user_id) =
+			(:updated_at, :title, :message, :valid_from, :valid_until, :group_id, :user_id)
+			WHERE id = :id AND tenant_id = :tenant_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer updateStatement.Close()
+
+	if _, err = updateStatement.ExecContext(ctx, announcement); err != nil {
+		return nil, err
+	}
+
+	return announcement, nil
+}
+
+func (r *repository) DeleteAnnouncement(ctx context.Context, id int64) error {
+
+	deleteStatement, err := r.db.PreparexContext(ctx, `DELETE FROM announcements WHERE id = $1 AND tenant_id = $2`)
+	if err != nil {
+		return err
+	}
+	defer deleteStatement.Close()
+
+	_, err = deleteStatement.ExecContext(ctx, id, tenant.ID(ctx))
+	return err
+}
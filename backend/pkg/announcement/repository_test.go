@@ -0,0 +1,64 @@
+//go:build integration
+
+package announcement
+
+import (
+	"context"
+	"testing"
+
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/group"
+	"github.com/d-rk/checkin-system/pkg/user"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestListUserAnnouncements_ReturnsPersonalAndGroupAnnouncements(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		users := user.NewRepo(db)
+		alice, err := users.SaveUser(ctx, &user.User{Name: "Alice", Role: user.RoleUser})
+		require.NoError(t, err)
+		bob, err := users.SaveUser(ctx, &user.User{Name: "Bob", Role: user.RoleUser})
+		require.NoError(t, err)
+
+		groups := group.NewRepo(db)
+		climbing, err := groups.SaveGroup(ctx, &group.Group{Name: "Climbing"})
+		require.NoError(t, err)
+		rowing, err := groups.SaveGroup(ctx, &group.Group{Name: "Rowing"})
+		require.NoError(t, err)
+
+		repo := NewRepo(db)
+		for _, a := range []*Announcement{
+			{Title: "for alice", UserID: null.IntFrom(alice.ID)},
+			{Title: "for bob", UserID: null.IntFrom(bob.ID)},
+			{Title: "for climbing", GroupID: null.IntFrom(climbing.ID)},
+			{Title: "for rowing", GroupID: null.IntFrom(rowing.ID)},
+			{Title: "for everyone"},
+		} {
+			_, err = repo.SaveAnnouncement(ctx, a)
+			require.NoError(t, err)
+		}
+
+		announcements, err := repo.ListUserAnnouncements(ctx, alice.ID, null.IntFrom(climbing.ID))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"for alice", "for climbing"}, titles(announcements))
+
+		announcements, err = repo.ListUserAnnouncements(ctx, bob.ID, null.Int{})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"for bob"}, titles(announcements))
+	})
+}
+
+func titles(announcements []Announcement) []string {
+	result := make([]string, 0, len(announcements))
+	for _, a := range announcements {
+		result = append(result, a.Title)
+	}
+	return result
+}
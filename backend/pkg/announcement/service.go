@@ -0,0 +1,177 @@
+package announcement
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/group"
+	"github.com/d-rk/checkin-system/pkg/tenant"
+	"github.com/d-rk/checkin-system/pkg/user"
+	"github.com/d-rk/checkin-system/pkg/websocket"
+)
+
+type Service interface {
+	// ListAnnouncements returns the announcements within the scope, trainers only get the ones addressed to their
+	// groups or to their members.
+	ListAnnouncements(ctx context.Context, scope group.Scope) ([]Announcement, error)
+	ListCurrentAnnouncements(ctx context.Context, now time.Time) ([]Announcement, error)
+	ListUserNotes(ctx context.Context, user *user.User, now time.Time) ([]Announcement, error)
+	GetAnnouncementByID(ctx context.Context, id int64) (*Announcement, error)
+	CreateAnnouncement(ctx context.Context, announcement *Announcement) (*Announcement, error)
+	UpdateAnnouncement(ctx context.Context, announcement *Announcement) (*Announcement, error)
+	DeleteAnnouncement(ctx context.Context, id int64) error
+}
+
+type service struct {
+	repo      Repository
+	websocket *websocket.Server
+}
+
+func NewService(repo Repository, websocket *websocket.Server) Service {
+
+	s := &service{repo, websocket}
+	websocket.OnConnect(s.publishClient)
+
+	return s
+}
+
+func (s *service) ListAnnouncements(ctx context.Context, scope group.Scope) ([]Announcement, error) {
+	return s.repo.ListAnnouncements(ctx, scope)
+}
+
+// ListCurrentAnnouncements returns all kiosk announcements which are not yet expired.
+// Announcements which only become valid in the future are included, so kiosks can show them on time.
+func (s *service) ListCurrentAnnouncements(ctx context.Context, now time.Time) ([]Announcement, error) {
+
+	announcements, err := s.repo.ListPublicAnnouncements(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	current := make([]Announcement, 0, len(announcements))
+	for _, a := range announcements {
+		if !a.ExpiredAt(now) {
+			current = append(current, a)
+		}
+	}
+
+	return current, nil
+}
+
+// ListUserNotes returns the active personal notes of a user along with the active announcements of the user's group.
+func (s *service) ListUserNotes(ctx context.Context, user *user.User, now time.Time) ([]Announcement, error) {
+
+	announcements, err := s.repo.ListUserAnnouncements(ctx, user.ID, user.GroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	notes := make([]Announcement, 0, len(announcements))
+	for _, a := range announcements {
+		if a.ActiveAt(now) {
+			notes = append(notes, a)
+		}
+	}
+
+	return notes, nil
+}
+
+func (s *service) GetAnnouncementByID(ctx context.Context, id int64) (*Announcement, error) {
+	return s.repo.GetAnnouncementByID(ctx, id)
+}
+
+func (s *service) CreateAnnouncement(ctx context.Context, announcement *Announcement) (*Announcement, error) {
+
+	if err := validate(announcement); err != nil {
+		return nil, err
+	}
+
+	saved, err := s.repo.SaveAnnouncement(ctx, announcement)
+	if err != nil {
+		return nil, err
+	}
+
+	s.publish(ctx)
+
+	return saved, nil
+}
+
+func (s *service) UpdateAnnouncement(ctx context.Context, announcement *Announcement) (*Announcement, error) {
+
+	existing, err := s.repo.GetAnnouncementByID(ctx, announcement.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = validate(announcement); err != nil {
+		return nil, err
+	}
+
+	announcement.CreatedAt = existing.CreatedAt
+
+	updated, err := s.repo.UpdateAnnouncement(ctx, announcement)
+	if err != nil {
+		return nil, err
+	}
+
+	s.publish(ctx)
+
+	return updated, nil
+}
+
+func (s *service) DeleteAnnouncement(ctx context.Context, id int64) error {
+
+	if _, err := s.repo.GetAnnouncementByID(ctx, id); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteAnnouncement(ctx, id); err != nil {
+		return err
+	}
+
+	s.publish(ctx)
+
+	return nil
+}
+
+// publish sends the current announcements to all connected kiosks of the tenant.
+func (s *service) publish(ctx context.Context) {
+
+	announcements, err := s.ListCurrentAnnouncements(ctx, time.Now())
+	if err != nil {
+		slog.WarnContext(ctx, "failed to load announcements", "error", err)
+		return
+	}
+
+	_ = s.websocket.Publish(ctx, WebsocketMessage{Announcements: announcements})
+}
+
+func (s *service) publishClient(client *websocket.Client) {
+
+	ctx := tenant.WithID(context.Background(), client.TenantID)
+
+	announcements, err := s.ListCurrentAnnouncements(ctx, time.Now())
+	if err != nil {
+		slog.WarnContext(ctx, "failed to load announcements", "error", err)
+		return
+	}
+
+	_ = s.websocket.PublishClient(client, WebsocketMessage{Announcements: announcements})
+}
+
+func validate(announcement *Announcement) error {
+
+	if announcement.Title == "" {
+		return fmt.Errorf("empty title provided: %w", app.ErrInvalid)
+	}
+
+	if announcement.ValidFrom.Valid && announcement.ValidUntil.Valid &&
+		!announcement.ValidFrom.Time.Before(announcement.ValidUntil.Time) {
+		return fmt.Errorf("valid_from must be before valid_until: %w", app.ErrInvalid)
+	}
+
+	return nil
+}
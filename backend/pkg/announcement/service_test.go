@@ -0,0 +1,204 @@
+//go:build integration
+
+package announcement
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/group"
+	"github.com/d-rk/checkin-system/pkg/user"
+	"github.com/d-rk/checkin-system/pkg/websocket"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func newTestService(db *sqlx.DB) Service {
+	return NewService(NewRepo(db), &websocket.Server{})
+}
+
+func TestCreateAnnouncement(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s := newTestService(db)
+		now := time.Now()
+
+		_, err := s.CreateAnnouncement(ctx, &Announcement{})
+		require.ErrorIs(t, err, app.ErrInvalid, "title is required")
+
+		_, err = s.CreateAnnouncement(ctx, &Announcement{Title: "Hall closed", ValidFrom: null.TimeFrom(now),
+			ValidUntil: null.TimeFrom(now.Add(-time.Hour))})
+		require.ErrorIs(t, err, app.ErrInvalid, "window ends before it starts")
+
+		created, err := s.CreateAnnouncement(ctx, &Announcement{Title: "Hall closed",
+			Message: null.StringFrom("on Friday"), ValidUntil: null.TimeFrom(now.Add(time.Hour))})
+		require.NoError(t, err)
+
+		a, err := s.GetAnnouncementByID(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Hall closed", a.Title)
+		assert.Equal(t, "on Friday", a.Message.String)
+	})
+}
+
+func TestUpdateAnnouncement(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s := newTestService(db)
+
+		created, err := s.CreateAnnouncement(ctx, &Announcement{Title: "Hall closed"})
+		require.NoError(t, err)
+
+		_, err = s.UpdateAnnouncement(ctx, &Announcement{ID: created.ID})
+		require.ErrorIs(t, err, app.ErrInvalid)
+
+		_, err = s.UpdateAnnouncement(ctx, &Announcement{ID: created.ID + 1, Title: "Hall open"})
+		require.ErrorIs(t, err, app.ErrNotFound)
+
+		_, err = s.UpdateAnnouncement(ctx, &Announcement{ID: created.ID, Title: "Hall open"})
+		require.NoError(t, err)
+
+		a, err := s.GetAnnouncementByID(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Hall open", a.Title)
+		assert.True(t, a.UpdatedAt.Valid)
+		assert.WithinDuration(t, created.CreatedAt, a.CreatedAt, time.Second, "creation time is kept")
+	})
+}
+
+func TestDeleteAnnouncement(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s := newTestService(db)
+
+		created, err := s.CreateAnnouncement(ctx, &Announcement{Title: "Hall closed"})
+		require.NoError(t, err)
+
+		require.ErrorIs(t, s.DeleteAnnouncement(ctx, created.ID+1), app.ErrNotFound)
+
+		require.NoError(t, s.DeleteAnnouncement(ctx, created.ID))
+		_, err = s.GetAnnouncementByID(ctx, created.ID)
+		require.ErrorIs(t, err, app.ErrNotFound)
+
+		require.ErrorIs(t, s.DeleteAnnouncement(ctx, created.ID), app.ErrNotFound)
+	})
+}
+
+func TestListCurrentAnnouncements_ExcludesExpiredAndPersonalOnes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s := newTestService(db)
+		now := time.Now()
+
+		alice, err := user.NewRepo(db).SaveUser(ctx, &user.User{Name: "Alice", Role: user.RoleUser})
+		require.NoError(t, err)
+
+		for _, a := range []*Announcement{
+			{Title: "expired", ValidUntil: null.TimeFrom(now.Add(-time.Hour))},
+			{Title: "current", ValidUntil: null.TimeFrom(now.Add(time.Hour))},
+			{Title: "upcoming", ValidFrom: null.TimeFrom(now.Add(time.Hour))},
+			{Title: "for alice", UserID: null.IntFrom(alice.ID)},
+		} {
+			_, err = s.CreateAnnouncement(ctx, a)
+			require.NoError(t, err)
+		}
+
+		announcements, err := s.ListCurrentAnnouncements(ctx, now)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"current", "upcoming"}, titles(announcements))
+	})
+}
+
+func TestListUserNotes_OnlyReturnsActiveOnes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s := newTestService(db)
+		now := time.Now()
+
+		climbing, err := group.NewRepo(db).SaveGroup(ctx, &group.Group{Name: "Climbing"})
+		require.NoError(t, err)
+		alice, err := user.NewRepo(db).SaveUser(ctx, &user.User{Name: "Alice", Role: user.RoleUser,
+			GroupID: null.IntFrom(climbing.ID)})
+		require.NoError(t, err)
+
+		for _, a := range []*Announcement{
+			{Title: "see the treasurer", UserID: null.IntFrom(alice.ID)},
+			{Title: "expired", UserID: null.IntFrom(alice.ID), ValidUntil: null.TimeFrom(now.Add(-time.Hour))},
+			{Title: "upcoming", UserID: null.IntFrom(alice.ID), ValidFrom: null.TimeFrom(now.Add(time.Hour))},
+			{Title: "climbing trip", GroupID: null.IntFrom(climbing.ID)},
+			{Title: "for everyone"},
+		} {
+			_, err = s.CreateAnnouncement(ctx, a)
+			require.NoError(t, err)
+		}
+
+		notes, err := s.ListUserNotes(ctx, alice, now)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"see the treasurer", "climbing trip"}, titles(notes))
+	})
+}
+
+func TestListAnnouncements_TrainerScope(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s := newTestService(db)
+
+		groups := group.NewRepo(db)
+		climbing, err := groups.SaveGroup(ctx, &group.Group{Name: "Climbing"})
+		require.NoError(t, err)
+		rowing, err := groups.SaveGroup(ctx, &group.Group{Name: "Rowing"})
+		require.NoError(t, err)
+
+		users := user.NewRepo(db)
+		trainer, err := users.SaveUser(ctx, &user.User{Name: "Trainer", Role: user.RoleTrainer})
+		require.NoError(t, err)
+		require.NoError(t, groups.SetTrainerIDs(ctx, climbing.ID, []int64{trainer.ID}))
+		alice, err := users.SaveUser(ctx, &user.User{Name: "Alice", Role: user.RoleUser,
+			GroupID: null.IntFrom(climbing.ID)})
+		require.NoError(t, err)
+		bob, err := users.SaveUser(ctx, &user.User{Name: "Bob", Role: user.RoleUser, GroupID: null.IntFrom(rowing.ID)})
+		require.NoError(t, err)
+
+		for _, a := range []*Announcement{
+			{Title: "for alice", UserID: null.IntFrom(alice.ID)},
+			{Title: "for bob", UserID: null.IntFrom(bob.ID)},
+			{Title: "for climbing", GroupID: null.IntFrom(climbing.ID)},
+			{Title: "for rowing", GroupID: null.IntFrom(rowing.ID)},
+			{Title: "for everyone"},
+		} {
+			_, err = s.CreateAnnouncement(ctx, a)
+			require.NoError(t, err)
+		}
+
+		announcements, err := s.ListAnnouncements(ctx, group.TrainerScope(trainer.ID))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"for alice", "for climbing"}, titles(announcements))
+
+		announcements, err = s.ListAnnouncements(ctx, group.Scope{})
+		require.NoError(t, err)
+		assert.Len(t, announcements, 5)
+	})
+}
@@ -1,41 +1,90 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/d-rk/checkin-system/pkg/version"
 	"github.com/d-rk/checkin-system/pkg/wifi"
 
+	"github.com/d-rk/checkin-system/pkg/announcement"
 	"github.com/d-rk/checkin-system/pkg/app"
 	"github.com/d-rk/checkin-system/pkg/auth"
+	"github.com/d-rk/checkin-system/pkg/backup"
 	"github.com/d-rk/checkin-system/pkg/checkin"
 	"github.com/d-rk/checkin-system/pkg/clock"
+	"github.com/d-rk/checkin-system/pkg/edgesync"
+	"github.com/d-rk/checkin-system/pkg/group"
+	"github.com/d-rk/checkin-system/pkg/health"
+	"github.com/d-rk/checkin-system/pkg/location"
+	"github.com/d-rk/checkin-system/pkg/lockout"
+	"github.com/d-rk/checkin-system/pkg/oidc"
+	"github.com/d-rk/checkin-system/pkg/passwordreset"
+	"github.com/d-rk/checkin-system/pkg/selfservice"
+	"github.com/d-rk/checkin-system/pkg/tenant"
+	"github.com/d-rk/checkin-system/pkg/twofactor"
 	"github.com/d-rk/checkin-system/pkg/user"
 	"github.com/flytam/filenamify"
 	"github.com/gocarina/gocsv"
+	"gopkg.in/guregu/null.v4"
 )
 
 const contentTypeJSON = "application/json"
 const contentTypeCSV = "application/csv"
 
 type apiHandler struct {
-	userService    user.Service
-	checkinService checkin.Service
-	clockService   clock.Service
-	wifiService    wifi.Service
+	authService          auth.Service
+	userService          user.Service
+	lockoutService       lockout.Service
+	twoFactorService     twofactor.Service
+	oidcService          oidc.Service
+	passwordResetService passwordreset.Service
+	selfService          selfservice.Service
+	groupService         group.Service
+	locationService      location.Service
+	tenantService        tenant.Service
+	checkinService       checkin.Service
+	announcementService  announcement.Service
+	clockService         clock.Service
+	wifiService          wifi.Service
+	backupService        backup.Service
+	syncService          edgesync.Service
+	healthService        health.Service
 }
 
-func NewHandler(userService user.Service, checkinService checkin.Service, clockService clock.Service,
-	wifiService wifi.Service) ServerInterface {
+func NewHandler(authService auth.Service, userService user.Service, lockoutService lockout.Service,
+	twoFactorService twofactor.Service, oidcService oidc.Service, passwordResetService passwordreset.Service,
+	selfService selfservice.Service, groupService group.Service, locationService location.Service,
+	tenantService tenant.Service, checkinService checkin.Service, announcementService announcement.Service,
+	clockService clock.Service, wifiService wifi.Service, backupService backup.Service,
+	syncService edgesync.Service, healthService health.Service) ServerInterface {
 	return &apiHandler{
-		userService:    userService,
-		checkinService: checkinService,
-		clockService:   clockService,
-		wifiService:    wifiService,
+		authService:          authService,
+		userService:          userService,
+		lockoutService:       lockoutService,
+		twoFactorService:     twoFactorService,
+		oidcService:          oidcService,
+		passwordResetService: passwordResetService,
+		selfService:          selfService,
+		groupService:         groupService,
+		locationService:      locationService,
+		tenantService:        tenantService,
+		checkinService:       checkinService,
+		announcementService:  announcementService,
+		clockService:         clockService,
+		wifiService:          wifiService,
+		backupService:        backupService,
+		syncService:          syncService,
+		healthService:        healthService,
 	}
 }
 
@@ -48,23 +97,294 @@ func (h *apiHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := clientIP(r)
+
+	done, err := h.lockoutService.Check(r.Context(), credentials.Username, ip)
+	if err != nil {
+		lockoutError(w, r, err)
+		return
+	}
+	defer done()
+
 	u, err := h.userService.GetUserByNameAndPassword(r.Context(), credentials.Username, credentials.Password)
 	if err != nil && errors.Is(err, app.ErrNotFound) {
+		if recordErr := h.recordLoginFailure(r.Context(), credentials.Username, ip); recordErr != nil {
+			handlerError(w, r, recordErr)
+			return
+		}
+		handlerError(w, r, ErrInvalidCredentials.Wrap(err))
+		return
+	} else if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	h.completeLogin(w, r, u)
+}
+
+// completeLogin writes the bearer token for a user whose first factor was verified, or the challenge for the second
+// factor if the user needs one.
+func (h *apiHandler) completeLogin(w http.ResponseWriter, r *http.Request, u *user.User) {
+
+	totpRequired, err := h.twoFactorService.Required(r.Context(), u)
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	if totpRequired {
+		h.writeTotpChallenge(w, r, u)
+		return
+	}
+
+	if err = h.lockoutService.RecordSuccess(r.Context(), u.Name); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	bearerToken, err := generateBearerToken(r.Context(), h.authService, u.ID)
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, bearerToken)
+}
+
+// recordLoginFailure records a failed login. The failures of unknown usernames only count for the IP, so that they
+// cannot fill the lockout table.
+func (h *apiHandler) recordLoginFailure(ctx context.Context, username, ip string) error {
+
+	if _, err := h.userService.GetUserByName(ctx, username); errors.Is(err, app.ErrNotFound) {
+		username = ""
+	} else if err != nil {
+		return err
+	}
+
+	return h.lockoutService.RecordFailure(ctx, username, ip)
+}
+
+func (h *apiHandler) writeTotpChallenge(w http.ResponseWriter, r *http.Request, u *user.User) {
+
+	enabled, err := h.twoFactorService.Enabled(r.Context(), u.ID)
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	challengeToken, err := h.authService.GenerateChallengeToken(r.Context(), u.ID)
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusAccepted, TotpChallenge{
+		ChallengeToken:     challengeToken,
+		EnrollmentRequired: !enabled,
+	})
+}
+
+func (h *apiHandler) LoginTotp(w http.ResponseWriter, r *http.Request) {
+
+	request := &TotpLoginRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	}
+
+	u, err := h.challengedUser(r, request.ChallengeToken)
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	ip := clientIP(r)
+
+	done, err := h.lockoutService.Check(r.Context(), u.Name, ip)
+	if err != nil {
+		lockoutError(w, r, err)
+		return
+	}
+	defer done()
+
+	enabled, err := h.twoFactorService.Enabled(r.Context(), u.ID)
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	var recoveryCodes []string
+	if enabled {
+		err = h.twoFactorService.Verify(r.Context(), u.ID, request.Code)
+	} else {
+		recoveryCodes, err = h.twoFactorService.ConfirmEnrollment(r.Context(), u.ID, request.Code)
+	}
+
+	if err != nil && errors.Is(err, app.ErrInvalid) {
+		if recordErr := h.lockoutService.RecordFailure(r.Context(), u.Name, ip); recordErr != nil {
+			handlerError(w, r, recordErr)
+			return
+		}
 		handlerError(w, r, ErrInvalidCredentials.Wrap(err))
 		return
+	} else if err != nil && errors.Is(err, app.ErrConflict) {
+		handlerError(w, r, ErrConflict.Wrap(err))
+		return
 	} else if err != nil {
 		handlerError(w, r, err)
 		return
 	}
 
-	bearerToken, err := generateBearerToken(u.ID)
+	if err = h.lockoutService.RecordSuccess(r.Context(), u.Name); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	bearerToken, err := generateBearerToken(r.Context(), h.authService, u.ID)
 	if err != nil {
 		handlerError(w, r, err)
 		return
 	}
+
+	if len(recoveryCodes) > 0 {
+		bearerToken.RecoveryCodes = &recoveryCodes
+	}
+
 	writeJSON(w, r, http.StatusOK, bearerToken)
 }
 
+func (h *apiHandler) LoginTotpEnrollment(w http.ResponseWriter, r *http.Request) {
+
+	request := &TotpChallengeRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	}
+
+	u, err := h.challengedUser(r, request.ChallengeToken)
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	h.beginTotpEnrollment(w, r, u)
+}
+
+// challengedUser returns the user of a valid challenge token issued by Login.
+func (h *apiHandler) challengedUser(r *http.Request, challengeToken string) (*user.User, error) {
+
+	claims, err := h.authService.ValidateChallengeToken(r.Context(), challengeToken)
+	if err != nil {
+		return nil, ErrInvalidToken.Wrap(err)
+	}
+
+	if claims.Tenant() != tenant.ID(r.Context()) {
+		return nil, ErrInvalidToken.Wrap(fmt.Errorf("challenge of tenant %d used for tenant %d", claims.Tenant(),
+			tenant.ID(r.Context())))
+	}
+
+	u, err := h.userService.GetUserByID(r.Context(), claims.UserID)
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		return nil, ErrInvalidToken.Wrap(err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+func (h *apiHandler) GetOidcAuthorization(w http.ResponseWriter, r *http.Request) {
+
+	authorizationURL, err := h.oidcService.AuthorizationURL(r.Context())
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		handlerError(w, r, ErrNotFound.Wrap(err))
+		return
+	} else if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, OidcAuthorization{AuthorizationUrl: authorizationURL})
+}
+
+func (h *apiHandler) LoginOidc(w http.ResponseWriter, r *http.Request) {
+
+	request := &OidcLoginRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	}
+
+	ip := clientIP(r)
+
+	u, err := h.oidcService.Login(r.Context(), request.Code, request.State)
+	if err != nil && errors.Is(err, app.ErrInvalid) {
+		// the user of a rejected identity is unknown, so the failure only counts for the IP
+		if recordErr := h.lockoutService.RecordFailure(r.Context(), "", ip); recordErr != nil {
+			handlerError(w, r, recordErr)
+			return
+		}
+		handlerError(w, r, ErrInvalidCredentials.Wrap(err))
+		return
+	} else if err != nil && errors.Is(err, app.ErrNotFound) {
+		handlerError(w, r, ErrNotFound.Wrap(err))
+		return
+	} else if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	// locked users and IPs are rejected like in the password login
+	done, err := h.lockoutService.Check(r.Context(), u.Name, ip)
+	if err != nil {
+		lockoutError(w, r, err)
+		return
+	}
+	defer done()
+
+	h.completeLogin(w, r, u)
+}
+
+func (h *apiHandler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+
+	request := &PasswordResetRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	}
+
+	if err := h.passwordResetService.RequestPasswordReset(r.Context(), request.Username); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *apiHandler) RedeemPasswordToken(w http.ResponseWriter, r *http.Request) {
+
+	request := &RedeemPasswordTokenRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	}
+
+	err := h.passwordResetService.RedeemToken(r.Context(), request.Token, request.Password)
+	if err != nil && errors.Is(err, app.ErrInvalid) {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	} else if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (h *apiHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	refreshRequest := &RefreshTokenRequest{}
 
@@ -73,13 +393,16 @@ func (h *apiHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	claims, err := auth.ValidateRefreshToken(refreshRequest.RefreshToken)
+	claims, err := h.authService.ValidateRefreshToken(r.Context(), refreshRequest.RefreshToken)
 	if err != nil {
 		handlerError(w, r, ErrInvalidCredentials.Wrap(err))
 		return
 	}
 
-	_, err = h.userService.GetUserByID(r.Context(), claims.UserID)
+	// the refreshed token keeps the tenant of the refresh token
+	ctx := tenant.WithID(r.Context(), claims.Tenant())
+
+	_, err = h.userService.GetUserByID(ctx, claims.UserID)
 	if err != nil && errors.Is(err, app.ErrNotFound) {
 		handlerError(w, r, ErrNotFound.Wrap(err))
 		return
@@ -88,7 +411,7 @@ func (h *apiHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	bearerToken, err := generateBearerToken(claims.UserID)
+	bearerToken, err := generateBearerToken(ctx, h.authService, claims.UserID)
 	if err != nil {
 		handlerError(w, r, err)
 		return
@@ -96,8 +419,20 @@ func (h *apiHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, r, http.StatusOK, bearerToken)
 }
 
+func (h *apiHandler) GetJwks(w http.ResponseWriter, r *http.Request) {
+
+	jwks, err := h.authService.JWKS(r.Context())
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, r, http.StatusOK, toAPIJwks(jwks))
+}
+
 func (h *apiHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := h.userService.ListUsers(r.Context())
+	users, err := h.userService.ListUsers(r.Context(), groupScope(r))
 	if err != nil {
 		handlerError(w, r, err)
 		return
@@ -115,7 +450,21 @@ func (h *apiHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	u, err := h.userService.CreateUser(r.Context(), fromAPINewUser(apiUser))
+	newUser := fromAPINewUser(apiUser)
+
+	if err := checkSuperAdmin(r, newUser.Role); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	groupID, err := h.groupID(r, apiUser.Group)
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+	newUser.GroupID = groupID
+
+	u, err := h.userService.CreateUser(r.Context(), newUser)
 	if err != nil {
 		if errors.Is(err, app.ErrConflict) {
 			handlerError(w, r, ErrConflict.Wrap(err))
@@ -139,38 +488,1001 @@ func (h *apiHandler) GetAuthenticatedUser(w http.ResponseWriter, r *http.Request
 	h.GetUser(w, r, userID)
 }
 
-func (h *apiHandler) GetUser(w http.ResponseWriter, r *http.Request, userID UserIdPathParam) {
+func (h *apiHandler) BeginTotpEnrollment(w http.ResponseWriter, r *http.Request) {
+
+	userID, ok := r.Context().Value(authenticatedUserID).(int64)
+	if !ok {
+		handlerError(w, r, errors.New("authenticated user not found on context"))
+		return
+	}
+
 	u, err := h.userService.GetUserByID(r.Context(), userID)
-	if err != nil && errors.Is(err, app.ErrNotFound) {
-		handlerError(w, r, ErrNotFound.Wrap(err))
+	if err != nil {
+		handlerError(w, r, err)
 		return
-	} else if err != nil {
+	}
+
+	h.beginTotpEnrollment(w, r, u)
+}
+
+func (h *apiHandler) beginTotpEnrollment(w http.ResponseWriter, r *http.Request, u *user.User) {
+
+	enrollment, err := h.twoFactorService.BeginEnrollment(r.Context(), u)
+	if err != nil {
+		if errors.Is(err, app.ErrConflict) {
+			handlerError(w, r, ErrConflict.Wrap(err))
+			return
+		}
 		handlerError(w, r, err)
 		return
 	}
 
-	writeJSON(w, r, http.StatusOK, toAPIUser(u))
+	writeJSON(w, r, http.StatusOK, TotpEnrollment{Secret: enrollment.Secret, Uri: enrollment.URI})
 }
 
-func (h *apiHandler) UpdateUser(w http.ResponseWriter, r *http.Request, userID UserIdPathParam) {
+func (h *apiHandler) ConfirmTotpEnrollment(w http.ResponseWriter, r *http.Request) {
+
+	userID, ok := r.Context().Value(authenticatedUserID).(int64)
+	if !ok {
+		handlerError(w, r, errors.New("authenticated user not found on context"))
+		return
+	}
+
+	code := &TotpCode{}
+
+	if err := json.NewDecoder(r.Body).Decode(&code); err != nil {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	}
+
+	recoveryCodes, err := h.twoFactorService.ConfirmEnrollment(r.Context(), userID, code.Code)
+	if err != nil {
+		if errors.Is(err, app.ErrInvalid) {
+			handlerError(w, r, ErrBadRequest.Wrap(err))
+			return
+		} else if errors.Is(err, app.ErrConflict) {
+			handlerError(w, r, ErrConflict.Wrap(err))
+			return
+		}
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, RecoveryCodes{RecoveryCodes: recoveryCodes})
+}
+
+func (h *apiHandler) DisableTotp(w http.ResponseWriter, r *http.Request) {
+
+	userID, ok := r.Context().Value(authenticatedUserID).(int64)
+	if !ok {
+		handlerError(w, r, errors.New("authenticated user not found on context"))
+		return
+	}
+
+	code := &TotpCode{}
+
+	if err := json.NewDecoder(r.Body).Decode(&code); err != nil {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	}
+
+	u, err := h.userService.GetUserByID(r.Context(), userID)
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	ip := clientIP(r)
+
+	// the code is guessed like in the login, e.g. with a stolen token
+	done, err := h.lockoutService.Check(r.Context(), u.Name, ip)
+	if err != nil {
+		lockoutError(w, r, err)
+		return
+	}
+	defer done()
+
+	err = h.twoFactorService.Disable(r.Context(), userID, code.Code)
+	if err != nil && errors.Is(err, app.ErrInvalid) {
+		if recordErr := h.lockoutService.RecordFailure(r.Context(), u.Name, ip); recordErr != nil {
+			handlerError(w, r, recordErr)
+			return
+		}
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	} else if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *apiHandler) ResetUserTotp(w http.ResponseWriter, r *http.Request, userID UserIdPathParam) {
+
+	if _, err := h.managedUser(r, userID); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	if err := h.twoFactorService.Reset(r.Context(), userID); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *apiHandler) GetUser(w http.ResponseWriter, r *http.Request, userID UserIdPathParam) {
+	u, err := h.userService.GetUserByID(r.Context(), userID)
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		handlerError(w, r, ErrNotFound.Wrap(err))
+		return
+	} else if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPIUser(u))
+}
+
+func (h *apiHandler) UpdateUser(w http.ResponseWriter, r *http.Request, userID UserIdPathParam) {
+
+	apiUser := &User{}
+
+	if err := json.NewDecoder(r.Body).Decode(&apiUser); err != nil {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	}
+
+	if userID != apiUser.Id {
+		handlerError(w, r, ErrBadRequest.Wrap(fmt.Errorf("id missmatch: %d != %d", userID, apiUser.Id)))
+		return
+	}
+
+	updatedUser := fromAPIUser(apiUser)
+
+	if _, err := h.managedUser(r, userID); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	if err := checkSuperAdmin(r, updatedUser.Role); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	groupID, err := h.groupID(r, apiUser.Group)
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+	updatedUser.GroupID = groupID
+
+	u, err := h.userService.UpdateUser(r.Context(), updatedUser)
+	if err != nil {
+		if errors.Is(err, app.ErrNotFound) {
+			handlerError(w, r, ErrNotFound.Wrap(err))
+			return
+		} else if errors.Is(err, app.ErrConflict) {
+			handlerError(w, r, ErrConflict.Wrap(err))
+			return
+		}
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPIUser(u))
+}
+
+func (h *apiHandler) DeleteAllUsers(w http.ResponseWriter, r *http.Request) {
+
+	if err := h.userService.DeleteAllUsers(r.Context()); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *apiHandler) DeleteUser(w http.ResponseWriter, r *http.Request, userID UserIdPathParam) {
+
+	authenticatedUserID, ok := r.Context().Value(authenticatedUserID).(int64)
+	if !ok {
+		handlerError(w, r, errors.New("authenticated user not found on context"))
+		return
+	}
+
+	if authenticatedUserID == userID {
+		handlerError(w, r, ErrConflict.Wrap(errors.New("cannot delete yourself")))
+		return
+	}
+
+	if _, err := h.managedUser(r, userID); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	if err := h.userService.DeleteUser(r.Context(), userID); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *apiHandler) UpdateUserPassword(w http.ResponseWriter, r *http.Request, userID UserIdPathParam) {
+
+	password := &Password{}
+
+	if err := json.NewDecoder(r.Body).Decode(&password); err != nil {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	}
+
+	if _, err := h.managedUser(r, userID); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	if err := h.userService.UpdateUserPassword(r.Context(), userID, password.Password); err != nil {
+		if errors.Is(err, app.ErrNotFound) {
+			handlerError(w, r, ErrNotFound.Wrap(err))
+			return
+		} else if errors.Is(err, app.ErrInvalid) {
+			handlerError(w, r, ErrBadRequest.Wrap(err))
+			return
+		}
+		handlerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *apiHandler) UnlockUser(w http.ResponseWriter, r *http.Request, userID UserIdPathParam) {
+
+	u, err := h.userService.GetUserByID(r.Context(), userID)
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		handlerError(w, r, ErrNotFound.Wrap(err))
+		return
+	} else if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	if err = h.lockoutService.Unlock(r.Context(), u.Name); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *apiHandler) InviteUser(w http.ResponseWriter, r *http.Request, userID UserIdPathParam) {
+
+	err := h.passwordResetService.InviteUser(r.Context(), userID)
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		handlerError(w, r, ErrNotFound.Wrap(err))
+		return
+	} else if err != nil && errors.Is(err, app.ErrInvalid) {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	} else if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *apiHandler) GetMyCheckIns(w http.ResponseWriter, r *http.Request) {
+
+	userID, ok := r.Context().Value(authenticatedUserID).(int64)
+	if !ok {
+		handlerError(w, r, errors.New("authenticated user not found on context"))
+		return
+	}
+
+	history, err := h.selfService.GetHistory(r.Context(), userID)
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPICheckInHistory(history))
+}
+
+func (h *apiHandler) ListMyCards(w http.ResponseWriter, r *http.Request) {
+
+	userID, ok := r.Context().Value(authenticatedUserID).(int64)
+	if !ok {
+		handlerError(w, r, errors.New("authenticated user not found on context"))
+		return
+	}
+
+	cards, err := h.selfService.ListCards(r.Context(), userID)
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPICards(cards))
+}
+
+func (h *apiHandler) ReportMyCardLost(w http.ResponseWriter, r *http.Request) {
+
+	userID, ok := r.Context().Value(authenticatedUserID).(int64)
+	if !ok {
+		handlerError(w, r, errors.New("authenticated user not found on context"))
+		return
+	}
+
+	report := &LostCardReport{}
+
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	}
+
+	err := h.selfService.ReportCardLost(r.Context(), userID, report.RfidUid)
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		handlerError(w, r, ErrNotFound.Wrap(err))
+		return
+	} else if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *apiHandler) GetMyMembership(w http.ResponseWriter, r *http.Request) {
+
+	userID, ok := r.Context().Value(authenticatedUserID).(int64)
+	if !ok {
+		handlerError(w, r, errors.New("authenticated user not found on context"))
+		return
+	}
+
+	membership, err := h.selfService.GetMembership(r.Context(), userID)
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPIMembership(membership))
+}
+
+func (h *apiHandler) GetMyProfile(w http.ResponseWriter, r *http.Request) {
+
+	userID, ok := r.Context().Value(authenticatedUserID).(int64)
+	if !ok {
+		handlerError(w, r, errors.New("authenticated user not found on context"))
+		return
+	}
+
+	u, err := h.selfService.GetProfile(r.Context(), userID)
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPIProfile(u))
+}
+
+func (h *apiHandler) UpdateMyProfile(w http.ResponseWriter, r *http.Request) {
+
+	userID, ok := r.Context().Value(authenticatedUserID).(int64)
+	if !ok {
+		handlerError(w, r, errors.New("authenticated user not found on context"))
+		return
+	}
+
+	profile := &ProfileUpdate{}
+
+	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	}
+
+	u, err := h.selfService.UpdateProfile(r.Context(), userID, fromAPIProfileUpdate(profile))
+	if err != nil && errors.Is(err, app.ErrInvalid) {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	} else if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPIProfile(u))
+}
+
+func (h *apiHandler) ExportMyData(w http.ResponseWriter, r *http.Request) {
+
+	userID, ok := r.Context().Value(authenticatedUserID).(int64)
+	if !ok {
+		handlerError(w, r, errors.New("authenticated user not found on context"))
+		return
+	}
+
+	export, err := h.selfService.Export(r.Context(), userID)
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	filename := fmt.Sprintf("personal-data-%s.json", export.ExportedAt.Format(time.DateOnly))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("X-Filename", filename)
+
+	writeJSON(w, r, http.StatusOK, toAPIPersonalDataExport(export))
+}
+
+func (h *apiHandler) ListCheckIns(w http.ResponseWriter, r *http.Request, params ListCheckInsParams) {
+	checkins, err := h.checkinService.ListCheckIns(r.Context(), checkInFilter(r, params.LocationId))
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPICheckIns(checkins))
+}
+
+func (h *apiHandler) CreateCheckIn(
+	w http.ResponseWriter,
+	r *http.Request,
+	userID UserIdPathParam,
+	params CreateCheckInParams,
+) {
+
+	if _, err := h.scopedUser(r, userID); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	c, err := h.checkinService.CreateCheckInForUser(r.Context(), userID, null.IntFromPtr(params.LocationId),
+		params.Timestamp)
+	if err != nil {
+		if errors.Is(err, app.ErrConflict) {
+			handlerError(w, r, ErrConflict.Wrap(err))
+			return
+		} else if errors.Is(err, app.ErrInvalid) {
+			handlerError(w, r, ErrBadRequest.Wrap(err))
+			return
+		}
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, toAPICheckIn(c))
+}
+
+func (h *apiHandler) CreateRfidCheckIn(w http.ResponseWriter, r *http.Request, params CreateRfidCheckInParams) {
+
+	c, err := h.checkinService.CreateCheckInForRFID(r.Context(), params.Rfid, null.StringFromPtr(params.Reader).String,
+		params.Timestamp)
+	if err != nil {
+		if c != nil && errors.Is(err, app.ErrCapacityReached) {
+			rfidCheckInError(w, r, ErrCapacityReached, c)
+			return
+		} else if c != nil && errors.Is(err, app.ErrConflict) {
+			rfidCheckInError(w, r, ErrConflict, c)
+			return
+		} else if c != nil && errors.Is(err, app.ErrNotFound) {
+			rfidCheckInError(w, r, ErrNotFound, c)
+			return
+		} else if errors.Is(err, app.ErrNotFound) {
+			handlerError(w, r, ErrNotFound.Wrap(err))
+			return
+		}
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, toAPIRfidCheckIn(c))
+}
+
+func (h *apiHandler) ListAllCheckIns(w http.ResponseWriter, r *http.Request, params ListAllCheckInsParams) {
+	checkIns, err := h.checkinService.ListAllCheckIns(r.Context(), checkInFilter(r, params.LocationId))
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	switch r.Header.Get("Accept") {
+	case contentTypeCSV:
+		writeCSV(w, r, fmt.Sprintf("%s_all_checkins.csv", time.Now().Format("2006-01-02")), checkIns)
+	case contentTypeJSON:
+		fallthrough
+	default:
+		writeJSON(w, r, http.StatusOK, toAPICheckInsWithUser(checkIns))
+	}
+}
+
+func (h *apiHandler) ListCheckInDates(w http.ResponseWriter, r *http.Request, params ListCheckInDatesParams) {
+
+	dates, err := h.checkinService.ListCheckInDates(r.Context(), checkInFilter(r, params.LocationId))
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPICheckInsDates(dates))
+}
+
+func (h *apiHandler) ListCheckInsPerDay(w http.ResponseWriter, r *http.Request, params ListCheckInsPerDayParams) {
+	checkIns, err := h.checkinService.ListCheckInsPerDay(r.Context(), params.Day.Time,
+		checkInFilter(r, params.LocationId))
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	switch r.Header.Get("Accept") {
+	case contentTypeCSV:
+		writeCSV(w, r, fmt.Sprintf("%s.csv", params.Day.String()), checkIns)
+	case contentTypeJSON:
+		fallthrough
+	default:
+		writeJSON(w, r, http.StatusOK, toAPICheckInsWithUser(checkIns))
+	}
+}
+
+func (h *apiHandler) DeleteCheckIn(w http.ResponseWriter, r *http.Request, checkinID CheckInIdPathParam) {
+
+	c, err := h.checkinService.GetCheckInByID(r.Context(), checkinID)
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		handlerError(w, r, ErrNotFound.Wrap(err))
+		return
+	} else if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	if _, err = h.scopedUser(r, c.UserID); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	if err = h.checkinService.DeleteCheckInByID(r.Context(), checkinID); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *apiHandler) DeleteUserCheckIns(w http.ResponseWriter, r *http.Request, userID UserIdPathParam) {
+
+	if _, err := h.scopedUser(r, userID); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	if err := h.checkinService.DeleteCheckInsByUserID(r.Context(), userID); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *apiHandler) GetUserCheckIns(w http.ResponseWriter, r *http.Request, userID UserIdPathParam) {
+
+	u, err := h.scopedUser(r, userID)
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	checkIns, err := h.checkinService.ListUserCheckIns(r.Context(), userID)
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	switch r.Header.Get("Accept") {
+	case contentTypeCSV:
+		writeCSV(w, r, fmt.Sprintf("%s.csv", u.Name), checkIns)
+	case contentTypeJSON:
+		fallthrough
+	default:
+		writeJSON(w, r, http.StatusOK, toAPICheckIns(checkIns))
+	}
+}
+
+func (h *apiHandler) GetOccupancy(w http.ResponseWriter, r *http.Request, params GetOccupancyParams) {
+
+	occupancy, err := h.checkinService.GetOccupancy(r.Context(), checkInFilter(r, params.LocationId))
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPIOccupancy(occupancy))
+}
+
+func (h *apiHandler) ListOccupancySeries(w http.ResponseWriter, r *http.Request, params ListOccupancySeriesParams) {
+
+	series, err := h.checkinService.ListOccupancySeries(r.Context(), params.Day.Time,
+		null.IntFromPtr(params.GroupId), checkInFilter(r, params.LocationId))
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPIOccupancySeries(series))
+}
+
+func (h *apiHandler) ListUserGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.groupService.ListGroups(r.Context())
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	names := make([]string, len(groups))
+	for i, g := range groups {
+		names[i] = g.Name
+	}
+
+	writeJSON(w, r, http.StatusOK, names)
+}
+
+func (h *apiHandler) ListGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.groupService.ListGroups(r.Context())
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPIGroups(groups))
+}
+
+func (h *apiHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+
+	apiGroup := &NewGroup{}
+
+	if err := json.NewDecoder(r.Body).Decode(&apiGroup); err != nil {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	}
+
+	g, err := h.groupService.CreateGroup(r.Context(), fromAPINewGroup(apiGroup))
+	if err != nil {
+		if errors.Is(err, app.ErrInvalid) {
+			handlerError(w, r, ErrBadRequest.Wrap(err))
+			return
+		} else if errors.Is(err, app.ErrConflict) {
+			handlerError(w, r, ErrConflict.Wrap(err))
+			return
+		}
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, toAPIGroup(g))
+}
+
+func (h *apiHandler) GetGroup(w http.ResponseWriter, r *http.Request, groupID GroupIdPathParam) {
+	g, err := h.groupService.GetGroupByID(r.Context(), groupID)
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		handlerError(w, r, ErrNotFound.Wrap(err))
+		return
+	} else if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPIGroup(g))
+}
+
+func (h *apiHandler) UpdateGroup(w http.ResponseWriter, r *http.Request, groupID GroupIdPathParam) {
+
+	apiGroup := &NewGroup{}
+
+	if err := json.NewDecoder(r.Body).Decode(&apiGroup); err != nil {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	}
+
+	g := fromAPINewGroup(apiGroup)
+	g.ID = groupID
+
+	g, err := h.groupService.UpdateGroup(r.Context(), g)
+	if err != nil {
+		if errors.Is(err, app.ErrNotFound) {
+			handlerError(w, r, ErrNotFound.Wrap(err))
+			return
+		} else if errors.Is(err, app.ErrInvalid) {
+			handlerError(w, r, ErrBadRequest.Wrap(err))
+			return
+		} else if errors.Is(err, app.ErrConflict) {
+			handlerError(w, r, ErrConflict.Wrap(err))
+			return
+		}
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPIGroup(g))
+}
+
+func (h *apiHandler) DeleteGroup(w http.ResponseWriter, r *http.Request, groupID GroupIdPathParam) {
+
+	err := h.groupService.DeleteGroup(r.Context(), groupID)
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		handlerError(w, r, ErrNotFound.Wrap(err))
+		return
+	} else if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *apiHandler) ListGroupTrainers(w http.ResponseWriter, r *http.Request, groupID GroupIdPathParam) {
+
+	trainerIDs, err := h.groupService.ListTrainerIDs(r.Context(), groupID)
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		handlerError(w, r, ErrNotFound.Wrap(err))
+		return
+	} else if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	trainers := make([]user.User, 0, len(trainerIDs))
+	for _, trainerID := range trainerIDs {
+		u, err := h.userService.GetUserByID(r.Context(), trainerID)
+		if err != nil {
+			handlerError(w, r, err)
+			return
+		}
+		trainers = append(trainers, *u)
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPIUsers(trainers))
+}
+
+func (h *apiHandler) SetGroupTrainers(w http.ResponseWriter, r *http.Request, groupID GroupIdPathParam) {
+
+	groupTrainers := &GroupTrainers{}
+
+	if err := json.NewDecoder(r.Body).Decode(&groupTrainers); err != nil {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	}
+
+	for _, trainerID := range groupTrainers.TrainerIds {
+		u, err := h.userService.GetUserByID(r.Context(), trainerID)
+		if err != nil && errors.Is(err, app.ErrNotFound) {
+			handlerError(w, r, ErrBadRequest.Wrap(err))
+			return
+		} else if err != nil {
+			handlerError(w, r, err)
+			return
+		}
+		if u.Role != user.RoleTrainer {
+			handlerError(w, r, ErrBadRequest.Wrap(fmt.Errorf("user %d is not a trainer", u.ID)))
+			return
+		}
+	}
+
+	err := h.groupService.SetTrainerIDs(r.Context(), groupID, groupTrainers.TrainerIds)
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		handlerError(w, r, ErrNotFound.Wrap(err))
+		return
+	} else if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *apiHandler) ListLocations(w http.ResponseWriter, r *http.Request) {
+	locations, err := h.locationService.ListLocations(r.Context())
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPILocations(locations))
+}
+
+func (h *apiHandler) CreateLocation(w http.ResponseWriter, r *http.Request) {
+
+	apiLocation := &NewLocation{}
+
+	if err := json.NewDecoder(r.Body).Decode(&apiLocation); err != nil {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	}
+
+	l, err := h.locationService.CreateLocation(r.Context(), fromAPINewLocation(apiLocation))
+	if err != nil {
+		if errors.Is(err, app.ErrInvalid) {
+			handlerError(w, r, ErrBadRequest.Wrap(err))
+			return
+		} else if errors.Is(err, app.ErrConflict) {
+			handlerError(w, r, ErrConflict.Wrap(err))
+			return
+		}
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, toAPILocation(l))
+}
+
+func (h *apiHandler) GetLocation(w http.ResponseWriter, r *http.Request, locationID LocationIdPathParam) {
+	l, err := h.locationService.GetLocationByID(r.Context(), locationID)
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		handlerError(w, r, ErrNotFound.Wrap(err))
+		return
+	} else if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPILocation(l))
+}
+
+func (h *apiHandler) UpdateLocation(w http.ResponseWriter, r *http.Request, locationID LocationIdPathParam) {
+
+	apiLocation := &NewLocation{}
+
+	if err := json.NewDecoder(r.Body).Decode(&apiLocation); err != nil {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	}
+
+	l := fromAPINewLocation(apiLocation)
+	l.ID = locationID
+
+	l, err := h.locationService.UpdateLocation(r.Context(), l)
+	if err != nil {
+		if errors.Is(err, app.ErrNotFound) {
+			handlerError(w, r, ErrNotFound.Wrap(err))
+			return
+		} else if errors.Is(err, app.ErrInvalid) {
+			handlerError(w, r, ErrBadRequest.Wrap(err))
+			return
+		} else if errors.Is(err, app.ErrConflict) {
+			handlerError(w, r, ErrConflict.Wrap(err))
+			return
+		}
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPILocation(l))
+}
+
+func (h *apiHandler) DeleteLocation(w http.ResponseWriter, r *http.Request, locationID LocationIdPathParam) {
+
+	err := h.locationService.DeleteLocation(r.Context(), locationID)
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		handlerError(w, r, ErrNotFound.Wrap(err))
+		return
+	} else if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *apiHandler) ListLocationReaders(w http.ResponseWriter, r *http.Request, locationID LocationIdPathParam) {
+
+	readerIDs, err := h.locationService.ListReaderIDs(r.Context(), locationID)
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		handlerError(w, r, ErrNotFound.Wrap(err))
+		return
+	} else if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, LocationReaders{ReaderIds: readerIDs})
+}
+
+func (h *apiHandler) SetLocationReaders(w http.ResponseWriter, r *http.Request, locationID LocationIdPathParam) {
+
+	locationReaders := &LocationReaders{}
+
+	if err := json.NewDecoder(r.Body).Decode(&locationReaders); err != nil {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	}
+
+	err := h.locationService.SetReaderIDs(r.Context(), locationID, locationReaders.ReaderIds)
+	if err != nil {
+		if errors.Is(err, app.ErrNotFound) {
+			handlerError(w, r, ErrNotFound.Wrap(err))
+			return
+		} else if errors.Is(err, app.ErrInvalid) {
+			handlerError(w, r, ErrBadRequest.Wrap(err))
+			return
+		}
+		handlerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *apiHandler) ListTenants(w http.ResponseWriter, r *http.Request) {
+	tenants, err := h.tenantService.ListTenants(r.Context())
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPITenants(tenants))
+}
+
+func (h *apiHandler) CreateTenant(w http.ResponseWriter, r *http.Request) {
+
+	apiTenant := &NewTenant{}
+
+	if err := json.NewDecoder(r.Body).Decode(&apiTenant); err != nil {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	}
+
+	t, err := h.tenantService.CreateTenant(r.Context(), fromAPINewTenant(apiTenant))
+	if err != nil {
+		if errors.Is(err, app.ErrInvalid) {
+			handlerError(w, r, ErrBadRequest.Wrap(err))
+			return
+		} else if errors.Is(err, app.ErrConflict) {
+			handlerError(w, r, ErrConflict.Wrap(err))
+			return
+		}
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, toAPITenant(t))
+}
+
+func (h *apiHandler) GetTenant(w http.ResponseWriter, r *http.Request, tenantID TenantIdPathParam) {
+	t, err := h.tenantService.GetTenantByID(r.Context(), tenantID)
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		handlerError(w, r, ErrNotFound.Wrap(err))
+		return
+	} else if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPITenant(t))
+}
+
+func (h *apiHandler) UpdateTenant(w http.ResponseWriter, r *http.Request, tenantID TenantIdPathParam) {
 
-	apiUser := &User{}
+	apiTenant := &NewTenant{}
 
-	if err := json.NewDecoder(r.Body).Decode(&apiUser); err != nil {
+	if err := json.NewDecoder(r.Body).Decode(&apiTenant); err != nil {
 		handlerError(w, r, ErrBadRequest.Wrap(err))
 		return
 	}
 
-	if userID != apiUser.Id {
-		handlerError(w, r, ErrBadRequest.Wrap(fmt.Errorf("id missmatch: %d != %d", userID, apiUser.Id)))
-		return
-	}
+	t := fromAPINewTenant(apiTenant)
+	t.ID = tenantID
 
-	u, err := h.userService.UpdateUser(r.Context(), fromAPIUser(apiUser))
+	t, err := h.tenantService.UpdateTenant(r.Context(), t)
 	if err != nil {
 		if errors.Is(err, app.ErrNotFound) {
 			handlerError(w, r, ErrNotFound.Wrap(err))
 			return
+		} else if errors.Is(err, app.ErrInvalid) {
+			handlerError(w, r, ErrBadRequest.Wrap(err))
+			return
 		} else if errors.Is(err, app.ErrConflict) {
 			handlerError(w, r, ErrConflict.Wrap(err))
 			return
@@ -179,205 +1491,186 @@ func (h *apiHandler) UpdateUser(w http.ResponseWriter, r *http.Request, userID U
 		return
 	}
 
-	writeJSON(w, r, http.StatusOK, toAPIUser(u))
+	writeJSON(w, r, http.StatusOK, toAPITenant(t))
 }
 
-func (h *apiHandler) DeleteAllUsers(w http.ResponseWriter, r *http.Request) {
-
-	if err := h.userService.DeleteAllUsers(r.Context()); err != nil {
-		handlerError(w, r, err)
-		return
-	}
-
-	w.WriteHeader(http.StatusNoContent)
+// checkInFilter restricts checkIns to the group scope of the authenticated user and the optional location.
+func checkInFilter(r *http.Request, locationID *int64) checkin.Filter {
+	return checkin.Filter{Scope: groupScope(r), LocationID: null.IntFromPtr(locationID)}
 }
 
-func (h *apiHandler) DeleteUser(w http.ResponseWriter, r *http.Request, userID UserIdPathParam) {
+// groupID returns the id of the group with the given name. Unknown names are rejected, so that a typo
+// does not create a new group.
+func (h *apiHandler) groupID(r *http.Request, name *string) (null.Int, error) {
 
-	authenticatedUserID, ok := r.Context().Value(authenticatedUserID).(int64)
-	if !ok {
-		handlerError(w, r, errors.New("authenticated user not found on context"))
-		return
+	if name == nil || *name == "" {
+		return null.Int{}, nil
 	}
 
-	if authenticatedUserID == userID {
-		handlerError(w, r, ErrConflict.Wrap(errors.New("cannot delete yourself")))
-		return
+	g, err := h.groupService.GetGroupByName(r.Context(), *name)
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		return null.Int{}, ErrBadRequest.Wrap(fmt.Errorf("unknown group %s: %w", *name, err))
+	} else if err != nil {
+		return null.Int{}, err
 	}
 
-	if err := h.userService.DeleteUser(r.Context(), userID); err != nil {
+	return null.IntFrom(g.ID), nil
+}
+
+func (h *apiHandler) ListAnnouncements(w http.ResponseWriter, r *http.Request) {
+	announcements, err := h.announcementService.ListAnnouncements(r.Context(), groupScope(r))
+	if err != nil {
 		handlerError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	writeJSON(w, r, http.StatusOK, toAPIAnnouncements(announcements))
 }
 
-func (h *apiHandler) UpdateUserPassword(w http.ResponseWriter, r *http.Request, userID UserIdPathParam) {
+func (h *apiHandler) CreateAnnouncement(w http.ResponseWriter, r *http.Request) {
 
-	password := &Password{}
+	apiAnnouncement := &NewAnnouncement{}
 
-	if err := json.NewDecoder(r.Body).Decode(&password); err != nil {
+	if err := json.NewDecoder(r.Body).Decode(&apiAnnouncement); err != nil {
 		handlerError(w, r, ErrBadRequest.Wrap(err))
 		return
 	}
 
-	if err := h.userService.UpdateUserPassword(r.Context(), userID, password.Password); err != nil {
+	newAnnouncement := fromAPINewAnnouncement(apiAnnouncement)
+
+	groupID, err := h.groupID(r, apiAnnouncement.Group)
+	if err != nil {
 		handlerError(w, r, err)
 		return
 	}
+	newAnnouncement.GroupID = groupID
 
-	w.WriteHeader(http.StatusNoContent)
-}
-
-func (h *apiHandler) ListCheckIns(w http.ResponseWriter, r *http.Request) {
-	checkins, err := h.checkinService.ListCheckIns(r.Context())
-	if err != nil {
+	if err = h.checkAnnouncementScope(r, newAnnouncement); err != nil {
 		handlerError(w, r, err)
 		return
 	}
 
-	writeJSON(w, r, http.StatusOK, toAPICheckIns(checkins))
-}
-
-func (h *apiHandler) CreateCheckIn(
-	w http.ResponseWriter,
-	r *http.Request,
-	userID UserIdPathParam,
-	params CreateCheckInParams,
-) {
-
-	c, err := h.checkinService.CreateCheckInForUser(r.Context(), userID, params.Timestamp)
+	a, err := h.announcementService.CreateAnnouncement(r.Context(), newAnnouncement)
 	if err != nil {
-		if errors.Is(err, app.ErrConflict) {
-			handlerError(w, r, ErrConflict.Wrap(err))
+		if errors.Is(err, app.ErrInvalid) {
+			handlerError(w, r, ErrBadRequest.Wrap(err))
 			return
 		}
 		handlerError(w, r, err)
 		return
 	}
 
-	writeJSON(w, r, http.StatusCreated, toAPICheckIn(c))
+	writeJSON(w, r, http.StatusCreated, toAPIAnnouncement(a))
 }
 
-func (h *apiHandler) CreateRfidCheckIn(w http.ResponseWriter, r *http.Request, params CreateRfidCheckInParams) {
+func (h *apiHandler) GetAnnouncement(w http.ResponseWriter, r *http.Request, announcementID AnnouncementIdPathParam) {
 
-	c, err := h.checkinService.CreateCheckInForRFID(r.Context(), params.Rfid, params.Timestamp)
+	a, err := h.scopedAnnouncement(r, announcementID)
 	if err != nil {
-		if errors.Is(err, app.ErrConflict) {
-			handlerError(w, r, ErrConflict.Wrap(err))
-			return
-		} else if errors.Is(err, app.ErrNotFound) {
-			handlerError(w, r, ErrNotFound.Wrap(err))
-			return
-		}
 		handlerError(w, r, err)
 		return
 	}
 
-	writeJSON(w, r, http.StatusCreated, toAPICheckIn(c))
+	writeJSON(w, r, http.StatusOK, toAPIAnnouncement(a))
 }
 
-func (h *apiHandler) ListAllCheckIns(w http.ResponseWriter, r *http.Request) {
-	checkIns, err := h.checkinService.ListAllCheckIns(r.Context())
-	if err != nil {
-		handlerError(w, r, err)
-		return
+// scopedAnnouncement returns the announcement if it is within the group scope of the authenticated user.
+func (h *apiHandler) scopedAnnouncement(r *http.Request, announcementID int64) (*announcement.Announcement, error) {
+
+	a, err := h.announcementService.GetAnnouncementByID(r.Context(), announcementID)
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		return nil, ErrNotFound.Wrap(err)
+	} else if err != nil {
+		return nil, err
 	}
 
-	switch r.Header.Get("Accept") {
-	case contentTypeCSV:
-		writeCSV(w, r, fmt.Sprintf("%s_all_checkins.csv", time.Now().Format("2006-01-02")), checkIns)
-	case contentTypeJSON:
-		fallthrough
-	default:
-		writeJSON(w, r, http.StatusOK, toAPICheckInsWithUser(checkIns))
+	if err = h.checkAnnouncementScope(r, a); err != nil {
+		return nil, err
 	}
+
+	return a, nil
 }
 
-func (h *apiHandler) ListCheckInDates(w http.ResponseWriter, r *http.Request) {
+func (h *apiHandler) UpdateAnnouncement(
+	w http.ResponseWriter,
+	r *http.Request,
+	announcementID AnnouncementIdPathParam,
+) {
+
+	apiAnnouncement := &NewAnnouncement{}
 
-	dates, err := h.checkinService.ListCheckInDates(r.Context())
-	if err != nil {
+	if err := json.NewDecoder(r.Body).Decode(&apiAnnouncement); err != nil {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	}
+
+	if _, err := h.scopedAnnouncement(r, announcementID); err != nil {
 		handlerError(w, r, err)
 		return
 	}
 
-	writeJSON(w, r, http.StatusOK, toAPICheckInsDates(dates))
-}
+	a := fromAPINewAnnouncement(apiAnnouncement)
+	a.ID = announcementID
 
-func (h *apiHandler) ListCheckInsPerDay(w http.ResponseWriter, r *http.Request, params ListCheckInsPerDayParams) {
-	checkIns, err := h.checkinService.ListCheckInsPerDay(r.Context(), params.Day.Time)
+	groupID, err := h.groupID(r, apiAnnouncement.Group)
 	if err != nil {
 		handlerError(w, r, err)
 		return
 	}
+	a.GroupID = groupID
 
-	switch r.Header.Get("Accept") {
-	case contentTypeCSV:
-		writeCSV(w, r, fmt.Sprintf("%s.csv", params.Day.String()), checkIns)
-	case contentTypeJSON:
-		fallthrough
-	default:
-		writeJSON(w, r, http.StatusOK, toAPICheckInsWithUser(checkIns))
-	}
-}
-
-func (h *apiHandler) DeleteCheckIn(w http.ResponseWriter, r *http.Request, checkinID CheckInIdPathParam) {
-
-	if err := h.checkinService.DeleteCheckInByID(r.Context(), checkinID); err != nil {
+	// trainers must not move an announcement out of their scope either
+	if err = h.checkAnnouncementScope(r, a); err != nil {
 		handlerError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
-}
-
-func (h *apiHandler) DeleteUserCheckIns(w http.ResponseWriter, r *http.Request, userID UserIdPathParam) {
-
-	if err := h.checkinService.DeleteCheckInsByUserID(r.Context(), userID); err != nil {
+	a, err = h.announcementService.UpdateAnnouncement(r.Context(), a)
+	if err != nil {
+		if errors.Is(err, app.ErrNotFound) {
+			handlerError(w, r, ErrNotFound.Wrap(err))
+			return
+		} else if errors.Is(err, app.ErrInvalid) {
+			handlerError(w, r, ErrBadRequest.Wrap(err))
+			return
+		}
 		handlerError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	writeJSON(w, r, http.StatusOK, toAPIAnnouncement(a))
 }
 
-func (h *apiHandler) GetUserCheckIns(w http.ResponseWriter, r *http.Request, userID UserIdPathParam) {
+func (h *apiHandler) DeleteAnnouncement(
+	w http.ResponseWriter,
+	r *http.Request,
+	announcementID AnnouncementIdPathParam,
+) {
 
-	u, err := h.userService.GetUserByID(r.Context(), userID)
-	if err != nil {
+	if _, err := h.scopedAnnouncement(r, announcementID); err != nil {
 		handlerError(w, r, err)
 		return
 	}
 
-	checkIns, err := h.checkinService.ListUserCheckIns(r.Context(), userID)
-	if err != nil {
+	err := h.announcementService.DeleteAnnouncement(r.Context(), announcementID)
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		handlerError(w, r, ErrNotFound.Wrap(err))
+		return
+	} else if err != nil {
 		handlerError(w, r, err)
 		return
 	}
 
-	switch r.Header.Get("Accept") {
-	case contentTypeCSV:
-		writeCSV(w, r, fmt.Sprintf("%s.csv", u.Name), checkIns)
-	case contentTypeJSON:
-		fallthrough
-	default:
-		writeJSON(w, r, http.StatusOK, toAPICheckIns(checkIns))
-	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func (h *apiHandler) ListUserGroups(w http.ResponseWriter, r *http.Request) {
-	groups, err := h.userService.ListUserGroups(r.Context())
-	if err != nil {
+func (h *apiHandler) GetClock(w http.ResponseWriter, r *http.Request, params GetClockParams) {
+
+	if err := h.checkDeploymentAccess(r); err != nil {
 		handlerError(w, r, err)
 		return
 	}
 
-	writeJSON(w, r, http.StatusOK, groups)
-}
-
-func (h *apiHandler) GetClock(w http.ResponseWriter, r *http.Request, params GetClockParams) {
 	c, err := h.clockService.GetClock(r.Context())
 	if err != nil {
 		handlerError(w, r, err)
@@ -388,6 +1681,11 @@ func (h *apiHandler) GetClock(w http.ResponseWriter, r *http.Request, params Get
 
 func (h *apiHandler) SetClock(w http.ResponseWriter, r *http.Request, params SetClockParams) {
 
+	if err := h.checkDeploymentAccess(r); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
 	ts, err := fromAPIRefTimestamp(params.Ref)
 	if err != nil {
 		handlerError(w, r, err)
@@ -423,8 +1721,131 @@ func (h *apiHandler) GetVersion(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (h *apiHandler) GetDiagnostics(w http.ResponseWriter, r *http.Request) {
+
+	if err := h.checkDeploymentAccess(r); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	diagnostics, err := h.healthService.Diagnostics(r.Context())
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPIDiagnostics(diagnostics))
+}
+
+func (h *apiHandler) DownloadBackup(w http.ResponseWriter, r *http.Request) {
+
+	if err := h.checkDeploymentAccess(r); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	snapshot, err := h.backupService.Snapshot(r.Context())
+	if err != nil && errors.Is(err, errors.ErrUnsupported) {
+		handlerError(w, r, ErrNotImplemented.Wrap(err))
+		return
+	} else if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+	defer snapshot.Close()
+
+	filename := fmt.Sprintf("checkin-system-%s.db", time.Now().UTC().Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", backup.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("X-Filename", filename)
+	w.WriteHeader(http.StatusOK)
+
+	if _, err = io.Copy(w, snapshot); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write backup", "error", err)
+	}
+}
+
+func (h *apiHandler) RestoreBackup(w http.ResponseWriter, r *http.Request) {
+
+	if err := h.checkDeploymentAccess(r); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	err := h.backupService.Restore(r.Context(), r.Body)
+	if err != nil && errors.Is(err, errors.ErrUnsupported) {
+		handlerError(w, r, ErrNotImplemented.Wrap(err))
+		return
+	} else if err != nil && errors.Is(err, app.ErrInvalid) {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	} else if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkDeploymentAccess returns ErrForbidden in multi-tenant mode, unless the authenticated user is a super admin, as
+// backups and diagnostics cover all tenants, and the clock and the wifi belong to the host serving all of them.
+func (h *apiHandler) checkDeploymentAccess(r *http.Request) error {
+
+	if role, _ := r.Context().Value(authenticatedUserRole).(string); h.tenantService.MultiTenant() &&
+		role != user.RoleSuperAdmin {
+		return ErrForbidden.Wrap(errors.New("only super admins may access all tenants"))
+	}
+
+	return nil
+}
+
+func (h *apiHandler) GetSyncSnapshot(w http.ResponseWriter, r *http.Request) {
+
+	snapshot, err := h.syncService.Snapshot(r.Context())
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPISyncSnapshot(snapshot))
+}
+
+func (h *apiHandler) PushSyncCheckIns(w http.ResponseWriter, r *http.Request) {
+
+	checkIns := &SyncCheckIns{}
+
+	if err := json.NewDecoder(r.Body).Decode(&checkIns); err != nil {
+		handlerError(w, r, ErrBadRequest.Wrap(err))
+		return
+	}
+
+	results, err := h.syncService.PushCheckIns(r.Context(), fromAPISyncCheckIns(checkIns))
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPISyncCheckInResults(results))
+}
+
+func (h *apiHandler) GetSyncStatus(w http.ResponseWriter, r *http.Request) {
+
+	status, err := h.syncService.Status(r.Context())
+	if err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, toAPISyncStatus(status))
+}
+
 func (h *apiHandler) GetWifiNetworks(w http.ResponseWriter, r *http.Request) {
 
+	if err := h.checkDeploymentAccess(r); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
 	networks, err := h.wifiService.ListNetworks(r.Context())
 	if err != nil {
 		handlerError(w, r, err)
@@ -436,6 +1857,11 @@ func (h *apiHandler) GetWifiNetworks(w http.ResponseWriter, r *http.Request) {
 
 func (h *apiHandler) AddWifiNetwork(w http.ResponseWriter, r *http.Request) {
 
+	if err := h.checkDeploymentAccess(r); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
 	network := &WifiNetwork{}
 
 	if err := json.NewDecoder(r.Body).Decode(&network); err != nil {
@@ -458,6 +1884,11 @@ func (h *apiHandler) AddWifiNetwork(w http.ResponseWriter, r *http.Request) {
 
 func (h *apiHandler) RemoveWifiNetwork(w http.ResponseWriter, r *http.Request, ssid SsidPathParam) {
 
+	if err := h.checkDeploymentAccess(r); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
 	if err := h.wifiService.RemoveNetwork(r.Context(), ssid); err != nil {
 		handlerError(w, r, err)
 		return
@@ -467,6 +1898,12 @@ func (h *apiHandler) RemoveWifiNetwork(w http.ResponseWriter, r *http.Request, s
 }
 
 func (h *apiHandler) GetWifiStatus(w http.ResponseWriter, r *http.Request) {
+
+	if err := h.checkDeploymentAccess(r); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
 	status, err := h.wifiService.GetStatus(r.Context())
 	if err != nil {
 		handlerError(w, r, err)
@@ -476,6 +1913,12 @@ func (h *apiHandler) GetWifiStatus(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *apiHandler) ToggleWifiMode(w http.ResponseWriter, r *http.Request) {
+
+	if err := h.checkDeploymentAccess(r); err != nil {
+		handlerError(w, r, err)
+		return
+	}
+
 	err := h.wifiService.ToggleWifiMode(r.Context())
 	if err != nil {
 		handlerError(w, r, err)
@@ -484,6 +1927,27 @@ func (h *apiHandler) ToggleWifiMode(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func lockoutError(w http.ResponseWriter, r *http.Request, err error) {
+
+	var lockedErr *lockout.LockedError
+	if errors.As(err, &lockedErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockedErr.RetryAfter.Seconds()))))
+		handlerError(w, r, ErrLoginLocked.Wrap(err))
+		return
+	}
+
+	handlerError(w, r, err)
+}
+
+// clientIP returns the IP of the client, RemoteAddr is set from the headers of trusted proxies by the server.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func writeJSON(w http.ResponseWriter, r *http.Request, status int, response any) {
 
 	w.Header().Set("Content-Type", contentTypeJSON)
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/clock"
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/d-rk/checkin-system/pkg/tenant"
+	"github.com/d-rk/checkin-system/pkg/user"
+	"github.com/d-rk/checkin-system/pkg/wifi"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingHost records the calls to the clock and the wifi of the host.
+type recordingHost struct {
+	calls []string
+}
+
+func (h *recordingHost) GetClock(_ context.Context) (clock.Clock, error) {
+	h.calls = append(h.calls, "GetClock")
+	return clock.Clock{}, nil
+}
+
+func (h *recordingHost) SetClock(_ context.Context, _ time.Time) error {
+	h.calls = append(h.calls, "SetClock")
+	return nil
+}
+
+func (h *recordingHost) ListNetworks(_ context.Context) ([]string, error) {
+	h.calls = append(h.calls, "ListNetworks")
+	return nil, nil
+}
+
+func (h *recordingHost) AddNetwork(_ context.Context, _, _ string) error {
+	h.calls = append(h.calls, "AddNetwork")
+	return nil
+}
+
+func (h *recordingHost) RemoveNetwork(_ context.Context, _ string) error {
+	h.calls = append(h.calls, "RemoveNetwork")
+	return nil
+}
+
+func (h *recordingHost) GetStatus(_ context.Context) (wifi.Status, error) {
+	h.calls = append(h.calls, "GetStatus")
+	return wifi.Status{}, nil
+}
+
+func (h *recordingHost) ToggleWifiMode(_ context.Context) error {
+	h.calls = append(h.calls, "ToggleWifiMode")
+	return nil
+}
+
+func (h *recordingHost) CheckScript() error {
+	return nil
+}
+
+func TestHostHandlers_MultiTenant_OnlySuperAdmins(t *testing.T) {
+
+	ref := time.Now().Format(time.RFC3339)
+
+	handlers := map[string]func(h *apiHandler, w http.ResponseWriter, r *http.Request){
+		"GetClock": func(h *apiHandler, w http.ResponseWriter, r *http.Request) {
+			h.GetClock(w, r, GetClockParams{Ref: ref})
+		},
+		"SetClock": func(h *apiHandler, w http.ResponseWriter, r *http.Request) {
+			h.SetClock(w, r, SetClockParams{Ref: ref})
+		},
+		"GetWifiNetworks": (*apiHandler).GetWifiNetworks,
+		"AddWifiNetwork":  (*apiHandler).AddWifiNetwork,
+		"RemoveWifiNetwork": func(h *apiHandler, w http.ResponseWriter, r *http.Request) {
+			h.RemoveWifiNetwork(w, r, "hall")
+		},
+		"GetWifiStatus":  (*apiHandler).GetWifiStatus,
+		"ToggleWifiMode": (*apiHandler).ToggleWifiMode,
+	}
+
+	for name, handle := range handlers {
+		for _, tc := range []struct {
+			role      string
+			forbidden bool
+		}{
+			{role: user.RoleAdmin, forbidden: true},
+			{role: user.RoleSuperAdmin},
+		} {
+			t.Run(name+" as "+tc.role, func(t *testing.T) {
+				host := &recordingHost{}
+				h := &apiHandler{
+					tenantService: tenant.NewService(nil, config.Tenant{BaseDomain: "checkin.example.org"}),
+					clockService:  host,
+					wifiService:   host,
+				}
+
+				body := strings.NewReader(`{"ssid":"hall","password":"secret-pw"}`)
+				r := httptest.NewRequest(http.MethodPost, "/", body)
+				r = r.WithContext(context.WithValue(r.Context(), authenticatedUserRole, tc.role))
+				w := httptest.NewRecorder()
+
+				handle(h, w, r)
+
+				if tc.forbidden {
+					assert.Equal(t, http.StatusForbidden, w.Code)
+					assert.Empty(t, host.calls)
+				} else {
+					assert.NotEqual(t, http.StatusForbidden, w.Code)
+					assert.NotEmpty(t, host.calls)
+				}
+			})
+		}
+	}
+}
@@ -1,9 +1,19 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 
+	"github.com/d-rk/checkin-system/pkg/announcement"
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/audit"
 	"github.com/d-rk/checkin-system/pkg/auth"
+	"github.com/d-rk/checkin-system/pkg/group"
+	"github.com/d-rk/checkin-system/pkg/tenant"
+	"github.com/d-rk/checkin-system/pkg/user"
+	"github.com/d-rk/checkin-system/pkg/websocket"
 	"golang.org/x/net/context"
 )
 
@@ -11,15 +21,19 @@ type contextKey int
 
 const (
 	authenticatedUserID contextKey = iota
+	authenticatedUserRole
 )
 
-func AuthMiddleware() MiddlewareFunc {
+// AuthMiddleware validates the bearer token of secured operations. The scopes of an operation are the roles
+// it is available to, any authenticated user may access operations without scopes. Sync users only access the
+// operations which list their role.
+func AuthMiddleware(authService auth.Service, userService user.Service) MiddlewareFunc {
 
 	return func(next http.Handler) http.Handler {
 
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-			if bearerTokenRequired(r) {
+			if roles, ok := r.Context().Value(BearerAuthScopes).([]string); ok {
 
 				token, err := auth.FindToken(r)
 				if err != nil {
@@ -27,13 +41,43 @@ func AuthMiddleware() MiddlewareFunc {
 					return
 				}
 
-				claims, err := auth.ValidateToken(token)
+				claims, err := authService.ValidateToken(r.Context(), token)
 				if err != nil {
 					handlerError(w, r, ErrInvalidToken.Wrap(err))
 					return
 				}
 
-				r = r.WithContext(context.WithValue(r.Context(), authenticatedUserID, claims.UserID))
+				// the user is loaded on every request, so that role changes and deletions apply immediately
+				tokenCtx := tenant.WithID(r.Context(), claims.Tenant())
+				u, err := userService.GetUserByID(tokenCtx, claims.UserID)
+				if err != nil && errors.Is(err, app.ErrNotFound) {
+					handlerError(w, r, ErrInvalidToken.Wrap(err))
+					return
+				} else if err != nil {
+					handlerError(w, r, err)
+					return
+				}
+
+				// tokens are only valid in the tenant they were issued for, super admins act in the tenant of the
+				// subdomain
+				ctx := tokenCtx
+				if hostTenant, ok := tenant.FromContext(r.Context()); ok && hostTenant != claims.Tenant() {
+					if u.Role != user.RoleSuperAdmin {
+						handlerError(w, r, ErrInvalidToken.Wrap(fmt.Errorf("token of tenant %d used for tenant %d",
+							claims.Tenant(), hostTenant)))
+						return
+					}
+					ctx = r.Context()
+				}
+
+				if (len(roles) > 0 || u.Role == user.RoleSync) && !hasRole(roles, u.Role) {
+					handlerError(w, r, ErrForbidden.Wrap(fmt.Errorf("role %s not in %v", u.Role, roles)))
+					return
+				}
+
+				ctx = context.WithValue(ctx, authenticatedUserID, u.ID)
+				ctx = audit.WithActorID(ctx, u.ID)
+				r = r.WithContext(context.WithValue(ctx, authenticatedUserRole, u.Role))
 			}
 
 			next.ServeHTTP(w, r)
@@ -41,7 +85,131 @@ func AuthMiddleware() MiddlewareFunc {
 	}
 }
 
-func bearerTokenRequired(r *http.Request) bool {
-	_, ok := r.Context().Value(BearerAuthScopes).([]string)
-	return ok
+// WebsocketAuthMiddleware authenticates the websocket clients like the check-in operations, as the events contain the
+// check-ins of the tenant. The token is read from the authorization header or from the subprotocols of the client.
+// The group scope of the user is passed on, so that trainers only receive the taps of their members.
+func WebsocketAuthMiddleware(authService auth.Service, userService user.Service) func(http.Handler) http.Handler {
+
+	authMiddleware := AuthMiddleware(authService, userService)
+
+	return func(next http.Handler) http.Handler {
+
+		authenticated := authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(websocket.WithScope(r.Context(), groupScope(r))))
+		}))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			if token := websocket.BearerToken(r); token != "" && r.Header.Get("Authorization") == "" {
+				r = r.Clone(r.Context())
+				r.Header.Set("Authorization", "Bearer "+token)
+			}
+
+			ctx := context.WithValue(r.Context(), BearerAuthScopes, []string{user.RoleAdmin, user.RoleTrainer})
+			authenticated.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// hasRole returns true if the role is one of the roles. Super admins have all roles of admins.
+func hasRole(roles []string, role string) bool {
+	return slices.Contains(roles, role) || (role == user.RoleSuperAdmin && slices.Contains(roles, user.RoleAdmin))
+}
+
+// checkSuperAdmin returns ErrForbidden if the role is reserved to super admins and the authenticated user is none.
+func checkSuperAdmin(r *http.Request, role string) error {
+
+	if authenticatedRole, _ := r.Context().Value(authenticatedUserRole).(string); role == user.RoleSuperAdmin &&
+		authenticatedRole != user.RoleSuperAdmin {
+		return ErrForbidden.Wrap(errors.New("only super admins may manage super admins"))
+	}
+
+	return nil
+}
+
+// managedUser returns the user if the authenticated user may manage it, i.e. super admins are only managed by
+// super admins.
+func (h *apiHandler) managedUser(r *http.Request, userID int64) (*user.User, error) {
+
+	u, err := h.userService.GetUserByID(r.Context(), userID)
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		return nil, ErrNotFound.Wrap(err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err = checkSuperAdmin(r, u.Role); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// groupScope returns the scope of the authenticated user: trainers may only access the members of their groups.
+func groupScope(r *http.Request) group.Scope {
+
+	if role, _ := r.Context().Value(authenticatedUserRole).(string); role == user.RoleTrainer {
+		userID, _ := r.Context().Value(authenticatedUserID).(int64)
+		return group.TrainerScope(userID)
+	}
+
+	return group.Scope{}
+}
+
+// checkScope returns ErrForbidden if the user is not within the group scope of the authenticated user.
+func (h *apiHandler) checkScope(r *http.Request, u *user.User) error {
+
+	inScope, err := h.groupService.InScope(r.Context(), groupScope(r), u.GroupID)
+	if err != nil {
+		return err
+	}
+
+	if !inScope {
+		return ErrForbidden.Wrap(fmt.Errorf("user %d is not a member of a group of the trainer", u.ID))
+	}
+
+	return nil
+}
+
+// checkAnnouncementScope returns ErrForbidden if the announcement is not within the group scope of the authenticated
+// user. Trainers may only manage the announcements of their groups and the personal notes of their members.
+func (h *apiHandler) checkAnnouncementScope(r *http.Request, a *announcement.Announcement) error {
+
+	scope := groupScope(r)
+	if !scope.Restricted() {
+		return nil
+	}
+
+	if a.UserID.Valid {
+		_, err := h.scopedUser(r, a.UserID.Int64)
+		return err
+	}
+
+	inScope, err := h.groupService.InScope(r.Context(), scope, a.GroupID)
+	if err != nil {
+		return err
+	}
+
+	if !inScope {
+		return ErrForbidden.Wrap(errors.New("announcement is not addressed to a group of the trainer"))
+	}
+
+	return nil
+}
+
+// scopedUser returns the user if it is within the group scope of the authenticated user.
+func (h *apiHandler) scopedUser(r *http.Request, userID int64) (*user.User, error) {
+
+	u, err := h.userService.GetUserByID(r.Context(), userID)
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		return nil, ErrNotFound.Wrap(err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err = h.checkScope(r, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
 }
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/auth"
+	"github.com/d-rk/checkin-system/pkg/user"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tokenAuth accepts the role of the user as token.
+type tokenAuth struct {
+	auth.Service
+}
+
+func (a tokenAuth) ValidateToken(_ context.Context, token string) (*auth.TokenClaims, error) {
+	return &auth.TokenClaims{UserID: userIDs[token], TenantID: 1}, nil
+}
+
+var userIDs = map[string]int64{user.RoleAdmin: 1, user.RoleTrainer: 2, user.RoleSync: 3}
+
+// roleUsers returns a user with the role of the id.
+type roleUsers struct {
+	user.Service
+}
+
+func (u roleUsers) GetUserByID(_ context.Context, id int64) (*user.User, error) {
+	for role, userID := range userIDs {
+		if userID == id {
+			return &user.User{ID: id, Role: role}, nil
+		}
+	}
+	return nil, app.ErrNotFound
+}
+
+func TestAuthMiddleware_SyncUsers_OnlyAccessTheSyncOperations(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		role   string
+		scopes []string
+		status int
+	}{
+		{name: "sync operation", role: user.RoleSync, scopes: []string{user.RoleSync}, status: http.StatusOK},
+		{name: "admin operation", role: user.RoleSync, scopes: []string{user.RoleAdmin}, status: http.StatusForbidden},
+		{name: "any authenticated user", role: user.RoleSync, scopes: []string{}, status: http.StatusForbidden},
+		{name: "admin at sync operation", role: user.RoleAdmin, scopes: []string{user.RoleSync},
+			status: http.StatusForbidden},
+		{name: "trainer as authenticated user", role: user.RoleTrainer, scopes: []string{}, status: http.StatusOK},
+	}
+
+	handler := AuthMiddleware(tokenAuth{}, roleUsers{})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Authorization", "Bearer "+tc.role)
+			r = r.WithContext(context.WithValue(r.Context(), BearerAuthScopes, tc.scopes))
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			assert.Equal(t, tc.status, w.Code)
+		})
+	}
+}
@@ -1,10 +1,19 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"log/slog"
 	"net/http"
+	"strings"
+
+	"github.com/d-rk/checkin-system/pkg/checkin"
+	"github.com/d-rk/checkin-system/pkg/tracing"
+
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	netHttpMiddleware "github.com/oapi-codegen/nethttp-middleware"
 )
 
 type Error interface {
@@ -15,9 +24,16 @@ type Error interface {
 var (
 	ErrInvalidCredentials = &sentinelAPIError{status: http.StatusUnauthorized, msg: "invalid credentials"}
 	ErrInvalidToken       = &sentinelAPIError{status: http.StatusUnauthorized, msg: "invalid token"}
+	ErrForbidden          = &sentinelAPIError{status: http.StatusForbidden, msg: "forbidden"}
 	ErrNotFound           = &sentinelAPIError{status: http.StatusNotFound, msg: "not found"}
 	ErrBadRequest         = &sentinelAPIError{status: http.StatusBadRequest, msg: "bad request"}
 	ErrConflict           = &sentinelAPIError{status: http.StatusConflict, msg: "conflict"}
+	ErrCapacityReached    = &sentinelAPIError{status: http.StatusConflict, msg: "capacity reached"}
+	ErrNotImplemented     = &sentinelAPIError{status: http.StatusNotImplemented, msg: "not implemented"}
+	ErrLoginLocked        = &sentinelAPIError{
+		status: http.StatusTooManyRequests,
+		msg:    "too many failed login attempts",
+	}
 )
 
 type sentinelAPIError struct {
@@ -55,27 +71,68 @@ func Wrap(err error, sentinel *sentinelAPIError) error {
 	return sentinelWrappedError{error: err, sentinel: sentinel}
 }
 
-func ValidateErrorHandlerFunc(w http.ResponseWriter, message string, statusCode int) {
-	errorResponse(w, statusCode, message)
+func ValidateErrorHandlerFunc(ctx context.Context, err error, w http.ResponseWriter, _ *http.Request,
+	opts netHttpMiddleware.ErrorHandlerOpts) {
+
+	statusCode := opts.StatusCode
+	if errors.Is(err, routers.ErrMethodNotAllowed) {
+		statusCode = http.StatusMethodNotAllowed
+	}
+
+	message := err.Error()
+
+	// request errors are verbose, with a decent message on the first line
+	var requestErr *openapi3filter.RequestError
+	if errors.As(err, &requestErr) {
+		message, _, _ = strings.Cut(message, "\n")
+	}
+
+	errorResponse(ctx, w, statusCode, message)
 }
 
-func handlerError(w http.ResponseWriter, _ *http.Request, err error) {
+func handlerError(w http.ResponseWriter, r *http.Request, err error) {
 	w.Header().Add("Content-Type", "application/json")
 
 	var apiErr Error
 
 	if errors.As(err, &apiErr) {
 		status, msg := apiErr.APIError()
-		errorResponse(w, status, msg)
+		errorResponse(r.Context(), w, status, msg)
 	} else {
-		slog.Error("unexpected error", "error", err)
-		errorResponse(w, http.StatusInternalServerError, "internal error")
+		slog.ErrorContext(r.Context(), "unexpected error", "error", err)
+		errorResponse(r.Context(), w, http.StatusInternalServerError, "internal error")
 	}
 }
 
-func errorResponse(w http.ResponseWriter, statusCode int, message string) {
+// errorResponse writes the error along with the id of the trace of the request, so that it can be looked up.
+func errorResponse(ctx context.Context, w http.ResponseWriter, statusCode int, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 
-	_ = json.NewEncoder(w).Encode(ErrorResponse{Message: message})
+	response := ErrorResponse{Message: message}
+	if traceID := tracing.TraceID(ctx); traceID != "" {
+		response.TraceId = &traceID
+	}
+
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// rfidCheckInError writes the error of a rejected tap along with the feedback to display on the kiosk.
+func rfidCheckInError(w http.ResponseWriter, r *http.Request, sentinel *sentinelAPIError, c *checkin.RFIDCheckIn) {
+
+	status, message := sentinel.APIError()
+
+	response := RfidCheckInError{
+		Message: message,
+		Display: toAPICheckInDisplay(&c.Display),
+		Notes:   toAPIAnnouncementsPtr(c.Notes),
+	}
+	if traceID := tracing.TraceID(r.Context()); traceID != "" {
+		response.TraceId = &traceID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(response)
 }
@@ -1,13 +1,19 @@
 package api
 
 import (
-	"os"
-	"strconv"
+	"context"
 	"time"
 
+	"github.com/d-rk/checkin-system/pkg/announcement"
 	"github.com/d-rk/checkin-system/pkg/auth"
 	"github.com/d-rk/checkin-system/pkg/checkin"
 	"github.com/d-rk/checkin-system/pkg/clock"
+	"github.com/d-rk/checkin-system/pkg/edgesync"
+	"github.com/d-rk/checkin-system/pkg/group"
+	"github.com/d-rk/checkin-system/pkg/health"
+	"github.com/d-rk/checkin-system/pkg/location"
+	"github.com/d-rk/checkin-system/pkg/selfservice"
+	"github.com/d-rk/checkin-system/pkg/tenant"
 	"github.com/d-rk/checkin-system/pkg/user"
 	"github.com/d-rk/checkin-system/pkg/wifi"
 	openapi_types "github.com/oapi-codegen/runtime/types"
@@ -19,9 +25,11 @@ func toAPIUser(u *user.User) *User {
 		Id:       u.ID,
 		Name:     u.Name,
 		Group:    u.Group.Ptr(),
+		GroupId:  u.GroupID.Ptr(),
 		Role:     u.Role,
 		MemberId: u.MemberID.Ptr(),
 		RfidUid:  u.RFIDuid.Ptr(),
+		Email:    u.Email.Ptr(),
 	}
 }
 
@@ -45,6 +53,7 @@ func fromAPIUser(u *User) *user.User {
 		Role:     u.Role,
 		MemberID: null.StringFromPtr(u.MemberId),
 		RFIDuid:  null.StringFromPtr(u.RfidUid),
+		Email:    null.StringFromPtr(u.Email),
 	}
 }
 
@@ -55,18 +64,195 @@ func fromAPINewUser(u *NewUser) *user.User {
 		Role:     u.Role,
 		MemberID: null.StringFromPtr(u.MemberId),
 		RFIDuid:  null.StringFromPtr(u.RfidUid),
+		Email:    null.StringFromPtr(u.Email),
 	}
 }
 
+func toAPIGroup(g *group.Group) *Group {
+	return &Group{
+		Id:          g.ID,
+		Name:        g.Name,
+		Description: g.Description.Ptr(),
+		Colour:      g.Colour.Ptr(),
+		Capacity:    nullIntPtr(g.Capacity),
+		Schedule:    g.Schedule.Ptr(),
+	}
+}
+
+func toAPIGroups(groups []group.Group) []Group {
+
+	result := make([]Group, len(groups))
+
+	for i, g := range groups {
+		gg := g
+		result[i] = *toAPIGroup(&gg)
+	}
+
+	return result
+}
+
+func fromAPINewGroup(g *NewGroup) *group.Group {
+
+	capacity := null.Int{}
+	if g.Capacity != nil {
+		capacity = null.IntFrom(int64(*g.Capacity))
+	}
+
+	return &group.Group{
+		Name:        g.Name,
+		Description: null.StringFromPtr(g.Description),
+		Colour:      null.StringFromPtr(g.Colour),
+		Capacity:    capacity,
+		Schedule:    null.StringFromPtr(g.Schedule),
+	}
+}
+
+func toAPILocation(l *location.Location) *Location {
+	return &Location{
+		Id:          l.ID,
+		Name:        l.Name,
+		Description: l.Description.Ptr(),
+		Capacity:    nullIntPtr(l.Capacity),
+	}
+}
+
+func toAPILocations(locations []location.Location) []Location {
+
+	result := make([]Location, len(locations))
+
+	for i, l := range locations {
+		ll := l
+		result[i] = *toAPILocation(&ll)
+	}
+
+	return result
+}
+
+func fromAPINewLocation(l *NewLocation) *location.Location {
+
+	capacity := null.Int{}
+	if l.Capacity != nil {
+		capacity = null.IntFrom(int64(*l.Capacity))
+	}
+
+	return &location.Location{
+		Name:        l.Name,
+		Description: null.StringFromPtr(l.Description),
+		Capacity:    capacity,
+	}
+}
+
+func toAPITenant(t *tenant.Tenant) *Tenant {
+	return &Tenant{
+		Id:   t.ID,
+		Name: t.Name,
+		Slug: t.Slug,
+	}
+}
+
+func toAPITenants(tenants []tenant.Tenant) []Tenant {
+
+	result := make([]Tenant, len(tenants))
+
+	for i, t := range tenants {
+		tt := t
+		result[i] = *toAPITenant(&tt)
+	}
+
+	return result
+}
+
+func fromAPINewTenant(t *NewTenant) *tenant.Tenant {
+	return &tenant.Tenant{
+		Name: t.Name,
+		Slug: t.Slug,
+	}
+}
+
+func toAPIOccupancy(o *checkin.Occupancy) *Occupancy {
+
+	groups := make([]GroupOccupancy, len(o.Groups))
+	for i, g := range o.Groups {
+		groups[i] = GroupOccupancy{
+			GroupId:  g.GroupID.Ptr(),
+			Name:     g.Name.Ptr(),
+			Count:    g.Count,
+			Capacity: nullIntPtr(g.Capacity),
+		}
+	}
+
+	return &Occupancy{
+		Timestamp:  o.Timestamp,
+		LocationId: o.LocationID.Ptr(),
+		Count:      o.Count,
+		Capacity:   nullIntPtr(o.Capacity),
+		Groups:     groups,
+	}
+}
+
+func toAPIOccupancySeries(series []checkin.OccupancyPoint) []OccupancyPoint {
+
+	result := make([]OccupancyPoint, len(series))
+
+	for i, p := range series {
+		result[i] = OccupancyPoint{Timestamp: p.Timestamp, Count: p.Count}
+	}
+
+	return result
+}
+
+func nullIntPtr(i null.Int) *int {
+	if !i.Valid {
+		return nil
+	}
+	v := int(i.Int64)
+	return &v
+}
+
 func toAPICheckIn(c *checkin.CheckIn) *CheckIn {
 	return &CheckIn{
-		Id:        c.ID,
-		Date:      openapi_types.Date{Time: c.Date},
-		Timestamp: c.Timestamp.Format(time.RFC3339),
-		UserId:    c.UserID,
+		Id:         c.ID,
+		Date:       openapi_types.Date{Time: c.Date},
+		Timestamp:  c.Timestamp.Format(time.RFC3339),
+		UserId:     c.UserID,
+		LocationId: c.LocationID.Ptr(),
+	}
+}
+
+func toAPIRfidCheckIn(c *checkin.RFIDCheckIn) *RfidCheckIn {
+	return &RfidCheckIn{
+		Id:         c.CheckIn.ID,
+		Date:       openapi_types.Date{Time: c.CheckIn.Date},
+		Timestamp:  c.CheckIn.Timestamp.Format(time.RFC3339),
+		UserId:     c.CheckIn.UserID,
+		LocationId: c.CheckIn.LocationID.Ptr(),
+		Display:    toAPICheckInDisplay(&c.Display),
+		Notes:      toAPIAnnouncementsPtr(c.Notes),
+	}
+}
+
+func toAPICheckInDisplay(d *checkin.Display) CheckInDisplay {
+	return CheckInDisplay{
+		Severity:         CheckInDisplaySeverity(d.Severity),
+		Greeting:         d.Greeting,
+		Message:          null.NewString(d.Message, d.Message != "").Ptr(),
+		UserName:         null.NewString(d.UserName, d.UserName != "").Ptr(),
+		VisitsThisMonth:  d.VisitsThisMonth,
+		VisitsThisSeason: d.VisitsThisSeason,
+		StreakWeeks:      d.StreakWeeks,
+		Milestone:        nonZeroPtr(d.Milestone),
+		Sound:            CheckInDisplaySound(d.Sound),
+		Led:              CheckInDisplayLed(d.LED),
 	}
 }
 
+func nonZeroPtr(i int) *int {
+	if i == 0 {
+		return nil
+	}
+	return &i
+}
+
 func toAPICheckIns(checkIns []checkin.CheckIn) []CheckIn {
 
 	result := make([]CheckIn, len(checkIns))
@@ -81,10 +267,11 @@ func toAPICheckIns(checkIns []checkin.CheckIn) []CheckIn {
 
 func toAPICheckInWithUser(c *checkin.WithUser) *CheckInWithUser {
 	return &CheckInWithUser{
-		Id:        c.ID,
-		Date:      openapi_types.Date{Time: c.Date},
-		Timestamp: c.Timestamp.Format(time.RFC3339),
-		UserId:    c.UserID,
+		Id:         c.ID,
+		Date:       openapi_types.Date{Time: c.Date},
+		Timestamp:  c.Timestamp.Format(time.RFC3339),
+		UserId:     c.UserID,
+		LocationId: c.LocationID.Ptr(),
 		User: User{
 			Id:       c.User.ID,
 			Name:     c.User.Name,
@@ -121,6 +308,117 @@ func toAPICheckInsDates(dates []checkin.Date) []CheckInDate {
 	return result
 }
 
+func toAPICheckInHistory(h *selfservice.History) CheckInHistory {
+	return CheckInHistory{
+		CheckIns: toAPICheckIns(h.CheckIns),
+		Statistics: CheckInStatistics{
+			Total:        h.Statistics.Total,
+			ThisMonth:    h.Statistics.ThisMonth,
+			ThisSeason:   h.Statistics.ThisSeason,
+			StreakWeeks:  h.Statistics.StreakWeeks,
+			SeasonStart:  openapi_types.Date{Time: h.Statistics.SeasonStart},
+			FirstCheckIn: h.Statistics.FirstCheckIn.Ptr(),
+			LastCheckIn:  h.Statistics.LastCheckIn.Ptr(),
+		},
+	}
+}
+
+func toAPICards(cards []selfservice.Card) []Card {
+
+	result := make([]Card, len(cards))
+
+	for i, c := range cards {
+		result[i] = Card{
+			RfidUid:        c.RFIDuid,
+			Status:         CardStatus(c.Status),
+			ReportedLostAt: c.ReportedLostAt.Ptr(),
+		}
+	}
+
+	return result
+}
+
+func toAPIMembership(m *selfservice.Membership) Membership {
+	return Membership{
+		MemberId:    m.MemberID.Ptr(),
+		Group:       m.Group.Ptr(),
+		Role:        m.Role,
+		MemberSince: m.MemberSince,
+		Active:      m.Active,
+		LastCheckIn: m.LastCheckIn.Ptr(),
+	}
+}
+
+func toAPIProfile(u *user.User) Profile {
+	return Profile{
+		Name:     u.Name,
+		MemberId: u.MemberID.Ptr(),
+		Group:    u.Group.Ptr(),
+		Email:    u.Email.Ptr(),
+	}
+}
+
+func fromAPIProfileUpdate(p *ProfileUpdate) *selfservice.Profile {
+	return &selfservice.Profile{
+		Email: null.StringFromPtr(p.Email),
+	}
+}
+
+func toAPIPersonalDataExport(e *selfservice.Export) PersonalDataExport {
+	return PersonalDataExport{
+		ExportedAt: e.ExportedAt,
+		User:       *toAPIUser(&e.User),
+		Membership: toAPIMembership(&e.Membership),
+		CheckIns:   toAPICheckIns(e.CheckIns),
+		Cards:      toAPICards(e.Cards),
+		Notes:      toAPIAnnouncements(e.Notes),
+	}
+}
+
+func toAPIAnnouncement(a *announcement.Announcement) *Announcement {
+	return &Announcement{
+		Id:         a.ID,
+		CreatedAt:  a.CreatedAt,
+		Title:      a.Title,
+		Message:    a.Message.Ptr(),
+		ValidFrom:  a.ValidFrom.Ptr(),
+		ValidUntil: a.ValidUntil.Ptr(),
+		Group:      a.Group.Ptr(),
+		UserId:     a.UserID.Ptr(),
+	}
+}
+
+func toAPIAnnouncements(announcements []announcement.Announcement) []Announcement {
+
+	result := make([]Announcement, len(announcements))
+
+	for i, a := range announcements {
+		aa := a
+		result[i] = *toAPIAnnouncement(&aa)
+	}
+
+	return result
+}
+
+func toAPIAnnouncementsPtr(announcements []announcement.Announcement) *[]Announcement {
+	if len(announcements) == 0 {
+		return nil
+	}
+	result := toAPIAnnouncements(announcements)
+	return &result
+}
+
+func fromAPINewAnnouncement(a *NewAnnouncement) *announcement.Announcement {
+	return &announcement.Announcement{
+		Title:      a.Title,
+		Message:    null.StringFromPtr(a.Message),
+		ValidFrom:  null.TimeFromPtr(a.ValidFrom),
+		ValidUntil: null.TimeFromPtr(a.ValidUntil),
+		Group:      null.StringFromPtr(a.Group),
+		UserID:     null.IntFromPtr(a.UserId),
+	}
+}
+
 func toAPIClock(refTimestamp string, c *clock.Clock) *Clock {
 	return &Clock{
 		RefTimestamp: refTimestamp,
@@ -148,6 +446,127 @@ func toAPIWifiStatus(status wifi.Status) WifiStatus {
 	}
 }
 
+func toAPISyncSnapshot(s *edgesync.Snapshot) SyncSnapshot {
+
+	result := SyncSnapshot{
+		Groups:    make([]SyncGroup, len(s.Groups)),
+		Locations: make([]SyncLocation, len(s.Locations)),
+		Users:     make([]SyncUser, len(s.Users)),
+	}
+
+	for i, g := range s.Groups {
+		result.Groups[i] = SyncGroup{
+			Id:          g.ID,
+			Name:        g.Name,
+			Description: g.Description.Ptr(),
+			Colour:      g.Colour.Ptr(),
+			Capacity:    nullIntPtr(g.Capacity),
+			Schedule:    g.Schedule.Ptr(),
+			TrainerIds:  g.TrainerIDs,
+		}
+	}
+
+	for i, l := range s.Locations {
+		result.Locations[i] = SyncLocation{
+			Id:          l.ID,
+			Name:        l.Name,
+			Description: l.Description.Ptr(),
+			Capacity:    nullIntPtr(l.Capacity),
+			ReaderIds:   l.ReaderIDs,
+		}
+	}
+
+	for i, u := range s.Users {
+		result.Users[i] = SyncUser{
+			Id:             u.ID,
+			Name:           u.Name,
+			Role:           u.Role,
+			GroupId:        u.GroupID.Ptr(),
+			MemberId:       u.MemberID.Ptr(),
+			RfidUid:        u.RFIDuid.Ptr(),
+			Email:          u.Email.Ptr(),
+			PasswordDigest: u.PasswordDigest.Ptr(),
+		}
+	}
+
+	return result
+}
+
+func fromAPISyncCheckIns(c *SyncCheckIns) []edgesync.CheckIn {
+
+	result := make([]edgesync.CheckIn, len(c.CheckIns))
+
+	for i, checkIn := range c.CheckIns {
+		result[i] = edgesync.CheckIn{
+			ID:         checkIn.Id,
+			UserID:     checkIn.UserId,
+			Timestamp:  checkIn.Timestamp,
+			LocationID: null.IntFromPtr(checkIn.LocationId),
+		}
+	}
+
+	return result
+}
+
+func toAPISyncCheckInResults(results []edgesync.Result) SyncCheckInResults {
+
+	result := SyncCheckInResults{}
+	result.Results = make([]struct {
+		Id      int64                            `json:"id"`
+		Outcome SyncCheckInResultsResultsOutcome `json:"outcome"`
+	}, len(results))
+
+	for i, r := range results {
+		result.Results[i].Id = r.ID
+		result.Results[i].Outcome = SyncCheckInResultsResultsOutcome(r.Outcome)
+	}
+
+	return result
+}
+
+func toAPISyncStatus(s *edgesync.Status) SyncStatus {
+
+	result := SyncStatus{
+		Enabled:         s.Enabled,
+		UpstreamUrl:     nonEmptyPtr(s.UpstreamURL),
+		PendingCheckIns: s.PendingCheckIns,
+		LastAttemptAt:   s.LastAttemptAt.Ptr(),
+		LastSuccessAt:   s.LastSuccessAt.Ptr(),
+		LastError:       s.LastError.Ptr(),
+	}
+
+	// the counts belong to the last successful sync
+	if s.LastSuccessAt.Valid {
+		result.PushedCheckIns = &s.PushedCheckIns
+		result.PulledUsers = &s.PulledUsers
+	}
+
+	return result
+}
+
+func toAPIDiagnostics(d *health.Diagnostics) Diagnostics {
+
+	result := Diagnostics{
+		Version: VersionInfo{
+			Version:   d.Version,
+			BuildTime: nonEmptyPtr(d.BuildTime),
+			GitCommit: nonEmptyPtr(d.GitCommit),
+		},
+		StartedAt:        d.StartedAt,
+		UptimeSeconds:    int64(d.Uptime.Seconds()),
+		LastCheckInAt:    d.LastCheckInAt.Ptr(),
+		ClockSkewSeconds: int64(d.ClockSkew.Seconds()),
+	}
+
+	result.Database.Driver = d.Driver
+	result.Database.SchemaVersion = d.SchemaVersion
+	result.Database.PendingMigrations = d.PendingMigrations
+	result.Database.SizeBytes = d.DatabaseSize
+	result.Database.RowCounts = d.RowCounts
+
+	return result
+}
+
 func fromAPIRefTimestamp(timestamp string) (time.Time, error) {
 
 	loc, _ := time.LoadLocation("Local")
@@ -159,30 +578,52 @@ func fromAPIRefTimestamp(timestamp string) (time.Time, error) {
 	return val, nil
 }
 
-func generateBearerToken(userID int64) (BearerToken, error) {
+func toAPIJwks(jwks *auth.JWKS) Jwks {
+
+	keys := make([]Jwk, 0, len(jwks.Keys))
+
+	for _, k := range jwks.Keys {
+		keys = append(keys, Jwk{
+			Kty: k.KeyType,
+			Kid: k.KeyID,
+			Alg: k.Algorithm,
+			Use: k.Use,
+			Crv: nonEmptyPtr(k.Curve),
+			X:   nonEmptyPtr(k.X),
+			N:   nonEmptyPtr(k.N),
+			E:   nonEmptyPtr(k.E),
+		})
+	}
+
+	return Jwks{Keys: keys}
+}
+
+func nonEmptyPtr(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func generateBearerToken(ctx context.Context, authService auth.Service, userID int64) (BearerToken, error) {
 
 	var (
 		bearerToken BearerToken
 		err         error
 	)
 
-	bearerToken.Token, err = auth.GenerateToken(userID)
+	bearerToken.Token, err = authService.GenerateToken(ctx, userID)
 	if err != nil {
 		return BearerToken{}, err
 	}
 
-	bearerToken.RefreshToken, err = auth.GenerateRefreshToken(userID)
+	bearerToken.RefreshToken, err = authService.GenerateRefreshToken(ctx, userID)
 	if err != nil {
 		return BearerToken{}, err
 	}
 
-	tokenExpiryMinutesStr := os.Getenv("TOKEN_EXPIRY_MINUTES")
-	if tokenExpiryMinutesStr != "" {
-		if tokenExpiryMinutes, parseErr := strconv.Atoi(tokenExpiryMinutesStr); parseErr == nil {
-			expirySeconds := int((time.Duration(tokenExpiryMinutes) * time.Minute).Seconds())
-			bearerToken.ExpiresIn = &expirySeconds
-		}
-	}
+	expirySeconds := int(authService.TokenExpiry().Seconds())
+	bearerToken.ExpiresIn = &expirySeconds
 
 	return bearerToken, nil
 }
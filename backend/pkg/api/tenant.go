@@ -0,0 +1,35 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/tenant"
+)
+
+// TenantMiddleware resolves the tenant from the subdomain of the request. Requests without subdomain are served
+// in the tenant of their token, or the default tenant if they are not authenticated.
+func TenantMiddleware(tenantService tenant.Service) func(http.Handler) http.Handler {
+
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			t, ok, err := tenantService.ResolveHost(r.Context(), r.Host)
+			if err != nil && errors.Is(err, app.ErrNotFound) {
+				handlerError(w, r, ErrNotFound.Wrap(err))
+				return
+			} else if err != nil {
+				handlerError(w, r, err)
+				return
+			}
+
+			if ok {
+				r = r.WithContext(tenant.WithID(r.Context(), t.ID))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
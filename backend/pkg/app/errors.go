@@ -6,4 +6,10 @@ var ErrNotFound = errors.New("not found")
 
 var ErrConflict = errors.New("conflict")
 
+var ErrInvalid = errors.New("invalid")
+
+var ErrLocked = errors.New("locked")
+
+var ErrCapacityReached = errors.New("capacity reached")
+
 var ErrInternal = errors.New("internal error")
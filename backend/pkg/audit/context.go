@@ -0,0 +1,20 @@
+package audit
+
+import (
+	"context"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+type contextKey struct{}
+
+// WithActorID returns a context for the actions of the authenticated user.
+func WithActorID(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, contextKey{}, userID)
+}
+
+// ActorID returns the id of the authenticated user, if any.
+func ActorID(ctx context.Context) null.Int {
+	id, ok := ctx.Value(contextKey{}).(int64)
+	return null.NewInt(id, ok)
+}
@@ -0,0 +1,23 @@
+package audit
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+const (
+	ActionUserDeleted     = "user_deleted"
+	ActionAllUsersDeleted = "all_users_deleted"
+)
+
+// Entry records an administrative action. The actor and subject are kept as plain ids, so that entries outlive the
+// users they refer to.
+type Entry struct {
+	ID        int64     `db:"id"         json:"id"`
+	TenantID  int64     `db:"tenant_id"  json:"-"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	ActorID   null.Int  `db:"actor_id"   json:"actor_id"`
+	Action    string    `db:"action"     json:"action"`
+	SubjectID null.Int  `db:"subject_id" json:"subject_id"`
+}
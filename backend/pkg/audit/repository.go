@@ -0,0 +1,37 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/tenant"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository interface {
+	SaveEntry(ctx context.Context, entry *Entry) error
+}
+
+type repository struct {
+	db *database.DB
+}
+
+func NewRepo(db *sqlx.DB) Repository {
+	return &repository{database.NewDB(db)}
+}
+
+func (r *repository) SaveEntry(ctx context.Context, entry *Entry) error {
+
+	entry.TenantID = tenant.ID(ctx)
+
+	insertStatement, err := r.db.PrepareNamedContext(ctx, `INSERT INTO audit_log
+			(tenant_id, created_at, actor_id, action, subject_id) VALUES
+			(:tenant_id, :created_at, :actor_id, :action, :subject_id) RETURNING id`)
+	if err != nil {
+		return err
+	}
+	defer insertStatement.Close()
+
+	return database.Classify(insertStatement.QueryRowContext(ctx, entry).Scan(&entry.ID))
+}
@@ -0,0 +1,42 @@
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+type Service interface {
+	// Record writes an audit entry for the action of the authenticated user. Within a transaction, the entry is only
+	// kept if the transaction is committed.
+	Record(ctx context.Context, action string, subjectID null.Int) error
+}
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{repo}
+}
+
+func (s *service) Record(ctx context.Context, action string, subjectID null.Int) error {
+
+	entry := &Entry{
+		CreatedAt: time.Now(),
+		ActorID:   ActorID(ctx),
+		Action:    action,
+		SubjectID: subjectID,
+	}
+
+	if err := s.repo.SaveEntry(ctx, entry); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "audit entry recorded", "event", action, "actor_id", entry.ActorID,
+		"subject_id", entry.SubjectID)
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+)
+
+const (
+	pemHeaderAlgorithm = "Algorithm"
+	pemHeaderCreated   = "Created"
+)
+
+const (
+	keyDirPermissions  = 0o700
+	keyFilePermissions = 0o600
+)
+
+// fileRepository stores every signing key as "<kid>.pem" in a directory, for deployments which
+// should not keep private keys in the database.
+type fileRepository struct {
+	dir string
+}
+
+func NewFileRepo(dir string) Repository {
+	return &fileRepository{dir}
+}
+
+func (r *fileRepository) ListSigningKeys(ctx context.Context) ([]SigningKey, error) {
+
+	paths, err := filepath.Glob(filepath.Join(r.dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]SigningKey, 0, len(paths))
+
+	for _, path := range paths {
+		key, err := r.GetSigningKey(ctx, strings.TrimSuffix(filepath.Base(path), ".pem"))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+
+	slices.SortFunc(keys, func(a, b SigningKey) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+
+	return keys, nil
+}
+
+func (r *fileRepository) GetSigningKey(_ context.Context, id string) (*SigningKey, error) {
+
+	path, err := r.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(path) //nolint:gosec // the file name is validated in path
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, app.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("invalid pem encoding of signing key file %s", path)
+	}
+
+	createdAt, err := time.Parse(time.RFC3339, block.Headers[pemHeaderCreated])
+	if err != nil {
+		return nil, fmt.Errorf("invalid creation time of signing key file %s: %w", path, err)
+	}
+
+	// the headers are not part of the PKCS #8 encoding expected by parseSigningKey
+	return &SigningKey{
+		ID:         id,
+		Algorithm:  block.Headers[pemHeaderAlgorithm],
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: block.Bytes})),
+		CreatedAt:  createdAt,
+	}, nil
+}
+
+func (r *fileRepository) SaveSigningKey(_ context.Context, key *SigningKey) error {
+
+	path, err := r.path(key.ID)
+	if err != nil {
+		return err
+	}
+
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return fmt.Errorf("invalid pem encoding of signing key %s", key.ID)
+	}
+
+	block.Headers = map[string]string{
+		pemHeaderAlgorithm: key.Algorithm,
+		pemHeaderCreated:   key.CreatedAt.UTC().Format(time.RFC3339),
+	}
+
+	if err = os.MkdirAll(r.dir, keyDirPermissions); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, pem.EncodeToMemory(block), keyFilePermissions)
+}
+
+func (r *fileRepository) DeleteSigningKey(_ context.Context, id string) error {
+
+	path, err := r.path(id)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// path returns the file of a key id, which is taken from untrusted token headers.
+func (r *fileRepository) path(id string) (string, error) {
+
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return "", fmt.Errorf("invalid signing key id %q: %w", id, app.ErrNotFound)
+	}
+
+	return filepath.Join(r.dir, id+".pem"), nil
+}
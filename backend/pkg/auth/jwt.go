@@ -1,96 +1,149 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
-	"os"
-	"strconv"
 	"strings"
 	"time"
 
+	"github.com/d-rk/checkin-system/pkg/tenant"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
+const challengeTokenExpiry = 5 * time.Minute
+
+const purposeTOTPChallenge = "totp-challenge"
+
+const (
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
+)
+
 type TokenClaims struct {
 	jwt.RegisteredClaims
 
 	UserID int64 `json:"userId"`
+
+	// TenantID is the tenant of the user. It is not set in tokens issued before multi-tenant mode.
+	TenantID int64 `json:"tenantId,omitempty"`
+
+	// Purpose is only set for tokens which must not be used as bearer token, e.g. login challenges.
+	Purpose string `json:"purpose,omitempty"`
+
+	// Type tells access and refresh tokens apart, so that neither is accepted in place of the other.
+	Type string `json:"typ,omitempty"`
 }
 
 type RefreshTokenClaims struct {
 	jwt.RegisteredClaims
 
 	UserID int64 `json:"userId"`
+
+	TenantID int64 `json:"tenantId,omitempty"`
+
+	Purpose string `json:"purpose,omitempty"`
+
+	Type string `json:"typ,omitempty"`
 }
 
-func GenerateToken(userID int64) (string, error) {
+// Tenant returns the tenant of the token.
+func (c *TokenClaims) Tenant() int64 {
+	return claimedTenant(c.TenantID)
+}
 
-	now := time.Now()
-	tokenExpiryMinutes, err := strconv.Atoi(os.Getenv("TOKEN_EXPIRY_MINUTES"))
+// Tenant returns the tenant of the refresh token.
+func (c *RefreshTokenClaims) Tenant() int64 {
+	return claimedTenant(c.TenantID)
+}
 
-	if err != nil {
-		return "", err
+func claimedTenant(id int64) int64 {
+	if id == 0 {
+		return tenant.DefaultID
 	}
+	return id
+}
+
+func (s *service) GenerateToken(ctx context.Context, userID int64) (string, error) {
+
+	now := time.Now()
 
 	claims := TokenClaims{
-		UserID: userID,
+		UserID:   userID,
+		TenantID: tenant.ID(ctx),
+		Type:     tokenTypeAccess,
 		RegisteredClaims: jwt.RegisteredClaims{
 			IssuedAt:  jwt.NewNumericDate(now),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * time.Duration(tokenExpiryMinutes))),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.tokenExpiry)),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("API_SECRET")))
+	return s.sign(ctx, claims)
 }
 
-// GenerateRefreshToken creates a new refresh token for the given user ID.
-// The refresh token has a longer expiry time than the access token.
-func GenerateRefreshToken(userID int64) (string, error) {
+func (s *service) GenerateRefreshToken(ctx context.Context, userID int64) (string, error) {
 	now := time.Now()
-	refreshTokenExpiryDays := 30 // Default to 30 days if env var not set
 
-	if refreshDaysStr := os.Getenv("REFRESH_TOKEN_EXPIRY_DAYS"); refreshDaysStr != "" {
-		if days, err := strconv.Atoi(refreshDaysStr); err == nil {
-			refreshTokenExpiryDays = days
-		}
+	claims := RefreshTokenClaims{
+		UserID:   userID,
+		TenantID: tenant.ID(ctx),
+		Type:     tokenTypeRefresh,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.refreshTokenExpiry)),
+		},
 	}
 
-	claims := RefreshTokenClaims{
-		UserID: userID,
+	return s.sign(ctx, claims)
+}
+
+func (s *service) GenerateChallengeToken(ctx context.Context, userID int64) (string, error) {
+	now := time.Now()
+
+	claims := TokenClaims{
+		UserID:   userID,
+		TenantID: tenant.ID(ctx),
+		Purpose:  purposeTOTPChallenge,
 		RegisteredClaims: jwt.RegisteredClaims{
 			IssuedAt:  jwt.NewNumericDate(now),
-			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour * 24 * time.Duration(refreshTokenExpiryDays))),
+			ExpiresAt: jwt.NewNumericDate(now.Add(challengeTokenExpiry)),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("API_SECRET")))
+	return s.sign(ctx, claims)
 }
 
-func ValidateRefreshToken(tokenString string) (*RefreshTokenClaims, error) {
-	claims := &RefreshTokenClaims{}
+func (s *service) ValidateChallengeToken(ctx context.Context, tokenString string) (*TokenClaims, error) {
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("API_SECRET")), nil
-	})
+	claims := &TokenClaims{}
+	if _, err := s.parseToken(ctx, tokenString, claims); err != nil {
+		return nil, err
+	}
 
+	if claims.Purpose != purposeTOTPChallenge {
+		return nil, errors.New("not a challenge token")
+	}
+
+	return claims, nil
+}
+
+func (s *service) ValidateRefreshToken(ctx context.Context, tokenString string) (*RefreshTokenClaims, error) {
+
+	claims := &RefreshTokenClaims{}
+
+	token, err := s.parseToken(ctx, tokenString, claims)
 	if err != nil {
 		return nil, err
 	}
 
-	if !token.Valid {
-		return nil, errors.New("refresh token invalid")
+	if claims.Purpose != "" {
+		return nil, fmt.Errorf("unexpected token purpose: %s", claims.Purpose)
 	}
 
-	claims, ok := token.Claims.(*RefreshTokenClaims)
-
-	if !ok {
-		return nil, errors.New("token valid but couldn't parse claims")
+	if err = checkType(token, claims.Type, tokenTypeRefresh); err != nil {
+		return nil, err
 	}
 
 	return claims, nil
@@ -105,16 +158,30 @@ func FindToken(request *http.Request) (string, error) {
 	return "", fmt.Errorf("invalid token: %s", bearer)
 }
 
-func ValidateToken(tokenString string) (*TokenClaims, error) {
+func (s *service) ValidateToken(ctx context.Context, tokenString string) (*TokenClaims, error) {
+
 	claims := &TokenClaims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("API_SECRET")), nil
-	})
+	token, err := s.parseToken(ctx, tokenString, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Purpose != "" {
+		return nil, fmt.Errorf("unexpected token purpose: %s", claims.Purpose)
+	}
+
+	if err = checkType(token, claims.Type, tokenTypeAccess); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
 
+// parseToken verifies the signature and expiry of the token and parses it into the claims.
+func (s *service) parseToken(ctx context.Context, tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.keyFunc(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -123,11 +190,30 @@ func ValidateToken(tokenString string) (*TokenClaims, error) {
 		return nil, errors.New("token invalid")
 	}
 
-	claims, ok := token.Claims.(*TokenClaims)
+	return token, nil
+}
+
+// checkType returns an error if the token is not of the expected type. Legacy HS256 tokens have no type, they are
+// accepted as before until they expire.
+func checkType(token *jwt.Token, tokenType string, expected string) error {
 
-	if !ok {
-		return nil, errors.New("token valid but couldn't parse claims")
+	if tokenType == expected || (tokenType == "" && token.Method == jwt.SigningMethodHS256) {
+		return nil
 	}
 
-	return claims, nil
+	return fmt.Errorf("unexpected token type %q, expected %q", tokenType, expected)
+}
+
+// sign creates a token signed with the current signing key, which is referenced in the "kid" header.
+func (s *service) sign(ctx context.Context, claims jwt.Claims) (string, error) {
+
+	key, err := s.signingKey(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
+
+	return token.SignedString(key.private)
 }
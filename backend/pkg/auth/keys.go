@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgorithmEdDSA = "EdDSA"
+	AlgorithmRS256 = "RS256"
+)
+
+const rsaKeyBits = 3072
+
+const pemTypePrivateKey = "PRIVATE KEY"
+
+// parsedKey is a signing key with its decoded private key.
+type parsedKey struct {
+	id        string
+	method    jwt.SigningMethod
+	private   crypto.Signer
+	createdAt time.Time
+}
+
+func signingMethod(algorithm string) (jwt.SigningMethod, error) {
+	switch algorithm {
+	case AlgorithmEdDSA:
+		return jwt.SigningMethodEdDSA, nil
+	case AlgorithmRS256:
+		return jwt.SigningMethodRS256, nil
+	default:
+		return nil, fmt.Errorf("unsupported token signing algorithm: %s", algorithm)
+	}
+}
+
+func generateSigningKey(algorithm string, now time.Time) (*SigningKey, error) {
+
+	var (
+		private crypto.Signer
+		err     error
+	)
+
+	switch algorithm {
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	default:
+		_, err = signingMethod(algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	jwk, err := publicJWK(private.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &SigningKey{
+		ID:         thumbprint(jwk),
+		Algorithm:  algorithm,
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: pemTypePrivateKey, Bytes: der})),
+		CreatedAt:  now,
+	}, nil
+}
+
+func parseSigningKey(key *SigningKey) (*parsedKey, error) {
+
+	method, err := signingMethod(key.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil || block.Type != pemTypePrivateKey {
+		return nil, fmt.Errorf("invalid pem encoding of signing key %s", key.ID)
+	}
+
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key %s: %w", key.ID, err)
+	}
+
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported signing key type %T", private)
+	}
+
+	return &parsedKey{
+		id:        key.ID,
+		method:    method,
+		private:   signer,
+		createdAt: key.CreatedAt,
+	}, nil
+}
+
+func (k *parsedKey) jwk() (JWK, error) {
+
+	jwk, err := publicJWK(k.private.Public())
+	if err != nil {
+		return JWK{}, err
+	}
+
+	jwk.KeyID = k.id
+	jwk.Algorithm = k.method.Alg()
+	jwk.Use = "sig"
+
+	return jwk, nil
+}
+
+// publicJWK returns the members of the JWK which are required to identify the public key.
+func publicJWK(public crypto.PublicKey) (JWK, error) {
+	switch key := public.(type) {
+	case ed25519.PublicKey:
+		return JWK{
+			KeyType: "OKP",
+			Curve:   "Ed25519",
+			X:       base64.RawURLEncoding.EncodeToString(key),
+		}, nil
+	case *rsa.PublicKey:
+		return JWK{
+			KeyType: "RSA",
+			N:       base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	default:
+		return JWK{}, errors.New("unsupported public key type")
+	}
+}
+
+// thumbprint calculates the JWK thumbprint as described in RFC 7638.
+func thumbprint(jwk JWK) string {
+
+	// encoding/json sorts map keys, which results in the required lexicographic order of the members
+	members := map[string]string{"kty": jwk.KeyType}
+	if jwk.KeyType == "OKP" {
+		members["crv"] = jwk.Curve
+		members["x"] = jwk.X
+	} else {
+		members["n"] = jwk.N
+		members["e"] = jwk.E
+	}
+
+	canonical, _ := json.Marshal(members)
+	digest := sha256.Sum256(canonical)
+
+	return base64.RawURLEncoding.EncodeToString(digest[:])
+}
@@ -0,0 +1,28 @@
+package auth
+
+import "time"
+
+// SigningKey is a private key used to sign tokens, stored PEM encoded in PKCS #8 format.
+// The ID is the RFC 7638 thumbprint of the public key and is set as "kid" header of every token it signs.
+type SigningKey struct {
+	ID         string    `db:"id"`
+	Algorithm  string    `db:"algorithm"`
+	PrivateKey string    `db:"private_key"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// JWK is the public part of a signing key as described in RFC 7517.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	Use       string `json:"use,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
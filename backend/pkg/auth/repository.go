@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/d-rk/checkin-system/pkg/database"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository interface {
+	ListSigningKeys(ctx context.Context) ([]SigningKey, error)
+	SaveSigningKey(ctx context.Context, key *SigningKey) error
+	DeleteSigningKey(ctx context.Context, id string) error
+}
+
+type repository struct {
+	db *database.DB
+}
+
+func NewRepo(db *sqlx.DB) Repository {
+	return &repository{database.NewDB(db)}
+}
+
+func (r *repository) ListSigningKeys(ctx context.Context) ([]SigningKey, error) {
+
+	keys := []SigningKey{}
+
+	if err := r.db.SelectContext(ctx, &keys, "SELECT * FROM signing_keys ORDER BY created_at DESC"); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (r *repository) SaveSigningKey(ctx context.Context, key *SigningKey) error {
+
+	insertStatement, err := r.db.PrepareNamedContext(ctx, `INSERT INTO signing_keys
+			(id, algorithm, private_key, created_at) VALUES
+			(:id, :algorithm, :private_key, :created_at)`)
+	if err != nil {
+		return err
+	}
+	defer insertStatement.Close()
+
+	_, err = insertStatement.ExecContext(ctx, key)
+	return err
+}
+
+func (r *repository) DeleteSigningKey(ctx context.Context, id string) error {
+
+	deleteStatement, err := r.db.PreparexContext(ctx, `DELETE FROM signing_keys WHERE id = $1`)
+	if err != nil {
+		return err
+	}
+	defer deleteStatement.Close()
+
+	_, err = deleteStatement.ExecContext(ctx, id)
+	return err
+}
@@ -0,0 +1,298 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Service issues and validates the tokens of the api.
+//
+// Tokens are signed with the newest signing key, which is replaced once it is older than the rotation interval.
+// Previous keys stay valid for verification until every token they signed has expired.
+type Service interface {
+	GenerateToken(ctx context.Context, userID int64) (string, error)
+	// GenerateRefreshToken creates a new refresh token for the given user ID.
+	// The refresh token has a longer expiry time than the access token.
+	GenerateRefreshToken(ctx context.Context, userID int64) (string, error)
+	// GenerateChallengeToken creates a short-lived token proving that the password of the user was verified.
+	// It can only be exchanged for a bearer token together with a valid second factor.
+	GenerateChallengeToken(ctx context.Context, userID int64) (string, error)
+	ValidateToken(ctx context.Context, tokenString string) (*TokenClaims, error)
+	ValidateRefreshToken(ctx context.Context, tokenString string) (*RefreshTokenClaims, error)
+	ValidateChallengeToken(ctx context.Context, tokenString string) (*TokenClaims, error)
+	// JWKS returns the public keys of all signing keys which can still verify tokens.
+	JWKS(ctx context.Context) (*JWKS, error)
+	// RotateKeys creates a new signing key and deletes keys which can no longer have signed a valid token.
+	RotateKeys(ctx context.Context) error
+	// TokenExpiry returns how long the bearer tokens are valid.
+	TokenExpiry() time.Duration
+}
+
+// keyLookupInterval limits the repository lookups of unknown key ids, so that tokens with made up key ids cannot cause
+// a query per request.
+const keyLookupInterval = 10 * time.Second
+
+type service struct {
+	repo               Repository
+	algorithm          string
+	rotationInterval   time.Duration
+	tokenExpiry        time.Duration
+	refreshTokenExpiry time.Duration
+	// legacySecret verifies HS256 tokens issued before the introduction of signing keys.
+	legacySecret []byte
+	now          func() time.Time
+
+	mu   sync.RWMutex
+	keys []*parsedKey // newest first
+
+	rotateMu sync.Mutex
+
+	lookupMu   sync.Mutex
+	lastLookup time.Time
+}
+
+// NewService creates a token service using the keys of the repository.
+//
+// New keys use the configured signing algorithm (EdDSA or RS256) and are rotated every KeyRotationDays. If a
+// legacy secret is configured, HS256 tokens signed with it are still accepted.
+func NewService(repo Repository, cfg config.Auth) (Service, error) {
+
+	if _, err := signingMethod(cfg.SigningAlgorithm); err != nil {
+		return nil, err
+	}
+
+	s := &service{
+		repo:               repo,
+		algorithm:          cfg.SigningAlgorithm,
+		rotationInterval:   time.Duration(cfg.KeyRotationDays) * 24 * time.Hour,
+		tokenExpiry:        time.Duration(cfg.TokenExpiryMinutes) * time.Minute,
+		refreshTokenExpiry: time.Duration(cfg.RefreshTokenExpiryDays) * 24 * time.Hour,
+		now:                time.Now,
+	}
+
+	if cfg.LegacySecret != "" {
+		s.legacySecret = []byte(cfg.LegacySecret)
+	}
+
+	return s, nil
+}
+
+func (s *service) TokenExpiry() time.Duration {
+	return s.tokenExpiry
+}
+
+func (s *service) JWKS(ctx context.Context) (*JWKS, error) {
+
+	keys, err := s.loadKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	jwks := &JWKS{Keys: make([]JWK, 0, len(keys))}
+
+	for _, key := range keys {
+		jwk, err := key.jwk()
+		if err != nil {
+			return nil, err
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks, nil
+}
+
+func (s *service) RotateKeys(ctx context.Context) error {
+
+	now := s.now()
+
+	key, err := generateSigningKey(s.algorithm, now)
+	if err != nil {
+		return err
+	}
+
+	if err = s.repo.SaveSigningKey(ctx, key); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "signing key created", "event", "signing_key_rotation", "kid", key.ID,
+		"algorithm", key.Algorithm)
+
+	keys, err := s.repo.ListSigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	// a key stops signing when its successor is created, and its tokens expire at most one
+	// refresh token lifetime later
+	for i := 1; i < len(keys); i++ {
+		if now.Sub(keys[i-1].CreatedAt) <= s.refreshTokenExpiry {
+			continue
+		}
+
+		if err = s.repo.DeleteSigningKey(ctx, keys[i].ID); err != nil {
+			return err
+		}
+
+		slog.InfoContext(ctx, "signing key deleted", "event", "signing_key_rotation", "kid", keys[i].ID)
+	}
+
+	_, err = s.loadKeys(ctx)
+	return err
+}
+
+// loadKeys reads all keys of the repository into the cache.
+func (s *service) loadKeys(ctx context.Context) ([]*parsedKey, error) {
+
+	signingKeys, err := s.repo.ListSigningKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*parsedKey, 0, len(signingKeys))
+
+	for i := range signingKeys {
+		key, err := parseSigningKey(&signingKeys[i])
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+
+	return keys, nil
+}
+
+// signingKey returns the newest key, creating a new one if it is due for rotation.
+func (s *service) signingKey(ctx context.Context) (*parsedKey, error) {
+
+	s.mu.RLock()
+	keys := s.keys
+	s.mu.RUnlock()
+
+	if key := s.currentKey(keys); key != nil {
+		return key, nil
+	}
+
+	s.rotateMu.Lock()
+	defer s.rotateMu.Unlock()
+
+	// another request or server instance might have created a new key in the meantime
+	keys, err := s.loadKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if key := s.currentKey(keys); key != nil {
+		return key, nil
+	}
+
+	if err = s.RotateKeys(ctx); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.keys[0], nil
+}
+
+func (s *service) currentKey(keys []*parsedKey) *parsedKey {
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	key := keys[0]
+	if key.method.Alg() != s.algorithm || s.now().Sub(key.createdAt) >= s.rotationInterval {
+		return nil
+	}
+
+	return key
+}
+
+// verificationKey returns the key with the given id, reloading the keys of the repository if it was created by
+// another server instance. Unknown ids reload the keys at most once per keyLookupInterval.
+func (s *service) verificationKey(ctx context.Context, id string) (*parsedKey, error) {
+
+	s.mu.RLock()
+	keys := s.keys
+	s.mu.RUnlock()
+
+	if key := findKey(keys, id); key != nil {
+		return key, nil
+	}
+
+	if !s.allowLookup() {
+		return nil, fmt.Errorf("unknown signing key %q", id)
+	}
+
+	keys, err := s.loadKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if key := findKey(keys, id); key != nil {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unknown signing key %q", id)
+}
+
+func findKey(keys []*parsedKey, id string) *parsedKey {
+	for _, key := range keys {
+		if key.id == id {
+			return key
+		}
+	}
+	return nil
+}
+
+// allowLookup returns true if the last lookup of an unknown key id is at least keyLookupInterval ago.
+func (s *service) allowLookup() bool {
+
+	s.lookupMu.Lock()
+	defer s.lookupMu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastLookup) < keyLookupInterval {
+		return false
+	}
+
+	s.lastLookup = now
+	return true
+}
+
+func (s *service) keyFunc(ctx context.Context) jwt.Keyfunc {
+	return func(token *jwt.Token) (any, error) {
+
+		kid, _ := token.Header["kid"].(string)
+
+		if kid == "" {
+			if s.legacySecret != nil && token.Method == jwt.SigningMethodHS256 {
+				return s.legacySecret, nil
+			}
+			return nil, errors.New("token without key id")
+		}
+
+		key, err := s.verificationKey(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return key.private.Public(), nil
+	}
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestService(t *testing.T, algorithm string) (*service, *time.Time) {
+
+	now := time.Now()
+	return &service{
+		repo:               NewFileRepo(t.TempDir()),
+		algorithm:          algorithm,
+		rotationInterval:   30 * 24 * time.Hour,
+		tokenExpiry:        time.Hour,
+		refreshTokenExpiry: 30 * 24 * time.Hour,
+		now:                func() time.Time { return now },
+	}, &now
+}
+
+func TestGenerateAndValidateToken(t *testing.T) {
+
+	for _, algorithm := range []string{AlgorithmEdDSA, AlgorithmRS256} {
+		t.Run(algorithm, func(t *testing.T) {
+
+			ctx := context.Background()
+			s, _ := newTestService(t, algorithm)
+
+			token, err := s.GenerateToken(ctx, 42)
+			require.NoError(t, err)
+
+			claims, err := s.ValidateToken(ctx, token)
+			require.NoError(t, err)
+			assert.Equal(t, int64(42), claims.UserID)
+
+			_, err = s.ValidateRefreshToken(ctx, token)
+			require.Error(t, err, "access tokens are no refresh tokens")
+
+			refreshToken, err := s.GenerateRefreshToken(ctx, 42)
+			require.NoError(t, err)
+
+			refreshClaims, err := s.ValidateRefreshToken(ctx, refreshToken)
+			require.NoError(t, err)
+			assert.Equal(t, int64(42), refreshClaims.UserID)
+
+			_, err = s.ValidateToken(ctx, refreshToken)
+			require.Error(t, err, "refresh tokens are no bearer tokens")
+
+			challenge, err := s.GenerateChallengeToken(ctx, 42)
+			require.NoError(t, err)
+
+			_, err = s.ValidateToken(ctx, challenge)
+			require.Error(t, err, "challenge tokens are no bearer tokens")
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+
+	ctx := context.Background()
+	s, now := newTestService(t, AlgorithmEdDSA)
+
+	oldToken, err := s.GenerateToken(ctx, 1)
+	require.NoError(t, err)
+
+	*now = now.Add(s.rotationInterval)
+
+	newToken, err := s.GenerateToken(ctx, 1)
+	require.NoError(t, err)
+
+	oldKid := tokenKeyID(t, oldToken)
+	assert.NotEqual(t, oldKid, tokenKeyID(t, newToken), "expired signing key is rotated")
+
+	// a second instance sharing the repository verifies tokens of both keys
+	other := &service{repo: s.repo, algorithm: AlgorithmEdDSA, now: s.now}
+	_, err = other.ValidateToken(ctx, oldToken)
+	require.NoError(t, err)
+	_, err = other.ValidateToken(ctx, newToken)
+	require.NoError(t, err)
+
+	jwks, err := s.JWKS(ctx)
+	require.NoError(t, err)
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "OKP", jwks.Keys[0].KeyType)
+	assert.Equal(t, oldKid, jwks.Keys[1].KeyID)
+
+	*now = now.Add(s.refreshTokenExpiry + time.Second)
+	require.NoError(t, s.RotateKeys(ctx))
+
+	jwks, err = s.JWKS(ctx)
+	require.NoError(t, err)
+	require.Len(t, jwks.Keys, 2, "key replaced longer than the token lifetime ago is deleted")
+	assert.NotEqual(t, oldKid, jwks.Keys[1].KeyID)
+}
+
+func TestLegacySecret(t *testing.T) {
+
+	ctx := context.Background()
+	s, _ := newTestService(t, AlgorithmEdDSA)
+
+	legacyToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, TokenClaims{UserID: 7}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	_, err = s.ValidateToken(ctx, legacyToken)
+	require.Error(t, err, "legacy tokens are rejected without API_SECRET")
+
+	s.legacySecret = []byte("secret")
+
+	claims, err := s.ValidateToken(ctx, legacyToken)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), claims.UserID)
+}
+
+func TestUnknownKeyID_IsLookedUpOncePerInterval(t *testing.T) {
+
+	ctx := context.Background()
+	s, now := newTestService(t, AlgorithmEdDSA)
+	repo := &countingRepo{Repository: s.repo}
+	s.repo = repo
+
+	for _, kid := range []string{"unknown-1", "unknown-2", "unknown-3"} {
+		token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, TokenClaims{UserID: 1, Type: tokenTypeAccess})
+		token.Header["kid"] = kid
+		tokenString, err := token.SignedString(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)))
+		require.NoError(t, err)
+
+		_, err = s.ValidateToken(ctx, tokenString)
+		require.Error(t, err)
+	}
+
+	assert.Equal(t, 1, repo.lookups)
+
+	*now = now.Add(keyLookupInterval)
+
+	// keys of other instances are found once the interval passed
+	other := &service{repo: s.repo, algorithm: AlgorithmEdDSA, rotationInterval: s.rotationInterval,
+		tokenExpiry: time.Hour, now: s.now}
+	token, err := other.GenerateToken(ctx, 1)
+	require.NoError(t, err)
+	repo.lookups = 0
+
+	_, err = s.ValidateToken(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, 1, repo.lookups)
+}
+
+// countingRepo counts the reads of the signing keys.
+type countingRepo struct {
+	Repository
+
+	lookups int
+}
+
+func (r *countingRepo) ListSigningKeys(ctx context.Context) ([]SigningKey, error) {
+	r.lookups++
+	return r.Repository.ListSigningKeys(ctx)
+}
+
+func tokenKeyID(t *testing.T, tokenString string) string {
+
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, &TokenClaims{})
+	require.NoError(t, err)
+
+	kid, _ := token.Header["kid"].(string)
+	return kid
+}
@@ -0,0 +1,127 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/d-rk/checkin-system/pkg/database"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+)
+
+type Repository interface {
+	// Driver returns the name of the database driver.
+	Driver() string
+	// SchemaVersion returns the last applied migration of the database.
+	SchemaVersion(ctx context.Context) (string, error)
+	// BackupTo copies a consistent snapshot of the database into the sqlite file at the path.
+	BackupTo(ctx context.Context, path string) error
+	// RestoreFrom replaces the content of the database with the sqlite file at the path.
+	RestoreFrom(ctx context.Context, path string) error
+	// Inspect checks the integrity of the sqlite file at the path and returns its last applied migration.
+	Inspect(ctx context.Context, path string) (string, error)
+}
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepo(db *sqlx.DB) Repository {
+	return &repository{db}
+}
+
+func (r *repository) Driver() string {
+	return r.db.DriverName()
+}
+
+func (r *repository) SchemaVersion(_ context.Context) (string, error) {
+	return database.SchemaVersion(r.db)
+}
+
+func (r *repository) BackupTo(ctx context.Context, path string) error {
+
+	file, err := sqlx.Open("sqlite3", fmt.Sprintf("file:%s?_loc=UTC", path))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return copyDatabase(ctx, file.DB, r.db.DB)
+}
+
+func (r *repository) RestoreFrom(ctx context.Context, path string) error {
+
+	file, err := sqlx.Open("sqlite3", fmt.Sprintf("file:%s?_loc=UTC&mode=ro", path))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return copyDatabase(ctx, r.db.DB, file.DB)
+}
+
+func (r *repository) Inspect(ctx context.Context, path string) (string, error) {
+
+	file, err := sqlx.Open("sqlite3", fmt.Sprintf("file:%s?_loc=UTC&mode=ro", path))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	var integrity string
+	if err = file.GetContext(ctx, &integrity, "PRAGMA integrity_check"); err != nil {
+		return "", err
+	}
+
+	if integrity != "ok" {
+		return "", fmt.Errorf("integrity check failed: %s", integrity)
+	}
+
+	return database.SchemaVersion(file)
+}
+
+// copyDatabase copies the pages of the source database into the destination with the sqlite online backup api.
+func copyDatabase(ctx context.Context, dest *sql.DB, src *sql.DB) error {
+
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return destConn.Raw(func(destDriverConn any) error {
+		return srcConn.Raw(func(srcDriverConn any) error {
+
+			destSqlite, ok := database.DriverConn(destDriverConn).(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("destination is no sqlite3 connection")
+			}
+
+			srcSqlite, ok := database.DriverConn(srcDriverConn).(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("source is no sqlite3 connection")
+			}
+
+			backup, err := destSqlite.Backup("main", srcSqlite, "main")
+			if err != nil {
+				return err
+			}
+
+			if _, err = backup.Step(-1); err != nil {
+				_ = backup.Finish()
+				return err
+			}
+
+			return backup.Finish()
+		})
+	})
+}
@@ -0,0 +1,238 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/d-rk/checkin-system/pkg/jobs"
+)
+
+const (
+	// ContentType is the media type of the backups.
+	ContentType = "application/vnd.sqlite3"
+
+	filePrefix           = "checkin-system-"
+	preRestoreFilePrefix = "checkin-system-pre-restore-"
+	fileSuffix           = ".db"
+	fileTimeFormat       = "20060102T150405Z"
+)
+
+type Service interface {
+	// Snapshot returns a consistent copy of the database as sqlite file. The file is removed when it is closed.
+	Snapshot(ctx context.Context) (io.ReadCloser, error)
+	// Restore replaces the content of the database with the sqlite file, if it is intact and has the same schema
+	// version as the database.
+	Restore(ctx context.Context, backup io.Reader) error
+	// CreateLocalBackup writes a backup to the backup dir and removes the backups exceeding the generations.
+	CreateLocalBackup(ctx context.Context) (string, error)
+	// Job creates a local backup every interval. It is nil if no backup dir is configured.
+	Job() *jobs.Job
+}
+
+type service struct {
+	repo        Repository
+	dir         string
+	interval    time.Duration
+	generations int
+	now         func() time.Time
+}
+
+func NewService(repo Repository, cfg config.Backup) Service {
+	return &service{
+		repo:        repo,
+		dir:         cfg.Dir,
+		interval:    time.Duration(cfg.IntervalHours) * time.Hour,
+		generations: cfg.Generations,
+		now:         time.Now,
+	}
+}
+
+func (s *service) Snapshot(ctx context.Context) (io.ReadCloser, error) {
+
+	if err := s.checkDriver(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp("", "checkin-system-snapshot-*.db")
+	if err != nil {
+		return nil, err
+	}
+	_ = file.Close()
+
+	if err = s.repo.BackupTo(ctx, file.Name()); err != nil {
+		_ = os.Remove(file.Name())
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	snapshot, err := os.Open(file.Name())
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return nil, err
+	}
+
+	return &tempFile{snapshot}, nil
+}
+
+func (s *service) Restore(ctx context.Context, backup io.Reader) error {
+
+	if err := s.checkDriver(); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp("", "checkin-system-restore-*.db")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = io.Copy(file, backup)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to receive backup: %w", err)
+	}
+
+	backupVersion, err := s.repo.Inspect(ctx, file.Name())
+	if err != nil {
+		return fmt.Errorf("backup is no valid database: %w: %w", err, app.ErrInvalid)
+	}
+
+	version, err := s.repo.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	if backupVersion != version {
+		return fmt.Errorf("backup has schema version %q, but the database has %q: %w", backupVersion, version,
+			app.ErrInvalid)
+	}
+
+	// the current data is kept, in case the wrong backup is restored
+	if s.dir != "" {
+		if _, err = s.backupToDir(ctx, preRestoreFilePrefix); err != nil {
+			return fmt.Errorf("failed to back up the database before the restore: %w", err)
+		}
+	}
+
+	if err = s.repo.RestoreFrom(ctx, file.Name()); err != nil {
+		return fmt.Errorf("failed to restore backup: %w", err)
+	}
+
+	slog.InfoContext(ctx, "backup restored", "event", "backup_restored", "schema_version", version)
+
+	return nil
+}
+
+func (s *service) CreateLocalBackup(ctx context.Context) (string, error) {
+
+	if err := s.checkDriver(); err != nil {
+		return "", err
+	}
+
+	if s.dir == "" {
+		return "", fmt.Errorf("no BACKUP_DIR configured: %w", app.ErrInvalid)
+	}
+
+	path, err := s.backupToDir(ctx, filePrefix)
+	if err != nil {
+		return "", err
+	}
+
+	slog.InfoContext(ctx, "backup created", "event", "backup_created", "path", path)
+
+	return path, s.removeOldBackups(ctx)
+}
+
+func (s *service) Job() *jobs.Job {
+
+	if s.dir == "" || s.repo.Driver() != "sqlite3" {
+		return nil
+	}
+
+	return &jobs.Job{
+		Name:     "backup",
+		Interval: s.interval,
+		Run: func(ctx context.Context) error {
+			_, err := s.CreateLocalBackup(ctx)
+			return err
+		},
+	}
+}
+
+func (s *service) checkDriver() error {
+
+	if driver := s.repo.Driver(); driver != "sqlite3" {
+		return fmt.Errorf("backups of %s databases are not supported: %w", driver, errors.ErrUnsupported)
+	}
+
+	return nil
+}
+
+func (s *service) backupToDir(ctx context.Context, prefix string) (string, error) {
+
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(s.dir, prefix+s.now().UTC().Format(fileTimeFormat)+fileSuffix)
+
+	if err := s.repo.BackupTo(ctx, path); err != nil {
+		_ = os.Remove(path)
+		return "", err
+	}
+
+	return path, nil
+}
+
+// removeOldBackups removes the scheduled backups exceeding the generations to keep, the oldest first.
+func (s *service) removeOldBackups(ctx context.Context) error {
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, filePrefix) && strings.HasSuffix(name, fileSuffix) &&
+			!strings.HasPrefix(name, preRestoreFilePrefix) {
+			backups = append(backups, name)
+		}
+	}
+
+	// the names contain the time of the backup, so they sort chronologically
+	slices.Sort(backups)
+
+	for len(backups) > s.generations {
+		if err = os.Remove(filepath.Join(s.dir, backups[0])); err != nil {
+			return err
+		}
+		slog.InfoContext(ctx, "old backup removed", "name", backups[0])
+		backups = backups[1:]
+	}
+
+	return nil
+}
+
+// tempFile is a file which is removed when it is closed.
+type tempFile struct {
+	*os.File
+}
+
+func (f *tempFile) Close() error {
+	err := f.File.Close()
+	_ = os.Remove(f.Name())
+	return err
+}
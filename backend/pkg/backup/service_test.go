@@ -0,0 +1,163 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/database"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// migratedDB returns a sqlite database with at most limit migrations applied, all of them if limit is 0.
+func migratedDB(t *testing.T, limit int) *sqlx.DB {
+	t.Helper()
+
+	db, err := sqlx.Connect("sqlite3", fmt.Sprintf("file:%s?_loc=UTC&_fk=1", filepath.Join(t.TempDir(), "checkin.db")))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	_, err = database.MigrateUp(context.Background(), db, limit)
+	require.NoError(t, err)
+
+	return db
+}
+
+func userNames(t *testing.T, db *sqlx.DB) []string {
+	t.Helper()
+
+	var names []string
+	require.NoError(t, db.Select(&names, "SELECT name FROM users WHERE role = 'USER' ORDER BY name"))
+	return names
+}
+
+func snapshot(t *testing.T, s Service) []byte {
+	t.Helper()
+
+	file, err := s.Snapshot(context.Background())
+	require.NoError(t, err)
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	require.NoError(t, err)
+	return data
+}
+
+func newTestService(db *sqlx.DB, dir string) *service {
+	return &service{repo: NewRepo(db), dir: dir, generations: 2, now: time.Now}
+}
+
+func TestSnapshot_Restore_ReplacesData(t *testing.T) {
+
+	ctx := context.Background()
+	db := migratedDB(t, 0)
+	s := newTestService(db, t.TempDir())
+
+	_, err := db.Exec(`INSERT INTO users (created_at, name, role) VALUES (current_timestamp, 'alice', 'USER')`)
+	require.NoError(t, err)
+
+	data := snapshot(t, s)
+
+	_, err = db.Exec(`DELETE FROM users`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO users (created_at, name, role) VALUES (current_timestamp, 'bob', 'USER')`)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Restore(ctx, bytes.NewReader(data)))
+
+	assert.Equal(t, []string{"alice"}, userNames(t, db))
+
+	// the replaced data is kept in a backup
+	entries, err := os.ReadDir(s.dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.True(t, strings.HasPrefix(entries[0].Name(), preRestoreFilePrefix))
+}
+
+func TestRestore_InvalidBackup_ReturnsInvalidErr(t *testing.T) {
+
+	ctx := context.Background()
+	db := migratedDB(t, 0)
+
+	_, err := db.Exec(`INSERT INTO users (created_at, name, role) VALUES (current_timestamp, 'alice', 'USER')`)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		backup func(t *testing.T) []byte
+	}{
+		{
+			name: "no database",
+			backup: func(_ *testing.T) []byte {
+				return []byte("this is not a database")
+			},
+		},
+		{
+			name: "other schema version",
+			backup: func(t *testing.T) []byte {
+				return snapshot(t, newTestService(migratedDB(t, 13), ""))
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := newTestService(db, "").Restore(ctx, bytes.NewReader(tc.backup(t)))
+			require.ErrorIs(t, err, app.ErrInvalid)
+			assert.Equal(t, []string{"alice"}, userNames(t, db))
+		})
+	}
+}
+
+func TestCreateLocalBackup_KeepsGenerations(t *testing.T) {
+
+	ctx := context.Background()
+	s := newTestService(migratedDB(t, 0), t.TempDir())
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	var paths []string
+	for i := 0; i < 3; i++ {
+		path, err := s.CreateLocalBackup(ctx)
+		require.NoError(t, err)
+		paths = append(paths, path)
+		now = now.Add(time.Hour)
+	}
+
+	assert.NoFileExists(t, paths[0])
+	assert.FileExists(t, paths[1])
+	assert.FileExists(t, paths[2])
+	assert.Equal(t, "checkin-system-20240301T140000Z.db", filepath.Base(paths[2]))
+
+	version, err := s.repo.Inspect(ctx, paths[2])
+	require.NoError(t, err)
+	assert.Equal(t, "0016-foreign-keys.sql", version)
+}
+
+func TestSnapshot_Postgres_ReturnsUnsupportedErr(t *testing.T) {
+
+	s := &service{repo: postgresRepo{}}
+
+	_, err := s.Snapshot(context.Background())
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+}
+
+type postgresRepo struct {
+	Repository
+}
+
+func (postgresRepo) Driver() string {
+	return "postgres"
+}
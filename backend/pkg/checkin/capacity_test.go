@@ -0,0 +1,157 @@
+//go:build integration
+
+package checkin
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/announcement"
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/group"
+	"github.com/d-rk/checkin-system/pkg/internal/testutil"
+	"github.com/d-rk/checkin-system/pkg/location"
+	"github.com/d-rk/checkin-system/pkg/user"
+	"github.com/d-rk/checkin-system/pkg/websocket"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+// newDBService creates the service with the repositories and services of the database.
+func newDBService(t *testing.T, db *sqlx.DB, cfg config.Checkin) Service {
+	t.Helper()
+
+	ws := &websocket.Server{}
+
+	return NewService(NewRepo(db), testutil.UserService(t, db, config.Users{}),
+		announcement.NewService(announcement.NewRepo(db), ws), group.NewService(group.NewRepo(db)),
+		location.NewService(location.NewRepo(db)), ws, database.NewDB(db), cfg)
+}
+
+func saveCardHolders(t *testing.T, db *sqlx.DB, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		_, err := user.NewRepo(db).SaveUser(context.Background(), &user.User{Name: name, Role: user.RoleUser,
+			RFIDuid: null.StringFrom(name)})
+		require.NoError(t, err)
+	}
+}
+
+func TestCreateCheckInForRFID_ConcurrentTaps_TakeTheLastPlaceOnce(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		cfg := config.Default().Checkin
+		cfg.OccupancyCapacity = 1
+		s := newDBService(t, db, cfg)
+
+		names := []string{"Alice", "Bob", "Carol", "Dave"}
+		saveCardHolders(t, db, names...)
+
+		timestamp := time.Now()
+		errs := make(chan error, len(names))
+
+		var wg sync.WaitGroup
+		for _, name := range names {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := s.CreateCheckInForRFID(ctx, name, "", &timestamp)
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		checkedIn := 0
+		for err := range errs {
+			if err == nil {
+				checkedIn++
+			} else {
+				require.ErrorIs(t, err, app.ErrCapacityReached)
+			}
+		}
+		assert.Equal(t, 1, checkedIn)
+	})
+}
+
+func TestCreateCheckInForRFID_PresentAtOtherLocation_IsRejectedWhenFull(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s := newDBService(t, db, config.Default().Checkin)
+		saveCardHolders(t, db, "Alice", "Bob")
+
+		locationService := location.NewService(location.NewRepo(db))
+		for name, reader := range map[string]string{"Hall": "hall", "Gym": "gym"} {
+			l, err := locationService.CreateLocation(ctx, &location.Location{Name: name, Capacity: null.IntFrom(1)})
+			require.NoError(t, err)
+			require.NoError(t, locationService.SetReaderIDs(ctx, l.ID, []string{reader}))
+		}
+
+		// Alice is still present in the gym after midnight, when the hall is already full
+		midnight := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+		for _, tap := range []struct {
+			rfid   string
+			reader string
+			at     time.Time
+		}{
+			{"Alice", "gym", midnight.Add(-30 * time.Minute)},
+			{"Bob", "hall", midnight.Add(10 * time.Minute)},
+		} {
+			_, err := s.CreateCheckInForRFID(ctx, tap.rfid, tap.reader, &tap.at)
+			require.NoError(t, err)
+		}
+
+		timestamp := midnight.Add(20 * time.Minute)
+		_, err := s.CreateCheckInForRFID(ctx, "Alice", "hall", &timestamp)
+		require.ErrorIs(t, err, app.ErrCapacityReached)
+	})
+}
+
+func TestCreateCheckInForRFID_RejectedTaps_ReturnTheDisplay(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		cfg := config.Default().Checkin
+		cfg.OccupancyCapacity = 1
+		s := newDBService(t, db, cfg)
+		saveCardHolders(t, db, "Alice", "Bob")
+
+		timestamp := time.Now()
+
+		_, err := s.CreateCheckInForRFID(ctx, "Alice", "", &timestamp)
+		require.NoError(t, err)
+
+		for _, tt := range []struct {
+			rfid     string
+			err      error
+			severity Severity
+			greeting string
+		}{
+			{rfid: "Alice", err: app.ErrConflict, severity: SeverityWarning, greeting: "Hello Alice!"},
+			{rfid: "Bob", err: app.ErrCapacityReached, severity: SeverityError, greeting: "Sorry Bob!"},
+			{rfid: "Carol", err: app.ErrNotFound, severity: SeverityError, greeting: "Unknown card"},
+		} {
+			c, err := s.CreateCheckInForRFID(ctx, tt.rfid, "", &timestamp)
+			require.ErrorIs(t, err, tt.err, tt.rfid)
+			require.NotNil(t, c, tt.rfid)
+			assert.Nil(t, c.CheckIn, tt.rfid)
+			assert.Equal(t, tt.severity, c.Display.Severity, tt.rfid)
+			assert.Equal(t, tt.greeting, c.Display.Greeting, tt.rfid)
+		}
+	})
+}
@@ -0,0 +1,170 @@
+package checkin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/user"
+	"gopkg.in/guregu/null.v4"
+)
+
+const daysInWeek = 7
+
+type Severity string
+
+const (
+	SeverityOK      Severity = "ok"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+type Sound string
+
+const (
+	SoundShortBeep  Sound = "short-beep"
+	SoundDoubleBeep Sound = "double-beep"
+	SoundLongBeep   Sound = "long-beep"
+)
+
+type LEDPattern string
+
+const (
+	LEDGreenBlink     LEDPattern = "green-blink"
+	LEDAlternateBlink LEDPattern = "alternate-blink"
+	LEDRedBlink       LEDPattern = "red-blink"
+)
+
+// milestones are the total numbers of trainings which get a special message on the kiosk.
+// All of them take the "th" suffix.
+var milestones = []int{10, 25, 50, 100, 150, 200, 250, 300}
+
+// Display is the feedback shown by a kiosk or reader after a tap.
+type Display struct {
+	Severity         Severity   `json:"severity"`
+	Greeting         string     `json:"greeting"`
+	Message          string     `json:"message,omitempty"`
+	UserName         string     `json:"user_name,omitempty"`
+	VisitsThisMonth  int        `json:"visits_this_month"`
+	VisitsThisSeason int        `json:"visits_this_season"`
+	StreakWeeks      int        `json:"streak_weeks"`
+	Milestone        int        `json:"milestone,omitempty"`
+	Sound            Sound      `json:"sound"`
+	LED              LEDPattern `json:"led"`
+}
+
+func unknownRFIDDisplay() Display {
+	return Display{
+		Severity: SeverityError,
+		Greeting: "Unknown card",
+		Message:  "please contact a trainer",
+		Sound:    SoundLongBeep,
+		LED:      LEDRedBlink,
+	}
+}
+
+// newDisplay builds the feedback for a user based on all of their checkIns (including the current one).
+func newDisplay(u *user.User, checkIns []CheckIn, now time.Time, seasonStart time.Month) Display {
+
+	display := Display{
+		Severity: SeverityOK,
+		Greeting: fmt.Sprintf("Hello %s!", u.Name),
+		UserName: u.Name,
+		Sound:    SoundShortBeep,
+		LED:      LEDGreenBlink,
+	}
+
+	statistics := newStatistics(checkIns, now, seasonStart)
+	display.VisitsThisMonth = statistics.ThisMonth
+	display.VisitsThisSeason = statistics.ThisSeason
+	display.StreakWeeks = statistics.StreakWeeks
+
+	for _, m := range milestones {
+		if len(checkIns) == m {
+			display.Milestone = m
+			display.Message = fmt.Sprintf("%dth training!", m)
+		}
+	}
+
+	return display
+}
+
+// newStatistics counts the checkIns of a user relative to the day of now.
+func newStatistics(checkIns []CheckIn, now time.Time, seasonStart time.Month) Statistics {
+
+	today := truncateToStartOfDay(now)
+	monthStart := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	statistics := Statistics{
+		Total:       len(checkIns),
+		SeasonStart: startOfSeason(today, seasonStart),
+	}
+
+	for _, c := range checkIns {
+		if !c.Date.Before(monthStart) {
+			statistics.ThisMonth++
+		}
+		if !c.Date.Before(statistics.SeasonStart) {
+			statistics.ThisSeason++
+		}
+		if !statistics.FirstCheckIn.Valid || c.Timestamp.Before(statistics.FirstCheckIn.Time) {
+			statistics.FirstCheckIn = null.TimeFrom(c.Timestamp)
+		}
+		if !statistics.LastCheckIn.Valid || c.Timestamp.After(statistics.LastCheckIn.Time) {
+			statistics.LastCheckIn = null.TimeFrom(c.Timestamp)
+		}
+	}
+
+	statistics.StreakWeeks = weekStreak(checkIns, today)
+
+	return statistics
+}
+
+func alreadyCheckedInDisplay(display Display) Display {
+	display.Severity = SeverityWarning
+	display.Message = "already checked in today"
+	display.Milestone = 0
+	display.Sound = SoundDoubleBeep
+	display.LED = LEDAlternateBlink
+	return display
+}
+
+func capacityReachedDisplay(u *user.User) Display {
+	return Display{
+		Severity: SeverityError,
+		Greeting: fmt.Sprintf("Sorry %s!", u.Name),
+		Message:  "capacity reached, please wait for a free spot",
+		UserName: u.Name,
+		Sound:    SoundLongBeep,
+		LED:      LEDRedBlink,
+	}
+}
+
+func startOfSeason(day time.Time, seasonStart time.Month) time.Time {
+	year := day.Year()
+	if day.Month() < seasonStart {
+		year--
+	}
+	return time.Date(year, seasonStart, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// weekStreak counts the consecutive weeks (ending with the week of today) which contain at least one checkIn.
+func weekStreak(checkIns []CheckIn, today time.Time) int {
+
+	weeks := make(map[time.Time]bool, len(checkIns))
+	for _, c := range checkIns {
+		weeks[startOfWeek(c.Date)] = true
+	}
+
+	streak := 0
+	for week := startOfWeek(today); weeks[week]; week = week.AddDate(0, 0, -daysInWeek) {
+		streak++
+	}
+
+	return streak
+}
+
+func startOfWeek(day time.Time) time.Time {
+	day = truncateToStartOfDay(day)
+	offset := (int(day.Weekday()) + daysInWeek - 1) % daysInWeek // weeks start on monday
+	return day.AddDate(0, 0, -offset)
+}
@@ -0,0 +1,139 @@
+package checkin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func checkInsOn(days ...time.Time) []CheckIn {
+	checkIns := make([]CheckIn, len(days))
+	for i, d := range days {
+		checkIns[i] = CheckIn{ID: int64(i), Date: d, Timestamp: d}
+	}
+	return checkIns
+}
+
+func TestNewDisplay(t *testing.T) {
+
+	u := &user.User{ID: 1, Name: "Alice"}
+	now := time.Date(2025, time.October, 15, 18, 30, 0, 0, time.UTC) // wednesday
+
+	tests := []struct {
+		name     string
+		checkIns []CheckIn
+		expected Display
+	}{
+		{
+			name:     "first checkIn",
+			checkIns: checkInsOn(day(2025, time.October, 15)),
+			expected: Display{
+				Severity:         SeverityOK,
+				Greeting:         "Hello Alice!",
+				UserName:         "Alice",
+				VisitsThisMonth:  1,
+				VisitsThisSeason: 1,
+				StreakWeeks:      1,
+				Sound:            SoundShortBeep,
+				LED:              LEDGreenBlink,
+			},
+		},
+		{
+			name: "month, season and streak",
+			checkIns: checkInsOn(
+				day(2025, time.August, 20), // previous season
+				day(2025, time.September, 24),
+				day(2025, time.September, 30),
+				day(2025, time.October, 8),
+				day(2025, time.October, 13),
+				day(2025, time.October, 15),
+			),
+			expected: Display{
+				Severity:         SeverityOK,
+				Greeting:         "Hello Alice!",
+				UserName:         "Alice",
+				VisitsThisMonth:  3,
+				VisitsThisSeason: 5,
+				StreakWeeks:      4,
+				Sound:            SoundShortBeep,
+				LED:              LEDGreenBlink,
+			},
+		},
+		{
+			name: "streak interrupted",
+			checkIns: checkInsOn(
+				day(2025, time.September, 29),
+				day(2025, time.October, 15),
+			),
+			expected: Display{
+				Severity:         SeverityOK,
+				Greeting:         "Hello Alice!",
+				UserName:         "Alice",
+				VisitsThisMonth:  1,
+				VisitsThisSeason: 2,
+				StreakWeeks:      1,
+				Sound:            SoundShortBeep,
+				LED:              LEDGreenBlink,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, newDisplay(u, tt.checkIns, now, time.September))
+		})
+	}
+}
+
+func TestNewDisplay_Milestone(t *testing.T) {
+
+	u := &user.User{ID: 1, Name: "Bob"}
+	now := day(2025, time.October, 15)
+
+	days := make([]time.Time, 50)
+	for i := range days {
+		days[i] = now.AddDate(0, 0, -i*7)
+	}
+
+	display := newDisplay(u, checkInsOn(days...), now, time.September)
+
+	assert.Equal(t, 50, display.Milestone)
+	assert.Equal(t, "50th training!", display.Message)
+	assert.Equal(t, 50, display.StreakWeeks)
+}
+
+func TestNewStatistics(t *testing.T) {
+
+	now := day(2025, time.October, 15)
+
+	statistics := newStatistics(checkInsOn(
+		day(2025, time.October, 13),
+		day(2025, time.August, 20),
+		day(2025, time.October, 1),
+	), now, time.September)
+
+	assert.Equal(t, Statistics{
+		Total:        3,
+		ThisMonth:    2,
+		ThisSeason:   2,
+		StreakWeeks:  1,
+		SeasonStart:  day(2025, time.September, 1),
+		FirstCheckIn: null.TimeFrom(day(2025, time.August, 20)),
+		LastCheckIn:  null.TimeFrom(day(2025, time.October, 13)),
+	}, statistics)
+
+	assert.Equal(t, Statistics{SeasonStart: day(2025, time.September, 1)}, newStatistics(nil, now, time.September))
+}
+
+func TestStartOfSeason(t *testing.T) {
+	assert.Equal(t, day(2024, time.September, 1), startOfSeason(day(2025, time.March, 3), time.September))
+	assert.Equal(t, day(2025, time.September, 1), startOfSeason(day(2025, time.September, 1), time.September))
+	assert.Equal(t, day(2025, time.January, 1), startOfSeason(day(2025, time.June, 30), time.January))
+}
@@ -0,0 +1,27 @@
+package checkin
+
+import (
+	"github.com/d-rk/checkin-system/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// sources of the created checkIns
+const (
+	sourceRFID   = "rfid"
+	sourceManual = "manual"
+	sourceSync   = "sync"
+)
+
+var checkInsCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Name:      "checkins_total",
+	Help:      "Number of created checkIns by source (rfid, manual or sync).",
+}, []string{"source"})
+
+var unknownRFIDTaps = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Name:      "unknown_rfid_taps_total",
+	Help:      "Number of taps of rfid cards which belong to no user.",
+})
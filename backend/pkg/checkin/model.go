@@ -1,16 +1,42 @@
 package checkin
 
 import (
+	"context"
 	"time"
 
+	"github.com/d-rk/checkin-system/pkg/announcement"
+	"github.com/d-rk/checkin-system/pkg/group"
+	"github.com/d-rk/checkin-system/pkg/tenant"
 	"github.com/d-rk/checkin-system/pkg/user"
+	"gopkg.in/guregu/null.v4"
 )
 
 type CheckIn struct {
-	ID        int64     `db:"id"        json:"id"        csv:"id"`
-	Date      time.Time `db:"date"      json:"date"      csv:"date"`
-	Timestamp time.Time `db:"timestamp" json:"timestamp" csv:"timestamp"`
-	UserID    int64     `db:"user_id"   json:"user_id"   csv:"-"`
+	ID         int64     `db:"id"          json:"id"          csv:"id"`
+	TenantID   int64     `db:"tenant_id"   json:"-"           csv:"-"`
+	Date       time.Time `db:"date"        json:"date"        csv:"date"`
+	Timestamp  time.Time `db:"timestamp"   json:"timestamp"   csv:"timestamp"`
+	UserID     int64     `db:"user_id"     json:"user_id"     csv:"-"`
+	LocationID null.Int  `db:"location_id" json:"location_id" csv:"location_id"`
+}
+
+// Filter restricts checkIns to the group scope of the authenticated user and optionally to a location.
+type Filter struct {
+	Scope      group.Scope
+	LocationID null.Int
+}
+
+// filterCondition is an sql condition restricting checkIns to a Filter and the tenant, which are passed as $1 to $3
+// (see args). It has to precede the conditions on $4 and following, as sqlite numbers the parameters in the order
+// they appear in the query, not by their number.
+const filterCondition = group.ScopeCondition + `
+	AND (CAST($2 AS bigint) = 0 OR checkins.location_id = CAST($2 AS bigint))
+	AND checkins.tenant_id = $3`
+
+// args returns the query arguments of the filter and the tenant of the context followed by the given ones, which
+// start at $4.
+func (f Filter) args(ctx context.Context, args ...any) []any {
+	return append([]any{f.Scope.TrainerID, f.LocationID.Int64, tenant.ID(ctx)}, args...)
 }
 
 type WithUser struct {
@@ -23,7 +49,69 @@ type Date struct {
 	Date time.Time `db:"date" json:"date"`
 }
 
+// EventFull is sent to the kiosks when a tap was rejected because the capacity is reached.
+const EventFull = "full"
+
 type WebsocketMessage struct {
-	RFIDuid string   `db:"rfid_uid" json:"rfid_uid"`
-	CheckIn *CheckIn `              json:"check_in"`
+	RFIDuid   string     `db:"rfid_uid" json:"rfid_uid"`
+	Event     string     `              json:"event,omitempty"`
+	CheckIn   *CheckIn   `              json:"check_in"`
+	Display   *Display   `              json:"display,omitempty"`
+	Occupancy *Occupancy `              json:"occupancy,omitempty"`
+
+	Notes []announcement.Announcement `json:"notes,omitempty"`
+}
+
+// RFIDCheckIn is the result of a tap on a reader: the stored checkIn and the feedback to show on the kiosk.
+type RFIDCheckIn struct {
+	CheckIn *CheckIn
+	Display Display
+	Notes   []announcement.Announcement
+}
+
+// MergeOutcome is the result of merging a checkIn recorded by another instance, e.g. an edge deployment.
+type MergeOutcome string
+
+const (
+	// MergeCreated checkIns were stored, as the user had no checkIn on the day.
+	MergeCreated MergeOutcome = "created"
+	// MergeUpdated checkIns replaced the timestamp and location of a later checkIn of the user on the day.
+	MergeUpdated MergeOutcome = "updated"
+	// MergeKept checkIns were dropped, as the user already had an earlier checkIn on the day.
+	MergeKept MergeOutcome = "kept"
+)
+
+// Statistics summarizes the checkIns of a single user.
+type Statistics struct {
+	Total        int
+	ThisMonth    int
+	ThisSeason   int
+	StreakWeeks  int
+	SeasonStart  time.Time
+	FirstCheckIn null.Time
+	LastCheckIn  null.Time
+}
+
+// Occupancy is the number of people present at a point in time. As there are no check-outs, everybody who
+// checked in within the stay duration counts as present.
+type Occupancy struct {
+	Timestamp  time.Time        `json:"timestamp"`
+	LocationID null.Int         `json:"location_id"`
+	Count      int              `json:"count"`
+	Capacity   null.Int         `json:"capacity"`
+	Groups     []GroupOccupancy `json:"groups"`
+}
+
+// GroupOccupancy is the number of present members of a group. Members without group have no GroupID.
+type GroupOccupancy struct {
+	GroupID  null.Int    `json:"group_id"`
+	Name     null.String `json:"name"`
+	Count    int         `json:"count"`
+	Capacity null.Int    `json:"capacity"`
+}
+
+// OccupancyPoint is a single value of an occupancy time series.
+type OccupancyPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Count     int       `json:"count"`
 }
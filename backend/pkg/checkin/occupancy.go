@@ -0,0 +1,119 @@
+package checkin
+
+import (
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/d-rk/checkin-system/pkg/group"
+	"gopkg.in/guregu/null.v4"
+)
+
+const occupancySeriesStep = 15 * time.Minute
+
+type occupancyConfig struct {
+	// stay is how long a checkIn counts as present.
+	stay time.Duration
+	// capacity limits the number of people present regardless of their group.
+	capacity null.Int
+}
+
+// newOccupancyConfig returns the stay and the capacity, 0 is no capacity.
+func newOccupancyConfig(cfg config.Checkin) occupancyConfig {
+
+	occupancy := occupancyConfig{stay: time.Duration(cfg.OccupancyStayMinutes) * time.Minute}
+
+	if cfg.OccupancyCapacity > 0 {
+		occupancy.capacity = null.IntFrom(int64(cfg.OccupancyCapacity))
+	}
+
+	return occupancy
+}
+
+// newOccupancy counts the users with a checkIn within the stay duration before the timestamp.
+func newOccupancy(checkIns []WithUser, groups []group.Group, capacity null.Int, timestamp time.Time,
+	stay time.Duration) Occupancy {
+
+	occupancy := Occupancy{
+		Timestamp: timestamp,
+		Capacity:  capacity,
+		Groups:    make([]GroupOccupancy, 0, len(groups)+1),
+	}
+
+	groupCounts := make(map[int64]int, len(groups))
+	withoutGroup := 0
+
+	for _, c := range present(checkIns, timestamp, stay) {
+		occupancy.Count++
+		if c.User.GroupID.Valid {
+			groupCounts[c.User.GroupID.Int64]++
+		} else {
+			withoutGroup++
+		}
+	}
+
+	for _, g := range groups {
+		occupancy.Groups = append(occupancy.Groups, GroupOccupancy{
+			GroupID:  null.IntFrom(g.ID),
+			Name:     null.StringFrom(g.Name),
+			Count:    groupCounts[g.ID],
+			Capacity: g.Capacity,
+		})
+	}
+
+	if withoutGroup > 0 {
+		occupancy.Groups = append(occupancy.Groups, GroupOccupancy{Count: withoutGroup})
+	}
+
+	return occupancy
+}
+
+// Full returns true if no more members of the group may check in.
+func (o *Occupancy) Full(groupID null.Int) bool {
+
+	if o.Capacity.Valid && int64(o.Count) >= o.Capacity.Int64 {
+		return true
+	}
+
+	if !groupID.Valid {
+		return false
+	}
+
+	for _, g := range o.Groups {
+		if g.GroupID == groupID {
+			return g.Capacity.Valid && int64(g.Count) >= g.Capacity.Int64
+		}
+	}
+
+	return false
+}
+
+// newOccupancySeries returns the number of people present for every step from the start until the end.
+func newOccupancySeries(checkIns []WithUser, start time.Time, end time.Time, step time.Duration,
+	stay time.Duration) []OccupancyPoint {
+
+	var series []OccupancyPoint
+
+	for timestamp := start; !timestamp.After(end); timestamp = timestamp.Add(step) {
+		series = append(series, OccupancyPoint{
+			Timestamp: timestamp,
+			Count:     len(present(checkIns, timestamp, stay)),
+		})
+	}
+
+	return series
+}
+
+// present returns the latest checkIn of every user who checked in within the stay duration before the timestamp.
+func present(checkIns []WithUser, timestamp time.Time, stay time.Duration) map[int64]WithUser {
+
+	users := make(map[int64]WithUser)
+
+	for _, c := range checkIns {
+		if c.Timestamp.After(timestamp) || !c.Timestamp.After(timestamp.Add(-stay)) {
+			continue
+		}
+		users[c.UserID] = c
+	}
+
+	return users
+}
@@ -0,0 +1,73 @@
+package checkin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/group"
+	"github.com/d-rk/checkin-system/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func checkInAt(userID int64, groupID null.Int, timestamp time.Time) WithUser {
+	return WithUser{
+		CheckIn: CheckIn{Date: truncateToStartOfDay(timestamp), Timestamp: timestamp, UserID: userID},
+		User:    user.User{ID: userID, GroupID: groupID},
+	}
+}
+
+func TestNewOccupancy(t *testing.T) {
+
+	now := time.Date(2025, time.October, 15, 18, 30, 0, 0, time.UTC)
+	seniors := null.IntFrom(1)
+
+	checkIns := []WithUser{
+		checkInAt(1, seniors, now.Add(-3*time.Hour)), // left already
+		checkInAt(2, seniors, now.Add(-time.Hour)),
+		checkInAt(3, seniors, now.Add(-time.Minute)),
+		checkInAt(4, null.Int{}, now.Add(-time.Minute)),
+		checkInAt(5, seniors, now.Add(time.Minute)), // not yet there
+	}
+	groups := []group.Group{
+		{ID: 1, Name: "Seniors", Capacity: null.IntFrom(2)},
+		{ID: 2, Name: "Juniors"},
+	}
+
+	occupancy := newOccupancy(checkIns, groups, null.IntFrom(10), now, 2*time.Hour)
+
+	assert.Equal(t, 3, occupancy.Count)
+	require.Len(t, occupancy.Groups, 3)
+	assert.Equal(t, GroupOccupancy{GroupID: seniors, Name: null.StringFrom("Seniors"), Count: 2,
+		Capacity: null.IntFrom(2)}, occupancy.Groups[0])
+	assert.Equal(t, 0, occupancy.Groups[1].Count)
+	assert.Equal(t, GroupOccupancy{Count: 1}, occupancy.Groups[2])
+
+	assert.True(t, occupancy.Full(seniors))
+	assert.False(t, occupancy.Full(null.IntFrom(2)))
+	assert.False(t, occupancy.Full(null.Int{}))
+
+	occupancy.Capacity = null.IntFrom(3)
+	assert.True(t, occupancy.Full(null.Int{}))
+}
+
+func TestNewOccupancySeries(t *testing.T) {
+
+	start := time.Date(2025, time.October, 15, 18, 0, 0, 0, time.UTC)
+
+	checkIns := []WithUser{
+		checkInAt(1, null.Int{}, start.Add(-10*time.Minute)),
+		checkInAt(2, null.Int{}, start.Add(20*time.Minute)),
+	}
+
+	series := newOccupancySeries(checkIns, start, start.Add(time.Hour), 15*time.Minute, 30*time.Minute)
+
+	counts := make([]int, len(series))
+	for i, p := range series {
+		counts[i] = p.Count
+	}
+
+	assert.Equal(t, []int{1, 1, 1, 1, 0}, counts)
+	assert.Equal(t, start.Add(time.Hour), series[4].Timestamp)
+}
@@ -9,43 +9,54 @@ import (
 
 	"github.com/d-rk/checkin-system/pkg/app"
 	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/tenant"
 
 	"github.com/jmoiron/sqlx"
 )
 
 type Repository interface {
-	ListCheckIns(ctx context.Context) ([]CheckIn, error)
-	ListCheckInsPerDay(ctx context.Context, date time.Time) ([]WithUser, error)
-	ListAllCheckIns(ctx context.Context) ([]WithUser, error)
+	ListCheckIns(ctx context.Context, filter Filter) ([]CheckIn, error)
+	ListCheckInsPerDay(ctx context.Context, date time.Time, filter Filter) ([]WithUser, error)
+	ListAllCheckIns(ctx context.Context, filter Filter) ([]WithUser, error)
+	ListCheckInsBetween(ctx context.Context, from time.Time, until time.Time, filter Filter) ([]WithUser, error)
+	ListCheckInsByDates(ctx context.Context, from time.Time, to time.Time, filter Filter) ([]WithUser, error)
 	ListUserCheckIns(ctx context.Context, userID int64) ([]CheckIn, error)
+	GetCheckInByID(ctx context.Context, id int64) (*CheckIn, error)
+	GetUserCheckInByDate(ctx context.Context, userID int64, date time.Time) (*CheckIn, error)
 	GetLatestCheckinDate(ctx context.Context) (*time.Time, error)
 	DeleteCheckInByID(ctx context.Context, id int64) error
 	DeleteCheckInsByUserID(ctx context.Context, userID int64) error
 	DeleteCheckInsOlderThan(ctx context.Context, thresholdDays int64) error
+	CountCheckInsOlderThan(ctx context.Context, thresholdDays int64) (int64, error)
 	SaveCheckIn(ctx context.Context, checkIn *CheckIn) (*CheckIn, error)
-	ListCheckInDates(ctx context.Context) ([]Date, error)
+	UpdateCheckIn(ctx context.Context, checkIn *CheckIn) (*CheckIn, error)
+	// LockTenant serializes the transactions of the tenant which call it, until they end.
+	LockTenant(ctx context.Context) error
+	ListCheckInDates(ctx context.Context, filter Filter) ([]Date, error)
 }
 
 type repository struct {
-	db *sqlx.DB
+	db *database.DB
 }
 
 func NewRepo(db *sqlx.DB) Repository {
-	return &repository{db}
+	return &repository{database.NewDB(db)}
 }
 
-func (r *repository) ListCheckIns(ctx context.Context) ([]CheckIn, error) {
+func (r *repository) ListCheckIns(ctx context.Context, filter Filter) ([]CheckIn, error) {
 
 	var checkIns []CheckIn
 
-	if err := r.db.SelectContext(ctx, &checkIns, "SELECT * FROM checkins"); err != nil {
+	if err := r.db.SelectContext(ctx, &checkIns, `SELECT checkins.*
+			FROM checkins JOIN users ON checkins.user_id = users.id
+			WHERE `+filterCondition, filter.args(ctx)...); err != nil {
 		return nil, errors.New("no checkins found")
 	}
 
 	return checkIns, nil
 }
 
-func (r *repository) ListCheckInsPerDay(ctx context.Context, date time.Time) ([]WithUser, error) {
+func (r *repository) ListCheckInsPerDay(ctx context.Context, date time.Time, filter Filter) ([]WithUser, error) {
 
 	var checkIns []WithUser
 
@@ -56,17 +67,18 @@ func (r *repository) ListCheckInsPerDay(ctx context.Context, date time.Time) ([]
 			users.created_at "user.created_at",
 			users.updated_at "user.updated_at",
 			users.member_id "user.member_id",
-			users.rfid_uid "user.rfid_uid"
+			users.rfid_uid "user.rfid_uid",
+			users.group_id "user.group_id"
 			FROM checkins JOIN users ON checkins.user_id = users.id
-			WHERE checkins.date = $1
-			ORDER BY checkins.timestamp ASC`, date); err != nil {
+			WHERE `+filterCondition+` AND checkins.date = $4
+			ORDER BY checkins.timestamp ASC`, filter.args(ctx, date)...); err != nil {
 		return nil, fmt.Errorf("unable to query checkins: %s", err.Error())
 	}
 
 	return checkIns, nil
 }
 
-func (r *repository) ListAllCheckIns(ctx context.Context) ([]WithUser, error) {
+func (r *repository) ListAllCheckIns(ctx context.Context, filter Filter) ([]WithUser, error) {
 
 	var checkIns []WithUser
 
@@ -77,9 +89,58 @@ func (r *repository) ListAllCheckIns(ctx context.Context) ([]WithUser, error) {
 			users.created_at "user.created_at",
 			users.updated_at "user.updated_at",
 			users.member_id "user.member_id",
-			users.rfid_uid "user.rfid_uid"
+			users.rfid_uid "user.rfid_uid",
+			users.group_id "user.group_id"
 			FROM checkins JOIN users ON checkins.user_id = users.id
-			ORDER BY checkins.timestamp ASC`); err != nil {
+			WHERE `+filterCondition+`
+			ORDER BY checkins.timestamp ASC`, filter.args(ctx)...); err != nil {
+		return nil, fmt.Errorf("unable to query checkins: %s", err.Error())
+	}
+
+	return checkIns, nil
+}
+
+func (r *repository) ListCheckInsBetween(ctx context.Context, from time.Time, until time.Time,
+	filter Filter) ([]WithUser, error) {
+
+	var checkIns []WithUser
+
+	if err := r.db.SelectContext(ctx, &checkIns, `SELECT
+			checkins.*,
+			users.id "user.id",
+			users.name "user.name",
+			users.created_at "user.created_at",
+			users.updated_at "user.updated_at",
+			users.member_id "user.member_id",
+			users.rfid_uid "user.rfid_uid",
+			users.group_id "user.group_id"
+			FROM checkins JOIN users ON checkins.user_id = users.id
+			WHERE `+filterCondition+` AND checkins.timestamp > $4 AND checkins.timestamp <= $5
+			ORDER BY checkins.timestamp ASC`, filter.args(ctx, from, until)...); err != nil {
+		return nil, fmt.Errorf("unable to query checkins: %s", err.Error())
+	}
+
+	return checkIns, nil
+}
+
+// ListCheckInsByDates returns the checkIns with a date from the date from up to and including the date to.
+func (r *repository) ListCheckInsByDates(ctx context.Context, from time.Time, to time.Time,
+	filter Filter) ([]WithUser, error) {
+
+	var checkIns []WithUser
+
+	if err := r.db.SelectContext(ctx, &checkIns, `SELECT
+			checkins.*,
+			users.id "user.id",
+			users.name "user.name",
+			users.created_at "user.created_at",
+			users.updated_at "user.updated_at",
+			users.member_id "user.member_id",
+			users.rfid_uid "user.rfid_uid",
+			users.group_id "user.group_id"
+			FROM checkins JOIN users ON checkins.user_id = users.id
+			WHERE `+filterCondition+` AND checkins.date >= $4 AND checkins.date <= $5
+			ORDER BY checkins.timestamp ASC`, filter.args(ctx, from, to)...); err != nil {
 		return nil, fmt.Errorf("unable to query checkins: %s", err.Error())
 	}
 
@@ -90,89 +151,161 @@ func (r *repository) ListUserCheckIns(ctx context.Context, userID int64) ([]Chec
 
 	var checkIns []CheckIn
 
-	if err := r.db.SelectContext(ctx, &checkIns, "SELECT * FROM checkins WHERE user_id = $1", userID); err != nil {
+	if err := r.db.SelectContext(ctx, &checkIns, "SELECT * FROM checkins WHERE user_id = $1 AND tenant_id = $2",
+		userID, tenant.ID(ctx)); err != nil {
 		return nil, errors.New("no checkins found")
 	}
 
 	return checkIns, nil
 }
 
-func (r *repository) GetLatestCheckinDate(ctx context.Context) (*time.Time, error) {
+func (r *repository) GetCheckInByID(ctx context.Context, id int64) (*CheckIn, error) {
+
+	checkIn := CheckIn{}
+
+	if err := r.db.GetContext(ctx, &checkIn, "SELECT * FROM checkins WHERE id = $1 AND tenant_id = $2", id,
+		tenant.ID(ctx)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, app.ErrNotFound
+		}
+		return nil, err
+	}
 
-	var timestamp sql.NullTime
+	return &checkIn, nil
+}
+
+func (r *repository) GetUserCheckInByDate(ctx context.Context, userID int64, date time.Time) (*CheckIn, error) {
+
+	checkIn := CheckIn{}
 
-	if err := r.db.GetContext(ctx, &timestamp, "SELECT max(timestamp) FROM checkins"); err != nil {
+	if err := r.db.GetContext(ctx, &checkIn,
+		"SELECT * FROM checkins WHERE user_id = $1 AND date = $2 AND tenant_id = $3", userID, date,
+		tenant.ID(ctx)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, app.ErrNotFound
+		}
 		return nil, err
 	}
 
-	if !timestamp.Valid {
+	return &checkIn, nil
+}
+
+// GetLatestCheckinDate returns the latest checkIn of all tenants. It is used to check the system clock.
+func (r *repository) GetLatestCheckinDate(ctx context.Context) (*time.Time, error) {
+
+	var timestamp time.Time
+
+	// sqlite returns aggregates like max(timestamp) as text, which can't be scanned into a time
+	err := r.db.GetContext(ctx, &timestamp, "SELECT timestamp FROM checkins ORDER BY timestamp DESC LIMIT 1")
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
 		return nil, app.ErrNotFound
+	} else if err != nil {
+		return nil, err
 	}
 
-	return &timestamp.Time, nil
+	return &timestamp, nil
 }
 
 func (r *repository) DeleteCheckInByID(ctx context.Context, id int64) error {
 
-	return database.WithTransaction(r.db, func(_ database.Tx) error {
+	return r.db.WithTransaction(ctx, func(ctx context.Context) error {
 
-		deleteCheckinsStatement, err := r.db.PreparexContext(ctx, `DELETE FROM checkins WHERE id = $1`)
+		deleteCheckinsStatement, err := r.db.PreparexContext(ctx,
+			`DELETE FROM checkins WHERE id = $1 AND tenant_id = $2`)
 		if err != nil {
 			return err
 		}
 		defer deleteCheckinsStatement.Close()
 
-		_, err = deleteCheckinsStatement.ExecContext(ctx, id)
-		return err
+		_, err = deleteCheckinsStatement.ExecContext(ctx, id, tenant.ID(ctx))
+		return database.Classify(err)
 	})
 }
 
 func (r *repository) DeleteCheckInsByUserID(ctx context.Context, userID int64) error {
 
-	return database.WithTransaction(r.db, func(_ database.Tx) error {
+	return r.db.WithTransaction(ctx, func(ctx context.Context) error {
 
-		deleteCheckinsStatement, err := r.db.PreparexContext(ctx, `DELETE FROM checkins WHERE user_id = $1`)
+		deleteCheckinsStatement, err := r.db.PreparexContext(ctx,
+			`DELETE FROM checkins WHERE user_id = $1 AND tenant_id = $2`)
 		if err != nil {
 			return err
 		}
 		defer deleteCheckinsStatement.Close()
 
-		_, err = deleteCheckinsStatement.ExecContext(ctx, userID)
-		return err
+		_, err = deleteCheckinsStatement.ExecContext(ctx, userID, tenant.ID(ctx))
+		return database.Classify(err)
 	})
 }
 
+// DeleteCheckInsOlderThan deletes the old checkIns of all tenants.
 func (r *repository) DeleteCheckInsOlderThan(ctx context.Context, thresholdDays int64) error {
 
-	return database.WithTransaction(r.db, func(_ database.Tx) error {
-
-		var query string
+	return r.db.WithTransaction(ctx, func(ctx context.Context) error {
 
-		switch r.db.DriverName() {
-		case "postgres":
-			query = `DELETE FROM checkins WHERE DATE_PART('day', now() - date) > $1`
-		case "sqlite3":
-			query = `DELETE FROM checkins WHERE julianday('now') - julianday(date) > $1`
-		default:
-			return fmt.Errorf("unknown driver %s", r.db.DriverName())
+		condition, err := r.olderThanCondition()
+		if err != nil {
+			return err
 		}
 
-		deleteCheckinsStatement, err := r.db.PreparexContext(ctx, query)
+		deleteCheckinsStatement, err := r.db.PreparexContext(ctx, `DELETE FROM checkins WHERE `+condition)
 		if err != nil {
 			return err
 		}
 		defer deleteCheckinsStatement.Close()
 
 		_, err = deleteCheckinsStatement.ExecContext(ctx, thresholdDays)
-		return err
+		return database.Classify(err)
 	})
 }
 
+// CountCheckInsOlderThan returns the number of old checkIns of all tenants, i.e. the ones DeleteCheckInsOlderThan
+// deletes.
+func (r *repository) CountCheckInsOlderThan(ctx context.Context, thresholdDays int64) (int64, error) {
+
+	condition, err := r.olderThanCondition()
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+
+	if err = r.db.GetContext(ctx, &count, `SELECT COUNT(*) FROM checkins WHERE `+condition,
+		thresholdDays); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// olderThanCondition is an sql condition restricting checkIns to the ones older than the days passed as $1.
+func (r *repository) olderThanCondition() (string, error) {
+
+	switch r.db.DriverName() {
+	case "postgres":
+		return `DATE_PART('day', now() - date) > $1`, nil
+	case "sqlite3":
+		return `julianday('now') - julianday(date) > $1`, nil
+	default:
+		return "", fmt.Errorf("unknown driver %s", r.db.DriverName())
+	}
+}
+
+// LockTenant updates the tenant without changing it: postgres locks the row and sqlite the database until the end of
+// the transaction.
+func (r *repository) LockTenant(ctx context.Context) error {
+
+	_, err := r.db.ExecContext(ctx, "UPDATE tenants SET updated_at = updated_at WHERE id = $1", tenant.ID(ctx))
+	return err
+}
+
 func (r *repository) SaveCheckIn(ctx context.Context, checkIn *CheckIn) (*CheckIn, error) {
 
+	checkIn.TenantID = tenant.ID(ctx)
+
 	insertStatement, err := r.db.PrepareNamedContext(ctx, `INSERT INTO checkins
-		(date, timestamp, user_id) VALUES
-		(:date, :timestamp, :user_id) RETURNING id`)
+		(tenant_id, date, timestamp, user_id, location_id) VALUES
+		(:tenant_id, :date, :timestamp, :user_id, :location_id) RETURNING id`)
 	if err != nil {
 		return nil, err
 	}
@@ -181,21 +314,43 @@ func (r *repository) SaveCheckIn(ctx context.Context, checkIn *CheckIn) (*CheckI
 	row := insertStatement.QueryRow(checkIn)
 
 	if row.Err() != nil {
-		return nil, row.Err()
+		return nil, database.Classify(row.Err())
 	}
 
 	if err = row.Scan(&checkIn.ID); err != nil {
+		return nil, database.Classify(err)
+	}
+
+	return checkIn, nil
+}
+
+// UpdateCheckIn updates the timestamp and location of the checkIn.
+func (r *repository) UpdateCheckIn(ctx context.Context, checkIn *CheckIn) (*CheckIn, error) {
+
+	checkIn.TenantID = tenant.ID(ctx)
+
+	updateStatement, err := r.db.PrepareNamedContext(ctx, `UPDATE checkins SET
+		(timestamp, location_id) = (:timestamp, :location_id)
+		WHERE id = :id AND tenant_id = :tenant_id`)
+	if err != nil {
 		return nil, err
 	}
+	defer updateStatement.Close()
+
+	if _, err = updateStatement.ExecContext(ctx, checkIn); err != nil {
+		return nil, database.Classify(err)
+	}
 
 	return checkIn, nil
 }
 
-func (r *repository) ListCheckInDates(ctx context.Context) ([]Date, error) {
+func (r *repository) ListCheckInDates(ctx context.Context, filter Filter) ([]Date, error) {
 
 	var dates []Date
 
-	if err := r.db.SelectContext(ctx, &dates, "SELECT distinct date as date FROM checkins"); err != nil {
+	if err := r.db.SelectContext(ctx, &dates, `SELECT distinct checkins.date as date
+			FROM checkins JOIN users ON checkins.user_id = users.id
+			WHERE `+filterCondition, filter.args(ctx)...); err != nil {
 		return nil, errors.New("no checkIn dates found")
 	}
 
@@ -5,20 +5,186 @@ package checkin
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/d-rk/checkin-system/pkg/app"
 	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/user"
+	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetLatestCheckinDate_EmptyTable_ReturnsNotFoundErr(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration test in short mode.")
 	}
-	db := database.SetupTestDB(t)
-	repo := NewRepo(db)
-	ctx := context.Background()
-	ts, err := repo.GetLatestCheckinDate(ctx)
-	assert.Nil(t, ts)
-	assert.Equal(t, app.ErrNotFound, err)
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		repo := NewRepo(db)
+		ctx := context.Background()
+		ts, err := repo.GetLatestCheckinDate(ctx)
+		assert.Nil(t, ts)
+		assert.Equal(t, app.ErrNotFound, err)
+	})
+}
+
+func TestGetLatestCheckinDate_ReturnsLatestTimestamp(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		repo := NewRepo(db)
+		latest := time.Date(2024, 3, 2, 18, 30, 0, 0, time.UTC)
+
+		for i, timestamp := range []time.Time{latest.AddDate(0, 0, -1), latest} {
+			u, err := user.NewRepo(db).SaveUser(ctx, &user.User{Name: string(rune('A' + i)), Role: user.RoleUser})
+			require.NoError(t, err)
+			_, err = repo.SaveCheckIn(ctx, &CheckIn{Date: truncateToStartOfDay(timestamp), Timestamp: timestamp,
+				UserID: u.ID})
+			require.NoError(t, err)
+		}
+
+		ts, err := repo.GetLatestCheckinDate(ctx)
+		require.NoError(t, err)
+		assert.True(t, latest.Equal(*ts), "expected %v, got %v", latest, *ts)
+	})
+}
+
+func TestSaveCheckIn_SameDay_ReturnsConflictErr(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		u, err := user.NewRepo(db).SaveUser(ctx, &user.User{Name: "Alice", Role: user.RoleUser})
+		require.NoError(t, err)
+
+		repo := NewRepo(db)
+		now := time.Now().UTC()
+
+		_, err = repo.SaveCheckIn(ctx, &CheckIn{Date: truncateToStartOfDay(now), Timestamp: now, UserID: u.ID})
+		require.NoError(t, err)
+
+		_, err = repo.SaveCheckIn(ctx, &CheckIn{Date: truncateToStartOfDay(now), Timestamp: now, UserID: u.ID})
+		require.ErrorIs(t, err, app.ErrConflict)
+
+		var constraintErr *database.ConstraintError
+		require.ErrorAs(t, err, &constraintErr)
+		assert.Equal(t, database.UniqueViolation, constraintErr.Violation)
+	})
+}
+
+func TestListCheckInsByDates_IncludesFirstAndLastDay(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		u, err := user.NewRepo(db).SaveUser(ctx, &user.User{Name: "Alice", Role: user.RoleUser})
+		require.NoError(t, err)
+
+		repo := NewRepo(db)
+		first := time.Date(2024, 3, 1, 18, 30, 0, 0, time.UTC)
+
+		for days := range 4 {
+			timestamp := first.AddDate(0, 0, days-1)
+			_, err = repo.SaveCheckIn(ctx, &CheckIn{Date: truncateToStartOfDay(timestamp), Timestamp: timestamp,
+				UserID: u.ID})
+			require.NoError(t, err)
+		}
+
+		checkIns, err := repo.ListCheckInsByDates(ctx, truncateToStartOfDay(first),
+			truncateToStartOfDay(first.AddDate(0, 0, 1)), Filter{})
+		require.NoError(t, err)
+		require.Len(t, checkIns, 2)
+		assert.True(t, first.Equal(checkIns[0].Timestamp), "expected %v, got %v", first, checkIns[0].Timestamp)
+		assert.Equal(t, "Alice", checkIns[1].User.Name)
+	})
+}
+
+func TestCountCheckInsOlderThan_CountsDeletedCheckIns(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		u, err := user.NewRepo(db).SaveUser(ctx, &user.User{Name: "Alice", Role: user.RoleUser})
+		require.NoError(t, err)
+
+		repo := NewRepo(db)
+		now := time.Now().UTC()
+
+		for _, days := range []int{-100, -20, 0} {
+			timestamp := now.AddDate(0, 0, days)
+			_, err = repo.SaveCheckIn(ctx, &CheckIn{Date: truncateToStartOfDay(timestamp), Timestamp: timestamp,
+				UserID: u.ID})
+			require.NoError(t, err)
+		}
+
+		count, err := repo.CountCheckInsOlderThan(ctx, 30)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+
+		require.NoError(t, repo.DeleteCheckInsOlderThan(ctx, 30))
+
+		count, err = repo.CountCheckInsOlderThan(ctx, 30)
+		require.NoError(t, err)
+		assert.Zero(t, count)
+
+		checkIns, err := repo.ListCheckIns(ctx, Filter{})
+		require.NoError(t, err)
+		assert.Len(t, checkIns, 2)
+	})
+}
+
+func TestListCheckInsPerDay_ReturnsCheckInsOfTheDay(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		u, err := user.NewRepo(db).SaveUser(ctx, &user.User{Name: "Alice", Role: user.RoleUser})
+		require.NoError(t, err)
+
+		repo := NewRepo(db)
+		day := time.Date(2024, 3, 2, 18, 30, 0, 0, time.UTC)
+
+		for _, timestamp := range []time.Time{day.AddDate(0, 0, -1), day} {
+			_, err = repo.SaveCheckIn(ctx, &CheckIn{Date: truncateToStartOfDay(timestamp), Timestamp: timestamp,
+				UserID: u.ID})
+			require.NoError(t, err)
+		}
+
+		checkIns, err := repo.ListCheckInsPerDay(ctx, truncateToStartOfDay(day), Filter{})
+		require.NoError(t, err)
+		require.Len(t, checkIns, 1)
+		assert.True(t, day.Equal(checkIns[0].Timestamp), "expected %v, got %v", day, checkIns[0].Timestamp)
+		assert.Equal(t, "Alice", checkIns[0].User.Name)
+	})
+}
+
+func TestListCheckInsBetween_ExcludesFromIncludesUntil(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		repo := NewRepo(db)
+		from := time.Date(2024, 3, 2, 18, 0, 0, 0, time.UTC)
+
+		for i, timestamp := range []time.Time{from, from.Add(time.Hour), from.Add(2 * time.Hour)} {
+			u, err := user.NewRepo(db).SaveUser(ctx, &user.User{Name: string(rune('A' + i)), Role: user.RoleUser})
+			require.NoError(t, err)
+			_, err = repo.SaveCheckIn(ctx, &CheckIn{Date: truncateToStartOfDay(timestamp), Timestamp: timestamp,
+				UserID: u.ID})
+			require.NoError(t, err)
+		}
+
+		checkIns, err := repo.ListCheckInsBetween(ctx, from, from.Add(2*time.Hour), Filter{})
+		require.NoError(t, err)
+		require.Len(t, checkIns, 2)
+		assert.Equal(t, "B", checkIns[0].User.Name)
+		assert.Equal(t, "C", checkIns[1].User.Name)
+	})
 }
@@ -4,79 +4,146 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
-	"strconv"
+	"log/slog"
 	"time"
 
+	"github.com/d-rk/checkin-system/pkg/announcement"
 	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/group"
+	"github.com/d-rk/checkin-system/pkg/location"
+	"github.com/d-rk/checkin-system/pkg/tracing"
 	"github.com/d-rk/checkin-system/pkg/user"
 	"github.com/d-rk/checkin-system/pkg/websocket"
-	"github.com/lib/pq"
+	"gopkg.in/guregu/null.v4"
 )
 
 const hoursInDay = 24
 const daysInYear = 356
 
 type Service interface {
-	ListCheckIns(ctx context.Context) ([]CheckIn, error)
-	ListAllCheckIns(ctx context.Context) ([]WithUser, error)
+	ListCheckIns(ctx context.Context, filter Filter) ([]CheckIn, error)
+	ListAllCheckIns(ctx context.Context, filter Filter) ([]WithUser, error)
+	// ListCheckInsByDates returns the checkIns of the days from the day of from up to and including the day of to.
+	ListCheckInsByDates(ctx context.Context, from time.Time, to time.Time, filter Filter) ([]WithUser, error)
+	GetCheckInByID(ctx context.Context, checkinID int64) (*CheckIn, error)
 	DeleteCheckInByID(ctx context.Context, checkinID int64) error
 	DeleteCheckInsByUserID(ctx context.Context, userID int64) error
 	DeleteOldCheckIns(ctx context.Context) error
-	CreateCheckInForUser(ctx context.Context, userID int64, timestamp *time.Time) (*CheckIn, error)
-	CreateCheckInForRFID(ctx context.Context, rfidUID string, timestamp *time.Time) (*CheckIn, error)
-	ListCheckInsPerDay(ctx context.Context, day time.Time) ([]WithUser, error)
-	ListCheckInDates(ctx context.Context) ([]Date, error)
+	// CountOldCheckIns returns the number of checkIns of all tenants which DeleteOldCheckIns deletes.
+	CountOldCheckIns(ctx context.Context) (int64, error)
+	CreateCheckInForUser(ctx context.Context, userID int64, locationID null.Int, timestamp *time.Time) (*CheckIn,
+		error)
+	// CreateCheckInForRFID checks in the owner of the card at the location of the reader, if it is known. For an
+	// unknown card, a duplicate tap or a reached capacity, the feedback for the kiosk is returned along with the error.
+	CreateCheckInForRFID(ctx context.Context, rfidUID string, readerID string, timestamp *time.Time) (*RFIDCheckIn,
+		error)
+	// MergeCheckIn stores a checkIn recorded by another instance. There is only one checkIn per user and day, so the
+	// earliest checkIn of the day wins.
+	MergeCheckIn(ctx context.Context, userID int64, locationID null.Int, timestamp time.Time) (*CheckIn,
+		MergeOutcome, error)
+	ListCheckInsPerDay(ctx context.Context, day time.Time, filter Filter) ([]WithUser, error)
+	ListCheckInDates(ctx context.Context, filter Filter) ([]Date, error)
 	ListUserCheckIns(ctx context.Context, userID int64) ([]CheckIn, error)
+	GetUserStatistics(ctx context.Context, userID int64) (*Statistics, error)
+	GetOccupancy(ctx context.Context, filter Filter) (*Occupancy, error)
+	// ListOccupancySeries returns the occupancy of the day in steps of 15 minutes, optionally for a single group.
+	ListOccupancySeries(ctx context.Context, day time.Time, groupID null.Int, filter Filter) ([]OccupancyPoint,
+		error)
 }
 
 type service struct {
-	repo        Repository
-	userService user.Service
-	websocket   *websocket.Server
+	repo                Repository
+	userService         user.Service
+	announcementService announcement.Service
+	groupService        group.Service
+	locationService     location.Service
+	websocket           *websocket.Server
+	transactor          database.Transactor
+	occupancy           occupancyConfig
+	seasonStart         time.Month
+	retentionDays       int64
 }
 
-func NewService(repo Repository, userService user.Service, websocket *websocket.Server) Service {
+func NewService(repo Repository, userService user.Service, announcementService announcement.Service,
+	groupService group.Service, locationService location.Service, websocket *websocket.Server,
+	transactor database.Transactor, cfg config.Checkin) Service {
 
-	return &service{repo, userService, websocket}
+	return &service{repo, userService, announcementService, groupService, locationService, websocket, transactor,
+		newOccupancyConfig(cfg), time.Month(cfg.SeasonStartMonth), int64(cfg.RetentionDays)}
 }
 
-func (s *service) ListCheckIns(ctx context.Context) ([]CheckIn, error) {
-	return s.repo.ListCheckIns(ctx)
+func (s *service) ListCheckIns(ctx context.Context, filter Filter) ([]CheckIn, error) {
+	return s.repo.ListCheckIns(ctx, filter)
 }
 
-func (s *service) ListAllCheckIns(ctx context.Context) ([]WithUser, error) {
-	return s.repo.ListAllCheckIns(ctx)
+func (s *service) ListAllCheckIns(ctx context.Context, filter Filter) ([]WithUser, error) {
+	return s.repo.ListAllCheckIns(ctx, filter)
 }
 
-func (s *service) ListCheckInsPerDay(ctx context.Context, day time.Time) ([]WithUser, error) {
-	return s.repo.ListCheckInsPerDay(ctx, day)
+func (s *service) ListCheckInsByDates(ctx context.Context, from time.Time, to time.Time,
+	filter Filter) ([]WithUser, error) {
+	return s.repo.ListCheckInsByDates(ctx, truncateToStartOfDay(from), truncateToStartOfDay(to), filter)
 }
 
-func (s *service) ListCheckInDates(ctx context.Context) ([]Date, error) {
-	return s.repo.ListCheckInDates(ctx)
+func (s *service) GetCheckInByID(ctx context.Context, checkinID int64) (*CheckIn, error) {
+	return s.repo.GetCheckInByID(ctx, checkinID)
+}
+
+func (s *service) ListCheckInsPerDay(ctx context.Context, day time.Time, filter Filter) ([]WithUser, error) {
+	return s.repo.ListCheckInsPerDay(ctx, day, filter)
+}
+
+func (s *service) ListCheckInDates(ctx context.Context, filter Filter) ([]Date, error) {
+	return s.repo.ListCheckInDates(ctx, filter)
 }
 
 func (s *service) ListUserCheckIns(ctx context.Context, userID int64) ([]CheckIn, error) {
 	return s.repo.ListUserCheckIns(ctx, userID)
 }
 
-func (s *service) CreateCheckInForUser(ctx context.Context, userID int64, timestamp *time.Time) (*CheckIn, error) {
+func (s *service) GetUserStatistics(ctx context.Context, userID int64) (*Statistics, error) {
+
+	checkIns, err := s.repo.ListUserCheckIns(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	statistics := newStatistics(checkIns, time.Now(), s.seasonStart)
+	return &statistics, nil
+}
+
+func (s *service) CreateCheckInForUser(ctx context.Context, userID int64, locationID null.Int,
+	timestamp *time.Time) (*CheckIn, error) {
+
+	ctx, span := tracing.Start(ctx, "checkin.CreateCheckInForUser")
+	defer span.End()
 
 	checkinTimestamp := time.Now()
 	if timestamp != nil {
 		checkinTimestamp = *timestamp
 	}
 
+	if locationID.Valid {
+		if _, err := s.locationService.GetLocationByID(ctx, locationID.Int64); err != nil {
+			return nil, fmt.Errorf("unknown location %d: %w", locationID.Int64, app.ErrInvalid)
+		}
+	}
+
 	u, err := s.userService.GetUserByID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.createCheckinForUser(ctx, u, checkinTimestamp)
+	return s.createCheckinForUser(ctx, u, locationID, checkinTimestamp, sourceManual)
 }
 
-func (s *service) CreateCheckInForRFID(ctx context.Context, rfidUID string, timestamp *time.Time) (*CheckIn, error) {
+func (s *service) CreateCheckInForRFID(ctx context.Context, rfidUID string, readerID string,
+	timestamp *time.Time) (*RFIDCheckIn, error) {
+
+	ctx, span := tracing.Start(ctx, "checkin.CreateCheckInForRFID")
+	defer span.End()
 
 	websocketMessage := WebsocketMessage{}
 	websocketMessage.RFIDuid = rfidUID
@@ -86,42 +153,302 @@ func (s *service) CreateCheckInForRFID(ctx context.Context, rfidUID string, time
 		checkinTimestamp = *timestamp
 	}
 
+	locationID, err := s.readerLocationID(ctx, readerID)
+	if err != nil {
+		return nil, err
+	}
+
 	u, err := s.userService.GetUserByRfidUID(ctx, rfidUID, -1)
 
 	if err != nil && errors.Is(err, app.ErrNotFound) {
-		_ = s.websocket.Publish(websocketMessage)
-		return nil, err
+		unknownRFIDTaps.Inc()
+		display := unknownRFIDDisplay()
+		websocketMessage.Display = &display
+		_ = s.websocket.PublishLocation(ctx, locationID.Int64, websocketMessage)
+		return &RFIDCheckIn{Display: display}, err
 	} else if err != nil {
 		return nil, err
 	}
 
-	checkin, err := s.createCheckinForUser(ctx, u, checkinTimestamp)
+	checkin, occupancy, checkinErr := s.checkInWithinCapacity(ctx, u, locationID, checkinTimestamp)
+	if errors.Is(checkinErr, app.ErrCapacityReached) {
+		display := capacityReachedDisplay(u)
+		websocketMessage.Event = EventFull
+		websocketMessage.Display = &display
+		websocketMessage.Occupancy = occupancy
+		_ = s.websocket.PublishMember(ctx, locationID.Int64, s.inScope(ctx, u), websocketMessage)
+		return &RFIDCheckIn{Display: display}, checkinErr
+	} else if checkinErr != nil && !errors.Is(checkinErr, app.ErrConflict) {
+		return nil, checkinErr
+	}
+
+	display, notes, err := s.userDisplayAndNotes(ctx, u, checkinTimestamp)
 	if err != nil {
 		return nil, err
 	}
+	websocketMessage.Notes = notes
+
+	if checkinErr != nil {
+		display = alreadyCheckedInDisplay(display)
+		websocketMessage.Display = &display
+		_ = s.websocket.PublishMember(ctx, locationID.Int64, s.inScope(ctx, u), websocketMessage)
+		return &RFIDCheckIn{Display: display, Notes: notes}, checkinErr
+	}
+
+	occupancy, err = s.occupancyAt(ctx, checkinTimestamp, Filter{LocationID: locationID})
+	if err != nil {
+		slog.WarnContext(ctx, "failed to calculate occupancy", "error", err)
+	}
 
 	websocketMessage.CheckIn = checkin
-	_ = s.websocket.Publish(websocketMessage)
+	websocketMessage.Display = &display
+	websocketMessage.Occupancy = occupancy
+	_ = s.websocket.PublishMember(ctx, locationID.Int64, s.inScope(ctx, u), websocketMessage)
+
+	return &RFIDCheckIn{CheckIn: checkin, Display: display, Notes: notes}, nil
+}
+
+func (s *service) MergeCheckIn(ctx context.Context, userID int64, locationID null.Int,
+	timestamp time.Time) (*CheckIn, MergeOutcome, error) {
+
+	ctx, span := tracing.Start(ctx, "checkin.MergeCheckIn")
+	defer span.End()
+
+	if _, err := s.userService.GetUserByID(ctx, userID); err != nil {
+		return nil, "", err
+	}
+
+	// locations unknown to this instance are dropped, the checkIn still counts
+	if locationID.Valid {
+		_, err := s.locationService.GetLocationByID(ctx, locationID.Int64)
+		if err != nil && errors.Is(err, app.ErrNotFound) {
+			locationID = null.Int{}
+		} else if err != nil {
+			return nil, "", err
+		}
+	}
+
+	existing, err := s.repo.GetUserCheckInByDate(ctx, userID, truncateToStartOfDay(timestamp))
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		checkIn, saveErr := s.repo.SaveCheckIn(ctx, &CheckIn{
+			ID:         -1,
+			Date:       truncateToStartOfDay(timestamp),
+			Timestamp:  timestamp,
+			UserID:     userID,
+			LocationID: locationID,
+		})
+		if saveErr != nil && errors.Is(saveErr, app.ErrConflict) {
+			// the user checked in meanwhile, merge with that checkIn
+			return s.MergeCheckIn(ctx, userID, locationID, timestamp)
+		} else if saveErr == nil {
+			checkInsCreated.WithLabelValues(sourceSync).Inc()
+		}
+		return checkIn, MergeCreated, saveErr
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	if !timestamp.Before(existing.Timestamp) {
+		return existing, MergeKept, nil
+	}
+
+	existing.Timestamp = timestamp
+	existing.LocationID = locationID
+
+	checkIn, err := s.repo.UpdateCheckIn(ctx, existing)
+	return checkIn, MergeUpdated, err
+}
+
+// inScope returns whether the user is within a group scope, so that trainers only receive the taps of their members.
+func (s *service) inScope(ctx context.Context, u *user.User) func(scope group.Scope) bool {
+	return func(scope group.Scope) bool {
+		inScope, err := s.groupService.InScope(ctx, scope, u.GroupID)
+		if err != nil {
+			slog.WarnContext(ctx, "failed to check the group scope of a websocket client", "error", err)
+		}
+		return err == nil && inScope
+	}
+}
+
+// readerLocationID returns the location of the reader. Taps of unknown readers are stored without location.
+func (s *service) readerLocationID(ctx context.Context, readerID string) (null.Int, error) {
+
+	if readerID == "" {
+		return null.Int{}, nil
+	}
+
+	l, err := s.locationService.GetLocationByReaderID(ctx, readerID)
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		slog.WarnContext(ctx, "reader is not assigned to a location", "reader", readerID)
+		return null.Int{}, nil
+	} else if err != nil {
+		return null.Int{}, err
+	}
+
+	return null.IntFrom(l.ID), nil
+}
+
+func (s *service) GetOccupancy(ctx context.Context, filter Filter) (*Occupancy, error) {
+	return s.occupancyAt(ctx, time.Now(), filter)
+}
+
+func (s *service) ListOccupancySeries(ctx context.Context, day time.Time, groupID null.Int,
+	filter Filter) ([]OccupancyPoint, error) {
+
+	// the series follows the local time of the hall, not the utc dates of the checkIns
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+	end := start.AddDate(0, 0, 1)
+
+	checkIns, err := s.repo.ListCheckInsBetween(ctx, start.Add(-s.occupancy.stay), end, filter)
+	if err != nil {
+		return nil, err
+	}
 
-	return checkin, nil
+	if groupID.Valid {
+		groupCheckIns := make([]WithUser, 0, len(checkIns))
+		for _, c := range checkIns {
+			if c.User.GroupID == groupID {
+				groupCheckIns = append(groupCheckIns, c)
+			}
+		}
+		checkIns = groupCheckIns
+	}
+
+	return newOccupancySeries(checkIns, start, end, occupancySeriesStep, s.occupancy.stay), nil
 }
 
-func (s *service) createCheckinForUser(ctx context.Context, user *user.User, timestamp time.Time) (*CheckIn, error) {
+// occupancyAt returns the occupancy at the timestamp, with the groups of the scope of the filter.
+// The capacity of a location replaces the configured capacity.
+func (s *service) occupancyAt(ctx context.Context, timestamp time.Time, filter Filter) (*Occupancy, error) {
+
+	ctx, span := tracing.Start(ctx, "checkin.occupancyAt")
+	defer span.End()
+
+	checkIns, err := s.repo.ListCheckInsBetween(ctx, timestamp.Add(-s.occupancy.stay), timestamp, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	capacity := s.occupancy.capacity
+	if filter.LocationID.Valid {
+		l, err := s.locationService.GetLocationByID(ctx, filter.LocationID.Int64)
+		if err != nil {
+			return nil, err
+		}
+		if l.Capacity.Valid {
+			capacity = l.Capacity
+		}
+	}
+
+	groups, err := s.groupService.ListGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	scopeGroups := make([]group.Group, 0, len(groups))
+	for _, g := range groups {
+		inScope, err := s.groupService.InScope(ctx, filter.Scope, null.IntFrom(g.ID))
+		if err != nil {
+			return nil, err
+		}
+		if inScope {
+			scopeGroups = append(scopeGroups, g)
+		}
+	}
+
+	occupancy := newOccupancy(checkIns, scopeGroups, capacity, timestamp, s.occupancy.stay)
+	occupancy.LocationID = filter.LocationID
+	return &occupancy, nil
+}
+
+// checkInWithinCapacity checks the user in at the location, unless its capacity is reached. The check and the
+// checkIn are one transaction and the taps of the tenant are serialized, so that two taps can't take the last place.
+func (s *service) checkInWithinCapacity(ctx context.Context, u *user.User, locationID null.Int,
+	timestamp time.Time) (*CheckIn, *Occupancy, error) {
+
+	var checkIn *CheckIn
+	var occupancy *Occupancy
+
+	err := s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+
+		if err := s.repo.LockTenant(ctx); err != nil {
+			return err
+		}
+
+		full, o, err := s.capacityReached(ctx, u, locationID, timestamp)
+		occupancy = o
+		if err != nil {
+			return err
+		}
+
+		if full {
+			return fmt.Errorf("no more checkIns possible for user %d: %w", u.ID, app.ErrCapacityReached)
+		}
+
+		checkIn, err = s.createCheckinForUser(ctx, u, locationID, timestamp, sourceRFID)
+		return err
+	})
+
+	return checkIn, occupancy, err
+}
+
+// capacityReached returns true if the user may not check in at the location because the capacity is reached.
+// Users who are already present at the location are never rejected.
+func (s *service) capacityReached(ctx context.Context, u *user.User, locationID null.Int,
+	timestamp time.Time) (bool, *Occupancy, error) {
+
+	occupancy, err := s.occupancyAt(ctx, timestamp, Filter{LocationID: locationID})
+	if err != nil || !occupancy.Full(u.GroupID) {
+		return false, occupancy, err
+	}
+
+	checkIns, err := s.repo.ListCheckInsBetween(ctx, timestamp.Add(-s.occupancy.stay), timestamp,
+		Filter{LocationID: locationID})
+	if err != nil {
+		return false, occupancy, err
+	}
+
+	_, alreadyPresent := present(checkIns, timestamp, s.occupancy.stay)[u.ID]
+	return !alreadyPresent, occupancy, nil
+}
+
+// userDisplayAndNotes returns the display and the notes shown to the user on a tap.
+func (s *service) userDisplayAndNotes(ctx context.Context, u *user.User,
+	timestamp time.Time) (Display, []announcement.Announcement, error) {
+
+	ctx, span := tracing.Start(ctx, "checkin.userDisplayAndNotes")
+	defer span.End()
+
+	checkIns, err := s.repo.ListUserCheckIns(ctx, u.ID)
+	if err != nil {
+		return Display{}, nil, err
+	}
+
+	notes, err := s.announcementService.ListUserNotes(ctx, u, timestamp)
+	if err != nil {
+		return Display{}, nil, err
+	}
+
+	return newDisplay(u, checkIns, timestamp, s.seasonStart), notes, nil
+}
+
+func (s *service) createCheckinForUser(ctx context.Context, user *user.User, locationID null.Int,
+	timestamp time.Time, source string) (*CheckIn, error) {
 
 	checkIn := CheckIn{
-		ID:        -1,
-		Date:      truncateToStartOfDay(timestamp),
-		Timestamp: timestamp,
-		UserID:    user.ID,
+		ID:         -1,
+		Date:       truncateToStartOfDay(timestamp),
+		Timestamp:  timestamp,
+		UserID:     user.ID,
+		LocationID: locationID,
 	}
 
 	savedCheckIn, err := s.repo.SaveCheckIn(ctx, &checkIn)
 
-	var pgErr *pq.Error
-	if errors.As(err, &pgErr) {
-		if pgErr.Code == "23505" {
-			return nil, fmt.Errorf("checkIn for day already exists: %w", app.ErrConflict)
-		}
+	if errors.Is(err, app.ErrConflict) {
+		return nil, fmt.Errorf("checkIn for day already exists: %w", err)
+	} else if err == nil {
+		checkInsCreated.WithLabelValues(source).Inc()
 	}
 
 	return savedCheckIn, err
@@ -152,17 +479,11 @@ func (s *service) DeleteOldCheckIns(ctx context.Context) error {
 		}
 	}
 
-	retentionDaysEnv := os.Getenv("CHECKIN_RETENTION_DAYS")
-	if retentionDaysEnv == "" {
-		retentionDaysEnv = strconv.Itoa(daysInYear)
-	}
-
-	retentionDays, err := strconv.ParseInt(retentionDaysEnv, 10, 64)
-	if err != nil {
-		return err
-	}
+	return s.repo.DeleteCheckInsOlderThan(ctx, s.retentionDays)
+}
 
-	return s.repo.DeleteCheckInsOlderThan(ctx, retentionDays)
+func (s *service) CountOldCheckIns(ctx context.Context) (int64, error) {
+	return s.repo.CountCheckInsOlderThan(ctx, s.retentionDays)
 }
 
 func truncateToStartOfDay(t time.Time) time.Time {
@@ -0,0 +1,75 @@
+//go:build integration
+
+package checkin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/location"
+	"github.com/d-rk/checkin-system/pkg/user"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestMergeCheckIn(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s := newDBService(t, db, config.Default().Checkin)
+
+		alice, err := user.NewRepo(db).SaveUser(ctx, &user.User{Name: "Alice", Role: user.RoleUser})
+		require.NoError(t, err)
+		bob, err := user.NewRepo(db).SaveUser(ctx, &user.User{Name: "Bob", Role: user.RoleUser})
+		require.NoError(t, err)
+		hall, err := location.NewRepo(db).SaveLocation(ctx, &location.Location{Name: "Hall A"})
+		require.NoError(t, err)
+
+		morning := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+		evening := morning.Add(9 * time.Hour)
+
+		tests := []struct {
+			name       string
+			userID     int64
+			locationID null.Int
+			timestamp  time.Time
+			outcome    MergeOutcome
+			expected   CheckIn
+		}{
+			{name: "first of the day", userID: alice.ID, locationID: null.IntFrom(hall.ID), timestamp: evening,
+				outcome: MergeCreated, expected: CheckIn{Timestamp: evening, LocationID: null.IntFrom(hall.ID)}},
+			{name: "earlier replaces", userID: alice.ID, timestamp: morning, outcome: MergeUpdated,
+				expected: CheckIn{Timestamp: morning}},
+			{name: "later is kept", userID: alice.ID, locationID: null.IntFrom(hall.ID), timestamp: evening,
+				outcome: MergeKept, expected: CheckIn{Timestamp: morning}},
+			{name: "unknown location is dropped", userID: bob.ID, locationID: null.IntFrom(hall.ID + 1),
+				timestamp: evening, outcome: MergeCreated, expected: CheckIn{Timestamp: evening}},
+		}
+
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				_, outcome, err := s.MergeCheckIn(ctx, tc.userID, tc.locationID, tc.timestamp)
+				require.NoError(t, err)
+				assert.Equal(t, tc.outcome, outcome)
+
+				checkIns, err := s.ListUserCheckIns(ctx, tc.userID)
+				require.NoError(t, err)
+				require.Len(t, checkIns, 1)
+				assert.True(t, tc.expected.Timestamp.Equal(checkIns[0].Timestamp), "timestamp %v",
+					checkIns[0].Timestamp)
+				assert.Equal(t, tc.expected.LocationID, checkIns[0].LocationID)
+			})
+		}
+
+		_, _, err = s.MergeCheckIn(ctx, bob.ID+1, null.Int{}, morning)
+		require.ErrorIs(t, err, app.ErrNotFound)
+	})
+}
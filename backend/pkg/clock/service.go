@@ -18,8 +18,8 @@ type service struct {
 	executor cmd.Executor
 }
 
-func NewService() Service {
-	return &service{executor: cmd.NewExecutor()}
+func NewService(executor cmd.Executor) Service {
+	return &service{executor: executor}
 }
 
 func (s *service) GetClock(_ context.Context) (Clock, error) {
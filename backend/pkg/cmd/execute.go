@@ -6,9 +6,15 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
-	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/d-rk/checkin-system/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Executor interface {
@@ -20,10 +26,9 @@ type Cmd interface {
 	CombinedOutput() ([]byte, error)
 }
 
-func NewExecutor() Executor {
-	executor := executor{
-		useSSHTunnel: os.Getenv("USE_SSH_TUNNEL") != "",
-	}
+// NewExecutor returns an executor running the commands locally, or on the ssh host if the tunnel is configured.
+func NewExecutor(cfg config.Executor) Executor {
+	executor := executor{cfg}
 
 	err := executor.Call(context.Background(), "echo", "executor initialized")
 	if err != nil {
@@ -34,26 +39,38 @@ func NewExecutor() Executor {
 }
 
 type executor struct {
-	useSSHTunnel bool
+	config config.Executor
 }
 
 func (e *executor) CallString(ctx context.Context, command string, args ...string) (string, error) {
 
+	ctx, span := tracing.Start(ctx, "exec "+filepath.Base(command),
+		attribute.String("process.command", command),
+		attribute.Bool("process.ssh_tunnel", e.config.UseSSHTunnel))
+
 	var cmd *exec.Cmd
 
-	if e.useSSHTunnel {
-		sshHost := os.Getenv("SSH_HOST")
-		sshUser := os.Getenv("SSH_USER")
-		sshPassword := os.Getenv("SSH_PASSWORD")
+	if e.config.UseSSHTunnel {
 		originalCommand := mergeCommand(command, args)
 
-		cmd = exec.CommandContext(ctx, "sshpass", "-p", sshPassword, "ssh",
-			"-o", "StrictHostKeyChecking=no", fmt.Sprintf("%s@%s", sshUser, sshHost), originalCommand)
+		cmd = exec.CommandContext(ctx, "sshpass", "-p", e.config.SSHPassword, "ssh",
+			"-o", "StrictHostKeyChecking=no", fmt.Sprintf("%s@%s", e.config.SSHUser, e.config.SSHHost),
+			originalCommand)
 	} else {
 		cmd = exec.CommandContext(ctx, command, args...)
 	}
 
+	start := time.Now()
 	output, err := cmd.CombinedOutput()
+
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	// labelled without the directory of the command, e.g. of the wifi script in the temp dir
+	callDuration.WithLabelValues(filepath.Base(command), result).Observe(time.Since(start).Seconds())
+	tracing.End(span, err)
+
 	if err != nil {
 		logOutputLines(ctx, slog.LevelError, command, output)
 		return string(output), fmt.Errorf("call failed: %s %v error=%w", command, args, err)
@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/d-rk/checkin-system/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var callDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: metrics.Namespace,
+	Name:      "executor_call_duration_seconds",
+	Help:      "Duration of the commands called by the executor by command and result (ok or error).",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"command", "result"})
@@ -0,0 +1,264 @@
+package config
+
+import "net/netip"
+
+const (
+	defaultPort                   = 8080
+	defaultShutdownTimeoutSeconds = 30
+	defaultDatabasePort           = 5432
+	defaultKeyRotationDays        = 30
+	defaultTokenExpiryMinutes     = 60
+	defaultRefreshTokenExpiryDays = 30
+	defaultPasswordMinLength      = 8
+	defaultMaxFailuresPerUser     = 5
+	defaultMaxFailuresPerIP       = 20
+	defaultLockoutMinutes         = 15
+	defaultSMTPPort               = 587
+	defaultResetExpiryMinutes     = 60
+	defaultInvitationExpiryDays   = 7
+	defaultRetentionDays          = 356
+	defaultSeasonStartMonth       = 9
+	defaultOccupancyStayMinutes   = 120
+	defaultBackupIntervalHours    = 24
+	defaultBackupGenerations      = 7
+	defaultSyncIntervalSeconds    = 60
+)
+
+// Config is the configuration of the backend, grouped by the services using it.
+//
+// Every setting has a key in the YAML file and an environment variable, which takes precedence over the file.
+// Settings which are not configured keep the defaults of Default.
+type Config struct {
+	Server        Server        `yaml:"server"`
+	Database      Database      `yaml:"database"`
+	Auth          Auth          `yaml:"auth"`
+	Users         Users         `yaml:"users"`
+	Password      Password      `yaml:"password"`
+	Lockout       Lockout       `yaml:"lockout"`
+	TOTP          TOTP          `yaml:"totp"`
+	OIDC          OIDC          `yaml:"oidc"`
+	Mail          Mail          `yaml:"mail"`
+	PasswordReset PasswordReset `yaml:"password_reset"`
+	Tenant        Tenant        `yaml:"tenant"`
+	Checkin       Checkin       `yaml:"checkin"`
+	Executor      Executor      `yaml:"executor"`
+	Backup        Backup        `yaml:"backup"`
+	Sync          Sync          `yaml:"sync"`
+	Tracing       Tracing       `yaml:"tracing"`
+}
+
+type Server struct {
+	ListenAddress          string `yaml:"listen_address" env:"LISTEN_ADDRESS"`
+	Port                   int    `yaml:"port" env:"PORT"`
+	ShutdownTimeoutSeconds int    `yaml:"shutdown_timeout_seconds" env:"SHUTDOWN_TIMEOUT_SECONDS"`
+	// CORSAllowedOrigins are the origins allowed to call the api from a browser, "*" allows every origin.
+	CORSAllowedOrigins []string `yaml:"cors_allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+	// TrustedProxies are the IP addresses or CIDR ranges of the reverse proxies. Only their X-Forwarded-For and
+	// X-Real-IP headers are used as the address of the client.
+	TrustedProxies []string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+}
+
+// TrustedProxyPrefixes parses the trusted proxies, an IP address is a prefix of its full length.
+func (s *Server) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+
+	prefixes := make([]netip.Prefix, 0, len(s.TrustedProxies))
+
+	for _, entry := range s.TrustedProxies {
+
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+type Database struct {
+	Driver string `yaml:"driver" env:"DB_DRIVER"`
+	// Name is the name of the postgres database or the path of the sqlite file.
+	Name     string `yaml:"name" env:"DB_NAME"`
+	Host     string `yaml:"host" env:"DB_HOST"`
+	Port     int    `yaml:"port" env:"DB_PORT"`
+	User     string `yaml:"user" env:"DB_USER"`
+	Password string `yaml:"password" env:"DB_PASSWORD" secret:"true"`
+	SSLMode  string `yaml:"ssl_mode" env:"DB_SSL_MODE"`
+}
+
+type Auth struct {
+	SigningAlgorithm string `yaml:"signing_algorithm" env:"JWT_SIGNING_ALGORITHM"`
+	// KeyDir stores the signing keys in files instead of the database.
+	KeyDir                 string `yaml:"key_dir" env:"JWT_KEY_DIR"`
+	KeyRotationDays        int    `yaml:"key_rotation_days" env:"JWT_KEY_ROTATION_DAYS"`
+	TokenExpiryMinutes     int    `yaml:"token_expiry_minutes" env:"TOKEN_EXPIRY_MINUTES"`
+	RefreshTokenExpiryDays int    `yaml:"refresh_token_expiry_days" env:"REFRESH_TOKEN_EXPIRY_DAYS"`
+	// LegacySecret verifies the HS256 tokens issued before the introduction of signing keys.
+	LegacySecret string `yaml:"legacy_secret" env:"API_SECRET" secret:"true"`
+}
+
+type Users struct {
+	AdminPassword      string `yaml:"admin_password" env:"ADMIN_PASSWORD" secret:"true"`
+	SuperAdminPassword string `yaml:"super_admin_password" env:"SUPER_ADMIN_PASSWORD" secret:"true"`
+}
+
+type Password struct {
+	HashAlgorithm    string `yaml:"hash_algorithm" env:"PASSWORD_HASH_ALGORITHM"`
+	MinLength        int    `yaml:"min_length" env:"PASSWORD_MIN_LENGTH"`
+	BreachedListFile string `yaml:"breached_list_file" env:"PASSWORD_BREACHED_LIST_FILE"`
+}
+
+type Lockout struct {
+	MaxFailuresPerUser int `yaml:"max_failures_per_user" env:"LOGIN_MAX_FAILURES_PER_USER"`
+	MaxFailuresPerIP   int `yaml:"max_failures_per_ip" env:"LOGIN_MAX_FAILURES_PER_IP"`
+	LockoutMinutes     int `yaml:"lockout_minutes" env:"LOGIN_LOCKOUT_MINUTES"`
+	BackoffSeconds     int `yaml:"backoff_seconds" env:"LOGIN_BACKOFF_SECONDS"`
+}
+
+type TOTP struct {
+	Issuer           string `yaml:"issuer" env:"TOTP_ISSUER"`
+	RequiredForAdmin bool   `yaml:"required_for_admin" env:"TOTP_REQUIRED_FOR_ADMIN"`
+}
+
+type OIDC struct {
+	// IssuerURL enables the login with the identity provider.
+	IssuerURL     string   `yaml:"issuer_url" env:"OIDC_ISSUER_URL"`
+	ClientID      string   `yaml:"client_id" env:"OIDC_CLIENT_ID"`
+	ClientSecret  string   `yaml:"client_secret" env:"OIDC_CLIENT_SECRET" secret:"true"`
+	RedirectURL   string   `yaml:"redirect_url" env:"OIDC_REDIRECT_URL"`
+	Scopes        []string `yaml:"scopes" env:"OIDC_SCOPES"`
+	UsernameClaim string   `yaml:"username_claim" env:"OIDC_USERNAME_CLAIM"`
+	RoleClaim     string   `yaml:"role_claim" env:"OIDC_ROLE_CLAIM"`
+	// RoleMapping maps values of the role claim to roles, e.g. board:ADMIN.
+	RoleMapping   []string `yaml:"role_mapping" env:"OIDC_ROLE_MAPPING"`
+	AutoProvision bool     `yaml:"auto_provision" env:"OIDC_AUTO_PROVISION"`
+}
+
+type Mail struct {
+	// Sender is smtp, file (writes every mail to FileDir) or log.
+	Sender       string `yaml:"sender" env:"MAIL_SENDER"`
+	From         string `yaml:"from" env:"MAIL_FROM"`
+	FileDir      string `yaml:"file_dir" env:"MAIL_FILE_DIR"`
+	SMTPHost     string `yaml:"smtp_host" env:"SMTP_HOST"`
+	SMTPPort     int    `yaml:"smtp_port" env:"SMTP_PORT"`
+	SMTPUsername string `yaml:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword string `yaml:"smtp_password" env:"SMTP_PASSWORD" secret:"true"`
+}
+
+type PasswordReset struct {
+	// BaseURL is the url of the frontend the links in the mails point to.
+	BaseURL              string `yaml:"base_url" env:"APP_BASE_URL"`
+	ResetExpiryMinutes   int    `yaml:"reset_expiry_minutes" env:"PASSWORD_RESET_EXPIRY_MINUTES"`
+	InvitationExpiryDays int    `yaml:"invitation_expiry_days" env:"INVITATION_EXPIRY_DAYS"`
+}
+
+type Tenant struct {
+	// BaseDomain enables the multi-tenant mode, every tenant is served at its subdomain.
+	BaseDomain string `yaml:"base_domain" env:"TENANT_BASE_DOMAIN"`
+}
+
+type Checkin struct {
+	RetentionDays        int `yaml:"retention_days" env:"CHECKIN_RETENTION_DAYS"`
+	SeasonStartMonth     int `yaml:"season_start_month" env:"CHECKIN_SEASON_START_MONTH"`
+	OccupancyStayMinutes int `yaml:"occupancy_stay_minutes" env:"OCCUPANCY_STAY_MINUTES"`
+	// OccupancyCapacity limits the number of people present, 0 is unlimited.
+	OccupancyCapacity int `yaml:"occupancy_capacity" env:"OCCUPANCY_CAPACITY"`
+}
+
+type Executor struct {
+	// UseSSHTunnel runs the commands on the ssh host, e.g. the host of the container.
+	UseSSHTunnel bool   `yaml:"use_ssh_tunnel" env:"USE_SSH_TUNNEL"`
+	SSHHost      string `yaml:"ssh_host" env:"SSH_HOST"`
+	SSHUser      string `yaml:"ssh_user" env:"SSH_USER"`
+	SSHPassword  string `yaml:"ssh_password" env:"SSH_PASSWORD" secret:"true"`
+}
+
+type Backup struct {
+	// Dir enables the local backups.
+	Dir           string `yaml:"dir" env:"BACKUP_DIR"`
+	IntervalHours int    `yaml:"interval_hours" env:"BACKUP_INTERVAL_HOURS"`
+	Generations   int    `yaml:"generations" env:"BACKUP_GENERATIONS"`
+}
+
+type Sync struct {
+	// UpstreamURL makes this an edge instance syncing with the upstream instance.
+	UpstreamURL string `yaml:"upstream_url" env:"SYNC_UPSTREAM_URL"`
+	// Username is a user with the SYNC role upstream, which may only access the sync operations.
+	Username        string `yaml:"username" env:"SYNC_USERNAME"`
+	Password        string `yaml:"password" env:"SYNC_PASSWORD" secret:"true"`
+	IntervalSeconds int    `yaml:"interval_seconds" env:"SYNC_INTERVAL_SECONDS"`
+}
+
+type Tracing struct {
+	// Exporter is otlp (configured with the OTEL_EXPORTER_OTLP_* variables), stdout or none.
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"`
+}
+
+// Default returns the configuration used for the settings which are not configured.
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			ListenAddress:          "0.0.0.0",
+			Port:                   defaultPort,
+			ShutdownTimeoutSeconds: defaultShutdownTimeoutSeconds,
+		},
+		Database: Database{
+			Port: defaultDatabasePort,
+		},
+		Auth: Auth{
+			SigningAlgorithm:       "EdDSA",
+			KeyRotationDays:        defaultKeyRotationDays,
+			TokenExpiryMinutes:     defaultTokenExpiryMinutes,
+			RefreshTokenExpiryDays: defaultRefreshTokenExpiryDays,
+		},
+		Password: Password{
+			HashAlgorithm: "argon2id",
+			MinLength:     defaultPasswordMinLength,
+		},
+		Lockout: Lockout{
+			MaxFailuresPerUser: defaultMaxFailuresPerUser,
+			MaxFailuresPerIP:   defaultMaxFailuresPerIP,
+			LockoutMinutes:     defaultLockoutMinutes,
+			BackoffSeconds:     1,
+		},
+		TOTP: TOTP{
+			Issuer: "checkin-system",
+		},
+		OIDC: OIDC{
+			Scopes:        []string{"openid", "profile", "email"},
+			UsernameClaim: "preferred_username",
+			RoleClaim:     "groups",
+		},
+		Mail: Mail{
+			Sender:   "log",
+			SMTPPort: defaultSMTPPort,
+		},
+		PasswordReset: PasswordReset{
+			BaseURL:              "http://localhost:5173",
+			ResetExpiryMinutes:   defaultResetExpiryMinutes,
+			InvitationExpiryDays: defaultInvitationExpiryDays,
+		},
+		Checkin: Checkin{
+			RetentionDays:        defaultRetentionDays,
+			SeasonStartMonth:     defaultSeasonStartMonth,
+			OccupancyStayMinutes: defaultOccupancyStayMinutes,
+		},
+		Backup: Backup{
+			IntervalHours: defaultBackupIntervalHours,
+			Generations:   defaultBackupGenerations,
+		},
+		Sync: Sync{
+			IntervalSeconds: defaultSyncIntervalSeconds,
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			SampleRatio: 1,
+		},
+	}
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+database:
+  driver: sqlite3
+  name: checkin.db
+auth:
+  token_expiry_minutes: 30
+server:
+  cors_allowed_origins: [https://checkin.example.org]
+`), 0o600))
+
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("TOKEN_EXPIRY_MINUTES", "15")
+	t.Setenv("OIDC_SCOPES", "openid, email")
+	t.Setenv("TOTP_REQUIRED_FOR_ADMIN", "true")
+
+	config, err := Load()
+	require.NoError(t, err)
+
+	assert.Equal(t, "checkin.db", config.Database.Name)
+	assert.Equal(t, 15, config.Auth.TokenExpiryMinutes, "env overrides the file")
+	assert.Equal(t, []string{"https://checkin.example.org"}, config.Server.CORSAllowedOrigins)
+	assert.Equal(t, []string{"openid", "email"}, config.OIDC.Scopes)
+	assert.True(t, config.TOTP.RequiredForAdmin)
+	assert.Equal(t, 30, config.Server.ShutdownTimeoutSeconds, "default")
+}
+
+func TestLoad_Invalid(t *testing.T) {
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("database:\n  nmae: checkin.db\n"), 0o600))
+	t.Setenv("CONFIG_FILE", file)
+
+	_, err := Load()
+	require.ErrorContains(t, err, "field nmae not found")
+
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DB_DRIVER", "sqlite3")
+	t.Setenv("DB_NAME", "checkin.db")
+	t.Setenv("TOKEN_EXPIRY_MINUTES", "soon")
+
+	_, err = Load()
+	require.ErrorContains(t, err, "invalid TOKEN_EXPIRY_MINUTES (auth.token_expiry_minutes): expected an integer")
+}
+
+func TestValidate(t *testing.T) {
+
+	config := Default()
+	config.Database.Driver = "postgres"
+	config.Database.Name = "checkin"
+	config.Mail.Sender = "smtp"
+	config.Sync.UpstreamURL = "checkin.example.org"
+	config.Sync.Password = "secret"
+	config.Auth.TokenExpiryMinutes = 0
+	config.Server.TrustedProxies = []string{"10.0.0.0/8", "proxy.example.org"}
+
+	err := config.Validate()
+	require.Error(t, err)
+
+	for _, expected := range []string{
+		`database.host (DB_HOST) is required for postgres, got ""`,
+		`auth.token_expiry_minutes (TOKEN_EXPIRY_MINUTES) must be at least 1, got 0`,
+		`mail.smtp_host (SMTP_HOST) is required for the smtp mail sender, got ""`,
+		`sync.upstream_url (SYNC_UPSTREAM_URL) must be an absolute http(s) url, got "checkin.example.org"`,
+		`database.driver (DB_DRIVER) must be sqlite3 on edge instances, got "postgres"`,
+		`server.trusted_proxies (TRUSTED_PROXIES) must be a list of IP addresses or CIDR ranges`,
+	} {
+		assert.ErrorContains(t, err, expected)
+	}
+
+	config = Default()
+	config.Database.Driver = "sqlite3"
+	config.Database.Name = "checkin.db"
+	config.Server.TrustedProxies = []string{"172.16.0.0/12", "::1"}
+	assert.NoError(t, config.Validate())
+}
+
+func TestPrint(t *testing.T) {
+
+	config := Default()
+	config.Database.Password = "postgres"
+	config.Auth.LegacySecret = "secret"
+
+	var out bytes.Buffer
+	require.NoError(t, config.Print(&out))
+
+	assert.Contains(t, out.String(), "password: '********' # DB_PASSWORD")
+	assert.Contains(t, out.String(), "legacy_secret: '********' # API_SECRET")
+	assert.Contains(t, out.String(), `admin_password: "" # ADMIN_PASSWORD`)
+	assert.Contains(t, out.String(), "token_expiry_minutes: 60 # TOKEN_EXPIRY_MINUTES")
+	assert.NotContains(t, out.String(), "secret\n")
+	assert.Equal(t, "postgres", config.Database.Password, "the configuration is not changed")
+}
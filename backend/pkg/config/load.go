@@ -0,0 +1,215 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+const secretMask = "********"
+
+// Load returns the validated configuration: the defaults, overridden by the YAML file in CONFIG_FILE (if set),
+// overridden by the environment variables. A .env file in the working directory is added to the environment.
+func Load() (*Config, error) {
+
+	_ = godotenv.Load(".env")
+
+	config := Default()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := config.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	// the invalid variables keep the value of the file, so that all errors are reported at once
+	if err := errors.Join(config.applyEnv(), config.Validate()); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func (c *Config) readFile(path string) error {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	// a misspelled key would otherwise silently keep the default
+	decoder.KnownFields(true)
+
+	if err = decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// applyEnv overrides the settings with the environment variables which are set and not empty.
+func (c *Config) applyEnv() error {
+
+	var errs []error
+
+	for _, s := range c.settings() {
+		value := os.Getenv(s.env)
+		if value == "" {
+			continue
+		}
+		if err := s.set(value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s (%s): %w", s.env, s.key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Print writes the configuration as YAML file, with the names of the environment variables as comments and the
+// values of the secrets masked.
+func (c *Config) Print(w io.Writer) error {
+
+	masked := *c
+	for _, s := range masked.settings() {
+		if s.secret && s.value.String() != "" {
+			s.value.SetString(secretMask)
+		}
+	}
+
+	var document yaml.Node
+	if err := document.Encode(&masked); err != nil {
+		return err
+	}
+
+	envs := make(map[string]string)
+	for _, s := range masked.settings() {
+		envs[s.key] = s.env
+	}
+
+	// the document is a mapping of the sections, each of them a mapping of the settings
+	for i := 0; i+1 < len(document.Content); i += 2 {
+		section := document.Content[i].Value
+		settings := document.Content[i+1].Content
+		for j := 0; j+1 < len(settings); j += 2 {
+			key, value := settings[j], settings[j+1]
+			// the comment of a key is placed after its value, unless the value is an empty list
+			if value.Kind == yaml.SequenceNode && len(value.Content) == 0 {
+				value.LineComment = envs[section+"."+key.Value]
+			} else {
+				key.LineComment = envs[section+"."+key.Value]
+			}
+		}
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(&document); err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
+
+// setting is a field of a section of the configuration.
+type setting struct {
+	// key is the path in the YAML file, e.g. auth.token_expiry_minutes.
+	key    string
+	env    string
+	secret bool
+	value  reflect.Value
+}
+
+func (c *Config) settings() []setting {
+
+	var settings []setting
+
+	sections := reflect.ValueOf(c).Elem()
+
+	for i := range sections.NumField() {
+		section := sections.Field(i)
+		sectionKey := sections.Type().Field(i).Tag.Get("yaml")
+
+		for j := range section.NumField() {
+			field := section.Type().Field(j)
+			settings = append(settings, setting{
+				key:    sectionKey + "." + field.Tag.Get("yaml"),
+				env:    field.Tag.Get("env"),
+				secret: field.Tag.Get("secret") == "true",
+				value:  section.Field(j),
+			})
+		}
+	}
+
+	return settings
+}
+
+func (s setting) set(value string) error {
+
+	//nolint:exhaustive // the configuration only has fields of these kinds
+	switch s.value.Kind() {
+	case reflect.String:
+		s.value.SetString(value)
+	case reflect.Int:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("expected an integer")
+		}
+		s.value.SetInt(int64(i))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.New("expected a number")
+		}
+		s.value.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("expected true or false")
+		}
+		s.value.SetBool(b)
+	case reflect.Slice:
+		s.value.Set(reflect.ValueOf(splitList(value)))
+	default:
+		return fmt.Errorf("unsupported kind %s", s.value.Kind())
+	}
+
+	return nil
+}
+
+// display returns the value for messages, secrets are masked.
+func (s setting) display() string {
+
+	if s.secret {
+		return secretMask
+	}
+
+	if s.value.Kind() == reflect.String {
+		return strconv.Quote(s.value.String())
+	}
+
+	return fmt.Sprint(s.value.Interface())
+}
+
+// splitList splits a comma separated list, e.g. of CORS_ALLOWED_ORIGINS.
+func splitList(value string) []string {
+
+	var list []string
+
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+
+	return list
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+const maxPort = 65535
+
+// Validate checks all settings and returns an error listing every invalid one with its key and variable.
+func (c *Config) Validate() error {
+
+	v := newValidator(c)
+
+	c.validateInfrastructure(v)
+	c.validateAccounts(v)
+
+	v.atLeast(&c.Checkin.RetentionDays, 1)
+	v.between(&c.Checkin.SeasonStartMonth, 1, 12) //nolint:mnd // months
+	v.atLeast(&c.Checkin.OccupancyStayMinutes, 1)
+	v.atLeast(&c.Checkin.OccupancyCapacity, 0)
+
+	return v.err()
+}
+
+// validateInfrastructure checks the settings of the server, the database and the external systems.
+func (c *Config) validateInfrastructure(v *validator) {
+
+	v.between(&c.Server.Port, 1, maxPort)
+	v.atLeast(&c.Server.ShutdownTimeoutSeconds, 0)
+	_, err := c.Server.TrustedProxyPrefixes()
+	v.check(&c.Server.TrustedProxies, err == nil, "must be a list of IP addresses or CIDR ranges")
+
+	v.oneOf(&c.Database.Driver, "postgres", "sqlite3")
+	v.required(&c.Database.Name, "")
+	if c.Database.Driver == "postgres" {
+		v.required(&c.Database.Host, "for postgres")
+		v.between(&c.Database.Port, 1, maxPort)
+	}
+
+	v.oneOf(&c.Mail.Sender, "smtp", "file", "log")
+	switch c.Mail.Sender {
+	case "smtp":
+		v.required(&c.Mail.SMTPHost, "for the smtp mail sender")
+		v.required(&c.Mail.From, "for the smtp mail sender")
+		v.between(&c.Mail.SMTPPort, 1, maxPort)
+	case "file":
+		v.required(&c.Mail.FileDir, "for the file mail sender")
+	}
+
+	if c.Executor.UseSSHTunnel {
+		v.required(&c.Executor.SSHHost, "for the ssh tunnel")
+		v.required(&c.Executor.SSHUser, "for the ssh tunnel")
+	}
+
+	v.atLeast(&c.Backup.IntervalHours, 1)
+	v.atLeast(&c.Backup.Generations, 1)
+
+	if c.Sync.UpstreamURL != "" {
+		v.url(&c.Sync.UpstreamURL)
+		v.required(&c.Sync.Username, "for the sync")
+		v.check(&c.Database.Driver, c.Database.Driver == "sqlite3", "must be sqlite3 on edge instances")
+	}
+	v.atLeast(&c.Sync.IntervalSeconds, 1)
+
+	v.oneOf(&c.Tracing.Exporter, "otlp", "stdout", "none")
+	v.check(&c.Tracing.SampleRatio, c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1,
+		"must be between 0 and 1")
+}
+
+// validateAccounts checks the settings of the tokens, the passwords and the logins.
+func (c *Config) validateAccounts(v *validator) {
+
+	v.oneOf(&c.Auth.SigningAlgorithm, "EdDSA", "RS256")
+	v.atLeast(&c.Auth.KeyRotationDays, 1)
+	v.atLeast(&c.Auth.TokenExpiryMinutes, 1)
+	v.atLeast(&c.Auth.RefreshTokenExpiryDays, 1)
+
+	v.oneOf(&c.Password.HashAlgorithm, "argon2id", "bcrypt")
+	v.atLeast(&c.Password.MinLength, 1)
+
+	v.atLeast(&c.Lockout.MaxFailuresPerUser, 1)
+	v.atLeast(&c.Lockout.MaxFailuresPerIP, 1)
+	v.atLeast(&c.Lockout.LockoutMinutes, 1)
+	v.atLeast(&c.Lockout.BackoffSeconds, 0)
+
+	v.required(&c.TOTP.Issuer, "")
+
+	if c.OIDC.IssuerURL != "" {
+		v.url(&c.OIDC.IssuerURL)
+		v.required(&c.OIDC.ClientID, "for the OpenID Connect login")
+		v.url(&c.OIDC.RedirectURL)
+		v.required(&c.OIDC.UsernameClaim, "for the OpenID Connect login")
+	}
+	v.check(&c.OIDC.RoleMapping, validRoleMapping(c.OIDC.RoleMapping), "must contain entries like value:ROLE")
+
+	v.url(&c.PasswordReset.BaseURL)
+	v.atLeast(&c.PasswordReset.ResetExpiryMinutes, 1)
+	v.atLeast(&c.PasswordReset.InvitationExpiryDays, 1)
+}
+
+func validRoleMapping(mapping []string) bool {
+	for _, entry := range mapping {
+		if value, role, found := strings.Cut(entry, ":"); !found || value == "" || role == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// validator collects the invalid settings, which are identified by the address of their field.
+type validator struct {
+	settings map[uintptr]setting
+	errs     []error
+}
+
+func newValidator(c *Config) *validator {
+
+	v := &validator{settings: make(map[uintptr]setting)}
+	for _, s := range c.settings() {
+		v.settings[s.value.Addr().Pointer()] = s
+	}
+
+	return v
+}
+
+func (v *validator) check(field any, valid bool, requirement string) {
+
+	if valid {
+		return
+	}
+
+	s, found := v.settings[reflect.ValueOf(field).Pointer()]
+	if !found {
+		panic(fmt.Sprintf("%T is not a setting", field))
+	}
+
+	v.errs = append(v.errs, fmt.Errorf("%s (%s) %s, got %s", s.key, s.env, requirement, s.display()))
+}
+
+func (v *validator) required(field *string, condition string) {
+	v.check(field, *field != "", strings.TrimSpace("is required "+condition))
+}
+
+func (v *validator) oneOf(field *string, values ...string) {
+	v.check(field, slices.Contains(values, *field), "must be one of "+strings.Join(values, ", "))
+}
+
+func (v *validator) atLeast(field *int, minimum int) {
+	v.check(field, *field >= minimum, fmt.Sprintf("must be at least %d", minimum))
+}
+
+func (v *validator) between(field *int, minimum, maximum int) {
+	v.check(field, *field >= minimum && *field <= maximum, fmt.Sprintf("must be between %d and %d", minimum, maximum))
+}
+
+func (v *validator) url(field *string) {
+	u, err := url.Parse(*field)
+	v.check(field, err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+		"must be an absolute http(s) url")
+}
+
+func (v *validator) err() error {
+
+	if len(v.errs) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("invalid configuration:\n%w", errors.Join(v.errs...))
+}
@@ -3,67 +3,56 @@ package database
 import (
 	"fmt"
 	"log/slog"
+	"net"
 	"os"
+	"strconv"
+
+	"github.com/d-rk/checkin-system/pkg/config"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"           //revive: postgres driver
 	_ "github.com/mattn/go-sqlite3" //revive: sqlite3 driver
-	migrate "github.com/rubenv/sql-migrate"
 )
 
-func Connect() *sqlx.DB {
-	return connect(os.Getenv("DB_NAME"))
-}
-
-func connect(dbName string) *sqlx.DB {
+// Drivers are the supported database drivers, each with its own migrations.
+var Drivers = []string{"postgres", "sqlite3"}
 
-	dbDriver := os.Getenv("DB_DRIVER")
+func Connect(cfg config.Database) *sqlx.DB {
 
 	var dsn string
 
-	switch dbDriver {
+	switch cfg.Driver {
 	case "postgres":
-		dbHost := os.Getenv("DB_HOST")
-		dbUser := os.Getenv("DB_USER")
-		dbPassword := os.Getenv("DB_PASSWORD")
-		dbPort := os.Getenv("DB_PORT")
-		dbSslMode := os.Getenv("DB_SSL_MODE")
-
 		dsn = fmt.Sprintf(
-			"host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=UTC",
-			dbHost,
-			dbUser,
-			dbPassword,
-			dbName,
-			dbPort,
-			dbSslMode,
+			"host=%s user=%s password=%s dbname=%s port=%d sslmode=%s TimeZone=UTC",
+			cfg.Host,
+			cfg.User,
+			cfg.Password,
+			cfg.Name,
+			cfg.Port,
+			cfg.SSLMode,
 		)
 	case "sqlite3":
-		dsn = fmt.Sprintf("file:%s?_loc=UTC", dbName)
+		// sqlite only enforces the foreign keys and their actions if they are enabled for the connection
+		dsn = fmt.Sprintf("file:%s?_loc=UTC&_fk=1", cfg.Name)
 	}
 
-	db, err := sqlx.Connect(dbDriver, dsn)
-
-	if err != nil {
-		slog.Error("cannot connect to database", "dsn", dsn, "error", err)
-		os.Exit(1)
+	sqlDB, err := openTraced(cfg.Driver, dsn)
+	if err == nil {
+		err = sqlDB.Ping()
 	}
 
-	slog.Info("connected to database", "dsn", dsn, "driver", dbDriver)
-	return db
-}
-
-func RunMigration(db *sqlx.DB) {
-	migrations := &migrate.FileMigrationSource{
-		Dir: "db/migrations/" + db.DriverName(),
+	// the dsn of postgres contains the password
+	address := cfg.Name
+	if cfg.Driver == "postgres" {
+		address = net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)) + "/" + cfg.Name
 	}
 
-	migrate.SetTable("migrations")
-
-	n, err := migrate.Exec(db.DB, db.DriverName(), migrations, migrate.Up)
 	if err != nil {
-		slog.Error("migration failed", "error", err)
+		slog.Error("cannot connect to database", "database", address, "error", err)
 		os.Exit(1)
 	}
-	slog.Info("migration applied", "count", n)
+
+	slog.Info("connected to database", "database", address, "driver", cfg.Driver)
+	return sqlx.NewDb(sqlDB, cfg.Driver)
 }
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Violation is the kind of constraint a statement violated.
+type Violation int
+
+const (
+	UniqueViolation Violation = iota + 1
+	ForeignKeyViolation
+	NotNullViolation
+)
+
+func (v Violation) String() string {
+	switch v {
+	case UniqueViolation:
+		return "unique"
+	case ForeignKeyViolation:
+		return "foreign key"
+	case NotNullViolation:
+		return "not null"
+	default:
+		return "unknown"
+	}
+}
+
+// ConstraintError is a constraint violation reported by the database driver. It matches app.ErrConflict for unique
+// violations and app.ErrInvalid for all other violations, as well as the error of the driver.
+type ConstraintError struct {
+	Violation Violation
+	// Constraint is the name of the constraint or the columns, as far as reported by the driver.
+	Constraint string
+	Err        error
+}
+
+func (e *ConstraintError) Error() string {
+	if e.Constraint == "" {
+		return fmt.Sprintf("%s constraint violated: %v", e.Violation, e.Err)
+	}
+	return fmt.Sprintf("%s constraint %s violated: %v", e.Violation, e.Constraint, e.Err)
+}
+
+func (e *ConstraintError) Unwrap() []error {
+
+	if e.Violation == UniqueViolation {
+		return []error{e.Err, app.ErrConflict}
+	}
+
+	return []error{e.Err, app.ErrInvalid}
+}
+
+// Classifier returns the violation of a driver error, or false if the error is not a constraint violation of the
+// driver.
+type Classifier func(err error) (violation Violation, constraint string, ok bool)
+
+var (
+	classifiersMu sync.RWMutex
+	classifiers   = []Classifier{classifyPostgres, classifySqlite}
+)
+
+// RegisterClassifier adds the classifier of another database driver.
+func RegisterClassifier(classifier Classifier) {
+	classifiersMu.Lock()
+	defer classifiersMu.Unlock()
+
+	classifiers = append(classifiers, classifier)
+}
+
+// Classify returns a ConstraintError if the error is a constraint violation of any driver, otherwise the error
+// is returned unchanged.
+func Classify(err error) error {
+
+	if err == nil {
+		return nil
+	}
+
+	var constraintErr *ConstraintError
+	if errors.As(err, &constraintErr) {
+		return err
+	}
+
+	classifiersMu.RLock()
+	defer classifiersMu.RUnlock()
+
+	for _, classifier := range classifiers {
+		if violation, constraint, ok := classifier(err); ok {
+			return &ConstraintError{Violation: violation, Constraint: constraint, Err: err}
+		}
+	}
+
+	return err
+}
+
+func classifyPostgres(err error) (Violation, string, bool) {
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return 0, "", false
+	}
+
+	switch pqErr.Code {
+	case "23505":
+		return UniqueViolation, pqErr.Constraint, true
+	case "23503":
+		return ForeignKeyViolation, pqErr.Constraint, true
+	case "23502":
+		return NotNullViolation, pqErr.Column, true
+	default:
+		return 0, "", false
+	}
+}
+
+func classifySqlite(err error) (Violation, string, bool) {
+
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return 0, "", false
+	}
+
+	// sqlite only reports the columns in the message, e.g. "UNIQUE constraint failed: users.name"
+	_, columns, _ := strings.Cut(sqliteErr.Error(), "constraint failed: ")
+
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return UniqueViolation, columns, true
+	case sqlite3.ErrConstraintForeignKey:
+		return ForeignKeyViolation, columns, true
+	case sqlite3.ErrConstraintNotNull:
+		return NotNullViolation, columns, true
+	default:
+		return 0, "", false
+	}
+}
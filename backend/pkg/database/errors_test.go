@@ -0,0 +1,76 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		err        error
+		target     error
+		violation  Violation
+		constraint string
+	}{
+		{"postgres unique", &pq.Error{Code: "23505", Constraint: "users_tenant_name_key"}, app.ErrConflict,
+			UniqueViolation, "users_tenant_name_key"},
+		{"postgres foreign key", &pq.Error{Code: "23503", Constraint: "fk_checkins_user"}, app.ErrInvalid,
+			ForeignKeyViolation, "fk_checkins_user"},
+		{"postgres not null", &pq.Error{Code: "23502", Column: "name"}, app.ErrInvalid, NotNullViolation, "name"},
+		{"sqlite unique", sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintUnique},
+			app.ErrConflict, UniqueViolation, ""},
+		{"sqlite primary key",
+			sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintPrimaryKey},
+			app.ErrConflict, UniqueViolation, ""},
+		{"sqlite foreign key",
+			sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintForeignKey},
+			app.ErrInvalid, ForeignKeyViolation, ""},
+		{"sqlite not null", sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintNotNull},
+			app.ErrInvalid, NotNullViolation, ""},
+		{"wrapped", fmt.Errorf("insert failed: %w", &pq.Error{Code: "23505"}), app.ErrConflict, UniqueViolation, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Classify(tt.err)
+
+			require.ErrorIs(t, err, tt.target)
+			require.ErrorIs(t, err, tt.err)
+
+			var constraintErr *ConstraintError
+			require.ErrorAs(t, err, &constraintErr)
+			assert.Equal(t, tt.violation, constraintErr.Violation)
+			assert.Equal(t, tt.constraint, constraintErr.Constraint)
+			assert.Same(t, constraintErr, Classify(err))
+		})
+	}
+}
+
+func TestClassify_OtherErrors_AreUnchanged(t *testing.T) {
+
+	err := errors.New("connection refused")
+
+	assert.Nil(t, Classify(nil))
+	assert.Equal(t, err, Classify(err))
+	assert.Equal(t, &pq.Error{Code: "42P01"}, Classify(&pq.Error{Code: "42P01"}))
+}
+
+func TestRegisterClassifier(t *testing.T) {
+
+	errCustom := errors.New("duplicate key")
+
+	RegisterClassifier(func(err error) (Violation, string, bool) {
+		return UniqueViolation, "custom", errors.Is(err, errCustom)
+	})
+
+	require.ErrorIs(t, Classify(errCustom), app.ErrConflict)
+}
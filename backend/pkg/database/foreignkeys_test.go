@@ -0,0 +1,60 @@
+//go:build integration
+
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForeignKeys_UnknownParent_IsRejected(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		_, err := db.Exec(
+			`INSERT INTO checkins (date, timestamp, user_id) VALUES (current_date, current_timestamp, 999)`)
+
+		var constraintErr *ConstraintError
+		require.ErrorAs(t, Classify(err), &constraintErr)
+		assert.Equal(t, ForeignKeyViolation, constraintErr.Violation)
+		assert.ErrorIs(t, Classify(err), app.ErrInvalid)
+	})
+}
+
+func TestForeignKeys_DeletingTheParent_CascadesAndSetsNull(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+
+		var userID, groupID int64
+		require.NoError(t, db.GetContext(ctx, &groupID,
+			`INSERT INTO groups (created_at, name) VALUES (current_timestamp, 'climbing') RETURNING id`))
+		require.NoError(t, db.GetContext(ctx, &userID, `INSERT INTO users (created_at, name, role, group_id)
+			VALUES (current_timestamp, 'alice', 'USER', $1) RETURNING id`, groupID))
+		_, err := db.ExecContext(ctx,
+			`INSERT INTO announcements (created_at, title, user_id) VALUES (current_timestamp, 'note', $1)`, userID)
+		require.NoError(t, err)
+
+		_, err = db.ExecContext(ctx, `DELETE FROM groups WHERE id = $1`, groupID)
+		require.NoError(t, err)
+
+		var userGroup *int64
+		require.NoError(t, db.GetContext(ctx, &userGroup, `SELECT group_id FROM users WHERE id = $1`, userID))
+		assert.Nil(t, userGroup)
+
+		_, err = db.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, userID)
+		require.NoError(t, err)
+
+		var announcements int
+		require.NoError(t, db.GetContext(ctx, &announcements, `SELECT count(*) FROM announcements`))
+		assert.Zero(t, announcements)
+	})
+}
@@ -0,0 +1,168 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	embedded "github.com/d-rk/checkin-system/db"
+
+	"github.com/jmoiron/sqlx"
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+var migrationSet = migrate.MigrationSet{TableName: "migrations"}
+
+// Migration is a migration of the driver, along with the time it was applied.
+type Migration struct {
+	ID        string
+	AppliedAt *time.Time
+}
+
+func migrationSource(driver string) migrate.MigrationSource {
+	return &migrate.EmbedFileSystemMigrationSource{
+		FileSystem: embedded.Migrations,
+		Root:       "migrations/" + driver,
+	}
+}
+
+func RunMigration(db *sqlx.DB) {
+
+	n, err := MigrateUp(context.Background(), db, 0)
+	if err != nil {
+		slog.Error("migration failed", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("migration applied", "count", n)
+}
+
+// MigrateUp applies at most limit pending migrations, all of them if limit is 0, and returns how many were applied.
+func MigrateUp(ctx context.Context, db *sqlx.DB, limit int) (int, error) {
+	return execMigrations(ctx, db, migrate.Up, limit)
+}
+
+// MigrateDown rolls back at most limit applied migrations, all of them if limit is 0, and returns how many were
+// rolled back.
+func MigrateDown(ctx context.Context, db *sqlx.DB, limit int) (int, error) {
+	return execMigrations(ctx, db, migrate.Down, limit)
+}
+
+// execMigrations executes at most limit migrations in the direction. The sqlite migrations rebuild tables by copying
+// and dropping them, so they run without foreign keys, as dropping a table would delete the rows referencing it.
+// sqlite ignores the pragma within the transactions of the migrations, therefore it is set while the pool is limited
+// to one connection.
+func execMigrations(ctx context.Context, db *sqlx.DB, direction migrate.MigrationDirection, limit int) (int, error) {
+
+	if db.DriverName() != "sqlite3" {
+		return migrationSet.ExecMaxContext(ctx, db.DB, db.DriverName(), migrationSource(db.DriverName()), direction,
+			limit)
+	}
+
+	maxOpenConnections := db.Stats().MaxOpenConnections
+	db.SetMaxOpenConns(1)
+	defer db.SetMaxOpenConns(maxOpenConnections)
+
+	if _, err := db.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return 0, err
+	}
+
+	n, err := migrationSet.ExecMaxContext(ctx, db.DB, db.DriverName(), migrationSource(db.DriverName()), direction,
+		limit)
+
+	if _, pragmaErr := db.ExecContext(ctx, "PRAGMA foreign_keys = ON"); pragmaErr != nil {
+		return n, errors.Join(err, pragmaErr)
+	}
+
+	return n, err
+}
+
+// RedoMigration rolls back the last applied migration and applies it again.
+func RedoMigration(ctx context.Context, db *sqlx.DB) (string, error) {
+
+	migrations, err := Migrations(db)
+	if err != nil {
+		return "", err
+	}
+
+	var last string
+	for _, m := range migrations {
+		if m.AppliedAt != nil {
+			last = m.ID
+		}
+	}
+
+	if last == "" {
+		return "", errors.New("no migration applied")
+	}
+
+	if _, err = MigrateDown(ctx, db, 1); err != nil {
+		return "", fmt.Errorf("failed to roll back %s: %w", last, err)
+	}
+
+	if _, err = MigrateUp(ctx, db, 1); err != nil {
+		return "", fmt.Errorf("failed to apply %s: %w", last, err)
+	}
+
+	return last, nil
+}
+
+// Migrations returns all migrations of the driver, as well as applied migrations which are unknown to the binary.
+func Migrations(db *sqlx.DB) ([]Migration, error) {
+
+	known, err := migrationSource(db.DriverName()).FindMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := migrationSet.GetMigrationRecords(db.DB, db.DriverName())
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make(map[string]*Migration, len(known))
+	for _, m := range known {
+		migrations[m.Id] = &Migration{ID: m.Id}
+	}
+
+	for _, r := range records {
+		appliedAt := r.AppliedAt
+		if m, ok := migrations[r.Id]; ok {
+			m.AppliedAt = &appliedAt
+		} else {
+			migrations[r.Id] = &Migration{ID: r.Id, AppliedAt: &appliedAt}
+		}
+	}
+
+	result := make([]Migration, 0, len(migrations))
+	for _, m := range migrations {
+		result = append(result, *m)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+
+	return result, nil
+}
+
+// SchemaVersion returns the id of the last applied migration, or an empty string if none is applied.
+func SchemaVersion(db *sqlx.DB) (string, error) {
+
+	records, err := migrationSet.GetMigrationRecords(db.DB, db.DriverName())
+	if err != nil {
+		return "", err
+	}
+
+	var version string
+	for _, r := range records {
+		if r.Id > version {
+			version = r.Id
+		}
+	}
+
+	return version, nil
+}
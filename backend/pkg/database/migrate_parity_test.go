@@ -0,0 +1,26 @@
+//go:build integration
+
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrations_DriversHaveEquivalentSchema(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+
+	if err := setWorkingDir(); err != nil {
+		t.Fatalf("failed to set working dir: %v", err)
+	}
+
+	ctx := context.Background()
+	sqlite := schema(ctx, t, setupTestDB(t, "sqlite3"))
+	postgres := schema(ctx, t, setupTestDB(t, "postgres"))
+
+	assert.Equal(t, postgres, sqlite)
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/d-rk/checkin-system/pkg/config"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// column is a column of the schema, as far as it is comparable between the drivers.
+type column struct {
+	Table   string `db:"table_name"`
+	Name    string `db:"column_name"`
+	NotNull bool   `db:"not_null"`
+}
+
+// schema returns the columns of all tables except the migrations table, ordered by table and column name.
+func schema(ctx context.Context, t *testing.T, db *sqlx.DB) []column {
+	t.Helper()
+
+	var query string
+
+	switch db.DriverName() {
+	case "postgres":
+		query = `SELECT table_name, column_name, is_nullable = 'NO' AS not_null FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name != 'migrations'
+			ORDER BY table_name, column_name`
+	case "sqlite3":
+		query = `SELECT m.name AS table_name, p.name AS column_name, p."notnull" AS not_null
+			FROM sqlite_master m JOIN pragma_table_info(m.name) p
+			WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%' AND m.name != 'migrations'
+			ORDER BY m.name, p.name`
+	}
+
+	var columns []column
+	require.NoError(t, db.SelectContext(ctx, &columns, query))
+	return columns
+}
+
+func TestMigrations_HaveDownMigrations(t *testing.T) {
+
+	for _, driver := range Drivers {
+		t.Run(driver, func(t *testing.T) {
+			migrations, err := migrationSource(driver).FindMigrations()
+			require.NoError(t, err)
+			require.NotEmpty(t, migrations)
+
+			for _, m := range migrations {
+				assert.NotEmpty(t, m.Down, "%s has no down migration", m.Id)
+			}
+		})
+	}
+}
+
+func TestMigrate_Sqlite_UpDownUp(t *testing.T) {
+
+	ctx := context.Background()
+	db := Connect(config.Database{Driver: "sqlite3", Name: filepath.Join(t.TempDir(), "checkin.db")})
+	t.Cleanup(func() { _ = db.Close() })
+
+	n, err := MigrateUp(ctx, db, 0)
+	require.NoError(t, err)
+	migrated := schema(ctx, t, db)
+
+	_, err = db.Exec(`INSERT INTO users (created_at, name, role) VALUES (current_timestamp, 'alice', 'USER')`)
+	require.NoError(t, err)
+
+	id, err := RedoMigration(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, "0016-foreign-keys.sql", id)
+	assert.Equal(t, migrated, schema(ctx, t, db))
+
+	down, err := MigrateDown(ctx, db, 0)
+	require.NoError(t, err)
+	assert.Equal(t, n, down)
+	assert.Empty(t, schema(ctx, t, db))
+
+	migrations, err := Migrations(db)
+	require.NoError(t, err)
+	assert.Len(t, migrations, n)
+	assert.Nil(t, migrations[0].AppliedAt)
+
+	_, err = MigrateUp(ctx, db, 0)
+	require.NoError(t, err)
+	assert.Equal(t, migrated, schema(ctx, t, db))
+}
+
+func TestMigrate_Sqlite_RebuildingTables_KeepsReferencingRows(t *testing.T) {
+
+	ctx := context.Background()
+	db := Connect(config.Database{Driver: "sqlite3", Name: filepath.Join(t.TempDir(), "checkin.db")})
+	t.Cleanup(func() { _ = db.Close() })
+
+	// the schema before the users table is rebuilt by the groups migration
+	_, err := MigrateUp(ctx, db, 9)
+	require.NoError(t, err)
+
+	_, err = db.Exec(`INSERT INTO announcements (created_at, title, user_id) VALUES (current_timestamp, 'note', 1)`)
+	require.NoError(t, err)
+	_, err = db.Exec(
+		`INSERT INTO checkins (id, date, timestamp, user_id) VALUES (1, current_date, current_timestamp, 1)`)
+	require.NoError(t, err)
+
+	_, err = MigrateUp(ctx, db, 0)
+	require.NoError(t, err)
+
+	var announcements, checkIns int
+	require.NoError(t, db.Get(&announcements, "SELECT count(*) FROM announcements WHERE user_id = 1"))
+	require.NoError(t, db.Get(&checkIns, "SELECT count(*) FROM checkins WHERE user_id = 1"))
+	assert.Equal(t, 1, announcements)
+	assert.Equal(t, 1, checkIns)
+
+	var foreignKeys bool
+	require.NoError(t, db.Get(&foreignKeys, "PRAGMA foreign_keys"))
+	assert.True(t, foreignKeys, "the foreign keys are enabled again after the migrations")
+}
+
+func TestMigrate_Sqlite_RemovesRowsReferencingDeletedRows(t *testing.T) {
+
+	ctx := context.Background()
+	db := Connect(config.Database{Driver: "sqlite3", Name: filepath.Join(t.TempDir(), "checkin.db")})
+	t.Cleanup(func() { _ = db.Close() })
+
+	_, err := MigrateUp(ctx, db, 15)
+	require.NoError(t, err)
+
+	// rows left behind while the foreign keys were not enforced
+	conn, err := db.Connx(ctx)
+	require.NoError(t, err)
+	_, err = conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF")
+	require.NoError(t, err)
+	_, err = conn.ExecContext(ctx,
+		`INSERT INTO announcements (created_at, title, user_id) VALUES (current_timestamp, 'note', 999)`)
+	require.NoError(t, err)
+	_, err = conn.ExecContext(ctx,
+		`INSERT INTO checkins (date, timestamp, user_id, location_id) VALUES (current_date, current_timestamp, 1, 999)`)
+	require.NoError(t, err)
+	_, err = conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	_, err = MigrateUp(ctx, db, 0)
+	require.NoError(t, err)
+
+	var violations []struct {
+		Table  string `db:"table"`
+		RowID  int64  `db:"rowid"`
+		Parent string `db:"parent"`
+		FKID   int64  `db:"fkid"`
+	}
+	require.NoError(t, db.Select(&violations, "PRAGMA foreign_key_check"))
+	assert.Empty(t, violations)
+
+	var checkIns int
+	require.NoError(t, db.Get(&checkIns, "SELECT count(*) FROM checkins WHERE location_id IS NULL"))
+	assert.Equal(t, 1, checkIns)
+}
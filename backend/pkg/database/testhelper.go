@@ -7,16 +7,18 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
+	"strconv"
 	"testing"
 
+	"github.com/d-rk/checkin-system/pkg/config"
+
 	"github.com/joho/godotenv"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
-// SetupTestDB creates a new test database, runs migrations, and returns the DB, dbName, and a cleanup function.
+// SetupTestDB creates a new test database of the configured driver, runs the migrations, and drops it on cleanup.
 func SetupTestDB(t *testing.T) *sqlx.DB {
 	t.Helper()
 
@@ -24,8 +26,47 @@ func SetupTestDB(t *testing.T) *sqlx.DB {
 		t.Fatalf("failed to set working dir: %v", err)
 	}
 
-	_ = godotenv.Load(".env")
-	adminDB := Connect()
+	return setupTestDB(t, os.Getenv("DB_DRIVER"))
+}
+
+// ForEachDriver runs the test against a new test database of every driver. Postgres is skipped if no DB_HOST is
+// configured.
+func ForEachDriver(t *testing.T, test func(t *testing.T, db *sqlx.DB)) {
+	t.Helper()
+
+	if err := setWorkingDir(); err != nil {
+		t.Fatalf("failed to set working dir: %v", err)
+	}
+
+	for _, driver := range Drivers {
+		t.Run(driver, func(t *testing.T) {
+			test(t, setupTestDB(t, driver))
+		})
+	}
+}
+
+func setupTestDB(t *testing.T, driver string) *sqlx.DB {
+	t.Helper()
+
+	switch driver {
+	case "postgres":
+		return setupPostgresTestDB(t)
+	case "sqlite3":
+		return setupSqliteTestDB(t)
+	default:
+		t.Fatalf("unknown driver %q", driver)
+		return nil
+	}
+}
+
+func setupPostgresTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	if os.Getenv("DB_HOST") == "" {
+		t.Skip("skipping postgres test, DB_HOST is not configured.")
+	}
+
+	adminDB := Connect(postgresConfig(os.Getenv("DB_NAME")))
 
 	baseDB := os.Getenv("DB_NAME")
 	dbName := fmt.Sprintf("%s_it_%s", baseDB, uuid.New().String()[:8])
@@ -37,12 +78,12 @@ func SetupTestDB(t *testing.T) *sqlx.DB {
 	}
 	_ = adminDB.Close()
 
-	testDB := connect(dbName)
+	testDB := Connect(postgresConfig(dbName))
 	RunMigration(testDB)
 
 	t.Cleanup(func() {
 		_ = testDB.Close()
-		adminDB := Connect()
+		adminDB := Connect(postgresConfig(os.Getenv("DB_NAME")))
 		_, _ = adminDB.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS \"%s\" WITH (FORCE)", dbName))
 		_ = adminDB.Close()
 	})
@@ -50,6 +91,39 @@ func SetupTestDB(t *testing.T) *sqlx.DB {
 	return testDB
 }
 
+// postgresConfig returns the configuration of the postgres database of the DB_* variables with the name.
+func postgresConfig(name string) config.Database {
+
+	port := config.Default().Database.Port
+	if value, err := strconv.Atoi(os.Getenv("DB_PORT")); err == nil {
+		port = value
+	}
+
+	return config.Database{
+		Driver:   "postgres",
+		Name:     name,
+		Host:     os.Getenv("DB_HOST"),
+		Port:     port,
+		User:     os.Getenv("DB_USER"),
+		Password: os.Getenv("DB_PASSWORD"),
+		SSLMode:  os.Getenv("DB_SSL_MODE"),
+	}
+}
+
+func setupSqliteTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	testDB := Connect(config.Database{Driver: "sqlite3", Name: filepath.Join(t.TempDir(), "checkin.db")})
+	RunMigration(testDB)
+
+	t.Cleanup(func() {
+		_ = testDB.Close()
+	})
+
+	return testDB
+}
+
+// setWorkingDir changes into the backend folder and loads its .env file if there is one.
 func setWorkingDir() error {
 
 	path, err := os.Getwd()
@@ -57,19 +131,21 @@ func setWorkingDir() error {
 		return errors.New("unable to get working dir")
 	}
 
-	_, err = os.Stat(filepath.Join(path, ".env"))
+	_, err = os.Stat(filepath.Join(path, "go.mod"))
 
 	for os.IsNotExist(err) {
-		if strings.HasSuffix(path, "backend") {
-			return errors.New("unable to find .env in backend folder")
-		}
 		oldPath := path
 
 		if path = filepath.Dir(oldPath); path == oldPath {
-			return errors.New("unable to find .env file")
+			return errors.New("unable to find backend folder")
 		}
-		_, err = os.Stat(filepath.Join(path, ".env"))
+		_, err = os.Stat(filepath.Join(path, "go.mod"))
 	}
 
-	return os.Chdir(path)
+	if err = os.Chdir(path); err != nil {
+		return err
+	}
+
+	_ = godotenv.Load(".env")
+	return nil
 }
@@ -0,0 +1,206 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+
+	"github.com/d-rk/checkin-system/pkg/tracing"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// openTraced opens the database with a driver, which records a span for every query and statement.
+func openTraced(driverName, dsn string) (*sql.DB, error) {
+
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	drv := db.Driver()
+	if err = db.Close(); err != nil {
+		return nil, err
+	}
+
+	return sql.OpenDB(&tracedConnector{driverName: driverName, dsn: dsn, driver: drv}), nil
+}
+
+// DriverConn returns the connection of the database driver of a connection, e.g. in sql.Conn.Raw.
+func DriverConn(conn any) any {
+	if traced, ok := conn.(*tracedConn); ok {
+		return traced.Conn
+	}
+	return conn
+}
+
+type tracedConnector struct {
+	driverName string
+	dsn        string
+	driver     driver.Driver
+}
+
+func (c *tracedConnector) Connect(_ context.Context) (driver.Conn, error) {
+
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracedConn{Conn: conn, driverName: c.driverName}, nil
+}
+
+func (c *tracedConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// tracedConn forwards to the connection of the driver. Both supported drivers implement the context variants of the
+// interfaces, otherwise driver.ErrSkip lets database/sql fall back to the plain ones.
+type tracedConn struct {
+	driver.Conn
+
+	driverName string
+}
+
+func (c *tracedConn) start(ctx context.Context, operation, query string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "db."+operation,
+		semconv.DBSystemNameKey.String(c.driverName),
+		semconv.DBQueryText(query),
+	)
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string,
+	args []driver.NamedValue) (driver.Rows, error) {
+
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := c.start(ctx, "query", query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	endSpan(span, err)
+
+	return rows, err
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string,
+	args []driver.NamedValue) (driver.Result, error) {
+
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := c.start(ctx, "exec", query)
+	result, err := execer.ExecContext(ctx, query, args)
+	endSpan(span, err)
+
+	return result, err
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+
+	var stmt driver.Stmt
+	var err error
+
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracedStmt{Stmt: stmt, conn: c, query: query}, nil
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+
+	return c.Begin() //nolint:staticcheck // fallback of drivers without BeginTx
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *tracedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+type tracedStmt struct {
+	driver.Stmt
+
+	conn  *tracedConn
+	query string
+}
+
+func (s *tracedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+
+	queryer, ok := s.Stmt.(driver.StmtQueryContext)
+	if !ok {
+		return nil, errors.New("statement does not support QueryContext")
+	}
+
+	ctx, span := s.conn.start(ctx, "query", s.query)
+	rows, err := queryer.QueryContext(ctx, args)
+	endSpan(span, err)
+
+	return rows, err
+}
+
+func (s *tracedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+
+	execer, ok := s.Stmt.(driver.StmtExecContext)
+	if !ok {
+		return nil, errors.New("statement does not support ExecContext")
+	}
+
+	ctx, span := s.conn.start(ctx, "exec", s.query)
+	result, err := execer.ExecContext(ctx, args)
+	endSpan(span, err)
+
+	return result, err
+}
+
+func (s *tracedStmt) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return s.conn.CheckNamedValue(value)
+}
+
+// endSpan ends the span of a query. driver.ErrSkip is no error, database/sql retries the query with a statement.
+func endSpan(span trace.Span, err error) {
+	if errors.Is(err, driver.ErrSkip) {
+		err = nil
+	}
+	tracing.End(span, err)
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestOpenTraced(t *testing.T) {
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(sdktrace.NewTracerProvider()) })
+
+	ctx := context.Background()
+
+	sqlDB, err := openTraced("sqlite3", "file:"+filepath.Join(t.TempDir(), "traced.db")+"?_loc=UTC")
+	require.NoError(t, err)
+	db := sqlx.NewDb(sqlDB, "sqlite3")
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE things (name TEXT PRIMARY KEY)")
+	require.NoError(t, err)
+
+	// statements and transactions work as without tracing
+	require.NoError(t, NewDB(db).WithTransaction(ctx, func(ctx context.Context) error {
+		_, err := NewDB(db).NamedExecContext(ctx, "INSERT INTO things (name) VALUES (:name)",
+			map[string]any{"name": "a"})
+		return err
+	}))
+
+	_, err = db.ExecContext(ctx, "INSERT INTO things (name) VALUES ($1)", "a")
+	require.ErrorIs(t, Classify(err), app.ErrConflict)
+
+	var names []string
+	require.NoError(t, db.SelectContext(ctx, &names, "SELECT name FROM things"))
+	assert.Equal(t, []string{"a"}, names)
+
+	// the sqlite connection is still available for the backup api
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.Raw(func(driverConn any) error {
+		assert.IsType(t, &sqlite3.SQLiteConn{}, DriverConn(driverConn))
+		return nil
+	}))
+
+	var queries []string
+	failed := 0
+	for _, span := range recorder.Ended() {
+		for _, attribute := range span.Attributes() {
+			if attribute.Key == "db.query.text" {
+				queries = append(queries, span.Name()+" "+attribute.Value.AsString())
+			}
+		}
+		if len(span.Events()) > 0 {
+			failed++
+		}
+	}
+
+	assert.Equal(t, []string{
+		"db.exec CREATE TABLE things (name TEXT PRIMARY KEY)",
+		"db.exec INSERT INTO things (name) VALUES (?)",
+		"db.exec INSERT INTO things (name) VALUES ($1)",
+		"db.query SELECT name FROM things",
+	}, queries)
+	assert.Equal(t, 1, failed)
+}
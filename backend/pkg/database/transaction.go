@@ -7,27 +7,64 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
-// Tx is an interface that models the standard transaction in `database/sql`.
-//
-// To ensure `TransactionalFunc` funcs cannot commit or rollback a transaction (which is
-// handled by `WithTransaction`), those methods are not included here.
-type Tx interface {
-	Exec(query string, args ...interface{}) (sql.Result, error)
-	Prepare(query string) (*sql.Stmt, error)
-	Query(query string, args ...interface{}) (*sql.Rows, error)
-	QueryRow(query string, args ...interface{}) *sql.Row
-	QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row
+// TransactionalFunc is a function that runs within a transaction. All statements executed with the given context
+// are part of the transaction.
+type TransactionalFunc func(ctx context.Context) error
+
+// Transactor runs functions within a transaction, so that services can compose several repository calls atomically.
+type Transactor interface {
+	// WithTransaction begins a new transaction, or joins the transaction of the context, and handles
+	// rollback/commit based on the error returned by the `TransactionalFunc`.
+	WithTransaction(ctx context.Context, fn TransactionalFunc) error
+}
+
+// DB runs statements within the transaction of the context, if there is one, and on the database otherwise.
+type DB struct {
+	*sqlx.DB
+}
+
+func NewDB(db *sqlx.DB) *DB {
+	return &DB{db}
+}
+
+type txKey struct{}
+
+// activeTx is the transaction of a context, along with the database it belongs to.
+type activeTx struct {
+	db *sqlx.DB
+	tx *sqlx.Tx
+}
+
+// queryer is implemented by both *sqlx.DB and *sqlx.Tx.
+type queryer interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	PreparexContext(ctx context.Context, query string) (*sqlx.Stmt, error)
 	PrepareNamedContext(ctx context.Context, query string) (*sqlx.NamedStmt, error)
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+}
+
+func (db *DB) conn(ctx context.Context) queryer {
+	if active, ok := ctx.Value(txKey{}).(activeTx); ok && active.db == db.DB {
+		return active.tx
+	}
+	return db.DB
 }
 
-// TransactionalFunc a function that will be called with an initialized `Tx` object
-// that can be used for executing statements and queries against a database.
-type TransactionalFunc func(Tx) error
+// InTransaction reports whether the context has a transaction of the database.
+func (db *DB) InTransaction(ctx context.Context) bool {
+	active, ok := ctx.Value(txKey{}).(activeTx)
+	return ok && active.db == db.DB
+}
+
+func (db *DB) WithTransaction(ctx context.Context, fn TransactionalFunc) (err error) {
+
+	if db.InTransaction(ctx) {
+		return fn(ctx)
+	}
 
-// WithTransaction creates a new transaction and handles rollback/commit based on the
-// error object returned by the `TransactionalFunc`.
-func WithTransaction(db *sqlx.DB, fn TransactionalFunc) (err error) {
-	tx, err := db.Beginx()
+	tx, err := db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -46,6 +83,38 @@ func WithTransaction(db *sqlx.DB, fn TransactionalFunc) (err error) {
 		}
 	}()
 
-	err = fn(tx)
+	err = fn(context.WithValue(ctx, txKey{}, activeTx{db.DB, tx}))
 	return err
 }
+
+func (db *DB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return db.conn(ctx).GetContext(ctx, dest, query, args...)
+}
+
+func (db *DB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return db.conn(ctx).SelectContext(ctx, dest, query, args...)
+}
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return db.conn(ctx).ExecContext(ctx, query, args...)
+}
+
+func (db *DB) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	return db.conn(ctx).NamedExecContext(ctx, query, arg)
+}
+
+func (db *DB) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	return db.conn(ctx).QueryxContext(ctx, query, args...)
+}
+
+func (db *DB) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	return db.conn(ctx).QueryRowxContext(ctx, query, args...)
+}
+
+func (db *DB) PreparexContext(ctx context.Context, query string) (*sqlx.Stmt, error) {
+	return db.conn(ctx).PreparexContext(ctx, query)
+}
+
+func (db *DB) PrepareNamedContext(ctx context.Context, query string) (*sqlx.NamedStmt, error) {
+	return db.conn(ctx).PrepareNamedContext(ctx, query)
+}
@@ -0,0 +1,104 @@
+//go:build integration
+
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func insertTenant(ctx context.Context, t *testing.T, db *DB, slug string) {
+	t.Helper()
+	_, err := db.ExecContext(ctx, "INSERT INTO tenants (created_at, name, slug) VALUES (current_timestamp, $1, $1)",
+		slug)
+	require.NoError(t, err)
+}
+
+func countTenants(t *testing.T, db *DB) int {
+	t.Helper()
+	var count int
+	require.NoError(t, db.GetContext(context.Background(), &count, "SELECT count(*) FROM tenants"))
+	return count
+}
+
+func TestWithTransaction(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+
+	errAbort := errors.New("abort")
+
+	tests := []struct {
+		name  string
+		fn    func(t *testing.T, db *DB) TransactionalFunc
+		err   error
+		count int
+	}{
+		{"commit", func(t *testing.T, db *DB) TransactionalFunc {
+			return func(ctx context.Context) error {
+				assert.True(t, db.InTransaction(ctx))
+				insertTenant(ctx, t, db, "a")
+				insertTenant(ctx, t, db, "b")
+				return nil
+			}
+		}, nil, 3},
+		{"rollback on error", func(t *testing.T, db *DB) TransactionalFunc {
+			return func(ctx context.Context) error {
+				insertTenant(ctx, t, db, "a")
+				return errAbort
+			}
+		}, errAbort, 1},
+		{"nested transaction joins outer", func(t *testing.T, db *DB) TransactionalFunc {
+			return func(ctx context.Context) error {
+				require.NoError(t, db.WithTransaction(ctx, func(ctx context.Context) error {
+					insertTenant(ctx, t, db, "a")
+					return nil
+				}))
+				return errAbort
+			}
+		}, errAbort, 1},
+	}
+
+	ForEachDriver(t, func(t *testing.T, sqlxDB *sqlx.DB) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				db := NewDB(sqlxDB)
+				_, err := db.ExecContext(context.Background(), "DELETE FROM tenants WHERE id != 1")
+				require.NoError(t, err)
+
+				err = db.WithTransaction(context.Background(), tt.fn(t, db))
+
+				if tt.err != nil {
+					require.ErrorIs(t, err, tt.err)
+				} else {
+					require.NoError(t, err)
+				}
+				assert.Equal(t, tt.count, countTenants(t, db))
+			})
+		}
+	})
+}
+
+func TestWithTransaction_Panic_RollsBack(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	ForEachDriver(t, func(t *testing.T, sqlxDB *sqlx.DB) {
+		db := NewDB(sqlxDB)
+
+		assert.Panics(t, func() {
+			_ = db.WithTransaction(context.Background(), func(ctx context.Context) error {
+				insertTenant(ctx, t, db, "a")
+				panic("boom")
+			})
+		})
+
+		assert.Equal(t, 1, countTenants(t, db))
+		assert.False(t, db.InTransaction(context.Background()))
+	})
+}
@@ -0,0 +1,168 @@
+package edgesync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const clientTimeout = 30 * time.Second
+
+// client calls the sync endpoints of the upstream instance as the configured user.
+type client struct {
+	baseURL  string
+	username string
+	password string
+	http     *http.Client
+	token    string
+}
+
+func newClient(baseURL, username, password string) *client {
+	return &client{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		username: username,
+		password: password,
+		http:     &http.Client{Timeout: clientTimeout},
+	}
+}
+
+type pushRequest struct {
+	CheckIns []pushedCheckIn `json:"checkIns"`
+}
+
+// pushedCheckIn omits the location of checkIns without location, as the api does not accept null.
+type pushedCheckIn struct {
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"userId"`
+	Timestamp  time.Time `json:"timestamp"`
+	LocationID *int64    `json:"locationId,omitempty"`
+}
+
+type pushResponse struct {
+	Results []Result `json:"results"`
+}
+
+func (c *client) Snapshot(ctx context.Context) (*Snapshot, error) {
+
+	snapshot := &Snapshot{}
+
+	if err := c.call(ctx, http.MethodGet, "/api/v1/sync/snapshot", nil, snapshot); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+func (c *client) PushCheckIns(ctx context.Context, checkIns []CheckIn) ([]Result, error) {
+
+	request := &pushRequest{CheckIns: make([]pushedCheckIn, len(checkIns))}
+	for i, checkIn := range checkIns {
+		request.CheckIns[i] = pushedCheckIn{ID: checkIn.ID, UserID: checkIn.UserID, Timestamp: checkIn.Timestamp,
+			LocationID: checkIn.LocationID.Ptr()}
+	}
+
+	response := &pushResponse{}
+
+	if err := c.call(ctx, http.MethodPost, "/api/v1/sync/checkins", request, response); err != nil {
+		return nil, err
+	}
+
+	return response.Results, nil
+}
+
+// call sends the request with the bearer token of the user and decodes the response into result. It logs in first,
+// and again once the token expired.
+func (c *client) call(ctx context.Context, method, path string, body, result any) error {
+
+	if c.token == "" {
+		if err := c.login(ctx); err != nil {
+			return err
+		}
+	}
+
+	response, err := c.send(ctx, method, path, body)
+	if err == nil && response.StatusCode == http.StatusUnauthorized {
+		_ = response.Body.Close()
+		if err = c.login(ctx); err != nil {
+			return err
+		}
+		response, err = c.send(ctx, method, path, body)
+	}
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return responseError(method, path, response)
+	}
+
+	return json.NewDecoder(response.Body).Decode(result)
+}
+
+func (c *client) login(ctx context.Context) error {
+
+	c.token = ""
+
+	credentials := map[string]string{"username": c.username, "password": c.password}
+
+	response, err := c.send(ctx, http.MethodPost, "/api/login", credentials)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	// the upstream login requires a second factor, which can't be entered here
+	if response.StatusCode == http.StatusAccepted {
+		return fmt.Errorf("login of %s requires a second factor, which is not supported for syncing", c.username)
+	} else if response.StatusCode != http.StatusOK {
+		return responseError(http.MethodPost, "/api/login", response)
+	}
+
+	var token struct {
+		Token string `json:"token"`
+	}
+	if err = json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return err
+	}
+
+	c.token = token.Token
+	return nil
+}
+
+func (c *client) send(ctx context.Context, method, path string, body any) (*http.Response, error) {
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	return c.http.Do(request)
+}
+
+func responseError(method, path string, response *http.Response) error {
+
+	message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+	return fmt.Errorf("%s %s failed with %s: %s", method, path, response.Status, strings.TrimSpace(string(message)))
+}
@@ -0,0 +1,87 @@
+package edgesync
+
+import (
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/checkin"
+	"gopkg.in/guregu/null.v4"
+)
+
+// The json names of the types below are those of the sync endpoints of the api, as the edge instances decode the
+// responses of the upstream instance into them.
+
+// Snapshot is the state of the users, groups and locations of a tenant, which edge instances replicate.
+type Snapshot struct {
+	Groups    []Group    `json:"groups"`
+	Locations []Location `json:"locations"`
+	Users     []User     `json:"users"`
+}
+
+type Group struct {
+	ID          int64       `db:"id"          json:"id"`
+	Name        string      `db:"name"        json:"name"`
+	Description null.String `db:"description" json:"description"`
+	Colour      null.String `db:"colour"      json:"colour"`
+	Capacity    null.Int    `db:"capacity"    json:"capacity"`
+	Schedule    null.String `db:"schedule"    json:"schedule"`
+	TrainerIDs  []int64     `db:"-"           json:"trainerIds"`
+}
+
+type Location struct {
+	ID          int64       `db:"id"          json:"id"`
+	Name        string      `db:"name"        json:"name"`
+	Description null.String `db:"description" json:"description"`
+	Capacity    null.Int    `db:"capacity"    json:"capacity"`
+	ReaderIDs   []string    `db:"-"           json:"readerIds"`
+}
+
+// User is a user along with the digest of its password, so that trainers and members can log in at the edge
+// instance as well. Admins have no digest.
+type User struct {
+	ID             int64       `db:"id"              json:"id"`
+	Name           string      `db:"name"            json:"name"`
+	Role           string      `db:"role"            json:"role"`
+	GroupID        null.Int    `db:"group_id"        json:"groupId"`
+	MemberID       null.String `db:"member_id"       json:"memberId"`
+	RFIDuid        null.String `db:"rfid_uid"        json:"rfidUid"`
+	Email          null.String `db:"email"           json:"email"`
+	PasswordDigest null.String `db:"password_digest" json:"passwordDigest"`
+}
+
+// CheckIn is a checkIn of an edge instance, which was not yet merged into the upstream instance. The id is the one
+// of the edge instance.
+type CheckIn struct {
+	ID         int64     `db:"id"`
+	UserID     int64     `db:"user_id"`
+	Timestamp  time.Time `db:"timestamp"`
+	LocationID null.Int  `db:"location_id"`
+}
+
+// Outcome is the result of pushing a checkIn to the upstream instance.
+type Outcome string
+
+const (
+	Created Outcome = Outcome(checkin.MergeCreated)
+	Updated Outcome = Outcome(checkin.MergeUpdated)
+	Kept    Outcome = Outcome(checkin.MergeKept)
+	// Rejected checkIns belong to users unknown to the upstream instance. They are not pushed again.
+	Rejected Outcome = "rejected"
+)
+
+// Result is the outcome of a pushed checkIn.
+type Result struct {
+	ID      int64   `json:"id"`
+	Outcome Outcome `json:"outcome"`
+}
+
+// Status reports the synchronisation of an edge instance with its upstream instance.
+type Status struct {
+	Enabled         bool
+	UpstreamURL     string
+	PendingCheckIns int
+	LastAttemptAt   null.Time
+	LastSuccessAt   null.Time
+	LastError       null.String
+	PushedCheckIns  int // number of checkIns pushed by the last successful sync
+	PulledUsers     int // number of users pulled by the last successful sync
+}
@@ -0,0 +1,255 @@
+package edgesync
+
+import (
+	"context"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/tenant"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// placeholderPrefix names the replicated rows while a snapshot is applied, so that unique names can be swapped.
+const placeholderPrefix = "~sync~"
+
+type Repository interface {
+	// Driver returns the name of the database driver.
+	Driver() string
+	// ListUnsyncedCheckIns returns the oldest checkIns of the tenant, which were not yet pushed upstream.
+	ListUnsyncedCheckIns(ctx context.Context, limit int) ([]CheckIn, error)
+	CountUnsyncedCheckIns(ctx context.Context) (int, error)
+	// MarkSynced records the outcome of a pushed checkIn, so that it is not pushed again.
+	MarkSynced(ctx context.Context, checkIn CheckIn, outcome Outcome, syncedAt time.Time) error
+	// ApplySnapshot replaces the users, groups and locations of the tenant with those of the snapshot, keeping their
+	// ids. The checkIns of removed users are deleted.
+	ApplySnapshot(ctx context.Context, snapshot *Snapshot, now time.Time) error
+}
+
+type repository struct {
+	db *database.DB
+}
+
+func NewRepo(db *sqlx.DB) Repository {
+	return &repository{database.NewDB(db)}
+}
+
+func (r *repository) Driver() string {
+	return r.db.DriverName()
+}
+
+// unsyncedCondition selects the checkIns without synced_checkins entry. The user is compared as well, as sqlite
+// reuses the id of the last checkIn once it was deleted.
+const unsyncedCondition = `FROM checkins
+	LEFT JOIN synced_checkins ON synced_checkins.checkin_id = checkins.id
+		AND synced_checkins.user_id = checkins.user_id
+	WHERE synced_checkins.checkin_id IS NULL AND checkins.tenant_id = $1`
+
+func (r *repository) ListUnsyncedCheckIns(ctx context.Context, limit int) ([]CheckIn, error) {
+
+	checkIns := make([]CheckIn, 0)
+
+	if err := r.db.SelectContext(ctx, &checkIns, `SELECT checkins.id, checkins.user_id, checkins.timestamp,
+		checkins.location_id `+unsyncedCondition+` ORDER BY checkins.id LIMIT $2`, tenant.ID(ctx), limit); err != nil {
+		return nil, err
+	}
+
+	return checkIns, nil
+}
+
+func (r *repository) CountUnsyncedCheckIns(ctx context.Context) (int, error) {
+
+	var count int
+
+	if err := r.db.GetContext(ctx, &count, `SELECT count(*) `+unsyncedCondition, tenant.ID(ctx)); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (r *repository) MarkSynced(ctx context.Context, checkIn CheckIn, outcome Outcome, syncedAt time.Time) error {
+
+	_, err := r.db.ExecContext(ctx, `INSERT INTO synced_checkins (checkin_id, user_id, synced_at, outcome)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (checkin_id) DO UPDATE SET
+		(user_id, synced_at, outcome) = (excluded.user_id, excluded.synced_at, excluded.outcome)`,
+		checkIn.ID, checkIn.UserID, syncedAt, outcome)
+
+	return database.Classify(err)
+}
+
+func (r *repository) ApplySnapshot(ctx context.Context, snapshot *Snapshot, now time.Time) error {
+
+	return r.db.WithTransaction(ctx, func(ctx context.Context) error {
+
+		if err := r.deleteRemoved(ctx, snapshot); err != nil {
+			return err
+		}
+
+		// the unique values are released first, so that they can move between rows
+		for _, query := range []string{
+			`UPDATE groups SET name = '` + placeholderPrefix + `' || id WHERE tenant_id = $1`,
+			`UPDATE locations SET name = '` + placeholderPrefix + `' || id WHERE tenant_id = $1`,
+			`UPDATE users SET (name, rfid_uid, member_id) = ('` + placeholderPrefix + `' || id, NULL, NULL)
+				WHERE tenant_id = $1`,
+		} {
+			if _, err := r.db.ExecContext(ctx, query, tenant.ID(ctx)); err != nil {
+				return err
+			}
+		}
+
+		if err := r.saveGroups(ctx, snapshot.Groups, now); err != nil {
+			return err
+		}
+
+		if err := r.saveLocations(ctx, snapshot.Locations, now); err != nil {
+			return err
+		}
+
+		return r.saveUsers(ctx, snapshot.Users, snapshot.Groups, now)
+	})
+}
+
+// deleteRemoved deletes the users, groups and locations of the tenant, which are not part of the snapshot.
+func (r *repository) deleteRemoved(ctx context.Context, snapshot *Snapshot) error {
+
+	userIDs := make(map[int64]bool, len(snapshot.Users))
+	for _, u := range snapshot.Users {
+		userIDs[u.ID] = true
+	}
+
+	if err := r.deleteMissing(ctx, "users", userIDs, []string{
+		`DELETE FROM checkins WHERE user_id = $1`,
+		`DELETE FROM group_trainers WHERE user_id = $1`,
+		`DELETE FROM users WHERE id = $1`,
+	}); err != nil {
+		return err
+	}
+
+	groupIDs := make(map[int64]bool, len(snapshot.Groups))
+	for _, g := range snapshot.Groups {
+		groupIDs[g.ID] = true
+	}
+
+	if err := r.deleteMissing(ctx, "groups", groupIDs, []string{
+		`UPDATE users SET group_id = NULL WHERE group_id = $1`,
+		`DELETE FROM announcements WHERE group_id = $1`,
+		`DELETE FROM group_trainers WHERE group_id = $1`,
+		`DELETE FROM groups WHERE id = $1`,
+	}); err != nil {
+		return err
+	}
+
+	locationIDs := make(map[int64]bool, len(snapshot.Locations))
+	for _, l := range snapshot.Locations {
+		locationIDs[l.ID] = true
+	}
+
+	return r.deleteMissing(ctx, "locations", locationIDs, []string{
+		`UPDATE checkins SET location_id = NULL WHERE location_id = $1`,
+		`DELETE FROM location_readers WHERE location_id = $1`,
+		`DELETE FROM locations WHERE id = $1`,
+	})
+}
+
+// deleteMissing runs the queries for every row of the table of the tenant, whose id is not kept.
+func (r *repository) deleteMissing(ctx context.Context, table string, keep map[int64]bool, queries []string) error {
+
+	var ids []int64
+
+	if err := r.db.SelectContext(ctx, &ids, `SELECT id FROM `+table+` WHERE tenant_id = $1`,
+		tenant.ID(ctx)); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if keep[id] {
+			continue
+		}
+		for _, query := range queries {
+			if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *repository) saveGroups(ctx context.Context, groups []Group, now time.Time) error {
+
+	for _, g := range groups {
+		if _, err := r.db.ExecContext(ctx, `INSERT INTO groups
+			(id, tenant_id, created_at, name, description, colour, capacity, schedule) VALUES
+			($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (id) DO UPDATE SET
+			(name, description, colour, capacity, schedule) =
+			(excluded.name, excluded.description, excluded.colour, excluded.capacity, excluded.schedule)`,
+			g.ID, tenant.ID(ctx), now, g.Name, g.Description, g.Colour, g.Capacity, g.Schedule); err != nil {
+			return database.Classify(err)
+		}
+	}
+
+	return nil
+}
+
+func (r *repository) saveLocations(ctx context.Context, locations []Location, now time.Time) error {
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM location_readers WHERE tenant_id = $1`,
+		tenant.ID(ctx)); err != nil {
+		return err
+	}
+
+	for _, l := range locations {
+		if _, err := r.db.ExecContext(ctx, `INSERT INTO locations
+			(id, tenant_id, created_at, name, description, capacity) VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (id) DO UPDATE SET
+			(name, description, capacity) = (excluded.name, excluded.description, excluded.capacity)`,
+			l.ID, tenant.ID(ctx), now, l.Name, l.Description, l.Capacity); err != nil {
+			return database.Classify(err)
+		}
+
+		for _, readerID := range l.ReaderIDs {
+			if _, err := r.db.ExecContext(ctx, `INSERT INTO location_readers (tenant_id, reader_id, location_id)
+				VALUES ($1, $2, $3)`, tenant.ID(ctx), readerID, l.ID); err != nil {
+				return database.Classify(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// saveUsers saves the users and assigns the trainers of the groups, which refer to them.
+func (r *repository) saveUsers(ctx context.Context, users []User, groups []Group, now time.Time) error {
+
+	for _, u := range users {
+		if _, err := r.db.ExecContext(ctx, `INSERT INTO users
+			(id, tenant_id, created_at, name, role, group_id, member_id, rfid_uid, email, password_digest) VALUES
+			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (id) DO UPDATE SET
+			(name, role, group_id, member_id, rfid_uid, email, password_digest) =
+			(excluded.name, excluded.role, excluded.group_id, excluded.member_id, excluded.rfid_uid, excluded.email,
+			excluded.password_digest)`,
+			u.ID, tenant.ID(ctx), now, u.Name, u.Role, u.GroupID, u.MemberID, u.RFIDuid, u.Email,
+			u.PasswordDigest); err != nil {
+			return database.Classify(err)
+		}
+	}
+
+	for _, g := range groups {
+		if _, err := r.db.ExecContext(ctx, `DELETE FROM group_trainers WHERE group_id = $1`, g.ID); err != nil {
+			return err
+		}
+
+		for _, trainerID := range g.TrainerIDs {
+			if _, err := r.db.ExecContext(ctx, `INSERT INTO group_trainers (group_id, user_id) VALUES ($1, $2)`,
+				g.ID, trainerID); err != nil {
+				return database.Classify(err)
+			}
+		}
+	}
+
+	return nil
+}
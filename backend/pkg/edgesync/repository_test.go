@@ -0,0 +1,137 @@
+package edgesync
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/database"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+var now = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+func migratedDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	db, err := sqlx.Connect("sqlite3",
+		fmt.Sprintf("file:%s?_loc=UTC&_fk=1", filepath.Join(t.TempDir(), "checkin.db")))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	_, err = database.MigrateUp(context.Background(), db, 0)
+	require.NoError(t, err)
+
+	return db
+}
+
+func insertCheckIn(t *testing.T, db *sqlx.DB, userID int64, timestamp time.Time) int64 {
+	t.Helper()
+
+	var id int64
+	require.NoError(t, db.Get(&id, `INSERT INTO checkins (date, timestamp, user_id) VALUES ($1, $2, $3) RETURNING id`,
+		timestamp.Truncate(24*time.Hour), timestamp, userID))
+	return id
+}
+
+func TestApplySnapshot(t *testing.T) {
+
+	ctx := context.Background()
+	db := migratedDB(t)
+	repo := NewRepo(db)
+
+	require.NoError(t, repo.ApplySnapshot(ctx, &Snapshot{
+		Groups:    []Group{{ID: 10, Name: "juniors"}, {ID: 11, Name: "seniors"}},
+		Locations: []Location{{ID: 20, Name: "boathouse", ReaderIDs: []string{"pi-1"}}},
+		Users: []User{
+			{ID: 30, Name: "alice", Role: "TRAINER", RFIDuid: null.StringFrom("abc"), GroupID: null.IntFrom(10)},
+			{ID: 31, Name: "bob", Role: "USER", RFIDuid: null.StringFrom("def")},
+			{ID: 32, Name: "carol", Role: "USER"},
+		},
+	}, now))
+
+	insertCheckIn(t, db, 32, now)
+
+	// the card of alice moved to bob, the groups swapped their names, carol and the location were removed
+	require.NoError(t, repo.ApplySnapshot(ctx, &Snapshot{
+		Groups:    []Group{{ID: 10, Name: "seniors", TrainerIDs: []int64{30}}, {ID: 11, Name: "juniors"}},
+		Locations: []Location{{ID: 21, Name: "hall", ReaderIDs: []string{"pi-1"}}},
+		Users: []User{
+			{ID: 30, Name: "alice", Role: "TRAINER", GroupID: null.IntFrom(11)},
+			{ID: 31, Name: "bob", Role: "USER", RFIDuid: null.StringFrom("abc")},
+		},
+	}, now))
+
+	var groups []string
+	require.NoError(t, db.Select(&groups, "SELECT id || ':' || name FROM groups ORDER BY id"))
+	assert.Equal(t, []string{"10:seniors", "11:juniors"}, groups)
+
+	var users []string
+	require.NoError(t, db.Select(&users,
+		"SELECT name || ':' || coalesce(rfid_uid, '') || ':' || coalesce(group_id, '') FROM users "+
+			"WHERE id >= 30 ORDER BY id"))
+	assert.Equal(t, []string{"alice::11", "bob:abc:"}, users)
+
+	var readers []string
+	require.NoError(t, db.Select(&readers, "SELECT reader_id || ':' || location_id FROM location_readers"))
+	assert.Equal(t, []string{"pi-1:21"}, readers)
+
+	var trainers []string
+	require.NoError(t, db.Select(&trainers, "SELECT group_id || ':' || user_id FROM group_trainers"))
+	assert.Equal(t, []string{"10:30"}, trainers)
+
+	var checkIns int
+	require.NoError(t, db.Get(&checkIns, "SELECT count(*) FROM checkins"))
+	assert.Zero(t, checkIns)
+
+	// the seeded admin is not part of the snapshots
+	var admins int
+	require.NoError(t, db.Get(&admins, "SELECT count(*) FROM users WHERE name = 'admin'"))
+	assert.Zero(t, admins)
+}
+
+func TestUnsyncedCheckIns(t *testing.T) {
+
+	ctx := context.Background()
+	db := migratedDB(t)
+	repo := NewRepo(db)
+
+	first := insertCheckIn(t, db, 1, now)
+	insertCheckIn(t, db, 1, now.Add(24*time.Hour))
+
+	checkIns, err := repo.ListUnsyncedCheckIns(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, checkIns, 1)
+	assert.Equal(t, CheckIn{ID: first, UserID: 1, Timestamp: now}, checkIns[0])
+
+	require.NoError(t, repo.MarkSynced(ctx, checkIns[0], Created, now))
+
+	pending, err := repo.CountUnsyncedCheckIns(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, pending)
+
+	// a new checkIn reusing the id of a deleted one is pushed as well
+	_, err = db.Exec("DELETE FROM checkins")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO users (id, created_at, name, role) VALUES (2, $1, 'bob', 'USER')", now)
+	require.NoError(t, err)
+	reused := insertCheckIn(t, db, 2, now)
+	require.Equal(t, first, reused)
+
+	checkIns, err = repo.ListUnsyncedCheckIns(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, checkIns, 1)
+	assert.Equal(t, int64(2), checkIns[0].UserID)
+
+	require.NoError(t, repo.MarkSynced(ctx, checkIns[0], Kept, now))
+
+	pending, err = repo.CountUnsyncedCheckIns(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, pending)
+}
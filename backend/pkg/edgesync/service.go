@@ -0,0 +1,303 @@
+package edgesync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/checkin"
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/group"
+	"github.com/d-rk/checkin-system/pkg/jobs"
+	"github.com/d-rk/checkin-system/pkg/location"
+	"github.com/d-rk/checkin-system/pkg/user"
+	"gopkg.in/guregu/null.v4"
+)
+
+// pushBatchSize limits the number of checkIns pushed with a single request.
+const pushBatchSize = 500
+
+// Service replicates an edge instance, e.g. a Raspberry Pi without permanent uplink, with its upstream instance.
+// The edge instance accepts checkIns offline and pushes them upstream, once it is online. Users, groups and
+// locations are managed upstream and pulled by the edge instance.
+type Service interface {
+	// Snapshot returns the users, groups and locations of the tenant, which edge instances replicate. Only the
+	// password digests of trainers and members are included.
+	Snapshot(ctx context.Context) (*Snapshot, error)
+	// PushCheckIns merges the checkIns of an edge instance. There is only one checkIn per user and day, so the
+	// earliest checkIn of the day wins. CheckIns of unknown users are rejected.
+	PushCheckIns(ctx context.Context, checkIns []CheckIn) ([]Result, error)
+	// Sync pushes the pending checkIns of this edge instance upstream and pulls the users, groups and locations.
+	Sync(ctx context.Context) error
+	// Job syncs every interval. It is nil if this is no edge instance.
+	Job() *jobs.Job
+	Status(ctx context.Context) (*Status, error)
+}
+
+type service struct {
+	repo            Repository
+	transactor      database.Transactor
+	userService     user.Service
+	groupService    group.Service
+	locationService location.Service
+	checkinService  checkin.Service
+	upstreamURL     string
+	upstream        *client
+	interval        time.Duration
+	now             func() time.Time
+
+	mu     sync.Mutex
+	status Status
+}
+
+// NewService returns the sync service. This is an edge instance, if an upstream url is configured, which requires
+// a sqlite database.
+func NewService(repo Repository, transactor database.Transactor, userService user.Service,
+	groupService group.Service, locationService location.Service, checkinService checkin.Service,
+	cfg config.Sync) (Service, error) {
+
+	s := &service{
+		repo:            repo,
+		transactor:      transactor,
+		userService:     userService,
+		groupService:    groupService,
+		locationService: locationService,
+		checkinService:  checkinService,
+		upstreamURL:     cfg.UpstreamURL,
+		interval:        time.Duration(cfg.IntervalSeconds) * time.Second,
+		now:             time.Now,
+	}
+
+	if s.upstreamURL == "" {
+		return s, nil
+	}
+
+	if driver := repo.Driver(); driver != "sqlite3" {
+		return nil, fmt.Errorf("edge instances require a sqlite3 database, not %s", driver)
+	}
+
+	s.upstream = newClient(s.upstreamURL, cfg.Username, cfg.Password)
+
+	return s, nil
+}
+
+func (s *service) Snapshot(ctx context.Context) (*Snapshot, error) {
+
+	snapshot := &Snapshot{Groups: []Group{}, Locations: []Location{}, Users: []User{}}
+
+	groups, err := s.groupService.ListGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, g := range groups {
+		trainerIDs, listErr := s.groupService.ListTrainerIDs(ctx, g.ID)
+		if listErr != nil {
+			return nil, listErr
+		}
+		snapshot.Groups = append(snapshot.Groups, Group{ID: g.ID, Name: g.Name, Description: g.Description,
+			Colour: g.Colour, Capacity: g.Capacity, Schedule: g.Schedule, TrainerIDs: trainerIDs})
+	}
+
+	locations, err := s.locationService.ListLocations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range locations {
+		readerIDs, listErr := s.locationService.ListReaderIDs(ctx, l.ID)
+		if listErr != nil {
+			return nil, listErr
+		}
+		snapshot.Locations = append(snapshot.Locations, Location{ID: l.ID, Name: l.Name,
+			Description: l.Description, Capacity: l.Capacity, ReaderIDs: readerIDs})
+	}
+
+	users, err := s.userService.ListUsers(ctx, group.Scope{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, u := range users {
+		snapshotUser := User{ID: u.ID, Name: u.Name, Role: u.Role, GroupID: u.GroupID, MemberID: u.MemberID,
+			RFIDuid: u.RFIDuid, Email: u.Email}
+		if edgeLogin(u.Role) {
+			snapshotUser.PasswordDigest = u.PasswordDigest
+		}
+		snapshot.Users = append(snapshot.Users, snapshotUser)
+	}
+
+	return snapshot, nil
+}
+
+// edgeLogin returns true if users of the role log in at edge instances, which get the digests of their passwords.
+// Admins manage the edge instances upstream, so that their digests never leave the upstream instance.
+func edgeLogin(role string) bool {
+	return role == user.RoleTrainer || role == user.RoleUser
+}
+
+func (s *service) PushCheckIns(ctx context.Context, checkIns []CheckIn) ([]Result, error) {
+
+	results := make([]Result, 0, len(checkIns))
+
+	for _, c := range checkIns {
+		_, outcome, err := s.checkinService.MergeCheckIn(ctx, c.UserID, c.LocationID, c.Timestamp)
+		if err != nil && errors.Is(err, app.ErrNotFound) {
+			results = append(results, Result{ID: c.ID, Outcome: Rejected})
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to merge checkIn %d: %w", c.ID, err)
+		}
+		results = append(results, Result{ID: c.ID, Outcome: Outcome(outcome)})
+	}
+
+	return results, nil
+}
+
+func (s *service) Sync(ctx context.Context) error {
+
+	if s.upstream == nil {
+		return fmt.Errorf("no SYNC_UPSTREAM_URL configured: %w", app.ErrInvalid)
+	}
+
+	attemptAt := s.now()
+	pushed, pulled, err := s.sync(ctx)
+
+	s.mu.Lock()
+	s.status.LastAttemptAt = null.TimeFrom(attemptAt)
+	if err == nil {
+		s.status.LastSuccessAt = null.TimeFrom(attemptAt)
+		s.status.LastError = null.String{}
+		s.status.PushedCheckIns = pushed
+		s.status.PulledUsers = pulled
+	} else {
+		s.status.LastError = null.StringFrom(err.Error())
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("sync with %s failed: %w", s.upstreamURL, err)
+	}
+
+	slog.InfoContext(ctx, "synced with upstream", "event", "sync_completed", "pushed_checkins", pushed,
+		"pulled_users", pulled)
+
+	return nil
+}
+
+// sync pushes the checkIns before pulling, so that the checkIns of users removed upstream are rejected rather than
+// deleted silently. It returns the number of pushed checkIns and pulled users.
+func (s *service) sync(ctx context.Context) (int, int, error) {
+
+	pushed, err := s.push(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	snapshot, err := s.upstream.Snapshot(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err = s.repo.ApplySnapshot(ctx, snapshot, s.now()); err != nil {
+		return 0, 0, fmt.Errorf("failed to apply snapshot: %w", err)
+	}
+
+	return pushed, len(snapshot.Users), nil
+}
+
+// push pushes the pending checkIns in batches and returns their number.
+func (s *service) push(ctx context.Context) (int, error) {
+
+	pushed := 0
+
+	for {
+		checkIns, err := s.repo.ListUnsyncedCheckIns(ctx, pushBatchSize)
+		if err != nil || len(checkIns) == 0 {
+			return pushed, err
+		}
+
+		results, err := s.upstream.PushCheckIns(ctx, checkIns)
+		if err != nil {
+			return pushed, err
+		}
+
+		byID := make(map[int64]CheckIn, len(checkIns))
+		for _, c := range checkIns {
+			byID[c.ID] = c
+		}
+
+		marked := 0
+		err = s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+			for _, result := range results {
+				checkIn, ok := byID[result.ID]
+				if !ok {
+					continue
+				}
+				if markErr := s.repo.MarkSynced(ctx, checkIn, result.Outcome, s.now()); markErr != nil {
+					return markErr
+				}
+				marked++
+			}
+			return nil
+		})
+		if err != nil {
+			return pushed, err
+		}
+
+		// the same checkIns would be pushed again
+		if marked < len(checkIns) {
+			return pushed + marked, fmt.Errorf("upstream returned results for %d of %d checkIns", marked,
+				len(checkIns))
+		}
+
+		pushed += marked
+	}
+}
+
+func (s *service) Job() *jobs.Job {
+
+	if s.upstream == nil {
+		return nil
+	}
+
+	return &jobs.Job{
+		Name:       "sync",
+		Interval:   s.interval,
+		RunOnStart: true,
+		Run: func(ctx context.Context) error {
+			// being offline is the normal case at the edge, so failures are no errors
+			if err := s.Sync(ctx); err != nil {
+				slog.WarnContext(ctx, "sync failed", "error", err)
+			}
+			return nil
+		},
+	}
+}
+
+func (s *service) Status(ctx context.Context) (*Status, error) {
+
+	s.mu.Lock()
+	status := s.status
+	s.mu.Unlock()
+
+	status.Enabled = s.upstream != nil
+	status.UpstreamURL = s.upstreamURL
+
+	if !status.Enabled {
+		return &status, nil
+	}
+
+	pending, err := s.repo.CountUnsyncedCheckIns(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status.PendingCheckIns = pending
+
+	return &status, nil
+}
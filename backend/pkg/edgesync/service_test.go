@@ -0,0 +1,137 @@
+package edgesync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/group"
+	"github.com/d-rk/checkin-system/pkg/internal/testutil"
+	"github.com/d-rk/checkin-system/pkg/location"
+	"github.com/d-rk/checkin-system/pkg/user"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeUpstream serves the login and sync endpoints. Its first token expires after one request.
+type fakeUpstream struct {
+	logins   int
+	token    string
+	pushed   []pushedCheckIn
+	snapshot Snapshot
+}
+
+func (u *fakeUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if r.URL.Path == "/api/login" {
+		u.logins++
+		u.token = fmt.Sprintf("token-%d", u.logins)
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": u.token})
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+u.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if u.logins == 1 {
+		u.token = "expired"
+	}
+
+	switch r.URL.Path {
+	case "/api/v1/sync/checkins":
+		request := pushRequest{}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		u.pushed = append(u.pushed, request.CheckIns...)
+
+		response := pushResponse{}
+		for _, c := range request.CheckIns {
+			response.Results = append(response.Results, Result{ID: c.ID, Outcome: Created})
+		}
+		_ = json.NewEncoder(w).Encode(response)
+	case "/api/v1/sync/snapshot":
+		_ = json.NewEncoder(w).Encode(u.snapshot)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestSync(t *testing.T) {
+
+	ctx := context.Background()
+	db := migratedDB(t)
+
+	upstream := &fakeUpstream{snapshot: Snapshot{Users: []User{{ID: 1, Name: "admin", Role: "ADMIN"}}}}
+	server := httptest.NewServer(upstream)
+	t.Cleanup(server.Close)
+
+	s := &service{repo: NewRepo(db), transactor: database.NewDB(db), upstreamURL: server.URL,
+		upstream: newClient(server.URL+"/", "admin", "secret"), now: func() time.Time { return now }}
+
+	checkInID := insertCheckIn(t, db, 1, now)
+
+	require.NoError(t, s.Sync(ctx))
+
+	// the token expired after the push, so the snapshot was requested after a second login
+	assert.Equal(t, 2, upstream.logins)
+	assert.Equal(t, []pushedCheckIn{{ID: checkInID, UserID: 1, Timestamp: now}}, upstream.pushed)
+
+	status, err := s.Status(ctx)
+	require.NoError(t, err)
+	assert.True(t, status.Enabled)
+	assert.Zero(t, status.PendingCheckIns)
+	assert.Equal(t, 1, status.PushedCheckIns)
+	assert.Equal(t, 1, status.PulledUsers)
+	assert.Equal(t, now, status.LastSuccessAt.Time)
+	assert.False(t, status.LastError.Valid)
+
+	// nothing is pushed again
+	require.NoError(t, s.Sync(ctx))
+	assert.Len(t, upstream.pushed, 1)
+
+	server.Close()
+
+	require.Error(t, s.Sync(ctx))
+
+	status, err = s.Status(ctx)
+	require.NoError(t, err)
+	assert.True(t, status.LastError.Valid)
+	assert.Equal(t, now, status.LastSuccessAt.Time)
+}
+
+func TestSnapshot_LeavesOutThePasswordsOfAdmins(t *testing.T) {
+
+	ctx := context.Background()
+	db := migratedDB(t)
+
+	userService := testutil.UserService(t, db, config.Users{AdminPassword: "Adm1n-secret-pw!"})
+
+	for _, u := range []*user.User{
+		{Name: "trainer", Role: user.RoleTrainer},
+		{Name: "member", Role: user.RoleUser},
+	} {
+		created, createErr := userService.CreateUser(ctx, u)
+		require.NoError(t, createErr)
+		require.NoError(t, userService.UpdateUserPassword(ctx, created.ID, "a long password"))
+	}
+
+	s, err := NewService(NewRepo(db), database.NewDB(db), userService, group.NewService(group.NewRepo(db)),
+		location.NewService(location.NewRepo(db)), nil, config.Sync{})
+	require.NoError(t, err)
+
+	snapshot, err := s.Snapshot(ctx)
+	require.NoError(t, err)
+
+	digests := make(map[string]bool)
+	for _, u := range snapshot.Users {
+		digests[u.Name] = u.PasswordDigest.Valid
+	}
+	assert.Equal(t, map[string]bool{"admin": false, "trainer": true, "member": true}, digests)
+}
@@ -0,0 +1,39 @@
+package group
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// Group is a training group members belong to. Trainers assigned to a group manage the check-ins of its members.
+type Group struct {
+	ID          int64       `db:"id"          json:"id"`
+	TenantID    int64       `db:"tenant_id"   json:"-"`
+	CreatedAt   time.Time   `db:"created_at"  json:"created_at"`
+	UpdatedAt   null.Time   `db:"updated_at"  json:"updated_at"`
+	Name        string      `db:"name"        json:"name"`
+	Description null.String `db:"description" json:"description"`
+	Colour      null.String `db:"colour"      json:"colour"`
+	Capacity    null.Int    `db:"capacity"    json:"capacity"`
+	Schedule    null.String `db:"schedule"    json:"schedule"`
+}
+
+// Scope restricts access to the members of the groups of a trainer. The zero value grants access to all members.
+type Scope struct {
+	TrainerID int64
+}
+
+// TrainerScope returns the scope of a trainer.
+func TrainerScope(trainerID int64) Scope {
+	return Scope{TrainerID: trainerID}
+}
+
+// Restricted returns true if the scope does not grant access to all members.
+func (s Scope) Restricted() bool {
+	return s.TrainerID != 0
+}
+
+// ScopeCondition is an sql condition restricting users to a scope. The TrainerID of the scope is passed as $1.
+const ScopeCondition = `(CAST($1 AS bigint) = 0 OR users.group_id IN
+	(SELECT group_id FROM group_trainers WHERE user_id = CAST($1 AS bigint)))`
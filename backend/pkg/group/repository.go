@@ -0,0 +1,182 @@
+package group
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/tenant"
+
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/guregu/null.v4"
+)
+
+type Repository interface {
+	ListGroups(ctx context.Context) ([]Group, error)
+	GetGroupByID(ctx context.Context, id int64) (*Group, error)
+	GetGroupByName(ctx context.Context, name string, excludeID int64) (*Group, error)
+	SaveGroup(ctx context.Context, group *Group) (*Group, error)
+	UpdateGroup(ctx context.Context, group *Group) (*Group, error)
+	DeleteGroup(ctx context.Context, id int64) error
+	ListTrainerIDs(ctx context.Context, groupID int64) ([]int64, error)
+	SetTrainerIDs(ctx context.Context, groupID int64, trainerIDs []int64) error
+	IsTrainer(ctx context.Context, trainerID, groupID int64) (bool, error)
+}
+
+type repository struct {
+	db *database.DB
+}
+
+func NewRepo(db *sqlx.DB) Repository {
+	return &repository{database.NewDB(db)}
+}
+
+func (r *repository) ListGroups(ctx context.Context) ([]Group, error) {
+
+	groups := make([]Group, 0)
+
+	if err := r.db.SelectContext(ctx, &groups, "SELECT * FROM groups WHERE tenant_id = $1 ORDER BY name",
+		tenant.ID(ctx)); err != nil {
+		return nil, fmt.Errorf("failed to list groups: %w", err)
+	}
+
+	return groups, nil
+}
+
+func (r *repository) GetGroupByID(ctx context.Context, id int64) (*Group, error) {
+
+	group := Group{}
+
+	if err := r.db.GetContext(ctx, &group, "SELECT * FROM groups WHERE id = $1 AND tenant_id = $2", id,
+		tenant.ID(ctx)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, app.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &group, nil
+}
+
+func (r *repository) GetGroupByName(ctx context.Context, name string, excludeID int64) (*Group, error) {
+
+	group := Group{}
+
+	if err := r.db.GetContext(ctx, &group, "SELECT * FROM groups WHERE name = $1 AND id != $2 AND tenant_id = $3",
+		name, excludeID, tenant.ID(ctx)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, app.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &group, nil
+}
+
+func (r *repository) SaveGroup(ctx context.Context, group *Group) (*Group, error) {
+
+	group.CreatedAt = time.Now()
+	group.TenantID = tenant.ID(ctx)
+
+	insertStatement, err := r.db.PrepareNamedContext(ctx, `INSERT INTO groups
+			(created_at, tenant_id, name, description, colour, capacity, schedule) VALUES
+			(:created_at, :tenant_id, :name, :description, :colour, :capacity, :schedule) RETURNING id`)
+	if err != nil {
+		return nil, err
+	}
+	defer insertStatement.Close()
+
+	if err = insertStatement.QueryRowContext(ctx, group).Scan(&group.ID); err != nil {
+		return nil, err
+	}
+
+	return group, nil
+}
+
+func (r *repository) UpdateGroup(ctx context.Context, group *Group) (*Group, error) {
+
+	group.UpdatedAt = null.TimeFrom(time.Now())
+	group.TenantID = tenant.ID(ctx)
+
+	updateStatement, err := r.db.PrepareNamedContext(ctx, `UPDATE groups SET
+			(updated_at, name, description, colour, capacity, schedule) =
+			(:updated_at, :name, :description, :colour, :capacity, :schedule)
+			WHERE id = :id AND tenant_id = :tenant_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer updateStatement.Close()
+
+	if _, err = updateStatement.ExecContext(ctx, group); err != nil {
+		return nil, err
+	}
+
+	return group, nil
+}
+
+// DeleteGroup deletes the group along with its announcements. Its members are kept without group.
+func (r *repository) DeleteGroup(ctx context.Context, id int64) error {
+
+	return r.db.WithTransaction(ctx, func(ctx context.Context) error {
+
+		for _, query := range []string{
+			`UPDATE users SET group_id = NULL WHERE group_id = $1`,
+			`DELETE FROM announcements WHERE group_id = $1`,
+			`DELETE FROM group_trainers WHERE group_id = $1`,
+		} {
+			if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+				return err
+			}
+		}
+
+		_, err := r.db.ExecContext(ctx, `DELETE FROM groups WHERE id = $1 AND tenant_id = $2`, id, tenant.ID(ctx))
+		return err
+	})
+}
+
+func (r *repository) ListTrainerIDs(ctx context.Context, groupID int64) ([]int64, error) {
+
+	trainerIDs := make([]int64, 0)
+
+	if err := r.db.SelectContext(ctx, &trainerIDs,
+		"SELECT user_id FROM group_trainers WHERE group_id = $1 ORDER BY user_id", groupID); err != nil {
+		return nil, fmt.Errorf("failed to list trainers: %w", err)
+	}
+
+	return trainerIDs, nil
+}
+
+func (r *repository) SetTrainerIDs(ctx context.Context, groupID int64, trainerIDs []int64) error {
+
+	return r.db.WithTransaction(ctx, func(ctx context.Context) error {
+
+		if _, err := r.db.ExecContext(ctx, `DELETE FROM group_trainers WHERE group_id = $1`, groupID); err != nil {
+			return err
+		}
+
+		for _, trainerID := range trainerIDs {
+			if _, err := r.db.ExecContext(ctx, `INSERT INTO group_trainers (group_id, user_id) VALUES ($1, $2)`,
+				groupID, trainerID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *repository) IsTrainer(ctx context.Context, trainerID, groupID int64) (bool, error) {
+
+	var count int
+
+	if err := r.db.GetContext(ctx, &count,
+		"SELECT count(*) FROM group_trainers WHERE user_id = $1 AND group_id = $2", trainerID, groupID); err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
@@ -0,0 +1,141 @@
+package group
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"gopkg.in/guregu/null.v4"
+)
+
+const maxNameLength = 50
+
+var colourPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+type Service interface {
+	ListGroups(ctx context.Context) ([]Group, error)
+	GetGroupByID(ctx context.Context, id int64) (*Group, error)
+	GetGroupByName(ctx context.Context, name string) (*Group, error)
+	CreateGroup(ctx context.Context, group *Group) (*Group, error)
+	UpdateGroup(ctx context.Context, group *Group) (*Group, error)
+	// DeleteGroup deletes the group and its announcements. Its members are kept without group.
+	DeleteGroup(ctx context.Context, id int64) error
+	ListTrainerIDs(ctx context.Context, groupID int64) ([]int64, error)
+	// SetTrainerIDs replaces the trainers of the group. Callers have to make sure the users are trainers.
+	SetTrainerIDs(ctx context.Context, groupID int64, trainerIDs []int64) error
+	// InScope returns true if the members of the group are accessible within the scope.
+	InScope(ctx context.Context, scope Scope, groupID null.Int) (bool, error)
+}
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{repo}
+}
+
+func (s *service) ListGroups(ctx context.Context) ([]Group, error) {
+	return s.repo.ListGroups(ctx)
+}
+
+func (s *service) GetGroupByID(ctx context.Context, id int64) (*Group, error) {
+	return s.repo.GetGroupByID(ctx, id)
+}
+
+func (s *service) GetGroupByName(ctx context.Context, name string) (*Group, error) {
+	return s.repo.GetGroupByName(ctx, name, -1)
+}
+
+func (s *service) CreateGroup(ctx context.Context, group *Group) (*Group, error) {
+
+	if err := s.validate(ctx, group, -1); err != nil {
+		return nil, err
+	}
+
+	return s.repo.SaveGroup(ctx, group)
+}
+
+func (s *service) UpdateGroup(ctx context.Context, group *Group) (*Group, error) {
+
+	existing, err := s.repo.GetGroupByID(ctx, group.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.validate(ctx, group, group.ID); err != nil {
+		return nil, err
+	}
+
+	group.CreatedAt = existing.CreatedAt
+
+	return s.repo.UpdateGroup(ctx, group)
+}
+
+func (s *service) DeleteGroup(ctx context.Context, id int64) error {
+
+	if _, err := s.repo.GetGroupByID(ctx, id); err != nil {
+		return err
+	}
+
+	return s.repo.DeleteGroup(ctx, id)
+}
+
+func (s *service) ListTrainerIDs(ctx context.Context, groupID int64) ([]int64, error) {
+
+	if _, err := s.repo.GetGroupByID(ctx, groupID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListTrainerIDs(ctx, groupID)
+}
+
+func (s *service) SetTrainerIDs(ctx context.Context, groupID int64, trainerIDs []int64) error {
+
+	if _, err := s.repo.GetGroupByID(ctx, groupID); err != nil {
+		return err
+	}
+
+	trainerIDs = slices.Clone(trainerIDs)
+	slices.Sort(trainerIDs)
+
+	return s.repo.SetTrainerIDs(ctx, groupID, slices.Compact(trainerIDs))
+}
+
+func (s *service) InScope(ctx context.Context, scope Scope, groupID null.Int) (bool, error) {
+
+	if !scope.Restricted() {
+		return true, nil
+	}
+
+	if !groupID.Valid {
+		return false, nil
+	}
+
+	return s.repo.IsTrainer(ctx, scope.TrainerID, groupID.Int64)
+}
+
+func (s *service) validate(ctx context.Context, group *Group, excludeID int64) error {
+
+	group.Name = strings.TrimSpace(group.Name)
+	if group.Name == "" || len(group.Name) > maxNameLength {
+		return fmt.Errorf("group name must have 1 to %d characters: %w", maxNameLength, app.ErrInvalid)
+	}
+
+	if group.Colour.Valid && !colourPattern.MatchString(group.Colour.String) {
+		return fmt.Errorf("group colour must be a hex colour like #1e90ff: %w", app.ErrInvalid)
+	}
+
+	if group.Capacity.Valid && group.Capacity.Int64 < 1 {
+		return fmt.Errorf("group capacity must be positive: %w", app.ErrInvalid)
+	}
+
+	if _, err := s.repo.GetGroupByName(ctx, group.Name, excludeID); err == nil {
+		return fmt.Errorf("group with name already exists: %w", app.ErrConflict)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+//go:build integration
+
+package group
+
+import (
+	"context"
+	"testing"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+// insertTrainer inserts a trainer, as the user package cannot be imported by the tests of the groups.
+func insertTrainer(t *testing.T, db *sqlx.DB, name string) int64 {
+	t.Helper()
+
+	var id int64
+	require.NoError(t, db.QueryRowx(`INSERT INTO users (created_at, name, role) VALUES (current_timestamp, $1,
+		'TRAINER') RETURNING id`, name).Scan(&id))
+	return id
+}
+
+func TestCreateGroup(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+
+	tests := []struct {
+		name  string
+		group Group
+		err   error
+	}{
+		{"valid", Group{Name: " Juniors ", Colour: null.StringFrom("#1e90ff"), Capacity: null.IntFrom(20)}, nil},
+		{"empty name", Group{Name: "  "}, app.ErrInvalid},
+		{"long name", Group{Name: "abcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyz"}, app.ErrInvalid},
+		{"invalid colour", Group{Name: "Juniors", Colour: null.StringFrom("blue")}, app.ErrInvalid},
+		{"invalid capacity", Group{Name: "Juniors", Capacity: null.IntFrom(0)}, app.ErrInvalid},
+		{"duplicate name", Group{Name: "Seniors"}, app.ErrConflict},
+	}
+
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s := NewService(NewRepo(db))
+
+		_, err := s.CreateGroup(ctx, &Group{Name: "Seniors"})
+		require.NoError(t, err)
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				group := tt.group
+				g, err := s.CreateGroup(ctx, &group)
+
+				if tt.err != nil {
+					require.ErrorIs(t, err, tt.err)
+					return
+				}
+				require.NoError(t, err)
+
+				g, err = s.GetGroupByID(ctx, g.ID)
+				require.NoError(t, err)
+				assert.Equal(t, "Juniors", g.Name)
+				assert.Equal(t, "#1e90ff", g.Colour.String)
+			})
+		}
+	})
+}
+
+func TestSetTrainerIDs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s := NewService(NewRepo(db))
+
+		g, err := s.CreateGroup(ctx, &Group{Name: "Seniors"})
+		require.NoError(t, err)
+		anna := insertTrainer(t, db, "anna")
+		ben := insertTrainer(t, db, "ben")
+
+		require.NoError(t, s.SetTrainerIDs(ctx, g.ID, []int64{ben, anna, ben}))
+
+		trainerIDs, err := s.ListTrainerIDs(ctx, g.ID)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int64{anna, ben}, trainerIDs)
+
+		require.ErrorIs(t, s.SetTrainerIDs(ctx, g.ID+1, []int64{anna}), app.ErrNotFound)
+	})
+}
+
+func TestInScope(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s := NewService(NewRepo(db))
+
+		seniors, err := s.CreateGroup(ctx, &Group{Name: "Seniors"})
+		require.NoError(t, err)
+		juniors, err := s.CreateGroup(ctx, &Group{Name: "Juniors"})
+		require.NoError(t, err)
+		trainerID := insertTrainer(t, db, "anna")
+		require.NoError(t, s.SetTrainerIDs(ctx, seniors.ID, []int64{trainerID}))
+
+		tests := []struct {
+			name    string
+			scope   Scope
+			groupID null.Int
+			want    bool
+		}{
+			{"admin", Scope{}, null.Int{}, true},
+			{"own group", TrainerScope(trainerID), null.IntFrom(seniors.ID), true},
+			{"other group", TrainerScope(trainerID), null.IntFrom(juniors.ID), false},
+			{"no group", TrainerScope(trainerID), null.Int{}, false},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				inScope, err := s.InScope(ctx, tt.scope, tt.groupID)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, inScope)
+			})
+		}
+	})
+}
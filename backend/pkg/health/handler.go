@@ -0,0 +1,39 @@
+package health
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// LivenessHandler answers as long as the process serves requests.
+func LivenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("ok\n"))
+	}
+}
+
+// ReadinessHandler runs the readiness checks and answers with 503 Service Unavailable if one of them failed. The
+// response only names the checks and whether they passed, the errors are logged.
+func ReadinessHandler(service Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		readiness := service.Ready(r.Context())
+
+		status := http.StatusOK
+		if !readiness.Ready {
+			status = http.StatusServiceUnavailable
+		}
+
+		for _, check := range readiness.Checks {
+			if !check.OK {
+				slog.WarnContext(r.Context(), "readiness check failed", "check", check.Name, "error", check.Error)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(readiness)
+	}
+}
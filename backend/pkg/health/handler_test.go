@@ -0,0 +1,23 @@
+package health
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadinessHandler_LeavesOutTheErrors(t *testing.T) {
+
+	service := NewService(&fakeRepo{pingErr: errors.New("dial tcp 10.0.0.5:5432: connection refused")},
+		&fakeExecutor{}, &fakeWifi{})
+
+	rec := httptest.NewRecorder()
+	ReadinessHandler(service)(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), `{"name":"database","ok":false}`)
+	assert.NotContains(t, rec.Body.String(), "10.0.0.5")
+}
@@ -0,0 +1,39 @@
+package health
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// Check is the result of a single readiness check. The error is only logged, as the readiness is served without
+// authentication.
+type Check struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error error  `json:"-"`
+}
+
+// Readiness is the result of all readiness checks. The backend is ready, if all of them passed.
+type Readiness struct {
+	Ready  bool    `json:"ready"`
+	Checks []Check `json:"checks"`
+}
+
+// Diagnostics describes the state of the deployment for administrators.
+type Diagnostics struct {
+	Version           string
+	BuildTime         string
+	GitCommit         string
+	StartedAt         time.Time
+	Uptime            time.Duration
+	Driver            string
+	SchemaVersion     string
+	PendingMigrations int
+	DatabaseSize      int64
+	RowCounts         map[string]int64
+	LastCheckInAt     null.Time
+	// ClockSkew is how far the system clock is behind the latest checkIn. Readers store their taps with the time of
+	// the system, so a later checkIn means the clock was set back, e.g. by a raspi without hardware clock.
+	ClockSkew time.Duration
+}
@@ -0,0 +1,114 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/d-rk/checkin-system/pkg/database"
+
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/guregu/null.v4"
+)
+
+// countedTables are the tables whose rows are reported by the diagnostics.
+var countedTables = []string{"tenants", "users", "groups", "locations", "checkins", "announcements", "audit_log"}
+
+type Repository interface {
+	// Driver returns the name of the database driver.
+	Driver() string
+	Ping(ctx context.Context) error
+	// Migrations returns the last applied migration and the number of pending migrations.
+	Migrations(ctx context.Context) (string, int, error)
+	// Size returns the size of the database in bytes.
+	Size(ctx context.Context) (int64, error)
+	// CountRows returns the number of rows of the main tables of all tenants.
+	CountRows(ctx context.Context) (map[string]int64, error)
+	// LatestCheckIn returns the timestamp of the latest checkIn of all tenants.
+	LatestCheckIn(ctx context.Context) (null.Time, error)
+}
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepo(db *sqlx.DB) Repository {
+	return &repository{db}
+}
+
+func (r *repository) Driver() string {
+	return r.db.DriverName()
+}
+
+func (r *repository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+func (r *repository) Migrations(_ context.Context) (string, int, error) {
+
+	migrations, err := database.Migrations(r.db)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var version string
+	pending := 0
+
+	for _, m := range migrations {
+		if m.AppliedAt == nil {
+			pending++
+		} else if m.ID > version {
+			version = m.ID
+		}
+	}
+
+	return version, pending, nil
+}
+
+func (r *repository) Size(ctx context.Context) (int64, error) {
+
+	var size int64
+	var err error
+
+	switch r.db.DriverName() {
+	case "sqlite3":
+		err = r.db.GetContext(ctx, &size,
+			"SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()")
+	case "postgres":
+		err = r.db.GetContext(ctx, &size, "SELECT pg_database_size(current_database())")
+	default:
+		err = fmt.Errorf("unknown driver %s", r.db.DriverName())
+	}
+
+	return size, err
+}
+
+func (r *repository) CountRows(ctx context.Context) (map[string]int64, error) {
+
+	counts := make(map[string]int64, len(countedTables))
+
+	for _, table := range countedTables {
+		var count int64
+		if err := r.db.GetContext(ctx, &count, "SELECT count(*) FROM "+table); err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", table, err)
+		}
+		counts[table] = count
+	}
+
+	return counts, nil
+}
+
+func (r *repository) LatestCheckIn(ctx context.Context) (null.Time, error) {
+
+	var timestamp null.Time
+
+	// sqlite returns max(timestamp) as text, the column itself keeps its type
+	err := r.db.GetContext(ctx, &timestamp,
+		"SELECT timestamp FROM checkins WHERE timestamp IS NOT NULL ORDER BY timestamp DESC LIMIT 1")
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return null.Time{}, err
+	}
+
+	return timestamp, nil
+}
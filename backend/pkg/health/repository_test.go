@@ -0,0 +1,64 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/database"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_Sqlite(t *testing.T) {
+
+	ctx := context.Background()
+
+	db, err := sqlx.Connect("sqlite3", fmt.Sprintf("file:%s?_loc=UTC&_fk=1", filepath.Join(t.TempDir(), "checkin.db")))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	_, err = database.MigrateUp(ctx, db, 2)
+	require.NoError(t, err)
+
+	repo := NewRepo(db)
+
+	version, pending, err := repo.Migrations(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "0002-checkins-init.sql", version)
+	assert.Positive(t, pending)
+
+	_, err = database.MigrateUp(ctx, db, 0)
+	require.NoError(t, err)
+
+	_, pending, err = repo.Migrations(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, pending)
+
+	latest, err := repo.LatestCheckIn(ctx)
+	require.NoError(t, err)
+	assert.False(t, latest.Valid)
+
+	timestamp := time.Date(2024, 3, 1, 18, 30, 0, 0, time.UTC)
+	_, err = db.Exec(`INSERT INTO checkins (date, timestamp, user_id) VALUES ($1, $2, 1)`,
+		timestamp.Truncate(24*time.Hour), timestamp)
+	require.NoError(t, err)
+
+	latest, err = repo.LatestCheckIn(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, timestamp, latest.Time)
+
+	counts, err := repo.CountRows(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), counts["checkins"])
+	assert.Equal(t, int64(1), counts["users"])
+	assert.Equal(t, int64(1), counts["tenants"])
+
+	size, err := repo.Size(ctx)
+	require.NoError(t, err)
+	assert.Positive(t, size)
+}
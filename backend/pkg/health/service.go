@@ -0,0 +1,114 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/cmd"
+	"github.com/d-rk/checkin-system/pkg/version"
+	"github.com/d-rk/checkin-system/pkg/wifi"
+)
+
+const checkTimeout = 5 * time.Second
+
+type Service interface {
+	// Ready checks that the database is reachable and fully migrated, commands can be executed and the wifi script
+	// is present.
+	Ready(ctx context.Context) Readiness
+	Diagnostics(ctx context.Context) (*Diagnostics, error)
+}
+
+type service struct {
+	repo        Repository
+	executor    cmd.Executor
+	wifiService wifi.Service
+	startedAt   time.Time
+	now         func() time.Time
+}
+
+func NewService(repo Repository, executor cmd.Executor, wifiService wifi.Service) Service {
+	return &service{repo, executor, wifiService, time.Now(), time.Now}
+}
+
+func (s *service) Ready(ctx context.Context) Readiness {
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	checks := []struct {
+		name  string
+		check func(ctx context.Context) error
+	}{
+		{"database", s.repo.Ping},
+		{"migrations", s.checkMigrations},
+		{"executor", func(ctx context.Context) error { return s.executor.Call(ctx, "echo", "ready") }},
+		{"wifi_script", func(_ context.Context) error { return s.wifiService.CheckScript() }},
+	}
+
+	readiness := Readiness{Ready: true, Checks: make([]Check, 0, len(checks))}
+
+	for _, c := range checks {
+		check := Check{Name: c.name, OK: true}
+		if err := c.check(ctx); err != nil {
+			check.OK = false
+			check.Error = err
+			readiness.Ready = false
+		}
+		readiness.Checks = append(readiness.Checks, check)
+	}
+
+	return readiness
+}
+
+func (s *service) checkMigrations(ctx context.Context) error {
+
+	version, pending, err := s.repo.Migrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	if pending > 0 {
+		return fmt.Errorf("%d migrations pending after %q", pending, version)
+	}
+
+	return nil
+}
+
+func (s *service) Diagnostics(ctx context.Context) (*Diagnostics, error) {
+
+	now := s.now()
+
+	diagnostics := &Diagnostics{
+		Version:   version.Version,
+		BuildTime: version.BuildTime,
+		GitCommit: version.GitCommit,
+		StartedAt: s.startedAt,
+		Uptime:    now.Sub(s.startedAt),
+		Driver:    s.repo.Driver(),
+	}
+
+	var err error
+
+	if diagnostics.SchemaVersion, diagnostics.PendingMigrations, err = s.repo.Migrations(ctx); err != nil {
+		return nil, err
+	}
+
+	if diagnostics.DatabaseSize, err = s.repo.Size(ctx); err != nil {
+		return nil, err
+	}
+
+	if diagnostics.RowCounts, err = s.repo.CountRows(ctx); err != nil {
+		return nil, err
+	}
+
+	if diagnostics.LastCheckInAt, err = s.repo.LatestCheckIn(ctx); err != nil {
+		return nil, err
+	}
+
+	if diagnostics.LastCheckInAt.Valid && diagnostics.LastCheckInAt.Time.After(now) {
+		diagnostics.ClockSkew = diagnostics.LastCheckInAt.Time.Sub(now)
+	}
+
+	return diagnostics, nil
+}
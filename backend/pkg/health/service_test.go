@@ -0,0 +1,130 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/cmd"
+	"github.com/d-rk/checkin-system/pkg/wifi"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+type fakeRepo struct {
+	Repository
+	pingErr       error
+	pending       int
+	latestCheckIn null.Time
+}
+
+func (r *fakeRepo) Driver() string {
+	return "sqlite3"
+}
+
+func (r *fakeRepo) Ping(_ context.Context) error {
+	return r.pingErr
+}
+
+func (r *fakeRepo) Migrations(_ context.Context) (string, int, error) {
+	return "0015-edge-sync.sql", r.pending, nil
+}
+
+func (r *fakeRepo) Size(_ context.Context) (int64, error) {
+	return 4096, nil
+}
+
+func (r *fakeRepo) CountRows(_ context.Context) (map[string]int64, error) {
+	return map[string]int64{"users": 1}, nil
+}
+
+func (r *fakeRepo) LatestCheckIn(_ context.Context) (null.Time, error) {
+	return r.latestCheckIn, nil
+}
+
+type fakeExecutor struct {
+	cmd.Executor
+	err error
+}
+
+func (e *fakeExecutor) Call(_ context.Context, _ string, _ ...string) error {
+	return e.err
+}
+
+type fakeWifi struct {
+	wifi.Service
+	err error
+}
+
+func (w *fakeWifi) CheckScript() error {
+	return w.err
+}
+
+func TestReady(t *testing.T) {
+
+	failure := errors.New("failure")
+
+	tests := []struct {
+		name     string
+		repo     *fakeRepo
+		executor *fakeExecutor
+		wifi     *fakeWifi
+		failed   []string
+	}{
+		{name: "ready", repo: &fakeRepo{}, executor: &fakeExecutor{}, wifi: &fakeWifi{}},
+		{name: "database unreachable", repo: &fakeRepo{pingErr: failure}, executor: &fakeExecutor{},
+			wifi: &fakeWifi{}, failed: []string{"database"}},
+		{name: "pending migrations", repo: &fakeRepo{pending: 1}, executor: &fakeExecutor{}, wifi: &fakeWifi{},
+			failed: []string{"migrations"}},
+		{name: "executor and wifi script", repo: &fakeRepo{}, executor: &fakeExecutor{err: failure},
+			wifi: &fakeWifi{err: failure}, failed: []string{"executor", "wifi_script"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			readiness := NewService(tc.repo, tc.executor, tc.wifi).Ready(context.Background())
+
+			var failed []string
+			for _, check := range readiness.Checks {
+				if !check.OK {
+					failed = append(failed, check.Name)
+				}
+			}
+
+			assert.Equal(t, tc.failed, failed)
+			assert.Equal(t, len(tc.failed) == 0, readiness.Ready)
+			assert.Len(t, readiness.Checks, 4)
+		})
+	}
+}
+
+func TestDiagnostics_ClockSkew(t *testing.T) {
+
+	now := time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		latestCheckIn null.Time
+		skew          time.Duration
+	}{
+		{name: "no checkIns", latestCheckIn: null.Time{}},
+		{name: "checkIn in the past", latestCheckIn: null.TimeFrom(now.Add(-time.Hour))},
+		{name: "checkIn in the future", latestCheckIn: null.TimeFrom(now.Add(2 * time.Hour)), skew: 2 * time.Hour},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &service{repo: &fakeRepo{latestCheckIn: tc.latestCheckIn}, startedAt: now.Add(-time.Minute),
+				now: func() time.Time { return now }}
+
+			diagnostics, err := s.Diagnostics(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, tc.skew, diagnostics.ClockSkew)
+			assert.Equal(t, time.Minute, diagnostics.Uptime)
+			assert.Equal(t, tc.latestCheckIn, diagnostics.LastCheckInAt)
+		})
+	}
+}
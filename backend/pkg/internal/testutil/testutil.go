@@ -0,0 +1,28 @@
+// Package testutil wires the services which the tests of several packages need against a test database.
+package testutil
+
+import (
+	"testing"
+
+	"github.com/d-rk/checkin-system/pkg/audit"
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/password"
+	"github.com/d-rk/checkin-system/pkg/user"
+	"github.com/d-rk/checkin-system/pkg/websocket"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+// UserService returns the user service of the database with the default password hashing and policy.
+func UserService(t *testing.T, db *sqlx.DB, cfg config.Users) user.Service {
+	t.Helper()
+
+	hasher, err := password.NewHasher(config.Default().Password)
+	require.NoError(t, err)
+	policy, err := password.NewPolicy(config.Default().Password)
+	require.NoError(t, err)
+
+	return user.NewService(user.NewRepo(db), hasher, policy, &websocket.Server{}, audit.NewService(audit.NewRepo(db)),
+		database.NewDB(db), cfg)
+}
@@ -0,0 +1,121 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/tracing"
+)
+
+// Job is a task which runs periodically in the background.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	// RunOnStart runs the job right after the start of the runner, otherwise only once the first interval passed.
+	RunOnStart bool
+	Run        func(ctx context.Context) error
+}
+
+// Runner runs the jobs until it is stopped. A job never runs concurrently with itself.
+type Runner struct {
+	mu      sync.Mutex
+	jobs    []Job
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+}
+
+func NewRunner() *Runner {
+	return &Runner{}
+}
+
+// Add registers the job. Jobs added after the start are not run. The interval of the job has to be positive.
+func (r *Runner) Add(job Job) error {
+
+	if job.Interval <= 0 {
+		return fmt.Errorf("job %s: interval must be positive, got %s", job.Name, job.Interval)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.jobs = append(r.jobs, job)
+	return nil
+}
+
+// Start runs the jobs in the background until Stop is called or the context is done.
+func (r *Runner) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ctx, r.cancel = context.WithCancel(ctx)
+
+	for _, job := range r.jobs {
+		r.running.Add(1)
+		go func() {
+			defer r.running.Done()
+			r.schedule(ctx, job)
+		}()
+	}
+}
+
+// Stop cancels the running jobs and waits until they returned, at most until the context is done.
+func (r *Runner) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.mu.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		r.running.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("jobs did not stop in time: %w", ctx.Err())
+	}
+}
+
+func (r *Runner) schedule(ctx context.Context, job Job) {
+
+	if job.RunOnStart {
+		run(ctx, job)
+	}
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			run(ctx, job)
+		}
+	}
+}
+
+func run(ctx context.Context, job Job) {
+
+	ctx, span := tracing.Start(ctx, "job "+job.Name)
+
+	var err error
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("job panicked: %v", recovered)
+		}
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "job failed", "job", job.Name, "error", err)
+		}
+		tracing.End(span, err)
+	}()
+
+	err = job.Run(ctx)
+}
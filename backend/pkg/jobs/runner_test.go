@@ -0,0 +1,84 @@
+package jobs
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunner(t *testing.T) {
+
+	var onStart, periodic, panicking atomic.Int32
+	stopped := make(chan struct{})
+
+	runner := NewRunner()
+	require.NoError(t, runner.Add(Job{Name: "on start", Interval: time.Hour, RunOnStart: true,
+		Run: func(context.Context) error {
+			onStart.Add(1)
+			return nil
+		}}))
+	require.NoError(t, runner.Add(Job{Name: "periodic", Interval: 10 * time.Millisecond,
+		Run: func(ctx context.Context) error {
+			if periodic.Add(1) == 3 {
+				// the last run lasts until the runner is stopped
+				<-ctx.Done()
+				close(stopped)
+			}
+			return nil
+		}}))
+	require.NoError(t, runner.Add(Job{Name: "panicking", Interval: 10 * time.Millisecond,
+		Run: func(context.Context) error {
+			panicking.Add(1)
+			panic("failure")
+		}}))
+
+	runner.Start(context.Background())
+
+	require.Eventually(t, func() bool { return periodic.Load() == 3 && panicking.Load() >= 2 }, time.Second,
+		time.Millisecond)
+
+	require.NoError(t, runner.Stop(context.Background()))
+
+	select {
+	case <-stopped:
+	default:
+		t.Fatal("running job was not waited for")
+	}
+
+	assert.Equal(t, int32(1), onStart.Load())
+	assert.Equal(t, int32(3), periodic.Load())
+}
+
+func TestRunner_InvalidInterval_IsRejected(t *testing.T) {
+
+	runner := NewRunner()
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		require.Error(t, runner.Add(Job{Name: "invalid", Interval: interval, Run: func(context.Context) error {
+			return nil
+		}}))
+	}
+}
+
+func TestRunner_StopTimeout(t *testing.T) {
+
+	release := make(chan struct{})
+	defer close(release)
+
+	runner := NewRunner()
+	require.NoError(t, runner.Add(Job{Name: "stuck", Interval: time.Hour, RunOnStart: true,
+		Run: func(context.Context) error {
+			<-release
+			return nil
+		}}))
+	runner.Start(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	require.ErrorIs(t, runner.Stop(ctx), context.DeadlineExceeded)
+}
@@ -0,0 +1,18 @@
+package location
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// Location is a hall the check-ins happen in. Readers are assigned to a location by their id.
+type Location struct {
+	ID          int64       `db:"id"          json:"id"`
+	TenantID    int64       `db:"tenant_id"   json:"-"`
+	CreatedAt   time.Time   `db:"created_at"  json:"created_at"`
+	UpdatedAt   null.Time   `db:"updated_at"  json:"updated_at"`
+	Name        string      `db:"name"        json:"name"`
+	Description null.String `db:"description" json:"description"`
+	Capacity    null.Int    `db:"capacity"    json:"capacity"`
+}
@@ -0,0 +1,191 @@
+package location
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/tenant"
+
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/guregu/null.v4"
+)
+
+type Repository interface {
+	ListLocations(ctx context.Context) ([]Location, error)
+	GetLocationByID(ctx context.Context, id int64) (*Location, error)
+	GetLocationByName(ctx context.Context, name string, excludeID int64) (*Location, error)
+	GetLocationByReaderID(ctx context.Context, readerID string) (*Location, error)
+	SaveLocation(ctx context.Context, location *Location) (*Location, error)
+	UpdateLocation(ctx context.Context, location *Location) (*Location, error)
+	DeleteLocation(ctx context.Context, id int64) error
+	ListReaderIDs(ctx context.Context, locationID int64) ([]string, error)
+	SetReaderIDs(ctx context.Context, locationID int64, readerIDs []string) error
+}
+
+type repository struct {
+	db *database.DB
+}
+
+func NewRepo(db *sqlx.DB) Repository {
+	return &repository{database.NewDB(db)}
+}
+
+func (r *repository) ListLocations(ctx context.Context) ([]Location, error) {
+
+	locations := make([]Location, 0)
+
+	if err := r.db.SelectContext(ctx, &locations, "SELECT * FROM locations WHERE tenant_id = $1 ORDER BY name",
+		tenant.ID(ctx)); err != nil {
+		return nil, fmt.Errorf("failed to list locations: %w", err)
+	}
+
+	return locations, nil
+}
+
+func (r *repository) GetLocationByID(ctx context.Context, id int64) (*Location, error) {
+
+	location := Location{}
+
+	if err := r.db.GetContext(ctx, &location, "SELECT * FROM locations WHERE id = $1 AND tenant_id = $2", id,
+		tenant.ID(ctx)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, app.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &location, nil
+}
+
+func (r *repository) GetLocationByName(ctx context.Context, name string, excludeID int64) (*Location, error) {
+
+	location := Location{}
+
+	if err := r.db.GetContext(ctx, &location, "SELECT * FROM locations WHERE name = $1 AND id != $2 AND tenant_id = $3",
+		name, excludeID, tenant.ID(ctx)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, app.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &location, nil
+}
+
+func (r *repository) GetLocationByReaderID(ctx context.Context, readerID string) (*Location, error) {
+
+	location := Location{}
+
+	if err := r.db.GetContext(ctx, &location, `SELECT locations.*
+			FROM locations JOIN location_readers ON location_readers.location_id = locations.id
+			WHERE location_readers.reader_id = $1 AND location_readers.tenant_id = $2`, readerID,
+		tenant.ID(ctx)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, app.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &location, nil
+}
+
+func (r *repository) SaveLocation(ctx context.Context, location *Location) (*Location, error) {
+
+	location.CreatedAt = time.Now()
+	location.TenantID = tenant.ID(ctx)
+
+	insertStatement, err := r.db.PrepareNamedContext(ctx, `INSERT INTO locations
+			(created_at, tenant_id, name, description, capacity) VALUES
+			(:created_at, :tenant_id, :name, :description, :capacity) RETURNING id`)
+	if err != nil {
+		return nil, err
+	}
+	defer insertStatement.Close()
+
+	if err = insertStatement.QueryRowContext(ctx, location).Scan(&location.ID); err != nil {
+		return nil, err
+	}
+
+	return location, nil
+}
+
+func (r *repository) UpdateLocation(ctx context.Context, location *Location) (*Location, error) {
+
+	location.UpdatedAt = null.TimeFrom(time.Now())
+	location.TenantID = tenant.ID(ctx)
+
+	updateStatement, err := r.db.PrepareNamedContext(ctx, `UPDATE locations SET
+			(updated_at, name, description, capacity) =
+			(:updated_at, :name, :description, :capacity) WHERE id = :id AND tenant_id = :tenant_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer updateStatement.Close()
+
+	if _, err = updateStatement.ExecContext(ctx, location); err != nil {
+		return nil, err
+	}
+
+	return location, nil
+}
+
+// DeleteLocation deletes the location along with its readers. Its check-ins are kept without location.
+func (r *repository) DeleteLocation(ctx context.Context, id int64) error {
+
+	return r.db.WithTransaction(ctx, func(ctx context.Context) error {
+
+		for _, query := range []string{
+			`UPDATE checkins SET location_id = NULL WHERE location_id = $1`,
+			`DELETE FROM location_readers WHERE location_id = $1`,
+		} {
+			if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+				return err
+			}
+		}
+
+		_, err := r.db.ExecContext(ctx, `DELETE FROM locations WHERE id = $1 AND tenant_id = $2`, id, tenant.ID(ctx))
+		return err
+	})
+}
+
+func (r *repository) ListReaderIDs(ctx context.Context, locationID int64) ([]string, error) {
+
+	readerIDs := make([]string, 0)
+
+	if err := r.db.SelectContext(ctx, &readerIDs,
+		"SELECT reader_id FROM location_readers WHERE location_id = $1 ORDER BY reader_id", locationID); err != nil {
+		return nil, fmt.Errorf("failed to list readers: %w", err)
+	}
+
+	return readerIDs, nil
+}
+
+// SetReaderIDs replaces the readers of the location. Readers assigned to another location of the tenant are moved.
+func (r *repository) SetReaderIDs(ctx context.Context, locationID int64, readerIDs []string) error {
+
+	return r.db.WithTransaction(ctx, func(ctx context.Context) error {
+
+		if _, err := r.db.ExecContext(ctx, `DELETE FROM location_readers WHERE location_id = $1`,
+			locationID); err != nil {
+			return err
+		}
+
+		for _, readerID := range readerIDs {
+			if _, err := r.db.ExecContext(ctx, `DELETE FROM location_readers WHERE reader_id = $1 AND tenant_id = $2`,
+				readerID, tenant.ID(ctx)); err != nil {
+				return err
+			}
+			if _, err := r.db.ExecContext(ctx, `INSERT INTO location_readers (tenant_id, reader_id, location_id)
+					VALUES ($1, $2, $3)`, tenant.ID(ctx), readerID, locationID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,128 @@
+package location
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+)
+
+const maxNameLength = 50
+const maxReaderIDLength = 100
+
+type Service interface {
+	ListLocations(ctx context.Context) ([]Location, error)
+	GetLocationByID(ctx context.Context, id int64) (*Location, error)
+	// GetLocationByReaderID returns the location the reader is assigned to.
+	GetLocationByReaderID(ctx context.Context, readerID string) (*Location, error)
+	CreateLocation(ctx context.Context, location *Location) (*Location, error)
+	UpdateLocation(ctx context.Context, location *Location) (*Location, error)
+	// DeleteLocation deletes the location and unassigns its readers. Its check-ins are kept without location.
+	DeleteLocation(ctx context.Context, id int64) error
+	ListReaderIDs(ctx context.Context, locationID int64) ([]string, error)
+	// SetReaderIDs replaces the readers of the location. Readers assigned to another location are moved.
+	SetReaderIDs(ctx context.Context, locationID int64, readerIDs []string) error
+}
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{repo}
+}
+
+func (s *service) ListLocations(ctx context.Context) ([]Location, error) {
+	return s.repo.ListLocations(ctx)
+}
+
+func (s *service) GetLocationByID(ctx context.Context, id int64) (*Location, error) {
+	return s.repo.GetLocationByID(ctx, id)
+}
+
+func (s *service) GetLocationByReaderID(ctx context.Context, readerID string) (*Location, error) {
+	return s.repo.GetLocationByReaderID(ctx, readerID)
+}
+
+func (s *service) CreateLocation(ctx context.Context, location *Location) (*Location, error) {
+
+	if err := s.validate(ctx, location, -1); err != nil {
+		return nil, err
+	}
+
+	return s.repo.SaveLocation(ctx, location)
+}
+
+func (s *service) UpdateLocation(ctx context.Context, location *Location) (*Location, error) {
+
+	existing, err := s.repo.GetLocationByID(ctx, location.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.validate(ctx, location, location.ID); err != nil {
+		return nil, err
+	}
+
+	location.CreatedAt = existing.CreatedAt
+
+	return s.repo.UpdateLocation(ctx, location)
+}
+
+func (s *service) DeleteLocation(ctx context.Context, id int64) error {
+
+	if _, err := s.repo.GetLocationByID(ctx, id); err != nil {
+		return err
+	}
+
+	return s.repo.DeleteLocation(ctx, id)
+}
+
+func (s *service) ListReaderIDs(ctx context.Context, locationID int64) ([]string, error) {
+
+	if _, err := s.repo.GetLocationByID(ctx, locationID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListReaderIDs(ctx, locationID)
+}
+
+func (s *service) SetReaderIDs(ctx context.Context, locationID int64, readerIDs []string) error {
+
+	if _, err := s.repo.GetLocationByID(ctx, locationID); err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(readerIDs))
+	for _, readerID := range readerIDs {
+		readerID = strings.TrimSpace(readerID)
+		if readerID == "" || len(readerID) > maxReaderIDLength {
+			return fmt.Errorf("reader id must have 1 to %d characters: %w", maxReaderIDLength, app.ErrInvalid)
+		}
+		ids = append(ids, readerID)
+	}
+
+	slices.Sort(ids)
+
+	return s.repo.SetReaderIDs(ctx, locationID, slices.Compact(ids))
+}
+
+func (s *service) validate(ctx context.Context, location *Location, excludeID int64) error {
+
+	location.Name = strings.TrimSpace(location.Name)
+	if location.Name == "" || len(location.Name) > maxNameLength {
+		return fmt.Errorf("location name must have 1 to %d characters: %w", maxNameLength, app.ErrInvalid)
+	}
+
+	if location.Capacity.Valid && location.Capacity.Int64 < 1 {
+		return fmt.Errorf("location capacity must be positive: %w", app.ErrInvalid)
+	}
+
+	if _, err := s.repo.GetLocationByName(ctx, location.Name, excludeID); err == nil {
+		return fmt.Errorf("location with name already exists: %w", app.ErrConflict)
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+//go:build integration
+
+package location
+
+import (
+	"context"
+	"testing"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestCreateLocation(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+
+	tests := []struct {
+		name     string
+		location Location
+		err      error
+	}{
+		{"valid", Location{Name: " Hall B ", Capacity: null.IntFrom(40)}, nil},
+		{"empty name", Location{Name: ""}, app.ErrInvalid},
+		{"invalid capacity", Location{Name: "Hall B", Capacity: null.IntFrom(-1)}, app.ErrInvalid},
+		{"duplicate name", Location{Name: "Hall A"}, app.ErrConflict},
+	}
+
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s := NewService(NewRepo(db))
+
+		_, err := s.CreateLocation(ctx, &Location{Name: "Hall A"})
+		require.NoError(t, err)
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				location := tt.location
+				l, err := s.CreateLocation(ctx, &location)
+
+				if tt.err != nil {
+					require.ErrorIs(t, err, tt.err)
+					return
+				}
+				require.NoError(t, err)
+
+				l, err = s.GetLocationByID(ctx, l.ID)
+				require.NoError(t, err)
+				assert.Equal(t, "Hall B", l.Name)
+				assert.Equal(t, null.IntFrom(40), l.Capacity)
+			})
+		}
+	})
+}
+
+func TestUpdateLocation_KeepsOwnName(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s := NewService(NewRepo(db))
+
+		hall, err := s.CreateLocation(ctx, &Location{Name: "Hall A"})
+		require.NoError(t, err)
+
+		_, err = s.UpdateLocation(ctx, &Location{ID: hall.ID, Name: "Hall A", Capacity: null.IntFrom(10)})
+		require.NoError(t, err)
+
+		l, err := s.GetLocationByID(ctx, hall.ID)
+		require.NoError(t, err)
+		assert.Equal(t, null.IntFrom(10), l.Capacity)
+
+		_, err = s.UpdateLocation(ctx, &Location{ID: hall.ID + 1, Name: "Hall B"})
+		require.ErrorIs(t, err, app.ErrNotFound)
+	})
+}
+
+func TestSetReaderIDs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s := NewService(NewRepo(db))
+
+		hall, err := s.CreateLocation(ctx, &Location{Name: "Hall A"})
+		require.NoError(t, err)
+
+		require.NoError(t, s.SetReaderIDs(ctx, hall.ID, []string{"kiosk-2", " kiosk-1", "kiosk-2"}))
+
+		readerIDs, err := s.ListReaderIDs(ctx, hall.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"kiosk-1", "kiosk-2"}, readerIDs)
+
+		l, err := s.GetLocationByReaderID(ctx, "kiosk-1")
+		require.NoError(t, err)
+		assert.Equal(t, hall.ID, l.ID)
+
+		require.ErrorIs(t, s.SetReaderIDs(ctx, hall.ID, []string{" "}), app.ErrInvalid)
+		require.ErrorIs(t, s.SetReaderIDs(ctx, hall.ID+1, []string{"kiosk-1"}), app.ErrNotFound)
+	})
+}
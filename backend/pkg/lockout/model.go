@@ -0,0 +1,30 @@
+package lockout
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"gopkg.in/guregu/null.v4"
+)
+
+// Attempt tracks the failed logins for a key, which is either a username or a client IP.
+type Attempt struct {
+	Key           string    `db:"attempt_key"`
+	Failures      int       `db:"failures"`
+	LastFailureAt time.Time `db:"last_failure_at"`
+	LockedUntil   null.Time `db:"locked_until"`
+}
+
+// LockedError is returned when a login is rejected because of previous failed attempts.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry after %s", e.RetryAfter)
+}
+
+func (e *LockedError) Is(err error) bool {
+	return err == app.ErrLocked
+}
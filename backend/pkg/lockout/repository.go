@@ -0,0 +1,87 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/database"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository interface {
+	GetAttempt(ctx context.Context, key string) (*Attempt, error)
+	// IncrementFailures counts a failure of the key at now and returns the attempt. Failures before countedSince and
+	// expired lockouts are not counted anymore, the count starts over.
+	IncrementFailures(ctx context.Context, key string, now time.Time, countedSince time.Time) (*Attempt, error)
+	LockAttempt(ctx context.Context, key string, lockedUntil time.Time) error
+	DeleteAttempt(ctx context.Context, key string) error
+}
+
+type repository struct {
+	db *database.DB
+}
+
+func NewRepo(db *sqlx.DB) Repository {
+	return &repository{database.NewDB(db)}
+}
+
+func (r *repository) GetAttempt(ctx context.Context, key string) (*Attempt, error) {
+
+	attempt := Attempt{}
+
+	if err := r.db.GetContext(ctx, &attempt, "SELECT * FROM login_attempts WHERE attempt_key = $1", key); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, app.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &attempt, nil
+}
+
+func (r *repository) IncrementFailures(ctx context.Context, key string, now time.Time,
+	countedSince time.Time) (*Attempt, error) {
+
+	attempt := Attempt{}
+
+	// a single statement, so that concurrent failures are all counted
+	if err := r.db.GetContext(ctx, &attempt, `INSERT INTO login_attempts
+			(attempt_key, failures, last_failure_at) VALUES ($1, 1, $2)
+			ON CONFLICT (attempt_key) DO UPDATE SET
+			failures = CASE
+				WHEN login_attempts.locked_until <= $2
+					OR (login_attempts.locked_until IS NULL AND login_attempts.last_failure_at < $3) THEN 1
+				ELSE login_attempts.failures + 1 END,
+			locked_until = CASE
+				WHEN login_attempts.locked_until <= $2 THEN NULL
+				ELSE login_attempts.locked_until END,
+			last_failure_at = $2
+			RETURNING *`, key, now, countedSince); err != nil {
+		return nil, err
+	}
+
+	return &attempt, nil
+}
+
+func (r *repository) LockAttempt(ctx context.Context, key string, lockedUntil time.Time) error {
+
+	_, err := r.db.ExecContext(ctx, `UPDATE login_attempts SET locked_until = $1 WHERE attempt_key = $2`, lockedUntil,
+		key)
+	return err
+}
+
+func (r *repository) DeleteAttempt(ctx context.Context, key string) error {
+
+	deleteStatement, err := r.db.PreparexContext(ctx, `DELETE FROM login_attempts WHERE attempt_key = $1`)
+	if err != nil {
+		return err
+	}
+	defer deleteStatement.Close()
+
+	_, err = deleteStatement.ExecContext(ctx, key)
+	return err
+}
@@ -0,0 +1,187 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/d-rk/checkin-system/pkg/tenant"
+)
+
+const (
+	userKeyPrefix = "user:"
+	ipKeyPrefix   = "ip:"
+)
+
+// concurrentRetryAfter is the retry delay of an attempt for a username which is already being attempted.
+const concurrentRetryAfter = time.Second
+
+// Service protects the login against brute-force attacks.
+//
+// Failed logins are counted per username and per client IP. After every failure the next attempt is delayed
+// exponentially, and once the configured threshold is reached the username or IP is locked out completely.
+type Service interface {
+	// Check returns a LockedError if a login for the username from the IP is currently not allowed. Otherwise the
+	// username is reserved until done is called, once the outcome of the attempt is recorded, and concurrent attempts
+	// for it are rejected, as they would not be subject to the back-off.
+	Check(ctx context.Context, username, ip string) (done func(), err error)
+	// RecordFailure counts a failed login for the username and the IP. An empty username only counts the failure of
+	// the IP, it is used for unknown usernames, which must not fill the table.
+	RecordFailure(ctx context.Context, username, ip string) error
+	RecordSuccess(ctx context.Context, username string) error
+	Unlock(ctx context.Context, username string) error
+}
+
+type service struct {
+	repo               Repository
+	maxFailuresPerUser int
+	maxFailuresPerIP   int
+	lockoutDuration    time.Duration
+	backoffBase        time.Duration
+	now                func() time.Time
+
+	mu        sync.Mutex
+	attempted map[string]bool // the user keys of the running attempts
+}
+
+func NewService(repo Repository, cfg config.Lockout) Service {
+	return &service{
+		repo:               repo,
+		maxFailuresPerUser: cfg.MaxFailuresPerUser,
+		maxFailuresPerIP:   cfg.MaxFailuresPerIP,
+		lockoutDuration:    time.Duration(cfg.LockoutMinutes) * time.Minute,
+		backoffBase:        time.Duration(cfg.BackoffSeconds) * time.Second,
+		now:                time.Now,
+		attempted:          make(map[string]bool),
+	}
+}
+
+func (s *service) Check(ctx context.Context, username, ip string) (func(), error) {
+
+	key := userKey(ctx, username)
+
+	s.mu.Lock()
+	if s.attempted[key] {
+		s.mu.Unlock()
+		return nil, &LockedError{RetryAfter: concurrentRetryAfter}
+	}
+	s.attempted[key] = true
+	s.mu.Unlock()
+
+	done := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.attempted, key)
+	}
+
+	if err := s.check(ctx, key, ipKeyPrefix+ip); err != nil {
+		done()
+		return nil, err
+	}
+
+	return done, nil
+}
+
+func (s *service) check(ctx context.Context, keys ...string) error {
+
+	for _, key := range keys {
+
+		attempt, err := s.repo.GetAttempt(ctx, key)
+		if errors.Is(err, app.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if retryAfter := s.retryAfter(attempt); retryAfter > 0 {
+			return &LockedError{RetryAfter: retryAfter}
+		}
+	}
+
+	return nil
+}
+
+func (s *service) RecordFailure(ctx context.Context, username, ip string) error {
+
+	if username != "" {
+		if err := s.recordFailure(ctx, userKey(ctx, username), s.maxFailuresPerUser); err != nil {
+			return err
+		}
+	}
+
+	return s.recordFailure(ctx, ipKeyPrefix+ip, s.maxFailuresPerIP)
+}
+
+func (s *service) RecordSuccess(ctx context.Context, username string) error {
+	return s.repo.DeleteAttempt(ctx, userKey(ctx, username))
+}
+
+func (s *service) Unlock(ctx context.Context, username string) error {
+
+	if err := s.repo.DeleteAttempt(ctx, userKey(ctx, username)); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "login unlocked", "event", "login_unlock", "username", username)
+	return nil
+}
+
+// userKey returns the key of the username. Usernames are only unique within a tenant, the keys of the default
+// tenant are kept without tenant, so that they remain valid.
+func userKey(ctx context.Context, username string) string {
+
+	if id := tenant.ID(ctx); id != tenant.DefaultID {
+		return userKeyPrefix + strconv.FormatInt(id, 10) + ":" + username
+	}
+
+	return userKeyPrefix + username
+}
+
+func (s *service) recordFailure(ctx context.Context, key string, maxFailures int) error {
+
+	now := s.now()
+
+	attempt, err := s.repo.IncrementFailures(ctx, key, now, now.Add(-s.lockoutDuration))
+	if err != nil {
+		return err
+	}
+
+	if attempt.Failures >= maxFailures && !attempt.LockedUntil.Valid {
+		lockedUntil := now.Add(s.lockoutDuration)
+		if err = s.repo.LockAttempt(ctx, key, lockedUntil); err != nil {
+			return err
+		}
+		slog.WarnContext(ctx, "login locked", "event", "login_lockout", "key", key,
+			"failures", attempt.Failures, "locked_until", lockedUntil)
+	}
+
+	return nil
+}
+
+// retryAfter returns how long a login has to wait, either because of a lockout or because of the back-off.
+func (s *service) retryAfter(attempt *Attempt) time.Duration {
+
+	now := s.now()
+
+	if attempt.LockedUntil.Valid {
+		return attempt.LockedUntil.Time.Sub(now)
+	}
+
+	return attempt.LastFailureAt.Add(s.backoff(attempt.Failures)).Sub(now)
+}
+
+// backoff doubles the delay with every failure, capped at the lockout duration.
+func (s *service) backoff(failures int) time.Duration {
+
+	delay := s.backoffBase
+	for i := 1; i < failures && delay < s.lockoutDuration; i++ {
+		delay *= 2
+	}
+
+	return min(delay, s.lockoutDuration)
+}
@@ -0,0 +1,208 @@
+//go:build integration
+
+package lockout
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestService(db *sqlx.DB) (*service, *testClock) {
+	clock := &testClock{now: time.Date(2025, time.October, 1, 12, 0, 0, 0, time.UTC)}
+	return &service{
+		repo:               NewRepo(db),
+		maxFailuresPerUser: 3,
+		maxFailuresPerIP:   5,
+		lockoutDuration:    15 * time.Minute,
+		backoffBase:        time.Second,
+		now:                func() time.Time { return clock.now },
+		attempted:          make(map[string]bool),
+	}, clock
+}
+
+// check checks the login and ends the attempt at once.
+func check(ctx context.Context, s *service, username, ip string) error {
+
+	done, err := s.Check(ctx, username, ip)
+	if err != nil {
+		return err
+	}
+	done()
+
+	return nil
+}
+
+func TestBackoffAndLockout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s, clock := newTestService(db)
+
+		require.NoError(t, check(ctx, s, "admin", "10.0.0.1"))
+
+		require.NoError(t, s.RecordFailure(ctx, "admin", "10.0.0.1"))
+		require.ErrorIs(t, check(ctx, s, "admin", "10.0.0.1"), app.ErrLocked)
+
+		clock.advance(time.Second)
+		require.NoError(t, check(ctx, s, "admin", "10.0.0.1"))
+
+		require.NoError(t, s.RecordFailure(ctx, "admin", "10.0.0.1"))
+		clock.advance(time.Second)
+		require.ErrorIs(t, check(ctx, s, "admin", "10.0.0.1"), app.ErrLocked, "back-off doubles")
+		clock.advance(time.Second)
+		require.NoError(t, check(ctx, s, "admin", "10.0.0.1"))
+
+		require.NoError(t, s.RecordFailure(ctx, "admin", "10.0.0.1"))
+
+		err := check(ctx, s, "admin", "10.0.0.2")
+		var lockedErr *LockedError
+		require.ErrorAs(t, err, &lockedErr, "username is locked from every IP")
+		assert.Equal(t, 15*time.Minute, lockedErr.RetryAfter)
+
+		clock.advance(15 * time.Minute)
+		require.NoError(t, check(ctx, s, "admin", "10.0.0.2"))
+
+		require.NoError(t, s.RecordFailure(ctx, "admin", "10.0.0.1"))
+		attempt, err := s.repo.GetAttempt(ctx, "user:admin")
+		require.NoError(t, err)
+		assert.Equal(t, 1, attempt.Failures, "expired lockout starts over")
+		assert.False(t, attempt.LockedUntil.Valid)
+	})
+}
+
+func TestOldFailures_AreNotCounted(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s, clock := newTestService(db)
+
+		require.NoError(t, s.RecordFailure(ctx, "admin", "10.0.0.1"))
+		require.NoError(t, s.RecordFailure(ctx, "admin", "10.0.0.1"))
+		clock.advance(16 * time.Minute)
+		require.NoError(t, s.RecordFailure(ctx, "admin", "10.0.0.1"))
+
+		attempt, err := s.repo.GetAttempt(ctx, "user:admin")
+		require.NoError(t, err)
+		assert.Equal(t, 1, attempt.Failures)
+		assert.False(t, attempt.LockedUntil.Valid)
+	})
+}
+
+func TestLockoutPerIP(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s, clock := newTestService(db)
+
+		for _, username := range []string{"a", "b", "c", "d", "e"} {
+			require.NoError(t, s.RecordFailure(ctx, username, "10.0.0.1"))
+			clock.advance(time.Minute)
+		}
+
+		require.ErrorIs(t, check(ctx, s, "f", "10.0.0.1"), app.ErrLocked)
+		require.NoError(t, check(ctx, s, "f", "10.0.0.2"))
+	})
+}
+
+func TestRecordFailure_UnknownUsername_OnlyCountsTheIP(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s, _ := newTestService(db)
+
+		require.NoError(t, s.RecordFailure(ctx, "", "10.0.0.1"))
+
+		var keys []string
+		require.NoError(t, db.Select(&keys, "SELECT attempt_key FROM login_attempts"))
+		assert.Equal(t, []string{"ip:10.0.0.1"}, keys)
+	})
+}
+
+func TestRecordFailure_Concurrent_CountsEveryFailure(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s, _ := newTestService(db)
+		s.maxFailuresPerUser = 100
+		s.maxFailuresPerIP = 100
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Go(func() {
+				assert.NoError(t, s.RecordFailure(ctx, "admin", "10.0.0.1"))
+			})
+		}
+		wg.Wait()
+
+		attempt, err := s.repo.GetAttempt(ctx, "user:admin")
+		require.NoError(t, err)
+		assert.Equal(t, 10, attempt.Failures)
+	})
+}
+
+func TestCheck_ConcurrentAttempt_IsRejected(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s, _ := newTestService(db)
+
+		done, err := s.Check(ctx, "admin", "10.0.0.1")
+		require.NoError(t, err)
+
+		_, err = s.Check(ctx, "admin", "10.0.0.2")
+		var lockedErr *LockedError
+		require.ErrorAs(t, err, &lockedErr, "the password of the running attempt is not verified yet")
+		assert.Equal(t, concurrentRetryAfter, lockedErr.RetryAfter)
+
+		require.NoError(t, check(ctx, s, "bob", "10.0.0.1"), "other usernames are not affected")
+
+		done()
+		require.NoError(t, check(ctx, s, "admin", "10.0.0.2"))
+	})
+}
+
+func TestUnlock(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s, _ := newTestService(db)
+
+		for range 3 {
+			require.NoError(t, s.RecordFailure(ctx, "admin", "10.0.0.1"))
+		}
+		require.ErrorIs(t, check(ctx, s, "admin", "10.0.0.2"), app.ErrLocked)
+
+		require.NoError(t, s.Unlock(ctx, "admin"))
+		require.NoError(t, check(ctx, s, "admin", "10.0.0.2"))
+	})
+}
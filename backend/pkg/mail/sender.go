@@ -0,0 +1,204 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/config"
+
+	"github.com/google/uuid"
+)
+
+const (
+	SenderSMTP = "smtp"
+	SenderFile = "file"
+	SenderLog  = "log"
+)
+
+// tokenPattern matches the token of the links in the password mails.
+var tokenPattern = regexp.MustCompile(`(token=)[^\s&]+`)
+
+const (
+	mailDirPermissions  = 0o700
+	mailFilePermissions = 0o600
+)
+
+// Message is a plain text mail.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers mails.
+type Sender interface {
+	Send(ctx context.Context, message *Message) error
+}
+
+// NewSender creates the configured sender: smtp, file (writes every mail to the file dir) or log (the default, only
+// logs the mails, which is useful for local testing).
+func NewSender(cfg config.Mail) (Sender, error) {
+
+	switch cfg.Sender {
+	case SenderSMTP:
+		return &smtpSender{
+			addr:     net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)),
+			host:     cfg.SMTPHost,
+			username: cfg.SMTPUsername,
+			password: cfg.SMTPPassword,
+			from:     cfg.From,
+		}, nil
+	case SenderFile:
+		return &fileSender{dir: cfg.FileDir, from: cfg.From}, nil
+	case SenderLog:
+		return &logSender{}, nil
+	default:
+		return nil, fmt.Errorf("unknown mail sender: %s", cfg.Sender)
+	}
+}
+
+// smtpSender sends mails with STARTTLS whenever the server supports it, like smtp.SendMail, but aborts when the
+// context ends.
+type smtpSender struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+}
+
+func (s *smtpSender) Send(ctx context.Context, message *Message) error {
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	// closing the connection interrupts a pending read or write of the client
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err = conn.SetDeadline(deadline); err != nil {
+			_ = conn.Close()
+			return err
+		}
+	}
+
+	err = s.send(conn, message)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return errors.Join(ctxErr, err)
+	}
+
+	return err
+}
+
+func (s *smtpSender) send(conn net.Conn, message *Message) error {
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err = client.StartTLS(&tls.Config{ServerName: s.host, MinVersion: tls.VersionTLS12}); err != nil {
+			return err
+		}
+	}
+
+	if s.username != "" {
+		if err = client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return err
+		}
+	}
+
+	if err = client.Mail(s.from); err != nil {
+		return err
+	}
+
+	if err = client.Rcpt(message.To); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write(encode(s.from, message)); err != nil {
+		return err
+	}
+
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+type fileSender struct {
+	dir  string
+	from string
+}
+
+func (s *fileSender) Send(ctx context.Context, message *Message) error {
+
+	if err := os.MkdirAll(s.dir, mailDirPermissions); err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405"), uuid.NewString()))
+
+	if err := os.WriteFile(path, encode(s.from, message), mailFilePermissions); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "mail written to file", "to", message.To, "subject", message.Subject, "path", path)
+	return nil
+}
+
+// logSender logs the mails with the tokens of their links masked, as the logs must not grant access to accounts.
+type logSender struct{}
+
+func (s *logSender) Send(ctx context.Context, message *Message) error {
+	slog.InfoContext(ctx, "mail not sent, logging it instead", "to", message.To, "subject", message.Subject,
+		"body", maskTokens(message.Body))
+	return nil
+}
+
+// maskTokens replaces the values of the token parameters in the text.
+func maskTokens(text string) string {
+	return tokenPattern.ReplaceAllString(text, "${1}********")
+}
+
+// encode creates an RFC 5322 message. Header values are stripped of line breaks to prevent header injection.
+func encode(from string, message *Message) []byte {
+
+	header := strings.NewReplacer("\r", "", "\n", "")
+
+	var b strings.Builder
+	b.WriteString("From: " + header.Replace(from) + "\r\n")
+	b.WriteString("To: " + header.Replace(message.To) + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", header.Replace(message.Subject)) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+
+	return []byte(b.String())
+}
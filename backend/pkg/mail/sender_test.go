@@ -0,0 +1,48 @@
+package mail
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaskTokens(t *testing.T) {
+	body := "Set your password: https://checkin.example.com/set-password?token=abc-123_XYZ\n\nThe link expires soon."
+
+	masked := maskTokens(body)
+
+	assert.NotContains(t, masked, "abc-123_XYZ")
+	assert.Equal(t, "Set your password: https://checkin.example.com/set-password?token=********\n\n"+
+		"The link expires soon.", masked)
+	assert.Equal(t, "?a=1&token=********&b=2", maskTokens("?a=1&token=secret&b=2"))
+}
+
+func TestSmtpSender_Send_AbortsWhenTheContextEnds(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	// the server accepts the connection but never greets the client
+	go func() {
+		conn, acceptErr := listener.Accept()
+		if acceptErr == nil {
+			defer conn.Close()
+			_, _ = conn.Read(make([]byte, 1))
+		}
+	}()
+
+	sender := &smtpSender{addr: listener.Addr().String(), host: "127.0.0.1", from: "checkin@example.com"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = sender.Send(ctx, &Message{To: "alice@example.com", Subject: "Hello", Body: "Hello"})
+
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes the names of all metrics of the application.
+const Namespace = "checkin_system"
+
+// unmatched is the operation of requests which did not match any route, so that unknown paths don't create series.
+const unmatched = "unmatched"
+
+var requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: Namespace,
+	Name:      "http_request_duration_seconds",
+	Help:      "Duration of the http requests by operation of the api (or route) and status code.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"operation", "method", "code"})
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RegisterDB exports the statistics of the connection pool of the database.
+func RegisterDB(db *sql.DB) {
+
+	err := prometheus.Register(collectors.NewDBStatsCollector(db, Namespace))
+
+	var alreadyRegistered prometheus.AlreadyRegisteredError
+	if err != nil && !errors.As(err, &alreadyRegistered) {
+		panic(err)
+	}
+}
+
+// Middleware records the duration of every request by its operation.
+func Middleware(operation func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			requestDuration.WithLabelValues(operation(r), r.Method, strconv.Itoa(status)).
+				Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+// OperationName returns the name of the operation of routed requests: the operationId of the spec for requests of
+// the api, the route for others. The paths of the spec are the route patterns of the router.
+func OperationName(swagger *openapi3.T) func(r *http.Request) string {
+
+	operations := map[string]string{}
+
+	for path, item := range swagger.Paths.Map() {
+		for method, operation := range item.Operations() {
+			if operation.OperationID != "" {
+				operations[method+" "+path] = operation.OperationID
+			}
+		}
+	}
+
+	return func(r *http.Request) string {
+
+		rctx := chi.RouteContext(r.Context())
+		if rctx == nil || rctx.RoutePattern() == "" {
+			return unmatched
+		}
+
+		if operationID, ok := operations[r.Method+" "+rctx.RoutePattern()]; ok {
+			return operationID
+		}
+
+		return rctx.RoutePattern()
+	}
+}
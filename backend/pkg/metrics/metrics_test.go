@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+
+	paths := openapi3.NewPaths()
+	paths.Set("/api/v1/users/{userId}", &openapi3.PathItem{Get: &openapi3.Operation{OperationID: "getUserById"}})
+
+	router := chi.NewRouter()
+	router.Use(Middleware(OperationName(&openapi3.T{Paths: paths})))
+	router.Get("/api/v1/users/{userId}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	router.Get("/healthz", func(_ http.ResponseWriter, _ *http.Request) {})
+
+	for _, path := range []string{"/api/v1/users/1", "/api/v1/users/2", "/healthz", "/unknown/path"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	tests := []struct {
+		operation string
+		code      string
+		count     uint64
+	}{
+		{operation: "getUserById", code: "404", count: 2},
+		{operation: "/healthz", code: "200", count: 1},
+		{operation: unmatched, code: "404", count: 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.operation, func(t *testing.T) {
+			histogram := &dto.Metric{}
+			observer := requestDuration.WithLabelValues(tc.operation, http.MethodGet, tc.code)
+			require.NoError(t, observer.(prometheus.Metric).Write(histogram))
+			assert.Equal(t, tc.count, histogram.GetHistogram().GetSampleCount())
+		})
+	}
+}
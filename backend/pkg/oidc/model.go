@@ -0,0 +1,19 @@
+package oidc
+
+import "time"
+
+// Identity links the subject of an identity provider to a user.
+type Identity struct {
+	Issuer    string    `db:"issuer"`
+	Subject   string    `db:"subject"`
+	UserID    int64     `db:"user_id"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// LoginState is stored between redirecting to the identity provider and receiving its authorization code.
+type LoginState struct {
+	State        string    `db:"state"`
+	Nonce        string    `db:"nonce"`
+	CodeVerifier string    `db:"code_verifier"`
+	CreatedAt    time.Time `db:"created_at"`
+}
@@ -0,0 +1,100 @@
+package oidc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/database"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository interface {
+	GetIdentity(ctx context.Context, issuer, subject string) (*Identity, error)
+	SaveIdentity(ctx context.Context, identity *Identity) error
+	SaveLoginState(ctx context.Context, state *LoginState) error
+	// TakeLoginState deletes and returns a login state, so that every state can only be used once.
+	TakeLoginState(ctx context.Context, state string) (*LoginState, error)
+	DeleteLoginStatesBefore(ctx context.Context, createdAt time.Time) error
+}
+
+type repository struct {
+	db *database.DB
+}
+
+func NewRepo(db *sqlx.DB) Repository {
+	return &repository{database.NewDB(db)}
+}
+
+func (r *repository) GetIdentity(ctx context.Context, issuer, subject string) (*Identity, error) {
+
+	identity := Identity{}
+
+	if err := r.db.GetContext(ctx, &identity,
+		"SELECT * FROM user_identities WHERE issuer = $1 AND subject = $2", issuer, subject); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, app.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+func (r *repository) SaveIdentity(ctx context.Context, identity *Identity) error {
+
+	insertStatement, err := r.db.PrepareNamedContext(ctx, `INSERT INTO user_identities
+			(issuer, subject, user_id, created_at) VALUES
+			(:issuer, :subject, :user_id, :created_at)`)
+	if err != nil {
+		return err
+	}
+	defer insertStatement.Close()
+
+	_, err = insertStatement.ExecContext(ctx, identity)
+	return err
+}
+
+func (r *repository) SaveLoginState(ctx context.Context, state *LoginState) error {
+
+	insertStatement, err := r.db.PrepareNamedContext(ctx, `INSERT INTO oidc_login_states
+			(state, nonce, code_verifier, created_at) VALUES
+			(:state, :nonce, :code_verifier, :created_at)`)
+	if err != nil {
+		return err
+	}
+	defer insertStatement.Close()
+
+	_, err = insertStatement.ExecContext(ctx, state)
+	return err
+}
+
+func (r *repository) TakeLoginState(ctx context.Context, state string) (*LoginState, error) {
+
+	loginState := LoginState{}
+
+	if err := r.db.GetContext(ctx, &loginState,
+		"DELETE FROM oidc_login_states WHERE state = $1 RETURNING *", state); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, app.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &loginState, nil
+}
+
+func (r *repository) DeleteLoginStatesBefore(ctx context.Context, createdAt time.Time) error {
+
+	deleteStatement, err := r.db.PreparexContext(ctx, `DELETE FROM oidc_login_states WHERE created_at < $1`)
+	if err != nil {
+		return err
+	}
+	defer deleteStatement.Close()
+
+	_, err = deleteStatement.ExecContext(ctx, createdAt)
+	return err
+}
@@ -0,0 +1,343 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/d-rk/checkin-system/pkg/user"
+	"golang.org/x/oauth2"
+	"gopkg.in/guregu/null.v4"
+)
+
+const loginStateExpiry = 10 * time.Minute
+
+// Service signs in users with the authorization code flow and PKCE of an OpenID Connect identity provider.
+type Service interface {
+	// Enabled returns true if an identity provider is configured.
+	Enabled() bool
+	// AuthorizationURL starts a login and returns the url of the identity provider to redirect the browser to.
+	AuthorizationURL(ctx context.Context) (string, error)
+	// Login completes a login with the code and state passed to the redirect url and returns the signed-in user.
+	Login(ctx context.Context, code, state string) (*user.User, error)
+}
+
+// providerConfig describes the identity provider and how its claims are mapped to users.
+type providerConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	UsernameClaim string
+	RoleClaim     string
+	// RoleMapping maps values of the role claim to user roles. If it is not empty, only identities
+	// with a mapped value are allowed to sign in, and the role of the user is updated on every login.
+	RoleMapping [][2]string
+	// AutoProvision creates a user for an identity which matches no existing user.
+	AutoProvision bool
+}
+
+type service struct {
+	repo        Repository
+	userService user.Service
+	config      providerConfig
+	now         func() time.Time
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+func NewService(repo Repository, userService user.Service, cfg config.OIDC) Service {
+	return newService(repo, userService, newProviderConfig(cfg))
+}
+
+func newService(repo Repository, userService user.Service, config providerConfig) *service {
+	return &service{
+		repo:        repo,
+		userService: userService,
+		config:      config,
+		now:         time.Now,
+	}
+}
+
+// newProviderConfig splits the entries of the role mapping, e.g. board:ADMIN, which were validated on start-up.
+func newProviderConfig(cfg config.OIDC) providerConfig {
+
+	provider := providerConfig{
+		IssuerURL:     cfg.IssuerURL,
+		ClientID:      cfg.ClientID,
+		ClientSecret:  cfg.ClientSecret,
+		RedirectURL:   cfg.RedirectURL,
+		Scopes:        cfg.Scopes,
+		UsernameClaim: cfg.UsernameClaim,
+		RoleClaim:     cfg.RoleClaim,
+		AutoProvision: cfg.AutoProvision,
+	}
+
+	for _, entry := range cfg.RoleMapping {
+		value, role, _ := strings.Cut(entry, ":")
+		provider.RoleMapping = append(provider.RoleMapping, [2]string{value, role})
+	}
+
+	return provider
+}
+
+func (s *service) Enabled() bool {
+	return s.config.IssuerURL != ""
+}
+
+func (s *service) AuthorizationURL(ctx context.Context) (string, error) {
+
+	oauth2Config, _, err := s.oauth2Config(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	now := s.now()
+
+	if err = s.repo.DeleteLoginStatesBefore(ctx, now.Add(-loginStateExpiry)); err != nil {
+		slog.WarnContext(ctx, "failed to delete expired oidc login states", "error", err)
+	}
+
+	state := &LoginState{
+		State:        rand.Text(),
+		Nonce:        rand.Text(),
+		CodeVerifier: oauth2.GenerateVerifier(),
+		CreatedAt:    now,
+	}
+
+	if err = s.repo.SaveLoginState(ctx, state); err != nil {
+		return "", err
+	}
+
+	return oauth2Config.AuthCodeURL(state.State, oidc.Nonce(state.Nonce),
+		oauth2.S256ChallengeOption(state.CodeVerifier)), nil
+}
+
+func (s *service) Login(ctx context.Context, code, state string) (*user.User, error) {
+
+	oauth2Config, provider, err := s.oauth2Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	loginState, err := s.repo.TakeLoginState(ctx, state)
+	if errors.Is(err, app.ErrNotFound) {
+		return nil, fmt.Errorf("unknown oidc login state: %w", app.ErrInvalid)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if s.now().Sub(loginState.CreatedAt) > loginStateExpiry {
+		return nil, fmt.Errorf("oidc login state expired: %w", app.ErrInvalid)
+	}
+
+	token, err := oauth2Config.Exchange(ctx, code, oauth2.VerifierOption(loginState.CodeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("oidc code exchange failed: %w: %w", err, app.ErrInvalid)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("oidc token response without id_token: %w", app.ErrInvalid)
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: s.config.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w: %w", err, app.ErrInvalid)
+	}
+
+	if idToken.Nonce != loginState.Nonce {
+		return nil, fmt.Errorf("id_token nonce mismatch: %w", app.ErrInvalid)
+	}
+
+	claims := map[string]any{}
+	if err = idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	role, err := s.mapRole(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := s.identityUser(ctx, idToken.Issuer, idToken.Subject, claims, role)
+	if err != nil {
+		return nil, err
+	}
+
+	if role != "" && u.Role != role {
+		slog.InfoContext(ctx, "updating role from oidc claims", "user", u.Name, "old_role", u.Role, "role", role)
+		u.Role = role
+		u.UpdatedAt = null.TimeFrom(s.now())
+		return s.userService.UpdateUser(ctx, u)
+	}
+
+	return u, nil
+}
+
+// identityUser returns the user linked to the identity. An unlinked identity is only linked to an existing user with
+// its verified email address, and never to an admin, as an account of the identity provider must not take over an
+// account with more privileges. Otherwise a user with the name from the username claim is created if auto
+// provisioning is enabled.
+func (s *service) identityUser(ctx context.Context, issuer, subject string, claims map[string]any,
+	role string) (*user.User, error) {
+
+	identity, err := s.repo.GetIdentity(ctx, issuer, subject)
+	if err == nil {
+		return s.userService.GetUserByID(ctx, identity.UserID)
+	} else if !errors.Is(err, app.ErrNotFound) {
+		return nil, err
+	}
+
+	u, err := s.emailUser(ctx, claims)
+	if errors.Is(err, app.ErrNotFound) && s.config.AutoProvision {
+		u, err = s.provisionUser(ctx, subject, claims, role)
+	} else if errors.Is(err, app.ErrNotFound) {
+		return nil, fmt.Errorf("no user for oidc identity %s: %w", subject, app.ErrInvalid)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.repo.SaveIdentity(ctx, &Identity{
+		Issuer:    issuer,
+		Subject:   subject,
+		UserID:    u.ID,
+		CreatedAt: s.now(),
+	}); err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "linked oidc identity", "user", u.Name, "subject", subject)
+
+	return u, nil
+}
+
+// emailUser returns the only user with the verified email address of the claims, it returns ErrNotFound if there is
+// none.
+func (s *service) emailUser(ctx context.Context, claims map[string]any) (*user.User, error) {
+
+	email := verifiedEmail(claims)
+	if !email.Valid {
+		return nil, app.ErrNotFound
+	}
+
+	users, err := s.userService.ListUsersByEmail(ctx, email.String)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(users) == 0:
+		return nil, app.ErrNotFound
+	case len(users) > 1:
+		return nil, fmt.Errorf("several users with the email of the oidc identity: %w", app.ErrInvalid)
+	case users[0].Role == user.RoleAdmin || users[0].Role == user.RoleSuperAdmin:
+		return nil, fmt.Errorf("oidc identities are not linked to admins: %w", app.ErrInvalid)
+	}
+
+	return &users[0], nil
+}
+
+func (s *service) provisionUser(ctx context.Context, subject string, claims map[string]any,
+	role string) (*user.User, error) {
+
+	username, _ := claims[s.config.UsernameClaim].(string)
+	if username == "" {
+		return nil, fmt.Errorf("id_token without %s claim: %w", s.config.UsernameClaim, app.ErrInvalid)
+	}
+
+	if role == "" {
+		role = user.RoleUser
+	}
+
+	u, err := s.userService.CreateUser(ctx, &user.User{
+		Name:      username,
+		Role:      role,
+		Email:     verifiedEmail(claims),
+		CreatedAt: s.now(),
+	})
+	if errors.Is(err, app.ErrConflict) {
+		// the name belongs to a user who did not sign in with the identity provider before
+		return nil, fmt.Errorf("name %s of the oidc identity is taken: %w", username, app.ErrInvalid)
+	} else if err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "provisioned user from oidc", "user", username, "subject", subject)
+
+	return u, nil
+}
+
+func verifiedEmail(claims map[string]any) null.String {
+	email, _ := claims["email"].(string)
+	verified, _ := claims["email_verified"].(bool)
+	return null.NewString(email, email != "" && verified)
+}
+
+// mapRole returns the role of the first mapping matching a value of the role claim.
+func (s *service) mapRole(claims map[string]any) (string, error) {
+
+	if len(s.config.RoleMapping) == 0 {
+		return "", nil
+	}
+
+	var values []string
+	switch claim := claims[s.config.RoleClaim].(type) {
+	case string:
+		values = []string{claim}
+	case []any:
+		for _, v := range claim {
+			if value, ok := v.(string); ok {
+				values = append(values, value)
+			}
+		}
+	}
+
+	for _, mapping := range s.config.RoleMapping {
+		if slices.Contains(values, mapping[0]) {
+			return mapping[1], nil
+		}
+	}
+
+	return "", fmt.Errorf("oidc identity has no mapped role: %w", app.ErrInvalid)
+}
+
+// oauth2Config discovers the identity provider on first use, so that the server starts while it is unreachable.
+func (s *service) oauth2Config(ctx context.Context) (*oauth2.Config, *oidc.Provider, error) {
+
+	if !s.Enabled() {
+		return nil, nil, fmt.Errorf("oidc login not configured: %w", app.ErrNotFound)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.provider == nil {
+		provider, err := oidc.NewProvider(ctx, s.config.IssuerURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("oidc discovery failed: %w", err)
+		}
+		s.provider = provider
+	}
+
+	return &oauth2.Config{
+		ClientID:     s.config.ClientID,
+		ClientSecret: s.config.ClientSecret,
+		RedirectURL:  s.config.RedirectURL,
+		Endpoint:     s.provider.Endpoint(),
+		Scopes:       s.config.Scopes,
+	}, s.provider, nil
+}
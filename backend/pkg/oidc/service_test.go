@@ -0,0 +1,325 @@
+//go:build integration
+
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/group"
+	"github.com/d-rk/checkin-system/pkg/internal/testutil"
+	"github.com/d-rk/checkin-system/pkg/user"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+const testClientID = "checkin-system"
+
+// mockIdP is a minimal OpenID Connect provider issuing an id_token for every registered authorization code.
+type mockIdP struct {
+	*httptest.Server
+	key   *rsa.PrivateKey
+	codes map[string]authorization
+}
+
+type authorization struct {
+	codeChallenge string
+	nonce         string
+	claims        jwt.MapClaims
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	idp := &mockIdP{key: key, codes: make(map[string]authorization)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, map[string]any{
+			"issuer":                                idp.URL,
+			"authorization_endpoint":                idp.URL + "/authorize",
+			"token_endpoint":                        idp.URL + "/token",
+			"jwks_uri":                              idp.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", idp.token)
+
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+
+	return idp
+}
+
+// authorize simulates the user signing in at the identity provider and returns the code of the redirect.
+func (idp *mockIdP) authorize(t *testing.T, authorizationURL string, claims jwt.MapClaims) (string, string) {
+
+	u, err := url.Parse(authorizationURL)
+	require.NoError(t, err)
+
+	query := u.Query()
+	require.Equal(t, "S256", query.Get("code_challenge_method"))
+
+	code := rand.Text()
+	idp.codes[code] = authorization{
+		codeChallenge: query.Get("code_challenge"),
+		nonce:         query.Get("nonce"),
+		claims:        claims,
+	}
+
+	return code, query.Get("state")
+}
+
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+
+	auth, ok := idp.codes[r.FormValue("code")]
+	delete(idp.codes, r.FormValue("code"))
+
+	verifierDigest := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(verifierDigest[:]) != auth.codeChallenge {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":   idp.URL,
+		"aud":   testClientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"nonce": auth.nonce,
+	}
+	for k, v := range auth.claims {
+		claims[k] = v
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test"
+
+	idToken, err := token.SignedString(idp.key)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]any{"access_token": "access", "token_type": "Bearer", "id_token": idToken})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// newTestService returns a service with the users admin, whose email is board@example.org, and alice.
+func newTestService(t *testing.T, db *sqlx.DB, idp *mockIdP, autoProvision bool) (*service, user.Service) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	userService := testutil.UserService(t, db, config.Users{})
+
+	admin, err := userService.GetUserByName(ctx, "admin")
+	require.NoError(t, err)
+	admin.Email = null.StringFrom("board@example.org")
+	_, err = userService.UpdateUser(ctx, admin)
+	require.NoError(t, err)
+
+	_, err = userService.CreateUser(ctx, &user.User{Name: "alice", Role: user.RoleUser,
+		Email: null.StringFrom("alice@example.org")})
+	require.NoError(t, err)
+
+	return newService(NewRepo(db), userService, providerConfig{
+		IssuerURL:     idp.URL,
+		ClientID:      testClientID,
+		RedirectURL:   "http://localhost:5173/oidc/callback",
+		Scopes:        []string{"openid", "profile"},
+		UsernameClaim: "preferred_username",
+		RoleClaim:     "groups",
+		RoleMapping:   [][2]string{{"board", user.RoleAdmin}, {"members", user.RoleUser}},
+		AutoProvision: autoProvision,
+	}), userService
+}
+
+func TestLoginWithProvisioning(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	idp := newMockIdP(t)
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s, userService := newTestService(t, db, idp, true)
+
+		claims := jwt.MapClaims{"sub": "4711", "preferred_username": "jane", "groups": []string{"members", "board"}}
+
+		authorizationURL, err := s.AuthorizationURL(ctx)
+		require.NoError(t, err)
+
+		code, state := idp.authorize(t, authorizationURL, claims)
+
+		u, err := s.Login(ctx, code, state)
+		require.NoError(t, err)
+		assert.Equal(t, "jane", u.Name)
+		assert.Equal(t, user.RoleAdmin, u.Role, "first matching role mapping wins")
+
+		users, err := userService.ListUsers(ctx, group.Scope{})
+		require.NoError(t, err)
+		assert.Len(t, users, 3)
+
+		_, err = s.Login(ctx, code, state)
+		require.ErrorIs(t, err, app.ErrInvalid, "state can only be used once")
+
+		authorizationURL, err = s.AuthorizationURL(ctx)
+		require.NoError(t, err)
+
+		claims["preferred_username"] = "renamed"
+		claims["groups"] = "members"
+		code, state = idp.authorize(t, authorizationURL, claims)
+
+		u, err = s.Login(ctx, code, state)
+		require.NoError(t, err)
+		assert.Equal(t, "jane", u.Name, "subject is linked to the user")
+		assert.Equal(t, user.RoleUser, u.Role, "role is updated on login")
+
+		u, err = userService.GetUserByID(ctx, u.ID)
+		require.NoError(t, err)
+		assert.Equal(t, user.RoleUser, u.Role)
+	})
+}
+
+func TestLoginWithProvisioning_NameOfAnotherUser_IsRejected(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	idp := newMockIdP(t)
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s, userService := newTestService(t, db, idp, true)
+
+		authorizationURL, err := s.AuthorizationURL(ctx)
+		require.NoError(t, err)
+
+		code, state := idp.authorize(t, authorizationURL,
+			jwt.MapClaims{"sub": "4711", "preferred_username": "admin", "groups": []string{"members"}})
+
+		_, err = s.Login(ctx, code, state)
+		require.ErrorIs(t, err, app.ErrInvalid)
+
+		users, err := userService.ListUsers(ctx, group.Scope{})
+		require.NoError(t, err)
+		assert.Len(t, users, 2)
+
+		admin, err := userService.GetUserByName(ctx, "admin")
+		require.NoError(t, err)
+		assert.Equal(t, user.RoleAdmin, admin.Role)
+	})
+}
+
+func TestLoginWithoutProvisioning(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		wantErr bool
+	}{
+		{
+			name: "verified email of a user",
+			claims: jwt.MapClaims{"sub": "1", "preferred_username": "a.smith", "groups": []string{"members"},
+				"email": "Alice@example.org", "email_verified": true},
+		},
+		{
+			name: "unverified email",
+			claims: jwt.MapClaims{"sub": "2", "preferred_username": "alice", "groups": []string{"members"},
+				"email": "alice@example.org", "email_verified": false},
+			wantErr: true,
+		},
+		{
+			name:    "username of a user",
+			claims:  jwt.MapClaims{"sub": "3", "preferred_username": "alice", "groups": []string{"members"}},
+			wantErr: true,
+		},
+		{
+			name: "verified email of an admin",
+			claims: jwt.MapClaims{"sub": "4", "preferred_username": "admin", "groups": []string{"board"},
+				"email": "board@example.org", "email_verified": true},
+			wantErr: true,
+		},
+		{
+			name:    "unknown user",
+			claims:  jwt.MapClaims{"sub": "5", "preferred_username": "jane", "groups": []string{"board"}},
+			wantErr: true,
+		},
+		{
+			name: "unmapped role",
+			claims: jwt.MapClaims{"sub": "6", "preferred_username": "alice", "groups": []string{"guests"},
+				"email": "alice@example.org", "email_verified": true},
+			wantErr: true,
+		},
+	}
+
+	idp := newMockIdP(t)
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s, userService := newTestService(t, db, idp, false)
+
+		alice, err := userService.GetUserByName(ctx, "alice")
+		require.NoError(t, err)
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+
+				authorizationURL, err := s.AuthorizationURL(ctx)
+				require.NoError(t, err)
+
+				code, state := idp.authorize(t, authorizationURL, tt.claims)
+
+				u, err := s.Login(ctx, code, state)
+				if tt.wantErr {
+					require.ErrorIs(t, err, app.ErrInvalid)
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, alice.ID, u.ID)
+			})
+		}
+
+		authorizationURL, err := s.AuthorizationURL(ctx)
+		require.NoError(t, err)
+
+		code, state := idp.authorize(t, authorizationURL, jwt.MapClaims{"sub": "1", "groups": []string{"members"}})
+
+		u, err := s.Login(ctx, code, state)
+		require.NoError(t, err)
+		assert.Equal(t, alice.ID, u.ID, "identity stays linked without the email claim")
+	})
+}
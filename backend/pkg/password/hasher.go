@@ -0,0 +1,200 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/d-rk/checkin-system/pkg/config"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+const (
+	argon2Memory      = 64 * 1024
+	argon2Iterations  = 3
+	argon2Parallelism = 2
+	argon2SaltLength  = 16
+	argon2KeyLength   = 32
+)
+
+var ErrUnknownDigest = errors.New("unknown password digest format")
+
+// Hasher creates and verifies password digests.
+type Hasher interface {
+	// Hash returns an encoded digest of the password, including all parameters needed to verify it.
+	Hash(password string) (string, error)
+	// Verify returns true if the password matches the digest.
+	Verify(digest, password string) (bool, error)
+	// NeedsRehash returns true if the digest was not created with the current algorithm and parameters.
+	NeedsRehash(digest string) bool
+}
+
+// NewHasher returns a hasher which creates digests with the configured hash algorithm and verifies digests of all
+// supported algorithms.
+func NewHasher(cfg config.Password) (Hasher, error) {
+
+	argon2id := &argon2idHasher{
+		memory:      argon2Memory,
+		iterations:  argon2Iterations,
+		parallelism: argon2Parallelism,
+	}
+	bcryptHasher := &bcryptHasher{cost: bcrypt.DefaultCost}
+
+	switch cfg.HashAlgorithm {
+	case AlgorithmArgon2id:
+		return &hasher{preferred: argon2id, argon2id: argon2id, bcrypt: bcryptHasher}, nil
+	case AlgorithmBcrypt:
+		return &hasher{preferred: bcryptHasher, argon2id: argon2id, bcrypt: bcryptHasher}, nil
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm: %s", cfg.HashAlgorithm)
+	}
+}
+
+// hasher delegates to the hasher matching the format of a digest.
+type hasher struct {
+	preferred Hasher
+	argon2id  *argon2idHasher
+	bcrypt    *bcryptHasher
+}
+
+func (h *hasher) Hash(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+func (h *hasher) Verify(digest, password string) (bool, error) {
+	delegate, err := h.forDigest(digest)
+	if err != nil {
+		return false, err
+	}
+	return delegate.Verify(digest, password)
+}
+
+func (h *hasher) NeedsRehash(digest string) bool {
+	delegate, err := h.forDigest(digest)
+	if err != nil {
+		return true
+	}
+	return delegate != h.preferred || delegate.NeedsRehash(digest)
+}
+
+func (h *hasher) forDigest(digest string) (Hasher, error) {
+	switch {
+	case strings.HasPrefix(digest, "$argon2id$"):
+		return h.argon2id, nil
+	case strings.HasPrefix(digest, "$2a$"), strings.HasPrefix(digest, "$2b$"), strings.HasPrefix(digest, "$2y$"):
+		return h.bcrypt, nil
+	default:
+		return nil, ErrUnknownDigest
+	}
+}
+
+type bcryptHasher struct {
+	cost int
+}
+
+func (h *bcryptHasher) Hash(password string) (string, error) {
+	digest, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(digest), nil
+}
+
+func (h *bcryptHasher) Verify(digest, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(digest), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (h *bcryptHasher) NeedsRehash(digest string) bool {
+	cost, err := bcrypt.Cost([]byte(digest))
+	return err != nil || cost != h.cost
+}
+
+// argon2idHasher encodes digests in the PHC string format: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>.
+type argon2idHasher struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+type argon2idDigest struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func (h *argon2idHasher) Hash(password string) (string, error) {
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.iterations, h.memory, h.parallelism, argon2KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.memory, h.iterations,
+		h.parallelism, base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *argon2idHasher) Verify(digest, password string) (bool, error) {
+
+	d, err := decodeArgon2id(digest)
+	if err != nil {
+		return false, err
+	}
+
+	key := argon2.IDKey([]byte(password), d.salt, d.iterations, d.memory, d.parallelism, uint32(len(d.key)))
+
+	return subtle.ConstantTimeCompare(key, d.key) == 1, nil
+}
+
+func (h *argon2idHasher) NeedsRehash(digest string) bool {
+	d, err := decodeArgon2id(digest)
+	return err != nil || d.memory != h.memory || d.iterations != h.iterations || d.parallelism != h.parallelism
+}
+
+func decodeArgon2id(digest string) (*argon2idDigest, error) {
+
+	parts := strings.Split(digest, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id { //nolint:mnd // "", algorithm, version, params, salt, key
+		return nil, ErrUnknownDigest
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return nil, fmt.Errorf("invalid argon2id version: %w", err)
+	}
+	if version != argon2.Version {
+		return nil, fmt.Errorf("unsupported argon2id version: %d", version)
+	}
+
+	d := &argon2idDigest{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &d.memory, &d.iterations, &d.parallelism); err != nil {
+		return nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+
+	var err error
+	if d.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	if d.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, fmt.Errorf("invalid argon2id key: %w", err)
+	}
+
+	return d, nil
+}
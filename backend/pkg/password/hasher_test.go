@@ -0,0 +1,95 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHasher(t *testing.T) {
+
+	for _, algorithm := range []string{AlgorithmArgon2id, AlgorithmBcrypt} {
+		t.Run(algorithm, func(t *testing.T) {
+			h, err := NewHasher(config.Password{HashAlgorithm: algorithm})
+			require.NoError(t, err)
+
+			digest, err := h.Hash("correct horse")
+			require.NoError(t, err)
+
+			ok, err := h.Verify(digest, "correct horse")
+			require.NoError(t, err)
+			assert.True(t, ok)
+
+			ok, err = h.Verify(digest, "wrong horse")
+			require.NoError(t, err)
+			assert.False(t, ok)
+
+			assert.False(t, h.NeedsRehash(digest))
+		})
+	}
+}
+
+func TestHasher_NeedsRehash(t *testing.T) {
+
+	h, err := NewHasher(config.Default().Password)
+	require.NoError(t, err)
+
+	bcryptDigest, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	ok, err := h.Verify(string(bcryptDigest), "secret")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, h.NeedsRehash(string(bcryptDigest)))
+
+	weakArgon2id := &argon2idHasher{memory: 1024, iterations: 1, parallelism: 1}
+	weakDigest, err := weakArgon2id.Hash("secret")
+	require.NoError(t, err)
+
+	ok, err = h.Verify(weakDigest, "secret")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, h.NeedsRehash(weakDigest))
+
+	_, err = h.Verify("plain", "plain")
+	require.ErrorIs(t, err, ErrUnknownDigest)
+}
+
+func TestPolicy(t *testing.T) {
+
+	breachedList := filepath.Join(t.TempDir(), "breached.txt")
+	require.NoError(t, os.WriteFile(breachedList, []byte(
+		"password123\n"+
+			"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493\n", // sha1 of "password"
+	), 0600))
+
+	policy, err := NewPolicy(config.Password{MinLength: 8, BreachedListFile: breachedList})
+	require.NoError(t, err)
+
+	tests := []struct {
+		password string
+		valid    bool
+	}{
+		{password: "short", valid: false},
+		{password: "password", valid: false},
+		{password: "password123", valid: false},
+		{password: "correct horse battery", valid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			err := policy.Validate(tt.password)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, app.ErrInvalid)
+			}
+		})
+	}
+}
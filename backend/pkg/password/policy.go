@@ -0,0 +1,102 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1" //nolint:gosec // sha1 is the format of published breached password lists
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/config"
+)
+
+const sha1HexLength = 40
+
+// Policy defines the requirements a new password has to fulfill.
+type Policy struct {
+	MinLength int
+
+	// breached contains the upper case hex encoded sha1 digests of known breached passwords.
+	breached map[string]struct{}
+}
+
+// NewPolicy creates a policy with the configured minimum length and breached password list.
+//
+// The breached password list contains one entry per line, either as plain text password or as
+// sha1 hex digest (optionally followed by ":<count>" as in the haveibeenpwned downloads).
+func NewPolicy(cfg config.Password) (*Policy, error) {
+
+	policy := &Policy{
+		MinLength: cfg.MinLength,
+		breached:  make(map[string]struct{}),
+	}
+
+	if cfg.BreachedListFile != "" {
+		if err := policy.loadBreachedList(cfg.BreachedListFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return policy, nil
+}
+
+// Validate returns an error wrapping app.ErrInvalid if the password violates the policy.
+func (p *Policy) Validate(password string) error {
+
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("password too short, must be at least %d characters: %w", p.MinLength, app.ErrInvalid)
+	}
+
+	if _, found := p.breached[sha1Hex(password)]; found {
+		return fmt.Errorf("password found in list of breached passwords: %w", app.ErrInvalid)
+	}
+
+	return nil
+}
+
+func (p *Policy) loadBreachedList(path string) error {
+
+	file, err := os.Open(path) //nolint:gosec // path is provided by the operator
+	if err != nil {
+		return fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		entry, _, _ := strings.Cut(line, ":")
+		if isSHA1Hex(entry) {
+			p.breached[strings.ToUpper(entry)] = struct{}{}
+		} else {
+			p.breached[sha1Hex(line)] = struct{}{}
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read breached password list: %w", err)
+	}
+
+	slog.Info("loaded breached password list", "path", path, "count", len(p.breached))
+	return nil
+}
+
+func sha1Hex(password string) string {
+	digest := sha1.Sum([]byte(password)) //nolint:gosec // sha1 is the format of published breached password lists
+	return strings.ToUpper(hex.EncodeToString(digest[:]))
+}
+
+func isSHA1Hex(s string) bool {
+	if len(s) != sha1HexLength {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
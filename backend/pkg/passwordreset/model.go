@@ -0,0 +1,24 @@
+package passwordreset
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+const (
+	PurposeReset      = "reset"
+	PurposeInvitation = "invitation"
+)
+
+// Token allows to set the password of a user once. Only the sha256 digest of the token is stored.
+type Token struct {
+	ID          int64     `db:"id"`
+	UserID      int64     `db:"user_id"`
+	TenantID    int64     `db:"tenant_id"` // tenant of the user, only set by UseToken
+	Purpose     string    `db:"purpose"`
+	TokenDigest string    `db:"token_digest"`
+	CreatedAt   time.Time `db:"created_at"`
+	ExpiresAt   time.Time `db:"expires_at"`
+	UsedAt      null.Time `db:"used_at"`
+}
@@ -0,0 +1,97 @@
+package passwordreset
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/database"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository interface {
+	SaveToken(ctx context.Context, token *Token) error
+	CountTokensSince(ctx context.Context, userID int64, purpose string, since time.Time) (int, error)
+	// UseToken marks an unused and unexpired token as used and returns it.
+	UseToken(ctx context.Context, tokenDigest string, usedAt time.Time) (*Token, error)
+	InvalidateTokens(ctx context.Context, userID int64, usedAt time.Time) error
+	DeleteTokensExpiredBefore(ctx context.Context, expiresAt time.Time) error
+}
+
+type repository struct {
+	db *database.DB
+}
+
+func NewRepo(db *sqlx.DB) Repository {
+	return &repository{database.NewDB(db)}
+}
+
+func (r *repository) SaveToken(ctx context.Context, token *Token) error {
+
+	insertStatement, err := r.db.PrepareNamedContext(ctx, `INSERT INTO password_tokens
+			(user_id, purpose, token_digest, created_at, expires_at) VALUES
+			(:user_id, :purpose, :token_digest, :created_at, :expires_at) RETURNING id`)
+	if err != nil {
+		return err
+	}
+	defer insertStatement.Close()
+
+	return insertStatement.GetContext(ctx, &token.ID, token)
+}
+
+func (r *repository) CountTokensSince(ctx context.Context, userID int64, purpose string,
+	since time.Time) (int, error) {
+
+	var count int
+
+	err := r.db.GetContext(ctx, &count,
+		"SELECT count(*) FROM password_tokens WHERE user_id = $1 AND purpose = $2 AND created_at >= $3",
+		userID, purpose, since)
+
+	return count, err
+}
+
+func (r *repository) UseToken(ctx context.Context, tokenDigest string, usedAt time.Time) (*Token, error) {
+
+	token := Token{}
+
+	if err := r.db.GetContext(ctx, &token, `UPDATE password_tokens SET used_at = $1
+			WHERE token_digest = $2 AND used_at IS NULL AND expires_at > $1
+			RETURNING *, (SELECT tenant_id FROM users WHERE users.id = password_tokens.user_id) AS tenant_id`,
+		usedAt, tokenDigest); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, app.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (r *repository) InvalidateTokens(ctx context.Context, userID int64, usedAt time.Time) error {
+
+	updateStatement, err := r.db.PreparexContext(ctx,
+		`UPDATE password_tokens SET used_at = $1 WHERE user_id = $2 AND used_at IS NULL`)
+	if err != nil {
+		return err
+	}
+	defer updateStatement.Close()
+
+	_, err = updateStatement.ExecContext(ctx, usedAt, userID)
+	return err
+}
+
+func (r *repository) DeleteTokensExpiredBefore(ctx context.Context, expiresAt time.Time) error {
+
+	deleteStatement, err := r.db.PreparexContext(ctx, `DELETE FROM password_tokens WHERE expires_at < $1`)
+	if err != nil {
+		return err
+	}
+	defer deleteStatement.Close()
+
+	_, err = deleteStatement.ExecContext(ctx, expiresAt)
+	return err
+}
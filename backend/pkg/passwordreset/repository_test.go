@@ -0,0 +1,87 @@
+//go:build integration
+
+package passwordreset
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/user"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// saveTokens saves a token with the digest for each expiry of the user.
+func saveTokens(t *testing.T, repo Repository, userID int64, now time.Time, expiries map[string]time.Time) {
+	t.Helper()
+
+	for digest, expiresAt := range expiries {
+		require.NoError(t, repo.SaveToken(context.Background(), &Token{UserID: userID, Purpose: PurposeReset,
+			TokenDigest: digest, CreatedAt: now.Add(-time.Minute), ExpiresAt: expiresAt}))
+	}
+}
+
+func TestUseToken(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		u, err := user.NewRepo(db).SaveUser(ctx, &user.User{Name: "Alice", Role: user.RoleUser})
+		require.NoError(t, err)
+
+		repo := NewRepo(db)
+		now := time.Now().UTC().Truncate(time.Second)
+		saveTokens(t, repo, u.ID, now, map[string]time.Time{"valid": now.Add(time.Hour),
+			"expired": now.Add(-time.Second)})
+
+		token, err := repo.UseToken(ctx, "valid", now)
+		require.NoError(t, err)
+		assert.Equal(t, u.ID, token.UserID)
+		assert.Equal(t, u.TenantID, token.TenantID)
+		assert.True(t, now.Equal(token.UsedAt.Time), "expected %v, got %v", now, token.UsedAt.Time)
+
+		_, err = repo.UseToken(ctx, "valid", now)
+		require.ErrorIs(t, err, app.ErrNotFound, "tokens are single-use")
+
+		_, err = repo.UseToken(ctx, "expired", now)
+		require.ErrorIs(t, err, app.ErrNotFound)
+
+		_, err = repo.UseToken(ctx, "unknown", now)
+		require.ErrorIs(t, err, app.ErrNotFound)
+	})
+}
+
+func TestInvalidateTokens(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		users := user.NewRepo(db)
+		alice, err := users.SaveUser(ctx, &user.User{Name: "Alice", Role: user.RoleUser})
+		require.NoError(t, err)
+		bob, err := users.SaveUser(ctx, &user.User{Name: "Bob", Role: user.RoleUser})
+		require.NoError(t, err)
+
+		repo := NewRepo(db)
+		now := time.Now().UTC()
+		saveTokens(t, repo, alice.ID, now, map[string]time.Time{"alice-1": now.Add(time.Hour),
+			"alice-2": now.Add(time.Hour)})
+		saveTokens(t, repo, bob.ID, now, map[string]time.Time{"bob": now.Add(time.Hour)})
+
+		require.NoError(t, repo.InvalidateTokens(ctx, alice.ID, now))
+
+		for _, digest := range []string{"alice-1", "alice-2"} {
+			_, err = repo.UseToken(ctx, digest, now)
+			require.ErrorIs(t, err, app.ErrNotFound, digest)
+		}
+
+		_, err = repo.UseToken(ctx, "bob", now)
+		require.NoError(t, err)
+	})
+}
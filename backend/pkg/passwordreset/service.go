@@ -0,0 +1,204 @@
+package passwordreset
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/d-rk/checkin-system/pkg/mail"
+	"github.com/d-rk/checkin-system/pkg/password"
+	"github.com/d-rk/checkin-system/pkg/tenant"
+	"github.com/d-rk/checkin-system/pkg/user"
+)
+
+// resetThrottle limits reset mails to one per user in this interval.
+const resetThrottle = 5 * time.Minute
+
+const resetMailBody = `Hello %s,
+
+a new password was requested for your check-in system account. Open the following link within %s
+to choose a new password:
+
+%s
+
+If you did not request a new password, you can ignore this mail.
+`
+
+const invitationMailBody = `Hello %s,
+
+an account for the check-in system was created for you. Open the following link within %s
+to choose your password:
+
+%s
+`
+
+// Service sets passwords with single-use tokens sent by mail, either requested by the user
+// to reset a forgotten password or sent by an admin to invite a new user.
+type Service interface {
+	// RequestPasswordReset mails a reset link to the user. Unknown users and users without email address are
+	// ignored, so that the result does not reveal which users exist.
+	RequestPasswordReset(ctx context.Context, username string) error
+	// InviteUser mails a link to choose a password to the user.
+	InviteUser(ctx context.Context, userID int64) error
+	// RedeemToken sets the password of the user the token was issued for and invalidates all open tokens.
+	RedeemToken(ctx context.Context, token, password string) error
+}
+
+type service struct {
+	repo             Repository
+	userService      user.Service
+	policy           *password.Policy
+	sender           mail.Sender
+	baseURL          string
+	resetExpiry      time.Duration
+	invitationExpiry time.Duration
+	now              func() time.Time
+}
+
+func NewService(repo Repository, userService user.Service, policy *password.Policy, sender mail.Sender,
+	cfg config.PasswordReset) Service {
+
+	return &service{
+		repo:             repo,
+		userService:      userService,
+		policy:           policy,
+		sender:           sender,
+		baseURL:          cfg.BaseURL,
+		resetExpiry:      time.Duration(cfg.ResetExpiryMinutes) * time.Minute,
+		invitationExpiry: time.Duration(cfg.InvitationExpiryDays) * 24 * time.Hour,
+		now:              time.Now,
+	}
+}
+
+func (s *service) RequestPasswordReset(ctx context.Context, username string) error {
+
+	u, err := s.userService.GetUserByName(ctx, username)
+	if errors.Is(err, app.ErrNotFound) {
+		slog.InfoContext(ctx, "password reset for unknown user requested", "user", username)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if !u.Email.Valid {
+		slog.InfoContext(ctx, "password reset for user without email requested", "user", username)
+		return nil
+	}
+
+	recent, err := s.repo.CountTokensSince(ctx, u.ID, PurposeReset, s.now().Add(-resetThrottle))
+	if err != nil {
+		return err
+	}
+	if recent > 0 {
+		slog.InfoContext(ctx, "password reset throttled", "user", username)
+		return nil
+	}
+
+	return s.sendToken(ctx, u, PurposeReset, s.resetExpiry, "Reset your password", resetMailBody)
+}
+
+func (s *service) InviteUser(ctx context.Context, userID int64) error {
+
+	u, err := s.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !u.Email.Valid {
+		return fmt.Errorf("user has no email address: %w", app.ErrInvalid)
+	}
+
+	return s.sendToken(ctx, u, PurposeInvitation, s.invitationExpiry, "Your check-in system account",
+		invitationMailBody)
+}
+
+func (s *service) RedeemToken(ctx context.Context, token, password string) error {
+
+	// validate before using the token, so that a rejected password does not invalidate it
+	if err := s.policy.Validate(password); err != nil {
+		return err
+	}
+
+	now := s.now()
+
+	t, err := s.repo.UseToken(ctx, tokenDigest(token), now)
+	if errors.Is(err, app.ErrNotFound) {
+		return fmt.Errorf("invalid or expired token: %w", app.ErrInvalid)
+	} else if err != nil {
+		return err
+	}
+
+	// the links do not depend on the tenant, the token identifies the user in all tenants
+	ctx = tenant.WithID(ctx, t.TenantID)
+
+	if err = s.userService.UpdateUserPassword(ctx, t.UserID, password); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "password set with token", "event", "password_token_redeemed", "user_id", t.UserID,
+		"purpose", t.Purpose)
+
+	return s.repo.InvalidateTokens(ctx, t.UserID, now)
+}
+
+func (s *service) sendToken(ctx context.Context, u *user.User, purpose string, expiry time.Duration,
+	subject, body string) error {
+
+	now := s.now()
+
+	if err := s.repo.DeleteTokensExpiredBefore(ctx, now); err != nil {
+		slog.WarnContext(ctx, "failed to delete expired password tokens", "error", err)
+	}
+
+	token := rand.Text()
+
+	if err := s.repo.SaveToken(ctx, &Token{
+		UserID:      u.ID,
+		Purpose:     purpose,
+		TokenDigest: tokenDigest(token),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(expiry),
+	}); err != nil {
+		return err
+	}
+
+	link := s.baseURL + "/set-password?" + url.Values{"token": {token}}.Encode()
+
+	return s.sender.Send(ctx, &mail.Message{
+		To:      u.Email.String,
+		Subject: subject,
+		Body:    fmt.Sprintf(body, u.Name, formatExpiry(expiry), link),
+	})
+}
+
+// tokenDigest hashes a token. The tokens are random, so a fast hash is sufficient.
+func tokenDigest(token string) string {
+	digest := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(digest[:])
+}
+
+// formatExpiry returns the expiry in the largest unit which expresses it exactly, e.g. "7 days".
+func formatExpiry(expiry time.Duration) string {
+
+	n, unit := expiry/time.Minute, "minute"
+	switch {
+	case expiry%(24*time.Hour) == 0:
+		n, unit = expiry/(24*time.Hour), "day"
+	case expiry%time.Hour == 0:
+		n, unit = expiry/time.Hour, "hour"
+	}
+
+	if n != 1 {
+		unit += "s"
+	}
+
+	return fmt.Sprintf("%d %s", n, unit)
+}
@@ -0,0 +1,138 @@
+//go:build integration
+
+package passwordreset
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/internal/testutil"
+	"github.com/d-rk/checkin-system/pkg/mail"
+	"github.com/d-rk/checkin-system/pkg/password"
+	"github.com/d-rk/checkin-system/pkg/user"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+type recordingSender struct {
+	messages []mail.Message
+}
+
+func (s *recordingSender) Send(_ context.Context, message *mail.Message) error {
+	s.messages = append(s.messages, *message)
+	return nil
+}
+
+// newTestService returns a service with the users trainer, who has an email address, and member, who has none.
+func newTestService(t *testing.T, db *sqlx.DB) (*service, user.Service, *recordingSender, *time.Time) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	userService := testutil.UserService(t, db, config.Users{})
+
+	_, err := userService.CreateUser(ctx, &user.User{Name: "trainer", Role: user.RoleTrainer,
+		Email: null.StringFrom("trainer@example.org")})
+	require.NoError(t, err)
+	_, err = userService.CreateUser(ctx, &user.User{Name: "member", Role: user.RoleUser})
+	require.NoError(t, err)
+
+	sender := &recordingSender{}
+	now := time.Date(2025, time.October, 1, 12, 0, 0, 0, time.UTC)
+
+	s := NewService(NewRepo(db), userService, &password.Policy{MinLength: 8}, sender, config.PasswordReset{
+		BaseURL:              "https://checkin.example.org",
+		ResetExpiryMinutes:   60,
+		InvitationExpiryDays: 7,
+	}).(*service)
+	s.now = func() time.Time { return now }
+
+	return s, userService, sender, &now
+}
+
+var linkPattern = regexp.MustCompile(`https://checkin\.example\.org/set-password\?\S+`)
+
+func tokenFromMail(t *testing.T, message mail.Message) string {
+
+	link, err := url.Parse(linkPattern.FindString(message.Body))
+	require.NoError(t, err)
+
+	return link.Query().Get("token")
+}
+
+func TestPasswordReset(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s, userService, sender, now := newTestService(t, db)
+
+		require.NoError(t, s.RequestPasswordReset(ctx, "unknown"))
+		require.NoError(t, s.RequestPasswordReset(ctx, "member"))
+		assert.Empty(t, sender.messages, "no mail without user or email address")
+
+		require.NoError(t, s.RequestPasswordReset(ctx, "trainer"))
+		require.NoError(t, s.RequestPasswordReset(ctx, "trainer"))
+		require.Len(t, sender.messages, 1, "second request is throttled")
+		assert.Equal(t, "trainer@example.org", sender.messages[0].To)
+		assert.Contains(t, sender.messages[0].Body, "within 1 hour")
+
+		token := tokenFromMail(t, sender.messages[0])
+
+		require.ErrorIs(t, s.RedeemToken(ctx, token, "short"), app.ErrInvalid)
+		require.NoError(t, s.RedeemToken(ctx, token, "a new password"), "rejected password keeps the token")
+
+		_, err := userService.GetUserByNameAndPassword(ctx, "trainer", "a new password")
+		require.NoError(t, err)
+
+		require.ErrorIs(t, s.RedeemToken(ctx, token, "another password"), app.ErrInvalid, "token is single-use")
+
+		*now = now.Add(resetThrottle + time.Second)
+		require.NoError(t, s.RequestPasswordReset(ctx, "trainer"))
+		require.Len(t, sender.messages, 2)
+
+		*now = now.Add(time.Hour)
+		require.ErrorIs(t, s.RedeemToken(ctx, tokenFromMail(t, sender.messages[1]), "a new password"),
+			app.ErrInvalid, "token is expired")
+	})
+}
+
+func TestInviteUser(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s, userService, sender, _ := newTestService(t, db)
+
+		trainer, err := userService.GetUserByName(ctx, "trainer")
+		require.NoError(t, err)
+		member, err := userService.GetUserByName(ctx, "member")
+		require.NoError(t, err)
+
+		require.ErrorIs(t, s.InviteUser(ctx, member.ID), app.ErrInvalid)
+		require.ErrorIs(t, s.InviteUser(ctx, member.ID+1), app.ErrNotFound)
+
+		require.NoError(t, s.InviteUser(ctx, trainer.ID))
+		require.NoError(t, s.RequestPasswordReset(ctx, "trainer"))
+		require.Len(t, sender.messages, 2)
+		assert.Contains(t, sender.messages[0].Body, "within 7 days")
+
+		require.NoError(t, s.RedeemToken(ctx, tokenFromMail(t, sender.messages[0]), "my first password"))
+
+		_, err = userService.GetUserByNameAndPassword(ctx, "trainer", "my first password")
+		require.NoError(t, err)
+
+		require.ErrorIs(t, s.RedeemToken(ctx, tokenFromMail(t, sender.messages[1]), "my first password"),
+			app.ErrInvalid, "redeeming a token invalidates all other tokens of the user")
+	})
+}
@@ -0,0 +1,53 @@
+package selfservice
+
+import (
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/announcement"
+	"github.com/d-rk/checkin-system/pkg/checkin"
+	"github.com/d-rk/checkin-system/pkg/user"
+	"gopkg.in/guregu/null.v4"
+)
+
+const (
+	CardStatusActive = "active"
+	CardStatusLost   = "lost"
+)
+
+// History lists the checkIns of a member, newest first.
+type History struct {
+	CheckIns   []checkin.CheckIn
+	Statistics checkin.Statistics
+}
+
+// Card is an rfid card which is or was assigned to a member.
+type Card struct {
+	RFIDuid        string
+	Status         string
+	ReportedLostAt null.Time
+}
+
+// Membership is the status of a member. A member is active if they checked in during the current season.
+type Membership struct {
+	MemberID    null.String
+	Group       null.String
+	Role        string
+	MemberSince time.Time
+	Active      bool
+	LastCheckIn null.Time
+}
+
+// Profile holds the fields members can change themselves.
+type Profile struct {
+	Email null.String
+}
+
+// Export contains all personal data stored about a member.
+type Export struct {
+	ExportedAt time.Time
+	User       user.User
+	Membership Membership
+	CheckIns   []checkin.CheckIn
+	Cards      []Card
+	Notes      []announcement.Announcement
+}
@@ -0,0 +1,223 @@
+package selfservice
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/announcement"
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/checkin"
+	"github.com/d-rk/checkin-system/pkg/group"
+	"github.com/d-rk/checkin-system/pkg/user"
+	"gopkg.in/guregu/null.v4"
+)
+
+// Service gives members access to their own data. Every method is scoped to the given user id, which
+// must be the id of the authenticated user.
+type Service interface {
+	GetHistory(ctx context.Context, userID int64) (*History, error)
+	ListCards(ctx context.Context, userID int64) ([]Card, error)
+	ReportCardLost(ctx context.Context, userID int64, rfidUID string) error
+	GetMembership(ctx context.Context, userID int64) (*Membership, error)
+	GetProfile(ctx context.Context, userID int64) (*user.User, error)
+	UpdateProfile(ctx context.Context, userID int64, profile *Profile) (*user.User, error)
+	// Export returns all personal data of the user, e.g. for a GDPR data access request.
+	Export(ctx context.Context, userID int64) (*Export, error)
+}
+
+type service struct {
+	userService         user.Service
+	checkinService      checkin.Service
+	announcementService announcement.Service
+	now                 func() time.Time
+}
+
+func NewService(userService user.Service, checkinService checkin.Service,
+	announcementService announcement.Service) Service {
+
+	return &service{
+		userService:         userService,
+		checkinService:      checkinService,
+		announcementService: announcementService,
+		now:                 time.Now,
+	}
+}
+
+func (s *service) GetHistory(ctx context.Context, userID int64) (*History, error) {
+
+	checkIns, err := s.listCheckIns(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	statistics, err := s.checkinService.GetUserStatistics(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &History{CheckIns: checkIns, Statistics: *statistics}, nil
+}
+
+func (s *service) ListCards(ctx context.Context, userID int64) ([]Card, error) {
+
+	u, err := s.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.cards(ctx, u)
+}
+
+func (s *service) ReportCardLost(ctx context.Context, userID int64, rfidUID string) error {
+	_, err := s.userService.ReportCardLost(ctx, userID, rfidUID)
+	return err
+}
+
+func (s *service) GetMembership(ctx context.Context, userID int64) (*Membership, error) {
+
+	u, err := s.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.membership(ctx, u)
+}
+
+func (s *service) GetProfile(ctx context.Context, userID int64) (*user.User, error) {
+	return s.userService.GetUserByID(ctx, userID)
+}
+
+func (s *service) UpdateProfile(ctx context.Context, userID int64, profile *Profile) (*user.User, error) {
+
+	u, err := s.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	email := null.StringFrom(strings.TrimSpace(profile.Email.String))
+	if !profile.Email.Valid || email.String == "" {
+		email = null.String{}
+	} else if address, parseErr := mail.ParseAddress(email.String); parseErr != nil || address.Address != email.String {
+		return nil, fmt.Errorf("invalid email address: %w", app.ErrInvalid)
+	}
+
+	u.Email = email
+
+	return s.userService.UpdateUser(ctx, u)
+}
+
+func (s *service) Export(ctx context.Context, userID int64) (*Export, error) {
+
+	u, err := s.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	membership, err := s.membership(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	checkIns, err := s.listCheckIns(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	cards, err := s.cards(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	notes, err := s.personalNotes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Export{
+		ExportedAt: s.now(),
+		User:       *u,
+		Membership: *membership,
+		CheckIns:   checkIns,
+		Cards:      cards,
+		Notes:      notes,
+	}, nil
+}
+
+func (s *service) membership(ctx context.Context, u *user.User) (*Membership, error) {
+
+	statistics, err := s.checkinService.GetUserStatistics(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Membership{
+		MemberID:    u.MemberID,
+		Group:       u.Group,
+		Role:        u.Role,
+		MemberSince: u.CreatedAt,
+		Active:      statistics.ThisSeason > 0,
+		LastCheckIn: statistics.LastCheckIn,
+	}, nil
+}
+
+// cards returns the currently assigned card followed by the lost cards of the user.
+func (s *service) cards(ctx context.Context, u *user.User) ([]Card, error) {
+
+	lostCards, err := s.userService.ListLostCards(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	cards := make([]Card, 0, len(lostCards)+1)
+
+	if u.RFIDuid.Valid {
+		cards = append(cards, Card{RFIDuid: u.RFIDuid.String, Status: CardStatusActive})
+	}
+
+	for _, c := range lostCards {
+		cards = append(cards, Card{
+			RFIDuid:        c.RFIDuid,
+			Status:         CardStatusLost,
+			ReportedLostAt: null.TimeFrom(c.ReportedAt),
+		})
+	}
+
+	return cards, nil
+}
+
+func (s *service) listCheckIns(ctx context.Context, userID int64) ([]checkin.CheckIn, error) {
+
+	checkIns, err := s.checkinService.ListUserCheckIns(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(checkIns, func(a, b checkin.CheckIn) int {
+		return b.Timestamp.Compare(a.Timestamp)
+	})
+
+	return checkIns, nil
+}
+
+// personalNotes returns all notes addressed to the user, including expired ones. Group announcements
+// are not personal data and therefore not included.
+func (s *service) personalNotes(ctx context.Context, userID int64) ([]announcement.Announcement, error) {
+
+	announcements, err := s.announcementService.ListAnnouncements(ctx, group.Scope{})
+	if err != nil {
+		return nil, err
+	}
+
+	notes := make([]announcement.Announcement, 0)
+	for _, a := range announcements {
+		if a.UserID.Valid && a.UserID.Int64 == userID {
+			notes = append(notes, a)
+		}
+	}
+
+	return notes, nil
+}
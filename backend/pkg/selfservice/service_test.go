@@ -0,0 +1,173 @@
+//go:build integration
+
+package selfservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/announcement"
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/checkin"
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/group"
+	"github.com/d-rk/checkin-system/pkg/internal/testutil"
+	"github.com/d-rk/checkin-system/pkg/location"
+	"github.com/d-rk/checkin-system/pkg/user"
+	"github.com/d-rk/checkin-system/pkg/websocket"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func newTestService(t *testing.T, db *sqlx.DB) *service {
+	t.Helper()
+
+	ws := &websocket.Server{}
+	userService := testutil.UserService(t, db, config.Users{})
+	announcementService := announcement.NewService(announcement.NewRepo(db), ws)
+	checkinService := checkin.NewService(checkin.NewRepo(db), userService, announcementService,
+		group.NewService(group.NewRepo(db)), location.NewService(location.NewRepo(db)), ws, database.NewDB(db),
+		config.Default().Checkin)
+
+	return NewService(userService, checkinService, announcementService).(*service)
+}
+
+// saveMembers saves alice, who checked in today and two years ago and lost a card, and bob, who checked in
+// yesterday. Both have a personal note.
+func saveMembers(t *testing.T, s *service) (*user.User, *user.User) {
+	t.Helper()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	alice, err := s.userService.CreateUser(ctx, &user.User{Name: "alice", Role: user.RoleUser,
+		RFIDuid: null.StringFrom("a1"), Email: null.StringFrom("alice@example.org")})
+	require.NoError(t, err)
+	bob, err := s.userService.CreateUser(ctx, &user.User{Name: "bob", Role: user.RoleUser,
+		RFIDuid: null.StringFrom("b1")})
+	require.NoError(t, err)
+
+	_, err = s.userService.ReportCardLost(ctx, alice.ID, "a1")
+	require.NoError(t, err)
+	alice.RFIDuid = null.StringFrom("a2")
+	alice, err = s.userService.UpdateUser(ctx, alice)
+	require.NoError(t, err)
+
+	for _, c := range []struct {
+		userID    int64
+		timestamp time.Time
+	}{
+		{alice.ID, now.AddDate(-2, 0, 0)},
+		{bob.ID, now.AddDate(0, 0, -1)},
+		{alice.ID, now},
+	} {
+		_, err = s.checkinService.CreateCheckInForUser(ctx, c.userID, null.Int{}, &c.timestamp)
+		require.NoError(t, err)
+	}
+
+	for _, a := range []announcement.Announcement{
+		{Title: "for alice", UserID: null.IntFrom(alice.ID)},
+		{Title: "for bob", UserID: null.IntFrom(bob.ID)},
+		{Title: "for everyone"},
+	} {
+		_, err = s.announcementService.CreateAnnouncement(ctx, &a)
+		require.NoError(t, err)
+	}
+
+	return alice, bob
+}
+
+func TestGetHistory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s := newTestService(t, db)
+		alice, _ := saveMembers(t, s)
+
+		history, err := s.GetHistory(ctx, alice.ID)
+		require.NoError(t, err)
+
+		require.Len(t, history.CheckIns, 2)
+		assert.True(t, history.CheckIns[0].Timestamp.After(history.CheckIns[1].Timestamp), "newest checkIn first")
+
+		assert.Equal(t, 2, history.Statistics.Total)
+		assert.Equal(t, 1, history.Statistics.ThisSeason, "the checkIn two years ago is in an earlier season")
+		assert.True(t, history.Statistics.LastCheckIn.Time.Equal(history.CheckIns[0].Timestamp))
+		assert.True(t, history.Statistics.FirstCheckIn.Time.Equal(history.CheckIns[1].Timestamp))
+	})
+}
+
+func TestExport(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s := newTestService(t, db)
+		alice, _ := saveMembers(t, s)
+
+		export, err := s.Export(ctx, alice.ID)
+		require.NoError(t, err)
+
+		assert.Equal(t, "alice", export.User.Name)
+		assert.True(t, export.Membership.Active)
+
+		require.Len(t, export.CheckIns, 2)
+		assert.True(t, export.CheckIns[0].Timestamp.After(export.CheckIns[1].Timestamp), "newest checkIn first")
+
+		require.Len(t, export.Cards, 2)
+		assert.Equal(t, Card{RFIDuid: "a2", Status: CardStatusActive}, export.Cards[0])
+		assert.Equal(t, "a1", export.Cards[1].RFIDuid)
+		assert.Equal(t, CardStatusLost, export.Cards[1].Status)
+		assert.True(t, export.Cards[1].ReportedLostAt.Valid)
+
+		require.Len(t, export.Notes, 1)
+		assert.Equal(t, "for alice", export.Notes[0].Title)
+	})
+}
+
+func TestUpdateProfile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+
+	tests := []struct {
+		name     string
+		email    null.String
+		expected null.String
+		wantErr  bool
+	}{
+		{name: "change", email: null.StringFrom(" alice@example.com "), expected: null.StringFrom("alice@example.com")},
+		{name: "remove", email: null.StringFrom(""), expected: null.String{}},
+		{name: "invalid", email: null.StringFrom("Alice <alice@example.com>"), wantErr: true},
+	}
+
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s := newTestService(t, db)
+		alice, _ := saveMembers(t, s)
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+
+				_, err := s.UpdateProfile(ctx, alice.ID, &Profile{Email: tt.email})
+				if tt.wantErr {
+					require.ErrorIs(t, err, app.ErrInvalid)
+					return
+				}
+				require.NoError(t, err)
+
+				u, err := s.GetProfile(ctx, alice.ID)
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, u.Email)
+				assert.Equal(t, "a2", u.RFIDuid.String, "other fields are kept")
+			})
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// realIP replaces the remote address of requests from the trusted proxies with the address of the client, taken from
+// X-Forwarded-For or X-Real-IP. Requests from other addresses keep their remote address, so that the headers cannot be
+// forged to get around the login lockout.
+func realIP(trustedProxies []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			if addr, ok := remoteAddr(r); ok && trusted(trustedProxies, addr) {
+				if client := forwardedFor(r, trustedProxies); client != "" {
+					r.RemoteAddr = client
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedFor returns the address of the client. The hops of X-Forwarded-For are checked from the right, as only the
+// ones added by the trusted proxies are reliable, and the first untrusted one is the client.
+func forwardedFor(r *http.Request, trustedProxies []netip.Prefix) string {
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+
+	for _, hop := range slices.Backward(hops) {
+
+		addr, err := netip.ParseAddr(hop)
+		if err != nil {
+			return ""
+		}
+
+		if !trusted(trustedProxies, addr) {
+			return addr.String()
+		}
+	}
+
+	// every hop is a trusted proxy
+	if len(hops) > 0 {
+		return hops[0]
+	}
+
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.String()
+	}
+
+	return ""
+}
+
+func remoteAddr(r *http.Request) (netip.Addr, bool) {
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	return addr, true
+}
+
+func trusted(trustedProxies []netip.Prefix, addr netip.Addr) bool {
+
+	addr = addr.Unmap()
+
+	return slices.ContainsFunc(trustedProxies, func(prefix netip.Prefix) bool {
+		return prefix.Contains(addr)
+	})
+}
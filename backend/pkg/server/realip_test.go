@@ -0,0 +1,83 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRealIP(t *testing.T) {
+
+	trustedProxies := []netip.Prefix{netip.MustParsePrefix("172.16.0.0/12"), netip.MustParsePrefix("10.0.0.1/32")}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		realIP       string
+		expectedAddr string
+	}{
+		{
+			name:         "untrusted client cannot forge the headers",
+			remoteAddr:   "203.0.113.7:51234",
+			forwardedFor: []string{"198.51.100.1"},
+			realIP:       "198.51.100.2",
+			expectedAddr: "203.0.113.7:51234",
+		},
+		{
+			name:         "trusted proxy",
+			remoteAddr:   "172.18.0.3:40000",
+			forwardedFor: []string{"198.51.100.1"},
+			expectedAddr: "198.51.100.1",
+		},
+		{
+			name:         "hops prepended by the client are ignored",
+			remoteAddr:   "172.18.0.3:40000",
+			forwardedFor: []string{"192.0.2.99, 198.51.100.1", "10.0.0.1"},
+			expectedAddr: "198.51.100.1",
+		},
+		{
+			name:         "X-Real-IP of a trusted proxy",
+			remoteAddr:   "10.0.0.1:40000",
+			realIP:       "198.51.100.2",
+			expectedAddr: "198.51.100.2",
+		},
+		{
+			name:         "invalid hop keeps the remote address",
+			remoteAddr:   "10.0.0.1:40000",
+			forwardedFor: []string{"unknown"},
+			expectedAddr: "10.0.0.1:40000",
+		},
+		{
+			name:         "trusted proxy without headers",
+			remoteAddr:   "10.0.0.1:40000",
+			expectedAddr: "10.0.0.1:40000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			var remoteAddr string
+			handler := realIP(trustedProxies)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				remoteAddr = r.RemoteAddr
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			assert.Equal(t, tt.expectedAddr, remoteAddr)
+		})
+	}
+}
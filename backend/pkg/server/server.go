@@ -6,16 +6,39 @@ import (
 	"net"
 	"net/http"
 	"os"
-	"strings"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/d-rk/checkin-system/pkg/version"
 	"github.com/d-rk/checkin-system/pkg/wifi"
 
+	"github.com/d-rk/checkin-system/pkg/announcement"
 	"github.com/d-rk/checkin-system/pkg/api"
+	"github.com/d-rk/checkin-system/pkg/audit"
+	"github.com/d-rk/checkin-system/pkg/auth"
+	"github.com/d-rk/checkin-system/pkg/backup"
 	"github.com/d-rk/checkin-system/pkg/checkin"
 	"github.com/d-rk/checkin-system/pkg/clock"
+	"github.com/d-rk/checkin-system/pkg/cmd"
+	"github.com/d-rk/checkin-system/pkg/config"
 	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/edgesync"
+	"github.com/d-rk/checkin-system/pkg/group"
+	"github.com/d-rk/checkin-system/pkg/health"
+	"github.com/d-rk/checkin-system/pkg/jobs"
+	"github.com/d-rk/checkin-system/pkg/location"
+	"github.com/d-rk/checkin-system/pkg/lockout"
+	"github.com/d-rk/checkin-system/pkg/mail"
+	"github.com/d-rk/checkin-system/pkg/metrics"
+	"github.com/d-rk/checkin-system/pkg/oidc"
+	"github.com/d-rk/checkin-system/pkg/password"
+	"github.com/d-rk/checkin-system/pkg/passwordreset"
+	"github.com/d-rk/checkin-system/pkg/selfservice"
+	"github.com/d-rk/checkin-system/pkg/tenant"
+	"github.com/d-rk/checkin-system/pkg/tracing"
+	"github.com/d-rk/checkin-system/pkg/twofactor"
 	"github.com/d-rk/checkin-system/pkg/user"
 	"github.com/d-rk/checkin-system/pkg/websocket"
 	"github.com/getkin/kin-openapi/openapi3filter"
@@ -23,18 +46,17 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/jmoiron/sqlx"
-	"github.com/joho/godotenv"
 	netHttpMiddleware "github.com/oapi-codegen/nethttp-middleware"
 )
 
 const defaultMaxAge = 300
 const defaultTimeout = 60 * time.Second
 const defaultReadHeaderTimeout = 10 * time.Second
+const retentionInterval = 24 * time.Hour
 
-func NewDB(runMigration bool) *sqlx.DB {
+func NewDB(cfg config.Database, runMigration bool) *sqlx.DB {
 
-	_ = godotenv.Load(".env")
-	db := database.Connect()
+	db := database.Connect(cfg)
 
 	if runMigration {
 		database.RunMigration(db)
@@ -43,51 +65,208 @@ func NewDB(runMigration bool) *sqlx.DB {
 	return db
 }
 
-func NewRouter(ctx context.Context, db *sqlx.DB) chi.Router {
+// NewRouter creates the services and the router of the api. The background jobs of the services are added to the
+// runner, the websocket server publishes their events.
+func NewRouter(cfg *config.Config, db *sqlx.DB, runner *jobs.Runner, ws *websocket.Server) chi.Router {
 
-	ws := &websocket.Server{}
+	metrics.RegisterDB(db.DB)
 
+	authRepo := newAuthRepo(db, cfg.Auth)
 	userRepo := user.NewRepo(db)
 	checkinRepo := checkin.NewRepo(db)
+	announcementRepo := announcement.NewRepo(db)
+	lockoutRepo := lockout.NewRepo(db)
+	twoFactorRepo := twofactor.NewRepo(db)
+	oidcRepo := oidc.NewRepo(db)
+	passwordResetRepo := passwordreset.NewRepo(db)
+	groupRepo := group.NewRepo(db)
+	locationRepo := location.NewRepo(db)
+	tenantRepo := tenant.NewRepo(db)
+	auditRepo := audit.NewRepo(db)
+	healthRepo := health.NewRepo(db)
+	transactor := database.NewDB(db)
+
+	authService, err := auth.NewService(authRepo, cfg.Auth)
+	if err != nil {
+		panic(err)
+	}
+
+	passwordHasher, err := password.NewHasher(cfg.Password)
+	if err != nil {
+		panic(err)
+	}
 
-	userService := user.NewService(userRepo, ws)
-	checkinService := checkin.NewService(checkinRepo, userService, ws)
-	clockService := clock.NewService()
-	wifiService := wifi.NewService()
+	passwordPolicy, err := password.NewPolicy(cfg.Password)
+	if err != nil {
+		panic(err)
+	}
 
-	if err := checkinService.DeleteOldCheckIns(ctx); err != nil {
-		slog.WarnContext(ctx, "failed to delete old checkins", "error", err)
+	mailSender, err := mail.NewSender(cfg.Mail)
+	if err != nil {
+		panic(err)
 	}
 
-	return setupRouter(userService, checkinService, clockService, wifiService, ws)
+	auditService := audit.NewService(auditRepo)
+	userService := user.NewService(userRepo, passwordHasher, passwordPolicy, ws, auditService, transactor,
+		cfg.Users)
+	lockoutService := lockout.NewService(lockoutRepo, cfg.Lockout)
+	twoFactorService := twofactor.NewService(twoFactorRepo, cfg.TOTP)
+	oidcService := oidc.NewService(oidcRepo, userService, cfg.OIDC)
+	passwordResetService := passwordreset.NewService(passwordResetRepo, userService, passwordPolicy, mailSender,
+		cfg.PasswordReset)
+	groupService := group.NewService(groupRepo)
+	locationService := location.NewService(locationRepo)
+	tenantService := tenant.NewService(tenantRepo, cfg.Tenant)
+	announcementService := announcement.NewService(announcementRepo, ws)
+	checkinService := checkin.NewService(checkinRepo, userService, announcementService, groupService,
+		locationService, ws, transactor, cfg.Checkin)
+	selfService := selfservice.NewService(userService, checkinService, announcementService)
+	executor := cmd.NewExecutor(cfg.Executor)
+	clockService := clock.NewService(executor)
+	wifiService := wifi.NewService(executor)
+	healthService := health.NewService(healthRepo, executor, wifiService)
+
+	backupService, syncService := newReplicationServices(cfg, db, transactor, userService, groupService,
+		locationService, checkinService)
+
+	addJobs(runner, &jobs.Job{Name: "retention", Interval: retentionInterval, RunOnStart: true,
+		Run: checkinService.DeleteOldCheckIns}, backupService.Job(), syncService.Job())
+
+	return setupRouter(cfg.Server, authService, userService, lockoutService, twoFactorService, oidcService,
+		passwordResetService, selfService, groupService, locationService, tenantService, checkinService,
+		announcementService, clockService, wifiService, backupService, syncService, healthService, ws)
+}
+
+// addJobs registers the jobs with the runner. Jobs which are nil are disabled and skipped.
+func addJobs(runner *jobs.Runner, jobList ...*jobs.Job) {
+	for _, job := range jobList {
+		if job == nil {
+			continue
+		}
+		if err := runner.Add(*job); err != nil {
+			panic(err)
+		}
+	}
 }
 
-func Run() {
+// newReplicationServices creates the services copying the database, the local backups and the sync of edge
+// instances.
+func newReplicationServices(cfg *config.Config, db *sqlx.DB, transactor database.Transactor,
+	userService user.Service, groupService group.Service, locationService location.Service,
+	checkinService checkin.Service) (backup.Service, edgesync.Service) {
+
+	backupService := backup.NewService(backup.NewRepo(db), cfg.Backup)
+
+	syncService, err := edgesync.NewService(edgesync.NewRepo(db), transactor, userService, groupService,
+		locationService, checkinService, cfg.Sync)
+	if err != nil {
+		panic(err)
+	}
+
+	return backupService, syncService
+}
+
+// newAuthRepo stores the token signing keys in the key dir if configured, otherwise in the database.
+func newAuthRepo(db *sqlx.DB, cfg config.Auth) auth.Repository {
+	if cfg.KeyDir != "" {
+		return auth.NewFileRepo(cfg.KeyDir)
+	}
+	return auth.NewRepo(db)
+}
+
+// Run serves the api at the listen address and port until SIGINT or SIGTERM. Then the server stops accepting
+// connections, closes the websockets and waits up to the shutdown timeout for the running requests and jobs.
+func Run(cfg *config.Config) error {
 
 	slog.Info("starting up", "version", version.Version, "build_time", version.BuildTime,
 		"git_commit", version.GitCommit)
 
-	db := NewDB(true)
+	shutdownTimeout := time.Duration(cfg.Server.ShutdownTimeoutSeconds) * time.Second
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if shutdownErr := shutdownTracing(context.Background()); shutdownErr != nil {
+			slog.Warn("failed to flush spans", "error", shutdownErr)
+		}
+	}()
+
+	db := NewDB(cfg.Database, true)
 	defer db.Close()
 
-	router := NewRouter(context.Background(), db)
+	runner := jobs.NewRunner()
+	ws := &websocket.Server{}
 
 	srv := &http.Server{
-		Handler:           router,
+		Handler:           NewRouter(cfg, db, runner, ws),
 		ReadHeaderTimeout: defaultReadHeaderTimeout,
-		Addr:              net.JoinHostPort("0.0.0.0", "8080"),
+		Addr:              net.JoinHostPort(cfg.Server.ListenAddress, strconv.Itoa(cfg.Server.Port)),
+	}
+	// the http server does not track the hijacked websocket connections
+	srv.RegisterOnShutdown(ws.Close)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// the jobs are stopped after the requests are drained, not right at the signal
+	runner.Start(context.WithoutCancel(ctx))
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("listening", "address", srv.Addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serveErr:
+		slog.Error("server failed", "error", err)
+	case <-ctx.Done():
+		slog.Info("shutting down", "timeout", shutdownTimeout)
 	}
 
-	// And we serve HTTP until the world ends.
-	err := srv.ListenAndServe()
-	slog.Info("server stopped", "err", err)
+	shutdown(srv, runner, shutdownTimeout)
+
+	return err
+}
+
+// shutdown waits for the running requests and jobs, at most for the timeout.
+func shutdown(srv *http.Server, runner *jobs.Runner, timeout time.Duration) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Warn("requests did not complete before the shutdown", "error", err)
+	}
+
+	if err := runner.Stop(ctx); err != nil {
+		slog.Warn("jobs did not complete before the shutdown", "error", err)
+	}
+
+	slog.Info("server stopped")
 }
 
 func setupRouter(
+	cfg config.Server,
+	authService auth.Service,
 	userService user.Service,
+	lockoutService lockout.Service,
+	twoFactorService twofactor.Service,
+	oidcService oidc.Service,
+	passwordResetService passwordreset.Service,
+	selfService selfservice.Service,
+	groupService group.Service,
+	locationService location.Service,
+	tenantService tenant.Service,
 	checkinService checkin.Service,
+	announcementService announcement.Service,
 	clockService clock.Service,
 	wifiService wifi.Service,
+	backupService backup.Service,
+	syncService edgesync.Service,
+	healthService health.Service,
 	ws *websocket.Server,
 ) chi.Router {
 
@@ -103,22 +282,36 @@ func setupRouter(
 	// that server names match. We don't know how this thing will be run.
 	swagger.Servers = nil
 
-	apiHandler := api.NewHandler(userService, checkinService, clockService, wifiService)
+	trustedProxies, err := cfg.TrustedProxyPrefixes()
+	if err != nil {
+		slog.Error("invalid trusted proxies", "err", err)
+		os.Exit(1)
+	}
+
+	apiHandler := api.NewHandler(authService, userService, lockoutService, twoFactorService, oidcService,
+		passwordResetService, selfService, groupService, locationService, tenantService, checkinService,
+		announcementService, clockService, wifiService, backupService, syncService, healthService)
 
 	router.Use(middleware.RequestID)
-	router.Use(middleware.RealIP)
+	router.Use(realIP(trustedProxies))
+	operation := metrics.OperationName(swagger)
+	router.Use(tracing.Middleware(operation))
+	router.Use(metrics.Middleware(operation))
 	router.Use(middleware.Logger)
 	router.Use(middleware.Recoverer)
 
 	router.Use(middleware.Timeout(defaultTimeout))
 
-	router.Use(coreMiddleware())
+	router.Use(coreMiddleware(cfg))
+
+	// resolves the tenant of the api
+	router.Use(api.TenantMiddleware(tenantService))
 
 	validatorOptions := netHttpMiddleware.Options{
 		Options: openapi3filter.Options{
 			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
 		},
-		ErrorHandler: api.ValidateErrorHandlerFunc,
+		ErrorHandlerWithOpts: api.ValidateErrorHandlerFunc,
 	}
 
 	// register handler on router
@@ -126,11 +319,17 @@ func setupRouter(
 		BaseRouter: router,
 		Middlewares: []api.MiddlewareFunc{
 			netHttpMiddleware.OapiRequestValidatorWithOptions(swagger, &validatorOptions),
-			api.AuthMiddleware(),
+			api.AuthMiddleware(authService, userService),
 		},
 	})
 
-	router.Get("/websocket", websocket.CreateHandler(ws))
+	// clients only receive the events of the tenant of their token
+	router.With(api.WebsocketAuthMiddleware(authService, userService)).Get("/websocket", websocket.CreateHandler(ws))
+
+	// probes of docker and the monitoring and the metrics scraped by Prometheus, they need no authentication
+	router.Get("/healthz", health.LivenessHandler())
+	router.Get("/readyz", health.ReadinessHandler(healthService))
+	router.Method(http.MethodGet, "/metrics", metrics.Handler())
 
 	_ = chi.Walk(router, func(method string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
 		slog.Info("registered route", "method", method, "route", route)
@@ -140,9 +339,16 @@ func setupRouter(
 	return router
 }
 
-func coreMiddleware() func(http.Handler) http.Handler {
+func coreMiddleware(cfg config.Server) func(http.Handler) http.Handler {
+
+	// the cors handler allows every origin if the list is empty
+	allowedOrigins := cfg.CORSAllowedOrigins
+	if len(allowedOrigins) == 0 {
+		allowedOrigins = []string{""}
+	}
+
 	return cors.Handler(cors.Options{
-		AllowedOrigins:   strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ","),
+		AllowedOrigins:   allowedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
 		ExposedHeaders:   []string{"X-Filename"},
@@ -0,0 +1,48 @@
+package tenant
+
+import (
+	"context"
+	"net"
+	"strings"
+)
+
+type contextKey struct{}
+
+// WithID returns a context for the tenant. Repositories restrict all queries to the tenant of the context.
+func WithID(ctx context.Context, id int64) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the tenant of the context and whether it was set explicitly.
+func FromContext(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(contextKey{}).(int64)
+	return id, ok
+}
+
+// ID returns the tenant of the context, or the default tenant if none was set.
+func ID(ctx context.Context) int64 {
+	if id, ok := FromContext(ctx); ok {
+		return id
+	}
+	return DefaultID
+}
+
+// SlugFromHost returns the subdomain of the host below the base domain, e.g. "club" for
+// "club.checkin.example.org:8080" and the base domain "checkin.example.org".
+func SlugFromHost(host, baseDomain string) (string, bool) {
+
+	if baseDomain == "" {
+		return "", false
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	slug, found := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(baseDomain))
+	if !found || slug == "" || strings.Contains(slug, ".") {
+		return "", false
+	}
+
+	return slug, true
+}
@@ -0,0 +1,41 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlugFromHost(t *testing.T) {
+
+	tests := []struct {
+		host string
+		slug string
+		ok   bool
+	}{
+		{"club.checkin.example.org", "club", true},
+		{"Club.Checkin.Example.org:8080", "club", true},
+		{"checkin.example.org", "", false},
+		{"a.club.checkin.example.org", "", false},
+		{"club.example.org", "", false},
+		{"localhost:8080", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			slug, ok := SlugFromHost(tt.host, "checkin.example.org")
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.slug, slug)
+		})
+	}
+
+	_, ok := SlugFromHost("club.checkin.example.org", "")
+	assert.False(t, ok)
+}
+
+func TestID(t *testing.T) {
+
+	assert.Equal(t, DefaultID, ID(context.Background()))
+	assert.Equal(t, int64(3), ID(WithID(context.Background(), 3)))
+}
@@ -0,0 +1,19 @@
+package tenant
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// DefaultID is the tenant of single-tenant deployments. All data which existed before tenants belongs to it.
+const DefaultID int64 = 1
+
+// Tenant is a club hosted by the deployment. Its slug is the subdomain it is served at.
+type Tenant struct {
+	ID        int64     `db:"id"         json:"id"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt null.Time `db:"updated_at" json:"updated_at"`
+	Name      string    `db:"name"       json:"name"`
+	Slug      string    `db:"slug"       json:"slug"`
+}
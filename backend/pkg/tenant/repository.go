@@ -0,0 +1,107 @@
+package tenant
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/database"
+
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/guregu/null.v4"
+)
+
+type Repository interface {
+	ListTenants(ctx context.Context) ([]Tenant, error)
+	GetTenantByID(ctx context.Context, id int64) (*Tenant, error)
+	GetTenantBySlug(ctx context.Context, slug string, excludeID int64) (*Tenant, error)
+	SaveTenant(ctx context.Context, tenant *Tenant) (*Tenant, error)
+	UpdateTenant(ctx context.Context, tenant *Tenant) (*Tenant, error)
+}
+
+type repository struct {
+	db *database.DB
+}
+
+func NewRepo(db *sqlx.DB) Repository {
+	return &repository{database.NewDB(db)}
+}
+
+func (r *repository) ListTenants(ctx context.Context) ([]Tenant, error) {
+
+	tenants := make([]Tenant, 0)
+
+	if err := r.db.SelectContext(ctx, &tenants, "SELECT * FROM tenants ORDER BY name"); err != nil {
+		return nil, fmt.Errorf("failed to list tenants: %w", err)
+	}
+
+	return tenants, nil
+}
+
+func (r *repository) GetTenantByID(ctx context.Context, id int64) (*Tenant, error) {
+
+	tenant := Tenant{}
+
+	if err := r.db.GetContext(ctx, &tenant, "SELECT * FROM tenants WHERE id = $1", id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, app.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &tenant, nil
+}
+
+func (r *repository) GetTenantBySlug(ctx context.Context, slug string, excludeID int64) (*Tenant, error) {
+
+	tenant := Tenant{}
+
+	if err := r.db.GetContext(ctx, &tenant, "SELECT * FROM tenants WHERE slug = $1 AND id != $2", slug,
+		excludeID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, app.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &tenant, nil
+}
+
+func (r *repository) SaveTenant(ctx context.Context, tenant *Tenant) (*Tenant, error) {
+
+	tenant.CreatedAt = time.Now()
+
+	insertStatement, err := r.db.PrepareNamedContext(ctx, `INSERT INTO tenants
+			(created_at, name, slug) VALUES (:created_at, :name, :slug) RETURNING id`)
+	if err != nil {
+		return nil, err
+	}
+	defer insertStatement.Close()
+
+	if err = insertStatement.QueryRowContext(ctx, tenant).Scan(&tenant.ID); err != nil {
+		return nil, err
+	}
+
+	return tenant, nil
+}
+
+func (r *repository) UpdateTenant(ctx context.Context, tenant *Tenant) (*Tenant, error) {
+
+	tenant.UpdatedAt = null.TimeFrom(time.Now())
+
+	updateStatement, err := r.db.PrepareNamedContext(ctx, `UPDATE tenants SET
+			(updated_at, name, slug) = (:updated_at, :name, :slug) WHERE id = :id`)
+	if err != nil {
+		return nil, err
+	}
+	defer updateStatement.Close()
+
+	if _, err = updateStatement.ExecContext(ctx, tenant); err != nil {
+		return nil, err
+	}
+
+	return tenant, nil
+}
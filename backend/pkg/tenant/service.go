@@ -0,0 +1,113 @@
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/config"
+)
+
+const maxNameLength = 100
+
+// slugPattern matches valid dns labels.
+var slugPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+type Service interface {
+	ListTenants(ctx context.Context) ([]Tenant, error)
+	GetTenantByID(ctx context.Context, id int64) (*Tenant, error)
+	GetTenantBySlug(ctx context.Context, slug string) (*Tenant, error)
+	CreateTenant(ctx context.Context, tenant *Tenant) (*Tenant, error)
+	UpdateTenant(ctx context.Context, tenant *Tenant) (*Tenant, error)
+	// ResolveHost returns the tenant served at the subdomain of the host. It returns false if the host has no
+	// subdomain below TENANT_BASE_DOMAIN, or multi-tenant mode is disabled.
+	ResolveHost(ctx context.Context, host string) (*Tenant, bool, error)
+	// MultiTenant reports whether multi-tenant mode is enabled, i.e. TENANT_BASE_DOMAIN is set.
+	MultiTenant() bool
+}
+
+type service struct {
+	repo       Repository
+	baseDomain string
+}
+
+func NewService(repo Repository, cfg config.Tenant) Service {
+	return &service{repo, cfg.BaseDomain}
+}
+
+func (s *service) ListTenants(ctx context.Context) ([]Tenant, error) {
+	return s.repo.ListTenants(ctx)
+}
+
+func (s *service) GetTenantByID(ctx context.Context, id int64) (*Tenant, error) {
+	return s.repo.GetTenantByID(ctx, id)
+}
+
+func (s *service) GetTenantBySlug(ctx context.Context, slug string) (*Tenant, error) {
+	return s.repo.GetTenantBySlug(ctx, strings.ToLower(slug), -1)
+}
+
+func (s *service) CreateTenant(ctx context.Context, tenant *Tenant) (*Tenant, error) {
+
+	if err := s.validate(ctx, tenant, -1); err != nil {
+		return nil, err
+	}
+
+	return s.repo.SaveTenant(ctx, tenant)
+}
+
+func (s *service) UpdateTenant(ctx context.Context, tenant *Tenant) (*Tenant, error) {
+
+	existing, err := s.repo.GetTenantByID(ctx, tenant.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.validate(ctx, tenant, tenant.ID); err != nil {
+		return nil, err
+	}
+
+	tenant.CreatedAt = existing.CreatedAt
+
+	return s.repo.UpdateTenant(ctx, tenant)
+}
+
+func (s *service) ResolveHost(ctx context.Context, host string) (*Tenant, bool, error) {
+
+	slug, ok := SlugFromHost(host, s.baseDomain)
+	if !ok {
+		return nil, false, nil
+	}
+
+	tenant, err := s.repo.GetTenantBySlug(ctx, slug, -1)
+	if err != nil {
+		return nil, true, err
+	}
+
+	return tenant, true, nil
+}
+
+func (s *service) MultiTenant() bool {
+	return s.baseDomain != ""
+}
+
+func (s *service) validate(ctx context.Context, tenant *Tenant, excludeID int64) error {
+
+	tenant.Name = strings.TrimSpace(tenant.Name)
+	if tenant.Name == "" || len(tenant.Name) > maxNameLength {
+		return fmt.Errorf("tenant name must have 1 to %d characters: %w", maxNameLength, app.ErrInvalid)
+	}
+
+	tenant.Slug = strings.ToLower(strings.TrimSpace(tenant.Slug))
+	if !slugPattern.MatchString(tenant.Slug) {
+		return fmt.Errorf("tenant slug must be a valid subdomain: %w", app.ErrInvalid)
+	}
+
+	if _, err := s.repo.GetTenantBySlug(ctx, tenant.Slug, excludeID); err == nil {
+		return fmt.Errorf("tenant with slug already exists: %w", app.ErrConflict)
+	}
+
+	return nil
+}
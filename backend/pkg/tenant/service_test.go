@@ -0,0 +1,82 @@
+//go:build integration
+
+package tenant
+
+import (
+	"context"
+	"testing"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateTenant(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+
+	tests := []struct {
+		name   string
+		tenant Tenant
+		err    error
+	}{
+		{"valid", Tenant{Name: " Rowing Club ", Slug: "Rowing-Club"}, nil},
+		{"empty name", Tenant{Name: " ", Slug: "rowing"}, app.ErrInvalid},
+		{"invalid slug", Tenant{Name: "Rowing Club", Slug: "rowing.club"}, app.ErrInvalid},
+		{"trailing dash", Tenant{Name: "Rowing Club", Slug: "rowing-"}, app.ErrInvalid},
+		{"duplicate slug", Tenant{Name: "Other", Slug: "default"}, app.ErrConflict},
+	}
+
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s := NewService(NewRepo(db), config.Tenant{})
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				tenant := tt.tenant
+				created, err := s.CreateTenant(ctx, &tenant)
+
+				if tt.err != nil {
+					require.ErrorIs(t, err, tt.err)
+					return
+				}
+				require.NoError(t, err)
+
+				created, err = s.GetTenantByID(ctx, created.ID)
+				require.NoError(t, err)
+				assert.Equal(t, "Rowing Club", created.Name)
+				assert.Equal(t, "rowing-club", created.Slug)
+			})
+		}
+	})
+}
+
+func TestResolveHost(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s := NewService(NewRepo(db), config.Tenant{BaseDomain: "checkin.example.org"})
+
+		rowing, err := s.CreateTenant(ctx, &Tenant{Name: "Rowing Club", Slug: "rowing"})
+		require.NoError(t, err)
+
+		tenant, ok, err := s.ResolveHost(ctx, "Rowing.checkin.example.org")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, rowing.ID, tenant.ID)
+
+		_, ok, err = s.ResolveHost(ctx, "unknown.checkin.example.org")
+		assert.True(t, ok)
+		require.ErrorIs(t, err, app.ErrNotFound)
+
+		_, ok, err = s.ResolveHost(ctx, "checkin.example.org")
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestMiddleware(t *testing.T) {
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(sdktrace.NewTracerProvider()) })
+
+	var logs bytes.Buffer
+	logger := slog.New(NewLogHandler(slog.NewTextHandler(&logs, nil)))
+
+	handler := Middleware(func(_ *http.Request) string { return "createCheckIn" })(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger.ErrorContext(r.Context(), "failed")
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/checkins", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+
+	span := spans[0]
+	assert.Equal(t, "createCheckIn", span.Name())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+	assert.Equal(t, codes.Error, span.Status().Code)
+
+	assert.Contains(t, logs.String(), "trace_id=4bf92f3577b34da6a3ce929d0e0e4736")
+	assert.Contains(t, logs.String(), "span_id="+span.SpanContext().SpanID().String())
+}
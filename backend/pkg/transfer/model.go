@@ -0,0 +1,198 @@
+package transfer
+
+import (
+	"encoding/json"
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+const (
+	// Format identifies exports of the checkin-system.
+	Format = "checkin-system-export"
+	// FormatVersion is the version of the export format, it is increased on incompatible changes of the records.
+	FormatVersion = 1
+)
+
+// record types in the order of the export, so that every record follows the records it references.
+const (
+	TypeHeader         = "header"
+	TypeTenant         = "tenant"
+	TypeGroup          = "group"
+	TypeLocation       = "location"
+	TypeLocationReader = "location_reader"
+	TypeUser           = "user"
+	TypeGroupTrainer   = "group_trainer"
+	TypeUserIdentity   = "user_identity"
+	TypeUserTotp       = "user_totp"
+	TypeRecoveryCode   = "recovery_code"
+	TypeLostCard       = "lost_card"
+	TypeCheckIn        = "checkin"
+	TypeAnnouncement   = "announcement"
+)
+
+// RecordTypes are the types of the exported records, in the order of the export.
+var RecordTypes = []string{TypeTenant, TypeGroup, TypeLocation, TypeLocationReader, TypeUser, TypeGroupTrainer,
+	TypeUserIdentity, TypeUserTotp, TypeRecoveryCode, TypeLostCard, TypeCheckIn, TypeAnnouncement}
+
+// Line is a line of an export: the header or a record along with its type.
+type Line struct {
+	Type   string          `json:"type"`
+	Record json.RawMessage `json:"record"`
+}
+
+// Header is the first line of an export.
+type Header struct {
+	Format        string    `json:"format"`
+	Version       int       `json:"version"`
+	ExportedAt    time.Time `json:"exported_at"`
+	Driver        string    `json:"driver"`
+	SchemaVersion string    `json:"schema_version"`
+}
+
+type Tenant struct {
+	ID        int64     `db:"id"         json:"id"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt null.Time `db:"updated_at" json:"updated_at"`
+	Name      string    `db:"name"       json:"name"`
+	Slug      string    `db:"slug"       json:"slug"`
+}
+
+type Group struct {
+	ID          int64       `db:"id"          json:"id"`
+	TenantID    int64       `db:"tenant_id"   json:"tenant_id"`
+	CreatedAt   time.Time   `db:"created_at"  json:"created_at"`
+	UpdatedAt   null.Time   `db:"updated_at"  json:"updated_at"`
+	Name        string      `db:"name"        json:"name"`
+	Description null.String `db:"description" json:"description"`
+	Colour      null.String `db:"colour"      json:"colour"`
+	Capacity    null.Int    `db:"capacity"    json:"capacity"`
+	Schedule    null.String `db:"schedule"    json:"schedule"`
+}
+
+type Location struct {
+	ID          int64       `db:"id"          json:"id"`
+	TenantID    int64       `db:"tenant_id"   json:"tenant_id"`
+	CreatedAt   time.Time   `db:"created_at"  json:"created_at"`
+	UpdatedAt   null.Time   `db:"updated_at"  json:"updated_at"`
+	Name        string      `db:"name"        json:"name"`
+	Description null.String `db:"description" json:"description"`
+	Capacity    null.Int    `db:"capacity"    json:"capacity"`
+}
+
+type LocationReader struct {
+	TenantID   int64  `db:"tenant_id"   json:"tenant_id"`
+	ReaderID   string `db:"reader_id"   json:"reader_id"`
+	LocationID int64  `db:"location_id" json:"location_id"`
+}
+
+type User struct {
+	ID             int64       `db:"id"              json:"id"`
+	TenantID       int64       `db:"tenant_id"       json:"tenant_id"`
+	CreatedAt      time.Time   `db:"created_at"      json:"created_at"`
+	UpdatedAt      null.Time   `db:"updated_at"      json:"updated_at"`
+	Name           string      `db:"name"            json:"name"`
+	RFIDuid        null.String `db:"rfid_uid"        json:"rfid_uid"`
+	MemberID       null.String `db:"member_id"       json:"member_id"`
+	PasswordDigest null.String `db:"password_digest" json:"password_digest"`
+	Role           string      `db:"role"            json:"role"`
+	Email          null.String `db:"email"           json:"email"`
+	GroupID        null.Int    `db:"group_id"        json:"group_id"`
+}
+
+type GroupTrainer struct {
+	GroupID int64 `db:"group_id" json:"group_id"`
+	UserID  int64 `db:"user_id"  json:"user_id"`
+}
+
+type UserIdentity struct {
+	Issuer    string    `db:"issuer"     json:"issuer"`
+	Subject   string    `db:"subject"    json:"subject"`
+	UserID    int64     `db:"user_id"    json:"user_id"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+type UserTotp struct {
+	UserID       int64     `db:"user_id"        json:"user_id"`
+	Secret       string    `db:"secret"         json:"secret"`
+	CreatedAt    time.Time `db:"created_at"     json:"created_at"`
+	ConfirmedAt  null.Time `db:"confirmed_at"   json:"confirmed_at"`
+	LastUsedStep int64     `db:"last_used_step" json:"last_used_step"`
+}
+
+type RecoveryCode struct {
+	ID         int64     `db:"id"          json:"id"`
+	UserID     int64     `db:"user_id"     json:"user_id"`
+	CodeDigest string    `db:"code_digest" json:"code_digest"`
+	UsedAt     null.Time `db:"used_at"     json:"used_at"`
+}
+
+type LostCard struct {
+	ID         int64     `db:"id"          json:"id"`
+	UserID     int64     `db:"user_id"     json:"user_id"`
+	RFIDuid    string    `db:"rfid_uid"    json:"rfid_uid"`
+	ReportedAt time.Time `db:"reported_at" json:"reported_at"`
+}
+
+type CheckIn struct {
+	ID         int64     `db:"id"          json:"id"`
+	TenantID   int64     `db:"tenant_id"   json:"tenant_id"`
+	Date       time.Time `db:"date"        json:"date"`
+	Timestamp  null.Time `db:"timestamp"   json:"timestamp"`
+	UserID     int64     `db:"user_id"     json:"user_id"`
+	LocationID null.Int  `db:"location_id" json:"location_id"`
+}
+
+type Announcement struct {
+	ID         int64       `db:"id"          json:"id"`
+	TenantID   int64       `db:"tenant_id"   json:"tenant_id"`
+	CreatedAt  time.Time   `db:"created_at"  json:"created_at"`
+	UpdatedAt  null.Time   `db:"updated_at"  json:"updated_at"`
+	Title      string      `db:"title"       json:"title"`
+	Message    null.String `db:"message"     json:"message"`
+	ValidFrom  null.Time   `db:"valid_from"  json:"valid_from"`
+	ValidUntil null.Time   `db:"valid_until" json:"valid_until"`
+	UserID     null.Int    `db:"user_id"     json:"user_id"`
+	GroupID    null.Int    `db:"group_id"    json:"group_id"`
+}
+
+// Outcome is the result of saving an imported record.
+type Outcome int
+
+const (
+	// Created records were inserted.
+	Created Outcome = iota + 1
+	// Existing records were already present, e.g. a user with the same name, and have not been changed.
+	Existing
+	// Conflicting records were not inserted, as they violate a uniqueness constraint of another record.
+	Conflicting
+)
+
+// Count is the number of imported records of a type by their outcome. Skipped records were not inserted, because
+// they conflict with an existing record or reference a record which was not imported.
+type Count struct {
+	Created  int
+	Existing int
+	Skipped  int
+}
+
+// Report is the result of an import.
+type Report struct {
+	Header Header
+	// Counts are the counts by record type.
+	Counts map[string]*Count
+	// Skipped are the reasons of the skipped records.
+	Skipped []string
+	// DryRun is set if the import was rolled back.
+	DryRun bool
+}
+
+func newReport(header Header) *Report {
+
+	report := &Report{Header: header, Counts: make(map[string]*Count, len(RecordTypes))}
+	for _, t := range RecordTypes {
+		report.Counts[t] = &Count{}
+	}
+
+	return report
+}
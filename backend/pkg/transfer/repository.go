@@ -0,0 +1,296 @@
+package transfer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/d-rk/checkin-system/pkg/database"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Repository reads and writes the records of all tenants. The Save methods set the id of the record to the id of
+// the inserted or existing row, records are matched by their natural key, e.g. the name of a user within a tenant.
+type Repository interface {
+	Driver() string
+	SchemaVersion(ctx context.Context) (string, error)
+
+	ListTenants(ctx context.Context) ([]Tenant, error)
+	ListGroups(ctx context.Context) ([]Group, error)
+	ListLocations(ctx context.Context) ([]Location, error)
+	ListLocationReaders(ctx context.Context) ([]LocationReader, error)
+	ListUsers(ctx context.Context) ([]User, error)
+	ListGroupTrainers(ctx context.Context) ([]GroupTrainer, error)
+	ListUserIdentities(ctx context.Context) ([]UserIdentity, error)
+	ListUserTotps(ctx context.Context) ([]UserTotp, error)
+	ListRecoveryCodes(ctx context.Context) ([]RecoveryCode, error)
+	ListLostCards(ctx context.Context) ([]LostCard, error)
+	ListCheckIns(ctx context.Context) ([]CheckIn, error)
+	ListAnnouncements(ctx context.Context) ([]Announcement, error)
+
+	SaveTenant(ctx context.Context, tenant *Tenant) (Outcome, error)
+	SaveGroup(ctx context.Context, group *Group) (Outcome, error)
+	SaveLocation(ctx context.Context, location *Location) (Outcome, error)
+	SaveLocationReader(ctx context.Context, reader *LocationReader) (Outcome, error)
+	SaveUser(ctx context.Context, user *User) (Outcome, error)
+	SaveGroupTrainer(ctx context.Context, trainer *GroupTrainer) (Outcome, error)
+	SaveUserIdentity(ctx context.Context, identity *UserIdentity) (Outcome, error)
+	SaveUserTotp(ctx context.Context, totp *UserTotp) (Outcome, error)
+	SaveRecoveryCode(ctx context.Context, code *RecoveryCode) (Outcome, error)
+	SaveLostCard(ctx context.Context, card *LostCard) (Outcome, error)
+	SaveCheckIn(ctx context.Context, checkIn *CheckIn) (Outcome, error)
+	SaveAnnouncement(ctx context.Context, announcement *Announcement) (Outcome, error)
+}
+
+type repository struct {
+	db *database.DB
+}
+
+func NewRepo(db *sqlx.DB) Repository {
+	return &repository{database.NewDB(db)}
+}
+
+func (r *repository) Driver() string {
+	return r.db.DriverName()
+}
+
+func (r *repository) SchemaVersion(_ context.Context) (string, error) {
+	return database.SchemaVersion(r.db.DB)
+}
+
+func list[T any](ctx context.Context, db *database.DB, table string, query string) ([]T, error) {
+
+	records := make([]T, 0)
+
+	if err := db.SelectContext(ctx, &records, query); err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", table, err)
+	}
+
+	return records, nil
+}
+
+func (r *repository) ListTenants(ctx context.Context) ([]Tenant, error) {
+	return list[Tenant](ctx, r.db, "tenants", "SELECT * FROM tenants ORDER BY id")
+}
+
+func (r *repository) ListGroups(ctx context.Context) ([]Group, error) {
+	return list[Group](ctx, r.db, "groups", "SELECT * FROM groups ORDER BY id")
+}
+
+func (r *repository) ListLocations(ctx context.Context) ([]Location, error) {
+	return list[Location](ctx, r.db, "locations", "SELECT * FROM locations ORDER BY id")
+}
+
+func (r *repository) ListLocationReaders(ctx context.Context) ([]LocationReader, error) {
+	return list[LocationReader](ctx, r.db, "location readers",
+		"SELECT * FROM location_readers ORDER BY tenant_id, reader_id")
+}
+
+func (r *repository) ListUsers(ctx context.Context) ([]User, error) {
+	return list[User](ctx, r.db, "users", "SELECT * FROM users ORDER BY id")
+}
+
+func (r *repository) ListGroupTrainers(ctx context.Context) ([]GroupTrainer, error) {
+	return list[GroupTrainer](ctx, r.db, "group trainers", "SELECT * FROM group_trainers ORDER BY group_id, user_id")
+}
+
+func (r *repository) ListUserIdentities(ctx context.Context) ([]UserIdentity, error) {
+	return list[UserIdentity](ctx, r.db, "user identities", "SELECT * FROM user_identities ORDER BY user_id")
+}
+
+func (r *repository) ListUserTotps(ctx context.Context) ([]UserTotp, error) {
+	return list[UserTotp](ctx, r.db, "totp secrets", "SELECT * FROM user_totp ORDER BY user_id")
+}
+
+func (r *repository) ListRecoveryCodes(ctx context.Context) ([]RecoveryCode, error) {
+	return list[RecoveryCode](ctx, r.db, "recovery codes", "SELECT * FROM recovery_codes ORDER BY id")
+}
+
+func (r *repository) ListLostCards(ctx context.Context) ([]LostCard, error) {
+	return list[LostCard](ctx, r.db, "lost cards", "SELECT * FROM lost_cards ORDER BY id")
+}
+
+func (r *repository) ListCheckIns(ctx context.Context) ([]CheckIn, error) {
+	return list[CheckIn](ctx, r.db, "checkIns", "SELECT * FROM checkins ORDER BY id")
+}
+
+func (r *repository) ListAnnouncements(ctx context.Context) ([]Announcement, error) {
+	return list[Announcement](ctx, r.db, "announcements", "SELECT * FROM announcements ORDER BY id")
+}
+
+// findID returns the id of the row selected by the query, or false if there is none.
+func (r *repository) findID(ctx context.Context, query string, args ...any) (int64, bool, error) {
+
+	var id int64
+
+	if err := r.db.GetContext(ctx, &id, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	return id, true, nil
+}
+
+// insert inserts the record with a statement that does nothing on conflicts and returns the id of the new row. It
+// returns the given outcome if the record was not inserted because of a conflict.
+func (r *repository) insert(ctx context.Context, query string, record any, id *int64, conflict Outcome) (
+	Outcome, error) {
+
+	insertStatement, err := r.db.PrepareNamedContext(ctx, query+" ON CONFLICT DO NOTHING RETURNING id")
+	if err != nil {
+		return 0, err
+	}
+	defer insertStatement.Close()
+
+	if err = insertStatement.QueryRowContext(ctx, record).Scan(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return conflict, nil
+		}
+		return 0, database.Classify(err)
+	}
+
+	return Created, nil
+}
+
+// insertWithoutID inserts a record without id with a statement that does nothing on conflicts. It returns Existing
+// if the record was not inserted because of a conflict.
+func (r *repository) insertWithoutID(ctx context.Context, query string, record any) (Outcome, error) {
+
+	result, err := r.db.NamedExecContext(ctx, query+" ON CONFLICT DO NOTHING", record)
+	if err != nil {
+		return 0, database.Classify(err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if n == 0 {
+		return Existing, nil
+	}
+
+	return Created, nil
+}
+
+func (r *repository) SaveTenant(ctx context.Context, tenant *Tenant) (Outcome, error) {
+
+	id, ok, err := r.findID(ctx, "SELECT id FROM tenants WHERE slug = $1", tenant.Slug)
+	if err != nil || ok {
+		tenant.ID = id
+		return Existing, err
+	}
+
+	return r.insert(ctx, `INSERT INTO tenants (created_at, updated_at, name, slug) VALUES
+		(:created_at, :updated_at, :name, :slug)`, tenant, &tenant.ID, Conflicting)
+}
+
+func (r *repository) SaveGroup(ctx context.Context, group *Group) (Outcome, error) {
+
+	id, ok, err := r.findID(ctx, "SELECT id FROM groups WHERE tenant_id = $1 AND name = $2", group.TenantID,
+		group.Name)
+	if err != nil || ok {
+		group.ID = id
+		return Existing, err
+	}
+
+	return r.insert(ctx, `INSERT INTO groups
+		(tenant_id, created_at, updated_at, name, description, colour, capacity, schedule) VALUES
+		(:tenant_id, :created_at, :updated_at, :name, :description, :colour, :capacity, :schedule)`,
+		group, &group.ID, Conflicting)
+}
+
+func (r *repository) SaveLocation(ctx context.Context, location *Location) (Outcome, error) {
+
+	id, ok, err := r.findID(ctx, "SELECT id FROM locations WHERE tenant_id = $1 AND name = $2", location.TenantID,
+		location.Name)
+	if err != nil || ok {
+		location.ID = id
+		return Existing, err
+	}
+
+	return r.insert(ctx, `INSERT INTO locations
+		(tenant_id, created_at, updated_at, name, description, capacity) VALUES
+		(:tenant_id, :created_at, :updated_at, :name, :description, :capacity)`,
+		location, &location.ID, Conflicting)
+}
+
+func (r *repository) SaveLocationReader(ctx context.Context, reader *LocationReader) (Outcome, error) {
+	return r.insertWithoutID(ctx, `INSERT INTO location_readers (tenant_id, reader_id, location_id) VALUES
+		(:tenant_id, :reader_id, :location_id)`, reader)
+}
+
+// SaveUser matches users by name, users with the rfid uid or member id of another user are Conflicting.
+func (r *repository) SaveUser(ctx context.Context, user *User) (Outcome, error) {
+
+	id, ok, err := r.findID(ctx, "SELECT id FROM users WHERE tenant_id = $1 AND name = $2", user.TenantID,
+		user.Name)
+	if err != nil || ok {
+		user.ID = id
+		return Existing, err
+	}
+
+	return r.insert(ctx, `INSERT INTO users
+		(tenant_id, created_at, updated_at, name, rfid_uid, member_id, password_digest, role, email, group_id) VALUES
+		(:tenant_id, :created_at, :updated_at, :name, :rfid_uid, :member_id, :password_digest, :role, :email,
+		 :group_id)`, user, &user.ID, Conflicting)
+}
+
+func (r *repository) SaveGroupTrainer(ctx context.Context, trainer *GroupTrainer) (Outcome, error) {
+	return r.insertWithoutID(ctx, `INSERT INTO group_trainers (group_id, user_id) VALUES (:group_id, :user_id)`,
+		trainer)
+}
+
+func (r *repository) SaveUserIdentity(ctx context.Context, identity *UserIdentity) (Outcome, error) {
+	return r.insertWithoutID(ctx, `INSERT INTO user_identities (issuer, subject, user_id, created_at) VALUES
+		(:issuer, :subject, :user_id, :created_at)`, identity)
+}
+
+func (r *repository) SaveUserTotp(ctx context.Context, totp *UserTotp) (Outcome, error) {
+	return r.insertWithoutID(ctx, `INSERT INTO user_totp
+		(user_id, secret, created_at, confirmed_at, last_used_step) VALUES
+		(:user_id, :secret, :created_at, :confirmed_at, :last_used_step)`, totp)
+}
+
+func (r *repository) SaveRecoveryCode(ctx context.Context, code *RecoveryCode) (Outcome, error) {
+	return r.insert(ctx, `INSERT INTO recovery_codes (user_id, code_digest, used_at) VALUES
+		(:user_id, :code_digest, :used_at)`, code, &code.ID, Existing)
+}
+
+func (r *repository) SaveLostCard(ctx context.Context, card *LostCard) (Outcome, error) {
+
+	id, ok, err := r.findID(ctx, "SELECT id FROM lost_cards WHERE user_id = $1 AND rfid_uid = $2", card.UserID,
+		card.RFIDuid)
+	if err != nil || ok {
+		card.ID = id
+		return Existing, err
+	}
+
+	return r.insert(ctx, `INSERT INTO lost_cards (user_id, rfid_uid, reported_at) VALUES
+		(:user_id, :rfid_uid, :reported_at)`, card, &card.ID, Conflicting)
+}
+
+// SaveCheckIn returns Existing if the user already checked in on the day.
+func (r *repository) SaveCheckIn(ctx context.Context, checkIn *CheckIn) (Outcome, error) {
+	return r.insert(ctx, `INSERT INTO checkins (tenant_id, date, timestamp, user_id, location_id) VALUES
+		(:tenant_id, :date, :timestamp, :user_id, :location_id)`, checkIn, &checkIn.ID, Existing)
+}
+
+func (r *repository) SaveAnnouncement(ctx context.Context, announcement *Announcement) (Outcome, error) {
+
+	id, ok, err := r.findID(ctx, "SELECT id FROM announcements WHERE tenant_id = $1 AND title = $2 AND created_at = $3",
+		announcement.TenantID, announcement.Title, announcement.CreatedAt)
+	if err != nil || ok {
+		announcement.ID = id
+		return Existing, err
+	}
+
+	return r.insert(ctx, `INSERT INTO announcements
+		(tenant_id, created_at, updated_at, title, message, valid_from, valid_until, user_id, group_id) VALUES
+		(:tenant_id, :created_at, :updated_at, :title, :message, :valid_from, :valid_until, :user_id, :group_id)`,
+		announcement, &announcement.ID, Conflicting)
+}
@@ -0,0 +1,140 @@
+//go:build integration
+
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func seed(ctx context.Context, t *testing.T, repo Repository) {
+	t.Helper()
+
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	tenant := &Tenant{CreatedAt: now, Name: "Rowing", Slug: "rowing"}
+	_, err := repo.SaveTenant(ctx, tenant)
+	require.NoError(t, err)
+
+	group := &Group{TenantID: tenant.ID, CreatedAt: now, Name: "juniors"}
+	_, err = repo.SaveGroup(ctx, group)
+	require.NoError(t, err)
+
+	location := &Location{TenantID: tenant.ID, CreatedAt: now, Name: "boathouse"}
+	_, err = repo.SaveLocation(ctx, location)
+	require.NoError(t, err)
+
+	_, err = repo.SaveLocationReader(ctx, &LocationReader{TenantID: tenant.ID, ReaderID: "pi-1",
+		LocationID: location.ID})
+	require.NoError(t, err)
+
+	user := &User{TenantID: tenant.ID, CreatedAt: now, Name: "alice", Role: "TRAINER",
+		RFIDuid: null.StringFrom("abc"), GroupID: null.IntFrom(group.ID)}
+	_, err = repo.SaveUser(ctx, user)
+	require.NoError(t, err)
+
+	_, err = repo.SaveGroupTrainer(ctx, &GroupTrainer{GroupID: group.ID, UserID: user.ID})
+	require.NoError(t, err)
+
+	_, err = repo.SaveLostCard(ctx, &LostCard{UserID: user.ID, RFIDuid: "old", ReportedAt: now})
+	require.NoError(t, err)
+
+	_, err = repo.SaveCheckIn(ctx, &CheckIn{TenantID: tenant.ID, Date: now.Truncate(24 * time.Hour),
+		Timestamp: null.TimeFrom(now), UserID: user.ID, LocationID: null.IntFrom(location.ID)})
+	require.NoError(t, err)
+
+	_, err = repo.SaveAnnouncement(ctx, &Announcement{TenantID: tenant.ID, CreatedAt: now, Title: "regatta",
+		GroupID: null.IntFrom(group.ID)})
+	require.NoError(t, err)
+}
+
+func TestExportImport_BetweenDrivers(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+
+	ctx := context.Background()
+
+	database.ForEachDriver(t, func(t *testing.T, source *sqlx.DB) {
+
+		sourceRepo := NewRepo(source)
+		seed(ctx, t, sourceRepo)
+
+		var export bytes.Buffer
+		counts, err := NewService(sourceRepo, database.NewDB(source)).Export(ctx, &export)
+		require.NoError(t, err)
+		assert.Equal(t, 1, counts[TypeCheckIn])
+
+		database.ForEachDriver(t, func(t *testing.T, target *sqlx.DB) {
+
+			targetRepo := NewRepo(target)
+			s := NewService(targetRepo, database.NewDB(target))
+
+			report, err := s.Import(ctx, bytes.NewReader(export.Bytes()), false)
+			require.NoError(t, err)
+			assert.Empty(t, report.Skipped)
+
+			for _, recordType := range RecordTypes {
+				c := report.Counts[recordType]
+				assert.Equal(t, counts[recordType], c.Created+c.Existing, recordType)
+			}
+
+			checkIns, err := targetRepo.ListCheckIns(ctx)
+			require.NoError(t, err)
+			require.Len(t, checkIns, 1)
+
+			users, err := targetRepo.ListUsers(ctx)
+			require.NoError(t, err)
+
+			var alice User
+			for _, u := range users {
+				if u.Name == "alice" {
+					alice = u
+				}
+			}
+
+			assert.Equal(t, alice.ID, checkIns[0].UserID)
+			assert.Equal(t, alice.TenantID, checkIns[0].TenantID)
+			assert.Equal(t, "2024-03-01", checkIns[0].Date.UTC().Format(time.DateOnly))
+
+			// a second import finds all records
+			report, err = s.Import(ctx, bytes.NewReader(export.Bytes()), false)
+			require.NoError(t, err)
+
+			for _, recordType := range RecordTypes {
+				assert.Equal(t, Count{Existing: counts[recordType]}, *report.Counts[recordType], recordType)
+			}
+		})
+	})
+}
+
+func TestImport_DryRun_IsRolledBack(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+
+	ctx := context.Background()
+
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+
+		repo := NewRepo(db)
+		export := header + "\n" + `{"type":"tenant","record":{"id":2,"name":"Rowing","slug":"rowing"}}`
+
+		report, err := NewService(repo, database.NewDB(db)).Import(ctx, bytes.NewReader([]byte(export)), true)
+		require.NoError(t, err)
+		assert.True(t, report.DryRun)
+		assert.Equal(t, 1, report.Counts[TypeTenant].Created)
+
+		tenants, err := repo.ListTenants(ctx)
+		require.NoError(t, err)
+		assert.Len(t, tenants, 1)
+	})
+}
@@ -0,0 +1,524 @@
+package transfer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/database"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// errDryRun rolls back the transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+type Service interface {
+	// Export writes the header and the records of all tenants as JSON lines and returns the number of records by
+	// type. Sessions, signing keys, login attempts, pending password tokens and the audit log are not exported.
+	Export(ctx context.Context, w io.Writer) (map[string]int, error)
+	// Import inserts the records of an export within a single transaction, which is rolled back for a dry run. The
+	// ids of the records are remapped, records which already exist are kept unchanged and records which would
+	// violate a uniqueness constraint are skipped.
+	Import(ctx context.Context, r io.Reader, dryRun bool) (*Report, error)
+}
+
+type service struct {
+	repo       Repository
+	transactor database.Transactor
+	now        func() time.Time
+}
+
+func NewService(repo Repository, transactor database.Transactor) Service {
+	return &service{repo: repo, transactor: transactor, now: time.Now}
+}
+
+func (s *service) Export(ctx context.Context, w io.Writer) (map[string]int, error) {
+
+	version, err := s.repo.SchemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bufio.NewWriter(w)
+	e := &exporter{enc: json.NewEncoder(buf), counts: make(map[string]int, len(RecordTypes))}
+
+	if err = e.write(TypeHeader, Header{
+		Format:        Format,
+		Version:       FormatVersion,
+		ExportedAt:    s.now().UTC(),
+		Driver:        s.repo.Driver(),
+		SchemaVersion: version,
+	}); err != nil {
+		return nil, err
+	}
+
+	// the records are read within a transaction, so that they are consistent
+	err = s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		return errors.Join(
+			exportRecords(ctx, e, TypeTenant, s.repo.ListTenants),
+			exportRecords(ctx, e, TypeGroup, s.repo.ListGroups),
+			exportRecords(ctx, e, TypeLocation, s.repo.ListLocations),
+			exportRecords(ctx, e, TypeLocationReader, s.repo.ListLocationReaders),
+			exportRecords(ctx, e, TypeUser, s.repo.ListUsers),
+			exportRecords(ctx, e, TypeGroupTrainer, s.repo.ListGroupTrainers),
+			exportRecords(ctx, e, TypeUserIdentity, s.repo.ListUserIdentities),
+			exportRecords(ctx, e, TypeUserTotp, s.repo.ListUserTotps),
+			exportRecords(ctx, e, TypeRecoveryCode, s.repo.ListRecoveryCodes),
+			exportRecords(ctx, e, TypeLostCard, s.repo.ListLostCards),
+			exportRecords(ctx, e, TypeCheckIn, s.repo.ListCheckIns),
+			exportRecords(ctx, e, TypeAnnouncement, s.repo.ListAnnouncements),
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return e.counts, buf.Flush()
+}
+
+type exporter struct {
+	enc    *json.Encoder
+	counts map[string]int
+	err    error
+}
+
+// write writes the record as line, once an error occurred nothing is written anymore.
+func (e *exporter) write(recordType string, record any) error {
+
+	if e.err != nil {
+		return e.err
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		e.err = err
+		return err
+	}
+
+	if e.err = e.enc.Encode(Line{Type: recordType, Record: data}); e.err != nil {
+		return e.err
+	}
+
+	if recordType != TypeHeader {
+		e.counts[recordType]++
+	}
+
+	return nil
+}
+
+func exportRecords[T any](ctx context.Context, e *exporter, recordType string,
+	list func(ctx context.Context) ([]T, error)) error {
+
+	if e.err != nil {
+		return nil
+	}
+
+	records, err := list(ctx)
+	if err != nil {
+		e.err = err
+		return err
+	}
+
+	for _, record := range records {
+		if err = e.write(recordType, record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *service) Import(ctx context.Context, r io.Reader, dryRun bool) (*Report, error) {
+
+	dec := json.NewDecoder(r)
+
+	var line Line
+	if err := dec.Decode(&line); err != nil || line.Type != TypeHeader {
+		return nil, fmt.Errorf("export must start with a header: %w", app.ErrInvalid)
+	}
+
+	header, err := decode[Header](line)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.Format != Format || header.Version < 1 || header.Version > FormatVersion {
+		return nil, fmt.Errorf("unsupported export format %q version %d: %w", header.Format, header.Version,
+			app.ErrInvalid)
+	}
+
+	im := &importer{
+		repo:      s.repo,
+		report:    newReport(*header),
+		tenants:   make(map[int64]int64),
+		groups:    make(map[int64]int64),
+		locations: make(map[int64]int64),
+		users:     make(map[int64]int64),
+	}
+
+	err = s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+
+		for n := 2; ; n++ {
+
+			var line Line
+			if decodeErr := dec.Decode(&line); errors.Is(decodeErr, io.EOF) {
+				break
+			} else if decodeErr != nil {
+				return fmt.Errorf("line %d: %w: %w", n, decodeErr, app.ErrInvalid)
+			}
+
+			if importErr := im.importLine(ctx, line); importErr != nil {
+				return fmt.Errorf("line %d: %w", n, importErr)
+			}
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+
+		return nil
+	})
+
+	if errors.Is(err, errDryRun) {
+		im.report.DryRun = true
+	} else if err != nil {
+		return nil, err
+	}
+
+	return im.report, nil
+}
+
+func decode[T any](line Line) (*T, error) {
+
+	var record T
+	if err := json.Unmarshal(line.Record, &record); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w: %w", line.Type, err, app.ErrInvalid)
+	}
+
+	return &record, nil
+}
+
+// importer maps the ids of the export to the ids of the imported or existing records.
+type importer struct {
+	repo      Repository
+	report    *Report
+	tenants   map[int64]int64
+	groups    map[int64]int64
+	locations map[int64]int64
+	users     map[int64]int64
+}
+
+func (im *importer) importLine(ctx context.Context, line Line) error {
+
+	switch line.Type {
+	case TypeTenant:
+		return im.importTenant(ctx, line)
+	case TypeGroup:
+		return im.importGroup(ctx, line)
+	case TypeLocation:
+		return im.importLocation(ctx, line)
+	case TypeLocationReader:
+		return im.importLocationReader(ctx, line)
+	case TypeUser:
+		return im.importUser(ctx, line)
+	case TypeGroupTrainer:
+		return im.importGroupTrainer(ctx, line)
+	case TypeUserIdentity:
+		return im.importUserIdentity(ctx, line)
+	case TypeUserTotp:
+		return im.importUserTotp(ctx, line)
+	case TypeRecoveryCode:
+		return im.importRecoveryCode(ctx, line)
+	case TypeLostCard:
+		return im.importLostCard(ctx, line)
+	case TypeCheckIn:
+		return im.importCheckIn(ctx, line)
+	case TypeAnnouncement:
+		return im.importAnnouncement(ctx, line)
+	default:
+		return fmt.Errorf("unknown record type %q: %w", line.Type, app.ErrInvalid)
+	}
+}
+
+func (im *importer) importTenant(ctx context.Context, line Line) error {
+
+	t, err := decode[Tenant](line)
+	if err != nil {
+		return err
+	}
+
+	id := t.ID
+
+	outcome, err := im.repo.SaveTenant(ctx, t)
+	ok, err := im.count(line.Type, id, outcome, err)
+	if !ok {
+		return err
+	}
+
+	im.tenants[id] = t.ID
+	return nil
+}
+
+func (im *importer) importGroup(ctx context.Context, line Line) error {
+
+	g, err := decode[Group](line)
+	if err != nil {
+		return err
+	}
+
+	id := g.ID
+	if !im.ref(line.Type, id, TypeTenant, im.tenants, &g.TenantID) {
+		return nil
+	}
+
+	outcome, err := im.repo.SaveGroup(ctx, g)
+	ok, err := im.count(line.Type, id, outcome, err)
+	if !ok {
+		return err
+	}
+
+	im.groups[id] = g.ID
+	return nil
+}
+
+func (im *importer) importLocation(ctx context.Context, line Line) error {
+
+	l, err := decode[Location](line)
+	if err != nil {
+		return err
+	}
+
+	id := l.ID
+	if !im.ref(line.Type, id, TypeTenant, im.tenants, &l.TenantID) {
+		return nil
+	}
+
+	outcome, err := im.repo.SaveLocation(ctx, l)
+	ok, err := im.count(line.Type, id, outcome, err)
+	if !ok {
+		return err
+	}
+
+	im.locations[id] = l.ID
+	return nil
+}
+
+func (im *importer) importLocationReader(ctx context.Context, line Line) error {
+
+	lr, err := decode[LocationReader](line)
+	if err != nil {
+		return err
+	}
+
+	if !im.ref(line.Type, lr.ReaderID, TypeTenant, im.tenants, &lr.TenantID) ||
+		!im.ref(line.Type, lr.ReaderID, TypeLocation, im.locations, &lr.LocationID) {
+		return nil
+	}
+
+	outcome, err := im.repo.SaveLocationReader(ctx, lr)
+	_, err = im.count(line.Type, lr.ReaderID, outcome, err)
+	return err
+}
+
+func (im *importer) importUser(ctx context.Context, line Line) error {
+
+	u, err := decode[User](line)
+	if err != nil {
+		return err
+	}
+
+	id := u.ID
+	if !im.ref(line.Type, id, TypeTenant, im.tenants, &u.TenantID) {
+		return nil
+	}
+	u.GroupID = optionalRef(im.groups, u.GroupID)
+
+	outcome, err := im.repo.SaveUser(ctx, u)
+	ok, err := im.count(line.Type, id, outcome, err)
+	if !ok {
+		return err
+	}
+
+	im.users[id] = u.ID
+	return nil
+}
+
+func (im *importer) importGroupTrainer(ctx context.Context, line Line) error {
+
+	gt, err := decode[GroupTrainer](line)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%d/%d", gt.GroupID, gt.UserID)
+	if !im.ref(line.Type, key, TypeGroup, im.groups, &gt.GroupID) ||
+		!im.ref(line.Type, key, TypeUser, im.users, &gt.UserID) {
+		return nil
+	}
+
+	outcome, err := im.repo.SaveGroupTrainer(ctx, gt)
+	_, err = im.count(line.Type, key, outcome, err)
+	return err
+}
+
+func (im *importer) importUserIdentity(ctx context.Context, line Line) error {
+
+	ui, err := decode[UserIdentity](line)
+	if err != nil {
+		return err
+	}
+
+	key := ui.Issuer + " " + ui.Subject
+	if !im.ref(line.Type, key, TypeUser, im.users, &ui.UserID) {
+		return nil
+	}
+
+	outcome, err := im.repo.SaveUserIdentity(ctx, ui)
+	_, err = im.count(line.Type, key, outcome, err)
+	return err
+}
+
+func (im *importer) importUserTotp(ctx context.Context, line Line) error {
+
+	ut, err := decode[UserTotp](line)
+	if err != nil {
+		return err
+	}
+
+	key := ut.UserID
+	if !im.ref(line.Type, key, TypeUser, im.users, &ut.UserID) {
+		return nil
+	}
+
+	outcome, err := im.repo.SaveUserTotp(ctx, ut)
+	_, err = im.count(line.Type, key, outcome, err)
+	return err
+}
+
+func (im *importer) importRecoveryCode(ctx context.Context, line Line) error {
+
+	rc, err := decode[RecoveryCode](line)
+	if err != nil {
+		return err
+	}
+
+	key := rc.ID
+	if !im.ref(line.Type, key, TypeUser, im.users, &rc.UserID) {
+		return nil
+	}
+
+	outcome, err := im.repo.SaveRecoveryCode(ctx, rc)
+	_, err = im.count(line.Type, key, outcome, err)
+	return err
+}
+
+func (im *importer) importLostCard(ctx context.Context, line Line) error {
+
+	lc, err := decode[LostCard](line)
+	if err != nil {
+		return err
+	}
+
+	key := lc.ID
+	if !im.ref(line.Type, key, TypeUser, im.users, &lc.UserID) {
+		return nil
+	}
+
+	outcome, err := im.repo.SaveLostCard(ctx, lc)
+	_, err = im.count(line.Type, key, outcome, err)
+	return err
+}
+
+func (im *importer) importCheckIn(ctx context.Context, line Line) error {
+
+	c, err := decode[CheckIn](line)
+	if err != nil {
+		return err
+	}
+
+	key := c.ID
+	if !im.ref(line.Type, key, TypeTenant, im.tenants, &c.TenantID) ||
+		!im.ref(line.Type, key, TypeUser, im.users, &c.UserID) {
+		return nil
+	}
+	c.LocationID = optionalRef(im.locations, c.LocationID)
+
+	outcome, err := im.repo.SaveCheckIn(ctx, c)
+	_, err = im.count(line.Type, key, outcome, err)
+	return err
+}
+
+func (im *importer) importAnnouncement(ctx context.Context, line Line) error {
+
+	a, err := decode[Announcement](line)
+	if err != nil {
+		return err
+	}
+
+	// announcements for a user or group must not become visible to everyone, so these references are required
+	key := a.ID
+	if !im.ref(line.Type, key, TypeTenant, im.tenants, &a.TenantID) ||
+		(a.UserID.Valid && !im.ref(line.Type, key, TypeUser, im.users, &a.UserID.Int64)) ||
+		(a.GroupID.Valid && !im.ref(line.Type, key, TypeGroup, im.groups, &a.GroupID.Int64)) {
+		return nil
+	}
+
+	outcome, err := im.repo.SaveAnnouncement(ctx, a)
+	_, err = im.count(line.Type, key, outcome, err)
+	return err
+}
+
+// ref replaces the referenced id of the export with the imported id. It skips the record and returns false if the
+// referenced record was not imported.
+func (im *importer) ref(recordType string, key any, refType string, ids map[int64]int64, id *int64) bool {
+
+	if imported, ok := ids[*id]; ok {
+		*id = imported
+		return true
+	}
+
+	im.skip(recordType, key, fmt.Sprintf("references %s %d, which was not imported", refType, *id))
+	return false
+}
+
+// optionalRef returns the imported id of an optional reference, or null if the referenced record was not imported.
+func optionalRef(ids map[int64]int64, id null.Int) null.Int {
+
+	if imported, ok := ids[id.Int64]; ok && id.Valid {
+		return null.IntFrom(imported)
+	}
+
+	return null.Int{}
+}
+
+// count adds the outcome of a saved record to the report. It returns true if the record was imported or exists, so
+// that it can be referenced.
+func (im *importer) count(recordType string, key any, outcome Outcome, err error) (bool, error) {
+
+	if err != nil {
+		return false, fmt.Errorf("failed to import %s %v: %w", recordType, key, err)
+	}
+
+	switch outcome {
+	case Created:
+		im.report.Counts[recordType].Created++
+	case Existing:
+		im.report.Counts[recordType].Existing++
+	case Conflicting:
+		im.skip(recordType, key, "conflicts with the unique values of an existing "+recordType)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (im *importer) skip(recordType string, key any, reason string) {
+	im.report.Counts[recordType].Skipped++
+	im.report.Skipped = append(im.report.Skipped, fmt.Sprintf("%s %v: %s", recordType, key, reason))
+}
@@ -0,0 +1,110 @@
+//go:build integration
+
+package transfer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+const header = `{"type":"header","record":{"format":"checkin-system-export","version":1}}`
+
+func TestImport_RemapsIDs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+
+	ctx := context.Background()
+
+	export := strings.Join([]string{
+		header,
+		`{"type":"tenant","record":{"id":1,"name":"default","slug":"default"}}`,
+		`{"type":"tenant","record":{"id":2,"name":"Rowing","slug":"rowing"}}`,
+		`{"type":"user","record":{"id":7,"tenant_id":2,"name":"alice","role":"USER","group_id":3}}`,
+		`{"type":"user","record":{"id":8,"tenant_id":1,"name":"carol","role":"USER","rfid_uid":"0xCAFE"}}`,
+		`{"type":"user","record":{"id":9,"tenant_id":5,"name":"bob","role":"USER"}}`,
+		`{"type":"checkin","record":{"id":1,"tenant_id":2,"date":"2024-03-01T00:00:00Z",` +
+			`"timestamp":"2024-03-01T10:00:00Z","user_id":7,"location_id":4}}`,
+		`{"type":"checkin","record":{"id":2,"tenant_id":1,"date":"2024-03-01T00:00:00Z","user_id":8}}`,
+	}, "\n")
+
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+
+		repo := NewRepo(db)
+
+		// the rfid uid of carol belongs to another user of the default tenant
+		_, err := repo.SaveUser(ctx, &User{TenantID: 1, CreatedAt: time.Now(), Name: "dave", Role: "USER",
+			RFIDuid: null.StringFrom("0xCAFE")})
+		require.NoError(t, err)
+
+		report, err := NewService(repo, database.NewDB(db)).Import(ctx, strings.NewReader(export), false)
+		require.NoError(t, err)
+
+		assert.Equal(t, Count{Created: 1, Existing: 1}, *report.Counts[TypeTenant])
+		assert.Equal(t, Count{Created: 1, Skipped: 2}, *report.Counts[TypeUser])
+		assert.Equal(t, Count{Created: 1, Skipped: 1}, *report.Counts[TypeCheckIn])
+		assert.Equal(t, []string{
+			"user 8: conflicts with the unique values of an existing user",
+			"user 9: references tenant 5, which was not imported",
+			"checkin 2: references user 8, which was not imported",
+		}, report.Skipped)
+
+		users, err := repo.ListUsers(ctx)
+		require.NoError(t, err)
+
+		var alice User
+		for _, u := range users {
+			if u.Name == "alice" {
+				alice = u
+			}
+		}
+		assert.NotEqual(t, int64(1), alice.TenantID)
+		assert.False(t, alice.GroupID.Valid, "the group was not imported")
+
+		checkIns, err := repo.ListCheckIns(ctx)
+		require.NoError(t, err)
+		require.Len(t, checkIns, 1)
+		assert.Equal(t, alice.TenantID, checkIns[0].TenantID)
+		assert.Equal(t, alice.ID, checkIns[0].UserID)
+		assert.False(t, checkIns[0].LocationID.Valid, "the location was not imported")
+	})
+}
+
+func TestImport_InvalidExport_ReturnsInvalidErr(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+
+	tests := []struct {
+		name   string
+		export string
+	}{
+		{name: "empty", export: ""},
+		{name: "no header", export: `{"type":"tenant","record":{"id":1,"slug":"default"}}`},
+		{name: "other format", export: `{"type":"header","record":{"format":"other","version":1}}`},
+		{name: "newer version", export: `{"type":"header","record":{"format":"checkin-system-export","version":2}}`},
+		{name: "unknown type", export: header + "\n" + `{"type":"unknown","record":{}}`},
+		{name: "invalid record", export: header + "\n" + `{"type":"tenant","record":{"id":"one"}}`},
+		{name: "invalid json", export: header + "\n" + `{"type":`},
+	}
+
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		s := NewService(NewRepo(db), database.NewDB(db))
+
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				_, err := s.Import(context.Background(), strings.NewReader(tc.export), false)
+				require.ErrorIs(t, err, app.ErrInvalid)
+			})
+		}
+	})
+}
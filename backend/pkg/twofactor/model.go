@@ -0,0 +1,23 @@
+package twofactor
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// TOTP is the time-based one-time password configuration of a user.
+// It is only used for logins once the enrollment was confirmed with a valid code.
+type TOTP struct {
+	UserID       int64     `db:"user_id"`
+	Secret       string    `db:"secret"`
+	CreatedAt    time.Time `db:"created_at"`
+	ConfirmedAt  null.Time `db:"confirmed_at"`
+	LastUsedStep int64     `db:"last_used_step"`
+}
+
+// Enrollment contains everything needed to set up an authenticator app.
+type Enrollment struct {
+	Secret string
+	URI    string
+}
@@ -0,0 +1,157 @@
+package twofactor
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/database"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository interface {
+	GetTOTP(ctx context.Context, userID int64) (*TOTP, error)
+	SaveTOTP(ctx context.Context, totp *TOTP) error
+	ConfirmTOTP(ctx context.Context, userID int64, confirmedAt time.Time, step int64) error
+	UpdateLastUsedStep(ctx context.Context, userID int64, step int64) error
+	DeleteTOTP(ctx context.Context, userID int64) error
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, codeDigests []string) error
+	UseRecoveryCode(ctx context.Context, userID int64, codeDigest string, usedAt time.Time) (bool, error)
+}
+
+type repository struct {
+	db *database.DB
+}
+
+func NewRepo(db *sqlx.DB) Repository {
+	return &repository{database.NewDB(db)}
+}
+
+func (r *repository) GetTOTP(ctx context.Context, userID int64) (*TOTP, error) {
+
+	totp := TOTP{}
+
+	if err := r.db.GetContext(ctx, &totp, "SELECT * FROM user_totp WHERE user_id = $1", userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, app.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &totp, nil
+}
+
+func (r *repository) SaveTOTP(ctx context.Context, totp *TOTP) error {
+
+	upsertStatement, err := r.db.PrepareNamedContext(ctx, `INSERT INTO user_totp
+			(user_id, secret, created_at, confirmed_at, last_used_step) VALUES
+			(:user_id, :secret, :created_at, :confirmed_at, :last_used_step)
+			ON CONFLICT (user_id) DO UPDATE SET
+			secret = excluded.secret,
+			created_at = excluded.created_at,
+			confirmed_at = excluded.confirmed_at,
+			last_used_step = excluded.last_used_step`)
+	if err != nil {
+		return err
+	}
+	defer upsertStatement.Close()
+
+	_, err = upsertStatement.ExecContext(ctx, totp)
+	return err
+}
+
+func (r *repository) ConfirmTOTP(ctx context.Context, userID int64, confirmedAt time.Time, step int64) error {
+
+	updateStatement, err := r.db.PreparexContext(ctx,
+		`UPDATE user_totp SET (confirmed_at, last_used_step) = ($1, $2) WHERE user_id = $3`)
+	if err != nil {
+		return err
+	}
+	defer updateStatement.Close()
+
+	_, err = updateStatement.ExecContext(ctx, confirmedAt, step, userID)
+	return err
+}
+
+func (r *repository) UpdateLastUsedStep(ctx context.Context, userID int64, step int64) error {
+
+	updateStatement, err := r.db.PreparexContext(ctx, `UPDATE user_totp SET last_used_step = $1 WHERE user_id = $2`)
+	if err != nil {
+		return err
+	}
+	defer updateStatement.Close()
+
+	_, err = updateStatement.ExecContext(ctx, step, userID)
+	return err
+}
+
+func (r *repository) DeleteTOTP(ctx context.Context, userID int64) error {
+
+	return r.db.WithTransaction(ctx, func(ctx context.Context) error {
+
+		if _, err := r.db.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+
+		_, err := r.db.ExecContext(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID)
+		return err
+	})
+}
+
+func (r *repository) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeDigests []string) error {
+
+	return r.db.WithTransaction(ctx, func(ctx context.Context) error {
+
+		if _, err := r.db.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+
+		insertStatement, err := r.db.PrepareNamedContext(ctx,
+			`INSERT INTO recovery_codes (user_id, code_digest) VALUES (:user_id, :code_digest)`)
+		if err != nil {
+			return err
+		}
+		defer insertStatement.Close()
+
+		for _, digest := range codeDigests {
+			if _, err = insertStatement.ExecContext(ctx, map[string]any{
+				"user_id":     userID,
+				"code_digest": digest,
+			}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *repository) UseRecoveryCode(
+	ctx context.Context,
+	userID int64,
+	codeDigest string,
+	usedAt time.Time,
+) (bool, error) {
+
+	updateStatement, err := r.db.PreparexContext(ctx, `UPDATE recovery_codes SET used_at = $1
+			WHERE user_id = $2 AND code_digest = $3 AND used_at IS NULL`)
+	if err != nil {
+		return false, err
+	}
+	defer updateStatement.Close()
+
+	result, err := updateStatement.ExecContext(ctx, usedAt, userID, codeDigest)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
@@ -0,0 +1,207 @@
+package twofactor
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/d-rk/checkin-system/pkg/user"
+)
+
+const (
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 8
+)
+
+type Service interface {
+	// Enabled returns true if the user has confirmed a TOTP enrollment.
+	Enabled(ctx context.Context, userID int64) (bool, error)
+	// Required returns true if the user has to provide a second factor to log in.
+	Required(ctx context.Context, user *user.User) (bool, error)
+	// BeginEnrollment creates a new, unconfirmed TOTP secret for the user.
+	BeginEnrollment(ctx context.Context, user *user.User) (*Enrollment, error)
+	// ConfirmEnrollment enables TOTP if the code matches the pending secret and returns new recovery codes.
+	ConfirmEnrollment(ctx context.Context, userID int64, code string) ([]string, error)
+	// Verify checks a TOTP code or an unused recovery code of a user with enabled TOTP.
+	Verify(ctx context.Context, userID int64, code string) error
+	// Disable removes the TOTP of the user, an enabled one only with a valid TOTP or recovery code.
+	Disable(ctx context.Context, userID int64, code string) error
+	// Reset removes the TOTP of the user without a code, e.g. after the authenticator device was lost.
+	Reset(ctx context.Context, userID int64) error
+}
+
+type service struct {
+	repo                 Repository
+	issuer               string
+	requiredForAdminRole bool
+	now                  func() time.Time
+}
+
+func NewService(repo Repository, cfg config.TOTP) Service {
+	return &service{
+		repo:                 repo,
+		issuer:               cfg.Issuer,
+		requiredForAdminRole: cfg.RequiredForAdmin,
+		now:                  time.Now,
+	}
+}
+
+func (s *service) Enabled(ctx context.Context, userID int64) (bool, error) {
+
+	totp, err := s.repo.GetTOTP(ctx, userID)
+	if errors.Is(err, app.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return totp.ConfirmedAt.Valid, nil
+}
+
+func (s *service) Required(ctx context.Context, u *user.User) (bool, error) {
+
+	if s.requiredForAdminRole && (u.Role == user.RoleAdmin || u.Role == user.RoleSuperAdmin) {
+		return true, nil
+	}
+
+	return s.Enabled(ctx, u.ID)
+}
+
+func (s *service) BeginEnrollment(ctx context.Context, u *user.User) (*Enrollment, error) {
+
+	enabled, err := s.Enabled(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, fmt.Errorf("totp already enabled: %w", app.ErrConflict)
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.repo.SaveTOTP(ctx, &TOTP{UserID: u.ID, Secret: secret, CreatedAt: s.now()}); err != nil {
+		return nil, err
+	}
+
+	return &Enrollment{
+		Secret: secret,
+		URI:    otpauthURI(s.issuer, u.Name, secret),
+	}, nil
+}
+
+func (s *service) ConfirmEnrollment(ctx context.Context, userID int64, code string) ([]string, error) {
+
+	totp, err := s.repo.GetTOTP(ctx, userID)
+	if errors.Is(err, app.ErrNotFound) {
+		return nil, fmt.Errorf("no totp enrollment started: %w", app.ErrConflict)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if totp.ConfirmedAt.Valid {
+		return nil, fmt.Errorf("totp already enabled: %w", app.ErrConflict)
+	}
+
+	now := s.now()
+
+	step, ok := validateCode(totp.Secret, normalizeCode(code), now, totp.LastUsedStep)
+	if !ok {
+		return nil, fmt.Errorf("invalid totp code: %w", app.ErrInvalid)
+	}
+
+	if err = s.repo.ConfirmTOTP(ctx, userID, now, step); err != nil {
+		return nil, err
+	}
+
+	return s.generateRecoveryCodes(ctx, userID)
+}
+
+func (s *service) Verify(ctx context.Context, userID int64, code string) error {
+
+	totp, err := s.repo.GetTOTP(ctx, userID)
+	if errors.Is(err, app.ErrNotFound) {
+		return fmt.Errorf("totp not enabled: %w", app.ErrInvalid)
+	} else if err != nil {
+		return err
+	}
+
+	if !totp.ConfirmedAt.Valid {
+		return fmt.Errorf("totp not enabled: %w", app.ErrInvalid)
+	}
+
+	now := s.now()
+	code = normalizeCode(code)
+
+	if step, ok := validateCode(totp.Secret, code, now, totp.LastUsedStep); ok {
+		return s.repo.UpdateLastUsedStep(ctx, userID, step)
+	}
+
+	used, err := s.repo.UseRecoveryCode(ctx, userID, recoveryCodeDigest(code), now)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return fmt.Errorf("invalid totp or recovery code: %w", app.ErrInvalid)
+	}
+
+	return nil
+}
+
+func (s *service) Disable(ctx context.Context, userID int64, code string) error {
+
+	enabled, err := s.Enabled(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if enabled {
+		if err = s.Verify(ctx, userID, code); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.DeleteTOTP(ctx, userID)
+}
+
+func (s *service) Reset(ctx context.Context, userID int64) error {
+	return s.repo.DeleteTOTP(ctx, userID)
+}
+
+func (s *service) generateRecoveryCodes(ctx context.Context, userID int64) ([]string, error) {
+
+	codes := make([]string, recoveryCodeCount)
+	digests := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		code := strings.ToLower(rand.Text()[:recoveryCodeLength])
+		codes[i] = code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:]
+		digests[i] = recoveryCodeDigest(code)
+	}
+
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, digests); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// recoveryCodeDigest hashes a normalized recovery code. The codes are random, so a fast hash is sufficient.
+func recoveryCodeDigest(code string) string {
+	digest := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(digest[:])
+}
+
+// normalizeCode removes formatting added by users or authenticator apps, e.g. "123 456" or "ABCD-EFGH".
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
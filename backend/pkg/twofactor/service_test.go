@@ -0,0 +1,237 @@
+//go:build integration
+
+package twofactor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/user"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestService(db *sqlx.DB) (*service, *testClock) {
+	clock := &testClock{now: time.Date(2025, time.October, 1, 12, 0, 0, 0, time.UTC)}
+	return &service{
+		repo:                 NewRepo(db),
+		issuer:               "Checkin",
+		requiredForAdminRole: true,
+		now:                  func() time.Time { return clock.now },
+	}, clock
+}
+
+func saveUser(t *testing.T, db *sqlx.DB, name, role string) *user.User {
+	t.Helper()
+
+	u, err := user.NewRepo(db).SaveUser(context.Background(), &user.User{Name: name, Role: role})
+	require.NoError(t, err)
+
+	return u
+}
+
+// currentCode returns the code of the authenticator app at the time of the clock.
+func currentCode(t *testing.T, secret string, clock *testClock) string {
+	t.Helper()
+
+	code, err := generateCode(secret, timeStep(clock.now))
+	require.NoError(t, err)
+
+	return code
+}
+
+// enroll enables TOTP for the user and returns the secret and the recovery codes.
+func enroll(t *testing.T, s *service, clock *testClock, u *user.User) (string, []string) {
+	t.Helper()
+
+	enrollment, err := s.BeginEnrollment(context.Background(), u)
+	require.NoError(t, err)
+
+	recoveryCodes, err := s.ConfirmEnrollment(context.Background(), u.ID, currentCode(t, enrollment.Secret, clock))
+	require.NoError(t, err)
+
+	return enrollment.Secret, recoveryCodes
+}
+
+func TestEnrollment(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s, clock := newTestService(db)
+		u := saveUser(t, db, "Alice", user.RoleUser)
+
+		enrollment, err := s.BeginEnrollment(ctx, u)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(enrollment.URI, "otpauth://totp/Checkin:Alice?"), enrollment.URI)
+
+		enabled, err := s.Enabled(ctx, u.ID)
+		require.NoError(t, err)
+		assert.False(t, enabled, "unconfirmed enrollment")
+
+		_, err = s.ConfirmEnrollment(ctx, u.ID, "000000")
+		require.ErrorIs(t, err, app.ErrInvalid)
+
+		recoveryCodes, err := s.ConfirmEnrollment(ctx, u.ID, currentCode(t, enrollment.Secret, clock))
+		require.NoError(t, err)
+		assert.Len(t, recoveryCodes, recoveryCodeCount)
+
+		enabled, err = s.Enabled(ctx, u.ID)
+		require.NoError(t, err)
+		assert.True(t, enabled)
+
+		_, err = s.BeginEnrollment(ctx, u)
+		require.ErrorIs(t, err, app.ErrConflict, "enabled totp is not replaced")
+	})
+}
+
+func TestRequired(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s, clock := newTestService(db)
+		admin := saveUser(t, db, "Admin", user.RoleAdmin)
+		alice := saveUser(t, db, "Alice", user.RoleUser)
+
+		required, err := s.Required(ctx, admin)
+		require.NoError(t, err)
+		assert.True(t, required, "admins have to enroll")
+
+		required, err = s.Required(ctx, alice)
+		require.NoError(t, err)
+		assert.False(t, required)
+
+		enroll(t, s, clock, alice)
+
+		required, err = s.Required(ctx, alice)
+		require.NoError(t, err)
+		assert.True(t, required)
+	})
+}
+
+func TestVerify_Totp(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s, clock := newTestService(db)
+		u := saveUser(t, db, "Alice", user.RoleUser)
+		secret, _ := enroll(t, s, clock, u)
+
+		require.ErrorIs(t, s.Verify(ctx, u.ID, currentCode(t, secret, clock)), app.ErrInvalid,
+			"the code of the enrollment cannot be replayed")
+
+		clock.advance(totpPeriod * time.Second)
+		code := currentCode(t, secret, clock)
+		require.NoError(t, s.Verify(ctx, u.ID, code[:3]+" "+code[3:]))
+		require.ErrorIs(t, s.Verify(ctx, u.ID, code), app.ErrInvalid, "codes are single-use")
+
+		clock.advance(totpPeriod * time.Second)
+		require.ErrorIs(t, s.Verify(ctx, u.ID, "000000"), app.ErrInvalid)
+
+		other := saveUser(t, db, "Bob", user.RoleUser)
+		require.ErrorIs(t, s.Verify(ctx, other.ID, currentCode(t, secret, clock)), app.ErrInvalid,
+			"totp not enabled")
+	})
+}
+
+func TestVerify_RecoveryCode(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s, clock := newTestService(db)
+		u := saveUser(t, db, "Alice", user.RoleUser)
+		_, recoveryCodes := enroll(t, s, clock, u)
+
+		require.NoError(t, s.Verify(ctx, u.ID, strings.ToUpper(recoveryCodes[0])))
+		require.ErrorIs(t, s.Verify(ctx, u.ID, recoveryCodes[0]), app.ErrInvalid, "recovery codes are single-use")
+		require.NoError(t, s.Verify(ctx, u.ID, recoveryCodes[1]))
+	})
+}
+
+func TestDisable(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s, clock := newTestService(db)
+		alice := saveUser(t, db, "Alice", user.RoleUser)
+		bob := saveUser(t, db, "Bob", user.RoleUser)
+		secret, _ := enroll(t, s, clock, alice)
+		_, recoveryCodes := enroll(t, s, clock, bob)
+
+		require.ErrorIs(t, s.Disable(ctx, alice.ID, ""), app.ErrInvalid)
+		require.ErrorIs(t, s.Disable(ctx, alice.ID, "000000"), app.ErrInvalid)
+		enabled, err := s.Enabled(ctx, alice.ID)
+		require.NoError(t, err)
+		assert.True(t, enabled, "totp is kept without a valid code")
+
+		clock.advance(totpPeriod * time.Second)
+		require.NoError(t, s.Disable(ctx, alice.ID, currentCode(t, secret, clock)))
+		enabled, err = s.Enabled(ctx, alice.ID)
+		require.NoError(t, err)
+		assert.False(t, enabled)
+
+		require.NoError(t, s.Disable(ctx, bob.ID, recoveryCodes[0]))
+		enabled, err = s.Enabled(ctx, bob.ID)
+		require.NoError(t, err)
+		assert.False(t, enabled)
+	})
+}
+
+func TestDisable_PendingEnrollment_NeedsNoCode(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s, _ := newTestService(db)
+		u := saveUser(t, db, "Alice", user.RoleUser)
+
+		_, err := s.BeginEnrollment(ctx, u)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Disable(ctx, u.ID, ""))
+		_, err = s.repo.GetTOTP(ctx, u.ID)
+		require.ErrorIs(t, err, app.ErrNotFound)
+	})
+}
+
+func TestReset(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s, clock := newTestService(db)
+		u := saveUser(t, db, "Alice", user.RoleUser)
+		enroll(t, s, clock, u)
+
+		require.NoError(t, s.Reset(ctx, u.ID))
+
+		enabled, err := s.Enabled(ctx, u.ID)
+		require.NoError(t, err)
+		assert.False(t, enabled)
+		require.ErrorIs(t, s.Verify(ctx, u.ID, "000000"), app.ErrInvalid)
+	})
+}
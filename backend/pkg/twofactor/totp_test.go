@@ -0,0 +1,53 @@
+package twofactor
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfc6238Secret is the SHA1 seed of the test vectors in RFC 6238 appendix B.
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).
+	EncodeToString([]byte("12345678901234567890"))
+
+func TestGenerateCode(t *testing.T) {
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := generateCode(rfc6238Secret, timeStep(time.Unix(tt.unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, code, "time %d", tt.unix)
+	}
+}
+
+func TestValidateCode(t *testing.T) {
+
+	now := time.Unix(1234567890, 0)
+	current := timeStep(now)
+
+	code, err := generateCode(rfc6238Secret, current-1)
+	require.NoError(t, err)
+
+	step, ok := validateCode(rfc6238Secret, code, now, 0)
+	assert.True(t, ok, "previous step is accepted")
+	assert.Equal(t, current-1, step)
+
+	_, ok = validateCode(rfc6238Secret, code, now, step)
+	assert.False(t, ok, "used code is rejected")
+
+	_, ok = validateCode(rfc6238Secret, code, now.Add(2*totpPeriod*time.Second), 0)
+	assert.False(t, ok, "expired code is rejected")
+}
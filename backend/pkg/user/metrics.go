@@ -0,0 +1,14 @@
+package user
+
+import (
+	"github.com/d-rk/checkin-system/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var loginFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Name:      "login_failures_total",
+	Help:      "Number of failed password logins by reason (unknown_user or wrong_password).",
+}, []string{"reason"})
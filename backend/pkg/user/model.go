@@ -6,14 +6,35 @@ import (
 	"gopkg.in/guregu/null.v4"
 )
 
+const (
+	// RoleSuperAdmin administrates all tenants of the deployment. It is an ADMIN in every tenant.
+	RoleSuperAdmin = "SUPER_ADMIN"
+	RoleAdmin      = "ADMIN"
+	RoleTrainer    = "TRAINER"
+	RoleUser       = "USER"
+	// RoleSync is the role of the edge instances, which only access the sync operations.
+	RoleSync = "SYNC"
+)
+
 type User struct {
 	ID             int64       `db:"id"              json:"id"         csv:"-"`
+	TenantID       int64       `db:"tenant_id"       json:"-"          csv:"-"`
 	CreatedAt      time.Time   `db:"created_at"      json:"created_at" csv:"-"`
 	UpdatedAt      null.Time   `db:"updated_at"      json:"updated_at" csv:"-"`
 	Name           string      `db:"name"            json:"name"       csv:"name"`
-	Group          null.String `db:"group_name"      json:"group"      csv:"group"`
+	GroupID        null.Int    `db:"group_id"        json:"group_id"   csv:"-"`
+	Group          null.String `db:"group_name"      json:"group"      csv:"group"` // name of the group, read only
 	Role           string      `db:"role"            json:"role"       csv:"-"`
 	PasswordDigest null.String `db:"password_digest" json:"-"          csv:"-"`
 	MemberID       null.String `db:"member_id"       json:"member_id"  csv:"member_id"`
 	RFIDuid        null.String `db:"rfid_uid"        json:"rfid_uid"   csv:"rfid_uid"`
+	Email          null.String `db:"email"           json:"email"      csv:"-"`
+}
+
+// LostCard is an rfid card which was reported lost. It is no longer assigned to its user.
+type LostCard struct {
+	ID         int64     `db:"id"          json:"id"          csv:"-"`
+	UserID     int64     `db:"user_id"     json:"user_id"     csv:"-"`
+	RFIDuid    string    `db:"rfid_uid"    json:"rfid_uid"    csv:"rfid_uid"`
+	ReportedAt time.Time `db:"reported_at" json:"reported_at" csv:"reported_at"`
 }
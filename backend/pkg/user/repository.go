@@ -9,37 +9,46 @@ import (
 
 	"github.com/d-rk/checkin-system/pkg/app"
 	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/group"
+	"github.com/d-rk/checkin-system/pkg/tenant"
 
 	"github.com/jmoiron/sqlx"
 	"gopkg.in/guregu/null.v4"
 )
 
 type Repository interface {
-	ListUsers(ctx context.Context) ([]User, error)
+	ListUsers(ctx context.Context, scope group.Scope) ([]User, error)
 	GetUserByID(ctx context.Context, id int64) (*User, error)
 	GetUserByName(ctx context.Context, name string, excludeID int64) (*User, error)
+	ListUsersByEmail(ctx context.Context, email string) ([]User, error)
 	GetUserByRfidUID(ctx context.Context, rfidUID string, excludeID int64) (*User, error)
 	DeleteUser(ctx context.Context, id int64) error
 	DeleteAllUsers(ctx context.Context) error
 	SaveUser(ctx context.Context, user *User) (*User, error)
 	UpdateUser(ctx context.Context, user *User) (*User, error)
 	UpdateUserPasswordDigest(ctx context.Context, id int64, passwordDigest string) error
-	ListUserGroups(ctx context.Context) ([]string, error)
+	ListLostCards(ctx context.Context, userID int64) ([]LostCard, error)
+	SaveLostCard(ctx context.Context, card *LostCard) error
 }
 
+// selectUsers selects users along with the name of their group.
+const selectUsers = "SELECT users.*, groups.name AS group_name FROM users " +
+	"LEFT JOIN groups ON groups.id = users.group_id"
+
 type repository struct {
-	db *sqlx.DB
+	db *database.DB
 }
 
 func NewRepo(db *sqlx.DB) Repository {
-	return &repository{db}
+	return &repository{database.NewDB(db)}
 }
 
-func (r *repository) ListUsers(ctx context.Context) ([]User, error) {
+func (r *repository) ListUsers(ctx context.Context, scope group.Scope) ([]User, error) {
 
 	var users []User
 
-	if err := r.db.SelectContext(ctx, &users, "SELECT * FROM users"); err != nil {
+	if err := r.db.SelectContext(ctx, &users, selectUsers+" WHERE "+group.ScopeCondition+" AND users.tenant_id = $2",
+		scope.TrainerID, tenant.ID(ctx)); err != nil {
 		return nil, fmt.Errorf("failed to list users: %w", err)
 	}
 
@@ -50,7 +59,8 @@ func (r *repository) GetUserByID(ctx context.Context, uid int64) (*User, error)
 
 	user := User{}
 
-	if err := r.db.GetContext(ctx, &user, "SELECT * FROM users WHERE id = $1", uid); err != nil {
+	if err := r.db.GetContext(ctx, &user, selectUsers+" WHERE users.id = $1 AND users.tenant_id = $2", uid,
+		tenant.ID(ctx)); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, app.ErrNotFound
 		}
@@ -64,7 +74,9 @@ func (r *repository) GetUserByName(ctx context.Context, name string, excludeID i
 
 	user := User{}
 
-	if err := r.db.GetContext(ctx, &user, "SELECT * FROM users WHERE name = $1 and id != $2", name, excludeID); err != nil {
+	if err := r.db.GetContext(ctx, &user, selectUsers+`
+			WHERE users.name = $1 and users.id != $2 AND users.tenant_id = $3`, name, excludeID,
+		tenant.ID(ctx)); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, app.ErrNotFound
 		}
@@ -74,11 +86,25 @@ func (r *repository) GetUserByName(ctx context.Context, name string, excludeID i
 	return &user, nil
 }
 
+func (r *repository) ListUsersByEmail(ctx context.Context, email string) ([]User, error) {
+
+	users := make([]User, 0)
+
+	if err := r.db.SelectContext(ctx, &users, selectUsers+`
+			WHERE LOWER(users.email) = LOWER($1) AND users.tenant_id = $2`, email, tenant.ID(ctx)); err != nil {
+		return nil, fmt.Errorf("failed to list users by email: %w", err)
+	}
+
+	return users, nil
+}
+
 func (r *repository) GetUserByRfidUID(ctx context.Context, rfidUID string, excludeID int64) (*User, error) {
 
 	user := User{}
 
-	if err := r.db.GetContext(ctx, &user, "SELECT * FROM users WHERE rfid_uid = $1 and id != $2", rfidUID, excludeID); err != nil {
+	if err := r.db.GetContext(ctx, &user, selectUsers+`
+			WHERE users.rfid_uid = $1 and users.id != $2 AND users.tenant_id = $3`, rfidUID, excludeID,
+		tenant.ID(ctx)); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, app.ErrNotFound
 		}
@@ -90,54 +116,56 @@ func (r *repository) GetUserByRfidUID(ctx context.Context, rfidUID string, exclu
 
 func (r *repository) DeleteUser(ctx context.Context, id int64) error {
 
-	return database.WithTransaction(r.db, func(_ database.Tx) error {
+	return r.db.WithTransaction(ctx, func(ctx context.Context) error {
 
-		deleteCheckinsStatement, err := r.db.PreparexContext(ctx, `DELETE FROM checkins WHERE user_id = $1`)
+		deleteCheckinsStatement, err := r.db.PreparexContext(ctx,
+			`DELETE FROM checkins WHERE user_id = $1 AND tenant_id = $2`)
 		if err != nil {
 			return err
 		}
 		defer deleteCheckinsStatement.Close()
 
-		_, err = deleteCheckinsStatement.ExecContext(ctx, id)
+		_, err = deleteCheckinsStatement.ExecContext(ctx, id, tenant.ID(ctx))
 
 		if err != nil {
 			return err
 		}
 
-		deleteUserStatement, err := r.db.PreparexContext(ctx, `DELETE FROM users WHERE id = $1`)
+		deleteUserStatement, err := r.db.PreparexContext(ctx, `DELETE FROM users WHERE id = $1 AND tenant_id = $2`)
 		if err != nil {
 			return err
 		}
 		defer deleteUserStatement.Close()
 
-		_, err = deleteUserStatement.ExecContext(ctx, id)
-		return err
+		_, err = deleteUserStatement.ExecContext(ctx, id, tenant.ID(ctx))
+		return database.Classify(err)
 	})
 }
 
 func (r *repository) DeleteAllUsers(ctx context.Context) error {
 
-	return database.WithTransaction(r.db, func(_ database.Tx) error {
+	return r.db.WithTransaction(ctx, func(ctx context.Context) error {
 
-		deleteCheckinsStatement, err := r.db.PreparexContext(ctx, `DELETE FROM checkins`)
+		deleteCheckinsStatement, err := r.db.PreparexContext(ctx, `DELETE FROM checkins WHERE tenant_id = $1`)
 		if err != nil {
 			return err
 		}
 		defer deleteCheckinsStatement.Close()
 
-		_, err = deleteCheckinsStatement.ExecContext(ctx)
+		_, err = deleteCheckinsStatement.ExecContext(ctx, tenant.ID(ctx))
 
 		if err != nil {
 			return err
 		}
 
-		deleteUserStatement, err := r.db.PreparexContext(ctx, `DELETE FROM users`)
+		// super admins are kept, as they administrate all tenants
+		deleteUserStatement, err := r.db.PreparexContext(ctx, `DELETE FROM users WHERE tenant_id = $1 AND role != $2`)
 		if err != nil {
 			return err
 		}
 		defer deleteUserStatement.Close()
 
-		_, err = deleteUserStatement.ExecContext(ctx)
+		_, err = deleteUserStatement.ExecContext(ctx, tenant.ID(ctx), RoleSuperAdmin)
 		return err
 	})
 }
@@ -145,10 +173,11 @@ func (r *repository) DeleteAllUsers(ctx context.Context) error {
 func (r *repository) SaveUser(ctx context.Context, user *User) (*User, error) {
 
 	user.CreatedAt = time.Now()
+	user.TenantID = tenant.ID(ctx)
 
 	insertStatement, err := r.db.PrepareNamedContext(ctx, `INSERT INTO users
-    		(created_at, name, rfid_uid, member_id, role, group_name) VALUES
-            (:created_at, :name,:rfid_uid, :member_id, :role, :group_name) RETURNING id`)
+    		(created_at, tenant_id, name, rfid_uid, member_id, role, group_id, email) VALUES
+            (:created_at, :tenant_id, :name,:rfid_uid, :member_id, :role, :group_id, :email) RETURNING id`)
 	if err != nil {
 		return nil, err
 	}
@@ -157,11 +186,11 @@ func (r *repository) SaveUser(ctx context.Context, user *User) (*User, error) {
 	row := insertStatement.QueryRowContext(ctx, user)
 
 	if row.Err() != nil {
-		return nil, row.Err()
+		return nil, database.Classify(row.Err())
 	}
 
 	if err = row.Scan(&user.ID); err != nil {
-		return nil, err
+		return nil, database.Classify(err)
 	}
 
 	return user, nil
@@ -170,16 +199,20 @@ func (r *repository) SaveUser(ctx context.Context, user *User) (*User, error) {
 func (r *repository) UpdateUser(ctx context.Context, user *User) (*User, error) {
 
 	user.UpdatedAt = null.TimeFrom(time.Now())
+	user.TenantID = tenant.ID(ctx)
 
 	updateStatement, err := r.db.PrepareNamedContext(ctx, `UPDATE users SET
-    		(updated_at, name, rfid_uid, member_id, role, group_name) =
-            (:updated_at, :name,:rfid_uid, :member_id, :role, :group_name) WHERE id = :id`)
+    		(updated_at, name, rfid_uid, member_id, role, group_id, email) =
+            (:updated_at, :name,:rfid_uid, :member_id, :role, :group_id, :email)
+             WHERE id = :id AND tenant_id = :tenant_id`)
 	if err != nil {
 		return nil, err
 	}
 	defer updateStatement.Close()
 
-	_ = updateStatement.MustExecContext(ctx, user)
+	if _, err = updateStatement.ExecContext(ctx, user); err != nil {
+		return nil, database.Classify(err)
+	}
 
 	return user, nil
 }
@@ -188,40 +221,53 @@ func (r *repository) UpdateUserPasswordDigest(ctx context.Context, id int64, pas
 
 	updateStatement, err := r.db.PrepareNamedContext(ctx, `UPDATE users SET
     		(updated_at, password_digest) = (current_timestamp, :passwordDigest)
-             WHERE id = :id`)
+             WHERE id = :id AND tenant_id = :tenantID`)
 	if err != nil {
 		return err
 	}
 	defer updateStatement.Close()
 
-	_, err = updateStatement.ExecContext(ctx, map[string]interface{}{"id": id, "passwordDigest": passwordDigest})
-	return err
+	_, err = updateStatement.ExecContext(ctx, map[string]interface{}{"id": id, "passwordDigest": passwordDigest,
+		"tenantID": tenant.ID(ctx)})
+	return database.Classify(err)
 }
 
-func (r *repository) ListUserGroups(ctx context.Context) ([]string, error) {
+func (r *repository) ListLostCards(ctx context.Context, userID int64) ([]LostCard, error) {
 
-	groups := make([]string, 0)
-	rows, err := r.db.QueryContext(ctx, "SELECT distinct group_name FROM users where group_name is not null")
+	cards := make([]LostCard, 0)
 
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return groups, nil
-		}
-		return nil, err
+	if err := r.db.SelectContext(ctx, &cards,
+		"SELECT * FROM lost_cards WHERE user_id = $1 ORDER BY reported_at DESC", userID); err != nil {
+		return nil, fmt.Errorf("failed to list lost cards: %w", err)
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var group string
-		if err = rows.Scan(&group); err != nil {
-			return nil, err
+	return cards, nil
+}
+
+// SaveLostCard records the lost card and unassigns it from its user, if it is still assigned.
+func (r *repository) SaveLostCard(ctx context.Context, card *LostCard) error {
+
+	return r.db.WithTransaction(ctx, func(ctx context.Context) error {
+
+		insertStatement, err := r.db.PrepareNamedContext(ctx, `INSERT INTO lost_cards
+    		(user_id, rfid_uid, reported_at) VALUES (:user_id, :rfid_uid, :reported_at) RETURNING id`)
+		if err != nil {
+			return err
 		}
-		groups = append(groups, group)
-	}
+		defer insertStatement.Close()
 
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
+		if err = insertStatement.QueryRowContext(ctx, card).Scan(&card.ID); err != nil {
+			return database.Classify(err)
+		}
 
-	return groups, nil
+		updateStatement, err := r.db.PrepareNamedContext(ctx, `UPDATE users SET
+    		(updated_at, rfid_uid) = (:reported_at, null) WHERE id = :user_id AND rfid_uid = :rfid_uid`)
+		if err != nil {
+			return err
+		}
+		defer updateStatement.Close()
+
+		_, err = updateStatement.ExecContext(ctx, card)
+		return database.Classify(err)
+	})
 }
@@ -0,0 +1,142 @@
+//go:build integration
+
+package user
+
+import (
+	"context"
+	"testing"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestSaveUser_ConstraintViolations(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+
+	tests := []struct {
+		name      string
+		user      User
+		err       error
+		violation database.Violation
+	}{
+		{"duplicate name", User{Name: "Alice", Role: RoleUser}, app.ErrConflict, database.UniqueViolation},
+		{"duplicate rfid", User{Name: "Bob", Role: RoleUser, RFIDuid: null.StringFrom("0xCAFE")}, app.ErrConflict,
+			database.UniqueViolation},
+		{"distinct user", User{Name: "Bob", Role: RoleUser, RFIDuid: null.StringFrom("0xBEEF")}, nil, 0},
+	}
+
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				repo := NewRepo(db)
+				require.NoError(t, repo.DeleteAllUsers(ctx))
+
+				_, err := repo.SaveUser(ctx, &User{Name: "Alice", Role: RoleUser, RFIDuid: null.StringFrom("0xCAFE")})
+				require.NoError(t, err)
+
+				u := tt.user
+				_, err = repo.SaveUser(ctx, &u)
+
+				if tt.err == nil {
+					require.NoError(t, err)
+					return
+				}
+				require.ErrorIs(t, err, tt.err)
+
+				var constraintErr *database.ConstraintError
+				require.ErrorAs(t, err, &constraintErr)
+				assert.Equal(t, tt.violation, constraintErr.Violation)
+			})
+		}
+	})
+}
+
+func TestUpdateUser_DuplicateName_ReturnsConflictErr(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		repo := NewRepo(db)
+
+		_, err := repo.SaveUser(ctx, &User{Name: "Alice", Role: RoleUser})
+		require.NoError(t, err)
+		bob, err := repo.SaveUser(ctx, &User{Name: "Bob", Role: RoleUser})
+		require.NoError(t, err)
+
+		bob.Name = "Alice"
+		_, err = repo.UpdateUser(ctx, bob)
+		require.ErrorIs(t, err, app.ErrConflict)
+	})
+}
+
+func TestNullName_ReturnsInvalidErr(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		_, err := db.Exec("INSERT INTO users (created_at, name, role) VALUES (current_timestamp, NULL, $1)", RoleUser)
+
+		err = database.Classify(err)
+		require.ErrorIs(t, err, app.ErrInvalid)
+
+		var constraintErr *database.ConstraintError
+		require.ErrorAs(t, err, &constraintErr)
+		assert.Equal(t, database.NotNullViolation, constraintErr.Violation)
+	})
+}
+
+func TestDeleteUser_WithinFailedTransaction_IsRolledBack(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		repo := NewRepo(db)
+
+		alice, err := repo.SaveUser(ctx, &User{Name: "Alice", Role: RoleUser})
+		require.NoError(t, err)
+
+		err = database.NewDB(db).WithTransaction(ctx, func(ctx context.Context) error {
+			require.NoError(t, repo.DeleteUser(ctx, alice.ID))
+			_, err := repo.GetUserByID(ctx, alice.ID)
+			require.ErrorIs(t, err, app.ErrNotFound)
+			return app.ErrInternal
+		})
+		require.ErrorIs(t, err, app.ErrInternal)
+
+		_, err = repo.GetUserByID(ctx, alice.ID)
+		require.NoError(t, err)
+	})
+}
+
+func TestListUsersByEmail_IgnoresTheCase(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		repo := NewRepo(db)
+
+		for name, email := range map[string]string{"Alice": "alice@example.org", "Bob": "bob@example.org"} {
+			_, err := repo.SaveUser(ctx, &User{Name: name, Role: RoleUser, Email: null.StringFrom(email)})
+			require.NoError(t, err)
+		}
+
+		users, err := repo.ListUsersByEmail(ctx, "Alice@Example.org")
+		require.NoError(t, err)
+		require.Len(t, users, 1)
+		assert.Equal(t, "Alice", users[0].Name)
+
+		users, err = repo.ListUsersByEmail(ctx, "carol@example.org")
+		require.NoError(t, err)
+		assert.Empty(t, users)
+	})
+}
@@ -5,66 +5,112 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"os"
+	"time"
 
 	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/audit"
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/group"
+	"github.com/d-rk/checkin-system/pkg/password"
+	"github.com/d-rk/checkin-system/pkg/tenant"
+	"github.com/d-rk/checkin-system/pkg/tracing"
 	"github.com/d-rk/checkin-system/pkg/websocket"
-	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/guregu/null.v4"
 )
 
 type Service interface {
-	ListUsers(ctx context.Context) ([]User, error)
+	// ListUsers returns the users within the scope.
+	ListUsers(ctx context.Context, scope group.Scope) ([]User, error)
 	GetUserByNameAndPassword(ctx context.Context, name, password string) (*User, error)
 	GetUserByRfidUID(ctx context.Context, rfidUID string, excludeID int64) (*User, error)
 	GetUserByID(ctx context.Context, id int64) (*User, error)
+	GetUserByName(ctx context.Context, name string) (*User, error)
+	// ListUsersByEmail returns the users with the email address, ignoring the case. Addresses are not unique.
+	ListUsersByEmail(ctx context.Context, email string) ([]User, error)
 	CreateUser(ctx context.Context, user *User) (*User, error)
 	UpdateUser(ctx context.Context, user *User) (*User, error)
 	UpdateUserPassword(ctx context.Context, id int64, password string) error
+	// DeleteUser deletes the user along with its checkIns and records it in the audit log.
 	DeleteUser(ctx context.Context, id int64) error
 	DeleteAllUsers(ctx context.Context) error
-	ListUserGroups(ctx context.Context) ([]string, error)
+	// ReportCardLost unassigns the rfid card from the user, so that it can no longer be used to check in.
+	ReportCardLost(ctx context.Context, userID int64, rfidUID string) (*LostCard, error)
+	ListLostCards(ctx context.Context, userID int64) ([]LostCard, error)
 }
 
+// superAdminName is the name of the super admin created with the configured super admin password.
+const superAdminName = "superadmin"
+
 type service struct {
-	repo      Repository
-	websocket *websocket.Server
+	repo       Repository
+	hasher     password.Hasher
+	policy     *password.Policy
+	websocket  *websocket.Server
+	audit      audit.Service
+	transactor database.Transactor
 }
 
-func NewService(repo Repository, websocket *websocket.Server) Service {
-
-	adminPassword := os.Getenv("ADMIN_PASSWORD")
+// NewService creates the user service. The password of the admin is set to the configured admin password, and the
+// super admin is created if a super admin password is configured.
+func NewService(repo Repository, hasher password.Hasher, policy *password.Policy, websocket *websocket.Server,
+	auditService audit.Service, transactor database.Transactor, cfg config.Users) Service {
 
-	service := &service{repo, websocket}
-	if err := service.updateAdminPassword(context.Background(), adminPassword); err != nil {
+	service := &service{repo, hasher, policy, websocket, auditService, transactor}
+	if err := service.updateAdminPassword(context.Background(), cfg.AdminPassword); err != nil {
+		panic(err)
+	}
+	if err := service.ensureSuperAdmin(context.Background(), cfg.SuperAdminPassword); err != nil {
 		panic(err)
 	}
 
 	return service
 }
 
-func (s *service) ListUsers(ctx context.Context) ([]User, error) {
-	return s.repo.ListUsers(ctx)
+func (s *service) ListUsers(ctx context.Context, scope group.Scope) ([]User, error) {
+	return s.repo.ListUsers(ctx, scope)
 }
 
 func (s *service) GetUserByID(ctx context.Context, id int64) (*User, error) {
 	return s.repo.GetUserByID(ctx, id)
 }
 
+func (s *service) GetUserByName(ctx context.Context, name string) (*User, error) {
+	return s.repo.GetUserByName(ctx, name, -1)
+}
+
 func (s *service) GetUserByRfidUID(ctx context.Context, rfidUID string, excludeID int64) (*User, error) {
 	return s.repo.GetUserByRfidUID(ctx, rfidUID, excludeID)
 }
 
+func (s *service) ListUsersByEmail(ctx context.Context, email string) ([]User, error) {
+	return s.repo.ListUsersByEmail(ctx, email)
+}
+
 func (s *service) GetUserByNameAndPassword(ctx context.Context, name, password string) (*User, error) {
 
+	ctx, span := tracing.Start(ctx, "user.GetUserByNameAndPassword")
+	defer span.End()
+
 	user, err := s.repo.GetUserByName(ctx, name, -1)
-	if err != nil {
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		loginFailures.WithLabelValues("unknown_user").Inc()
+		return nil, err
+	} else if err != nil {
 		return nil, err
 	}
 
-	if !s.passwordEquals(user, password) {
+	if !s.passwordEquals(ctx, user, password) {
+		loginFailures.WithLabelValues("wrong_password").Inc()
 		return nil, app.ErrNotFound
 	}
 
+	if s.hasher.NeedsRehash(user.PasswordDigest.String) {
+		if err = s.storePassword(ctx, user, password); err != nil {
+			slog.WarnContext(ctx, "failed to upgrade password digest", "user_id", user.ID, "error", err)
+		}
+	}
+
 	return user, nil
 }
 
@@ -93,11 +139,27 @@ func (s *service) UpdateUser(ctx context.Context, user *User) (*User, error) {
 }
 
 func (s *service) DeleteUser(ctx context.Context, id int64) error {
-	return s.repo.DeleteUser(ctx, id)
+
+	return s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+
+		if err := s.repo.DeleteUser(ctx, id); err != nil {
+			return err
+		}
+
+		return s.audit.Record(ctx, audit.ActionUserDeleted, null.IntFrom(id))
+	})
 }
 
 func (s *service) DeleteAllUsers(ctx context.Context) error {
-	return s.repo.DeleteAllUsers(ctx)
+
+	return s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+
+		if err := s.repo.DeleteAllUsers(ctx); err != nil {
+			return err
+		}
+
+		return s.audit.Record(ctx, audit.ActionAllUsersDeleted, null.Int{})
+	})
 }
 
 func (s *service) CreateUser(ctx context.Context, user *User) (*User, error) {
@@ -121,11 +183,11 @@ func (s *service) CreateUser(ctx context.Context, user *User) (*User, error) {
 
 func (s *service) UpdateUserPassword(ctx context.Context, id int64, password string) error {
 
-	if password == "" {
-		return fmt.Errorf("empty password provided: %w", app.ErrConflict)
+	if err := s.policy.Validate(password); err != nil {
+		return err
 	}
 
-	user, err := s.repo.GetUserByID(context.Background(), id)
+	user, err := s.repo.GetUserByID(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -133,8 +195,34 @@ func (s *service) UpdateUserPassword(ctx context.Context, id int64, password str
 	return s.updateUserPassword(ctx, user, password)
 }
 
-func (s *service) ListUserGroups(ctx context.Context) ([]string, error) {
-	return s.repo.ListUserGroups(ctx)
+func (s *service) ReportCardLost(ctx context.Context, userID int64, rfidUID string) (*LostCard, error) {
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.RFIDuid.Valid || user.RFIDuid.String != rfidUID {
+		return nil, fmt.Errorf("rfid card not assigned to user: %w", app.ErrNotFound)
+	}
+
+	card := &LostCard{
+		UserID:     userID,
+		RFIDuid:    rfidUID,
+		ReportedAt: time.Now(),
+	}
+
+	if err = s.repo.SaveLostCard(ctx, card); err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "rfid card reported lost", "event", "card_lost", "user_id", userID)
+
+	return card, nil
+}
+
+func (s *service) ListLostCards(ctx context.Context, userID int64) ([]LostCard, error) {
+	return s.repo.ListLostCards(ctx, userID)
 }
 
 func (s *service) updateAdminPassword(ctx context.Context, password string) error {
@@ -143,6 +231,10 @@ func (s *service) updateAdminPassword(ctx context.Context, password string) erro
 		return nil
 	}
 
+	if err := s.policy.Validate(password); err != nil {
+		slog.WarnContext(ctx, "admin password does not fulfill the password policy", "error", err)
+	}
+
 	admin, err := s.repo.GetUserByName(ctx, "admin", -1)
 	if err != nil && errors.Is(err, app.ErrNotFound) {
 		slog.InfoContext(ctx, "not updating admin password. user not found")
@@ -154,24 +246,75 @@ func (s *service) updateAdminPassword(ctx context.Context, password string) erro
 	return s.updateUserPassword(ctx, admin, password)
 }
 
+// ensureSuperAdmin creates the super admin of the deployment in the default tenant, or updates its password. A user
+// of that name which is no super admin is left alone, as that would promote whoever took the name.
+func (s *service) ensureSuperAdmin(ctx context.Context, password string) error {
+
+	if password == "" {
+		return nil
+	}
+
+	if err := s.policy.Validate(password); err != nil {
+		slog.WarnContext(ctx, "super admin password does not fulfill the password policy", "error", err)
+	}
+
+	ctx = tenant.WithID(ctx, tenant.DefaultID)
+
+	superAdmin, err := s.repo.GetUserByName(ctx, superAdminName, -1)
+	if err != nil && errors.Is(err, app.ErrNotFound) {
+		slog.InfoContext(ctx, "creating super admin", "name", superAdminName)
+		superAdmin, err = s.repo.SaveUser(ctx, &User{Name: superAdminName, Role: RoleSuperAdmin})
+	}
+	if err != nil {
+		return err
+	}
+
+	if superAdmin.Role != RoleSuperAdmin {
+		slog.ErrorContext(ctx, "not creating the super admin, its name is taken by another user", "name",
+			superAdminName, "role", superAdmin.Role)
+		return nil
+	}
+
+	return s.updateUserPassword(ctx, superAdmin, password)
+}
+
 func (s *service) updateUserPassword(ctx context.Context, user *User, password string) error {
 
-	if s.passwordEquals(user, password) {
+	if s.passwordEquals(ctx, user, password) && !s.hasher.NeedsRehash(user.PasswordDigest.String) {
 		return nil
 	}
 
-	digest, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return s.storePassword(ctx, user, password)
+}
+
+func (s *service) storePassword(ctx context.Context, user *User, password string) error {
+
+	digest, err := s.hasher.Hash(password)
 	if err != nil {
 		return err
 	}
 
-	return s.repo.UpdateUserPasswordDigest(ctx, user.ID, string(digest))
+	if err = s.repo.UpdateUserPasswordDigest(ctx, user.ID, digest); err != nil {
+		return err
+	}
+
+	user.PasswordDigest.SetValid(digest)
+	return nil
 }
 
-func (s *service) passwordEquals(user *User, password string) bool {
+// passwordEquals returns true if the user has a password which matches the given one.
+// Users without a password can never log in.
+func (s *service) passwordEquals(ctx context.Context, user *User, password string) bool {
 
-	if user.PasswordDigest.IsZero() {
-		return true
+	if user.PasswordDigest.ValueOrZero() == "" {
+		return false
 	}
-	return bcrypt.CompareHashAndPassword([]byte(user.PasswordDigest.String), []byte(password)) != nil
+
+	equals, err := s.hasher.Verify(user.PasswordDigest.String, password)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to verify password digest", "user_id", user.ID, "error", err)
+		return false
+	}
+
+	return equals
 }
@@ -0,0 +1,274 @@
+//go:build integration
+
+package user
+
+import (
+	"context"
+	"testing"
+
+	"github.com/d-rk/checkin-system/pkg/app"
+	"github.com/d-rk/checkin-system/pkg/audit"
+	"github.com/d-rk/checkin-system/pkg/config"
+	"github.com/d-rk/checkin-system/pkg/database"
+	"github.com/d-rk/checkin-system/pkg/password"
+	"github.com/d-rk/checkin-system/pkg/websocket"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/guregu/null.v4"
+)
+
+func newTestService(t *testing.T, db *sqlx.DB, cfg config.Users) Service {
+	t.Helper()
+
+	hasher, err := password.NewHasher(config.Default().Password)
+	require.NoError(t, err)
+
+	policy, err := password.NewPolicy(config.Default().Password)
+	require.NoError(t, err)
+
+	return NewService(NewRepo(db), hasher, policy, &websocket.Server{}, audit.NewService(audit.NewRepo(db)),
+		database.NewDB(db), cfg)
+}
+
+func TestGetUserByNameAndPassword(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+
+	hasher, err := password.NewHasher(config.Default().Password)
+	require.NoError(t, err)
+
+	argon2Digest, err := hasher.Hash("secret-password")
+	require.NoError(t, err)
+
+	bcryptDigest, err := bcrypt.GenerateFromPassword([]byte("secret-password"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		digest      null.String
+		username    string
+		password    string
+		expectedErr error
+		rehashed    bool
+	}{
+		{
+			name:     "correct password",
+			digest:   null.StringFrom(argon2Digest),
+			username: "alice",
+			password: "secret-password",
+		},
+		{
+			name:        "wrong password",
+			digest:      null.StringFrom(argon2Digest),
+			username:    "alice",
+			password:    "wrong-password",
+			expectedErr: app.ErrNotFound,
+		},
+		{
+			name:        "empty password",
+			digest:      null.StringFrom(argon2Digest),
+			username:    "alice",
+			password:    "",
+			expectedErr: app.ErrNotFound,
+		},
+		{
+			name:        "unknown user",
+			digest:      null.StringFrom(argon2Digest),
+			username:    "bob",
+			password:    "secret-password",
+			expectedErr: app.ErrNotFound,
+		},
+		{
+			name:        "user without password",
+			username:    "alice",
+			password:    "",
+			expectedErr: app.ErrNotFound,
+		},
+		{
+			name:        "invalid digest",
+			digest:      null.StringFrom("secret-password"),
+			username:    "alice",
+			password:    "secret-password",
+			expectedErr: app.ErrNotFound,
+		},
+		{
+			name:     "legacy bcrypt digest is upgraded",
+			digest:   null.StringFrom(string(bcryptDigest)),
+			username: "alice",
+			password: "secret-password",
+			rehashed: true,
+		},
+		{
+			name:        "legacy bcrypt digest with wrong password is kept",
+			digest:      null.StringFrom(string(bcryptDigest)),
+			username:    "alice",
+			password:    "wrong-password",
+			expectedErr: app.ErrNotFound,
+		},
+	}
+
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				repo := NewRepo(db)
+				s := newTestService(t, db, config.Users{})
+				require.NoError(t, repo.DeleteAllUsers(ctx))
+
+				alice, err := repo.SaveUser(ctx, &User{Name: "alice", Role: RoleUser})
+				require.NoError(t, err)
+				if tt.digest.Valid {
+					require.NoError(t, repo.UpdateUserPasswordDigest(ctx, alice.ID, tt.digest.String))
+				}
+
+				u, err := s.GetUserByNameAndPassword(ctx, tt.username, tt.password)
+
+				if tt.expectedErr != nil {
+					require.ErrorIs(t, err, tt.expectedErr)
+					assert.Nil(t, u)
+				} else {
+					require.NoError(t, err)
+					assert.Equal(t, alice.ID, u.ID)
+				}
+
+				stored, err := repo.GetUserByID(ctx, alice.ID)
+				require.NoError(t, err)
+				if tt.rehashed {
+					assert.NotEqual(t, tt.digest, stored.PasswordDigest)
+					assert.False(t, hasher.NeedsRehash(stored.PasswordDigest.String))
+				} else {
+					assert.Equal(t, tt.digest, stored.PasswordDigest)
+				}
+			})
+		}
+	})
+}
+
+func TestEnsureSuperAdmin(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		s := newTestService(t, db, config.Users{SuperAdminPassword: "Sup3r-admin-secret!"})
+
+		u, err := s.GetUserByNameAndPassword(ctx, superAdminName, "Sup3r-admin-secret!")
+		require.NoError(t, err)
+		assert.Equal(t, RoleSuperAdmin, u.Role)
+	})
+}
+
+func TestEnsureSuperAdmin_OtherUserWithTheName_IsNotPromoted(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		repo := NewRepo(db)
+
+		imposter, err := repo.SaveUser(ctx, &User{Name: superAdminName, Role: RoleAdmin})
+		require.NoError(t, err)
+
+		newTestService(t, db, config.Users{SuperAdminPassword: "Sup3r-admin-secret!"})
+
+		u, err := repo.GetUserByID(ctx, imposter.ID)
+		require.NoError(t, err)
+		assert.Equal(t, RoleAdmin, u.Role)
+		assert.False(t, u.PasswordDigest.Valid, "the password of the user must not be changed")
+	})
+}
+
+func TestUpdateUserPassword_Policy(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		repo := NewRepo(db)
+		s := newTestService(t, db, config.Users{})
+
+		alice, err := repo.SaveUser(ctx, &User{Name: "alice", Role: RoleUser})
+		require.NoError(t, err)
+
+		err = s.UpdateUserPassword(ctx, alice.ID, "short")
+		require.ErrorIs(t, err, app.ErrInvalid)
+
+		u, err := repo.GetUserByID(ctx, alice.ID)
+		require.NoError(t, err)
+		assert.False(t, u.PasswordDigest.Valid)
+
+		err = s.UpdateUserPassword(ctx, alice.ID, "long enough")
+		require.NoError(t, err)
+
+		u, err = s.GetUserByNameAndPassword(ctx, "alice", "long enough")
+		require.NoError(t, err)
+		assert.Equal(t, alice.ID, u.ID)
+	})
+}
+
+func TestReportCardLost(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		repo := NewRepo(db)
+		s := newTestService(t, db, config.Users{})
+
+		alice, err := repo.SaveUser(ctx, &User{Name: "alice", Role: RoleUser, RFIDuid: null.StringFrom("a1")})
+		require.NoError(t, err)
+		bob, err := repo.SaveUser(ctx, &User{Name: "bob", Role: RoleUser, RFIDuid: null.StringFrom("b2")})
+		require.NoError(t, err)
+
+		_, err = s.ReportCardLost(ctx, alice.ID, "b2")
+		require.ErrorIs(t, err, app.ErrNotFound, "cards of other users cannot be reported")
+
+		u, err := repo.GetUserByID(ctx, bob.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "b2", u.RFIDuid.String)
+
+		card, err := s.ReportCardLost(ctx, alice.ID, "a1")
+		require.NoError(t, err)
+		assert.Equal(t, "a1", card.RFIDuid)
+
+		u, err = repo.GetUserByID(ctx, alice.ID)
+		require.NoError(t, err)
+		assert.False(t, u.RFIDuid.Valid)
+
+		lostCards, err := s.ListLostCards(ctx, alice.ID)
+		require.NoError(t, err)
+		require.Len(t, lostCards, 1)
+		assert.Equal(t, "a1", lostCards[0].RFIDuid)
+
+		_, err = s.ReportCardLost(ctx, alice.ID, "a1")
+		require.ErrorIs(t, err, app.ErrNotFound, "card is no longer assigned")
+	})
+}
+
+func TestDeleteUser_RecordsAuditEntry(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+	database.ForEachDriver(t, func(t *testing.T, db *sqlx.DB) {
+		ctx := context.Background()
+		repo := NewRepo(db)
+		s := newTestService(t, db, config.Users{})
+
+		alice, err := repo.SaveUser(ctx, &User{Name: "Alice", Role: RoleUser})
+		require.NoError(t, err)
+
+		require.NoError(t, s.DeleteUser(ctx, alice.ID))
+
+		_, err = repo.GetUserByID(ctx, alice.ID)
+		require.ErrorIs(t, err, app.ErrNotFound)
+
+		var entries []audit.Entry
+		require.NoError(t, db.Select(&entries, "SELECT * FROM audit_log"))
+		require.Len(t, entries, 1)
+		assert.Equal(t, audit.ActionUserDeleted, entries[0].Action)
+		assert.Equal(t, null.IntFrom(alice.ID), entries[0].SubjectID)
+	})
+}
@@ -1,8 +1,14 @@
 package websocket
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/d-rk/checkin-system/pkg/group"
+	"github.com/d-rk/checkin-system/pkg/tenant"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
@@ -10,9 +16,35 @@ import (
 
 const defaultBufferSize = 1024
 
+// BearerProtocol is the subprotocol of the clients which authenticate with the access token as the next subprotocol,
+// as browsers cannot set the authorization header of websockets.
+const BearerProtocol = "bearer"
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  defaultBufferSize,
 	WriteBufferSize: defaultBufferSize,
+	Subprotocols:    []string{BearerProtocol},
+}
+
+// BearerToken returns the access token the client offered as subprotocol after the BearerProtocol, or "" if it
+// offered none.
+func BearerToken(r *http.Request) string {
+
+	protocols := websocket.Subprotocols(r)
+	for i := 0; i < len(protocols)-1; i++ {
+		if protocols[i] == BearerProtocol {
+			return protocols[i+1]
+		}
+	}
+
+	return ""
+}
+
+type scopeKey struct{}
+
+// WithScope returns the context with the group scope of the authenticated user, which is assigned to the client.
+func WithScope(ctx context.Context, scope group.Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope)
 }
 
 func CreateHandler(server *Server) http.HandlerFunc {
@@ -30,18 +62,30 @@ func CreateHandler(server *Server) http.HandlerFunc {
 		}
 		defer conn.Close()
 
+		// kiosks subscribe to the taps of their hall with ?location=<id>
+		locationID, _ := strconv.ParseInt(r.URL.Query().Get("location"), 10, 64)
+		scope, _ := r.Context().Value(scopeKey{}).(group.Scope)
+
 		// create new client & add to client list
 		client := Client{
 			ID:         uuid.Must(uuid.NewRandom()).String(),
 			Connection: conn,
+			TenantID:   tenant.ID(r.Context()),
+			LocationID: locationID,
+			Scope:      scope,
+			writeMu:    &sync.Mutex{},
 		}
 
-		server.Clients = append(server.Clients, client)
+		server.addClient(client)
+		connectedClients.Inc()
+		defer connectedClients.Dec()
 
 		// greet the new client
 		greeting := fmt.Sprintf("Server: Welcome! Your ID is %s", client.ID)
 		_ = server.PublishClient(&client, Message{Message: greeting, Data: client.ID})
 
+		server.connected(&client)
+
 		// message handling
 		for {
 			messageType, p, err := conn.ReadMessage()
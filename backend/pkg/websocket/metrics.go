@@ -0,0 +1,14 @@
+package websocket
+
+import (
+	"github.com/d-rk/checkin-system/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var connectedClients = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: metrics.Namespace,
+	Name:      "websocket_clients",
+	Help:      "Number of connected websocket clients.",
+})
@@ -1,20 +1,48 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
 	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/group"
+	"github.com/d-rk/checkin-system/pkg/tenant"
+	"github.com/d-rk/checkin-system/pkg/tracing"
 
 	"github.com/gorilla/websocket"
 )
 
+// closeTimeout limits the time to send the close frame to a client on shutdown.
+const closeTimeout = time.Second
+
+// writeTimeout limits the time to send a message to a client, so that a client which stopped reading cannot hold
+// up the messages to the others.
+const writeTimeout = 5 * time.Second
+
 type Server struct {
+	// mu guards the clients. Messages are sent after releasing it, see send.
+	mu      sync.Mutex
 	Clients []Client
+
+	connectHandlers []func(client *Client)
 }
 
 // Client each client consists of auto-generated ID & connection.
 type Client struct {
 	ID         string
 	Connection *websocket.Conn
+	// TenantID is the tenant the client connected to. Clients only receive the messages of their tenant.
+	TenantID int64
+	// LocationID is the location the client subscribed to. Clients without location receive all messages.
+	LocationID int64
+	// Scope is the group scope of the authenticated user. Trainers only receive the taps of the members of their
+	// groups.
+	Scope group.Scope
+
+	// writeMu serializes the writes to the connection, which allows only one writer.
+	writeMu *sync.Mutex
 }
 
 // Message type for a valid message.
@@ -23,11 +51,57 @@ type Message struct {
 	Data    any    `json:"data"`
 }
 
+// OnConnect registers a handler which is called for every newly connected client.
+func (s *Server) OnConnect(handler func(client *Client)) {
+	s.connectHandlers = append(s.connectHandlers, handler)
+}
+
+func (s *Server) connected(client *Client) {
+	for _, handler := range s.connectHandlers {
+		handler(client)
+	}
+}
+
+// send writes the message to the client. The connection is closed if the write fails or times out, which ends the
+// read loop of the client and removes it.
 func (s *Server) send(client *Client, message []byte) {
-	_ = client.Connection.WriteMessage(1, message)
+	client.writeMu.Lock()
+	defer client.writeMu.Unlock()
+
+	_ = client.Connection.SetWriteDeadline(time.Now().Add(writeTimeout))
+
+	if err := client.Connection.WriteMessage(websocket.TextMessage, message); err != nil {
+		slog.Debug("closing websocket client", "client_id", client.ID, "error", err)
+		_ = client.Connection.Close()
+	}
+}
+
+// recipients returns the clients the message is sent to.
+func (s *Server) recipients(receives func(client *Client) bool) []Client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var clients []Client
+	for _, client := range s.Clients {
+		if receives(&client) {
+			clients = append(clients, client)
+		}
+	}
+
+	return clients
+}
+
+func (s *Server) addClient(client Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Clients = append(s.Clients, client)
 }
 
 func (s *Server) RemoveClient(client Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	// Read all client
 	for i := 0; i < len(s.Clients); i++ {
 		if client.ID == (s.Clients)[i].ID {
@@ -50,7 +124,11 @@ func (s *Server) ProcessMessage(client Client, _ int, payload []byte) {
 	_ = s.PublishClient(&client, Message{Message: "cannot handle client message", Data: payload})
 }
 
-func (s *Server) Publish(message any) error {
+// Publish sends the message to the clients of the tenant of the context.
+func (s *Server) Publish(ctx context.Context, message any) error {
+
+	_, span := tracing.Start(ctx, "websocket.Publish")
+	defer span.End()
 
 	rawMessage, err := json.Marshal(message)
 
@@ -58,14 +136,83 @@ func (s *Server) Publish(message any) error {
 		return err
 	}
 
-	// send to clients
-	for _, client := range s.Clients {
+	tenantID := tenant.ID(ctx)
+
+	clients := s.recipients(func(client *Client) bool {
+		return client.TenantID == tenantID
+	})
+
+	for _, client := range clients {
+		s.send(&client, rawMessage)
+	}
+
+	return nil
+}
+
+// PublishLocation sends the message to the clients of the location and to the clients without location within the
+// tenant of the context. Messages without location (0) are sent to all clients of the tenant.
+func (s *Server) PublishLocation(ctx context.Context, locationID int64, message any) error {
+
+	_, span := tracing.Start(ctx, "websocket.PublishLocation")
+	defer span.End()
+
+	rawMessage, err := json.Marshal(message)
+
+	if err != nil {
+		return err
+	}
+
+	for _, client := range s.locationRecipients(ctx, locationID) {
+		s.send(&client, rawMessage)
+	}
+
+	return nil
+}
+
+// PublishMember sends the message about a member to the clients of PublishLocation. Clients with a restricted scope
+// only receive it if inScope returns true for their scope, which is called once per scope.
+func (s *Server) PublishMember(ctx context.Context, locationID int64, inScope func(scope group.Scope) bool,
+	message any) error {
+
+	_, span := tracing.Start(ctx, "websocket.PublishMember")
+	defer span.End()
+
+	rawMessage, err := json.Marshal(message)
+
+	if err != nil {
+		return err
+	}
+
+	// the scopes are checked after releasing the lock of the clients, as they may query the database
+	scopes := make(map[group.Scope]bool)
+
+	for _, client := range s.locationRecipients(ctx, locationID) {
+		if client.Scope.Restricted() {
+			if _, ok := scopes[client.Scope]; !ok {
+				scopes[client.Scope] = inScope(client.Scope)
+			}
+			if !scopes[client.Scope] {
+				continue
+			}
+		}
 		s.send(&client, rawMessage)
 	}
 
 	return nil
 }
 
+// locationRecipients returns the clients of the location and the clients without location within the tenant of the
+// context.
+func (s *Server) locationRecipients(ctx context.Context, locationID int64) []Client {
+
+	tenantID := tenant.ID(ctx)
+
+	return s.recipients(func(client *Client) bool {
+		return client.TenantID == tenantID &&
+			(locationID == 0 || client.LocationID == 0 || client.LocationID == locationID)
+	})
+}
+
 func (s *Server) PublishClient(client *Client, message any) error {
 
 	rawMessage, err := json.Marshal(message)
@@ -78,3 +225,15 @@ func (s *Server) PublishClient(client *Client, message any) error {
 
 	return nil
 }
+
+// Close sends a close frame to all clients and closes their connections, so that they reconnect once the server is
+// back. It is called on shutdown, as the http server does not track the hijacked websocket connections.
+func (s *Server) Close() {
+
+	message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+
+	for _, client := range s.recipients(func(*Client) bool { return true }) {
+		_ = client.Connection.WriteControl(websocket.CloseMessage, message, time.Now().Add(closeTimeout))
+		_ = client.Connection.Close()
+	}
+}
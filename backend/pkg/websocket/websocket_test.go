@@ -0,0 +1,66 @@
+package websocket
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/d-rk/checkin-system/pkg/group"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// connect connects a client in the scope of the trainer, or in the scope of an admin for 0, and reads its greeting.
+func connect(t *testing.T, url string, trainerID int64) *websocket.Conn {
+	t.Helper()
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?trainer="+strconv.FormatInt(trainerID, 10), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	_, _, err = conn.ReadMessage()
+	require.NoError(t, err)
+
+	return conn
+}
+
+func TestPublishMember_TrainersOnlyReceiveTheirMembers(t *testing.T) {
+
+	server := &Server{}
+	handler := CreateHandler(server)
+
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trainerID, _ := strconv.ParseInt(r.URL.Query().Get("trainer"), 10, 64)
+		handler(w, r.WithContext(WithScope(r.Context(), group.TrainerScope(trainerID))))
+	}))
+	defer httpServer.Close()
+
+	url := "ws" + strings.TrimPrefix(httpServer.URL, "http")
+	admin := connect(t, url, 0)
+	ownTrainer := connect(t, url, 1)
+	otherTrainer := connect(t, url, 2)
+
+	var checked []group.Scope
+	require.NoError(t, server.PublishMember(context.Background(), 0, func(scope group.Scope) bool {
+		checked = append(checked, scope)
+		return scope.TrainerID == 1
+	}, Message{Message: "tap"}))
+
+	assert.ElementsMatch(t, []group.Scope{group.TrainerScope(1), group.TrainerScope(2)}, checked)
+
+	for _, conn := range []*websocket.Conn{admin, ownTrainer} {
+		_, message, err := conn.ReadMessage()
+		require.NoError(t, err)
+		assert.Contains(t, string(message), "tap")
+	}
+
+	require.NoError(t, otherTrainer.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+	_, _, err := otherTrainer.ReadMessage()
+	require.Error(t, err)
+}
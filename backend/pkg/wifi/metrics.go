@@ -0,0 +1,24 @@
+package wifi
+
+import (
+	"github.com/d-rk/checkin-system/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var scriptCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Name:      "wifi_script_calls_total",
+	Help:      "Number of calls of the wifi script by action and result (ok or error).",
+}, []string{"action", "result"})
+
+func countScriptCall(args []string, err error) {
+
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+
+	scriptCalls.WithLabelValues(args[0], result).Inc()
+}
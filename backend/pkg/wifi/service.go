@@ -31,6 +31,8 @@ type Service interface {
 	RemoveNetwork(ctx context.Context, ssid string) error
 	GetStatus(ctx context.Context) (Status, error)
 	ToggleWifiMode(ctx context.Context) error
+	// CheckScript returns an error if the script managing the wifi is missing or not executable.
+	CheckScript() error
 }
 
 type service struct {
@@ -38,7 +40,7 @@ type service struct {
 	scriptPath string
 }
 
-func NewService() Service {
+func NewService(executor cmd.Executor) Service {
 
 	scriptPath, err := writeScriptToTemp()
 	if err != nil {
@@ -46,7 +48,7 @@ func NewService() Service {
 	}
 
 	s := service{
-		executor:   cmd.NewExecutor(),
+		executor:   executor,
 		scriptPath: scriptPath,
 	}
 
@@ -136,6 +138,20 @@ func (s *service) ToggleWifiMode(ctx context.Context) error {
 	return s.executeScript(ctx, "toggle-mode")
 }
 
+func (s *service) CheckScript() error {
+
+	info, err := os.Stat(s.scriptPath)
+	if err != nil {
+		return err
+	}
+
+	if info.Mode().Perm()&0o100 == 0 {
+		return fmt.Errorf("%s is not executable", s.scriptPath)
+	}
+
+	return nil
+}
+
 func writeScriptToTemp() (string, error) {
 	tmpDir := os.TempDir()
 	scriptPath := filepath.Join(tmpDir, "wifi-manager.sh")
@@ -153,11 +169,15 @@ func writeScriptToTemp() (string, error) {
 }
 
 func (s *service) executeScriptString(ctx context.Context, args ...string) (string, error) {
-	return s.executor.CallString(ctx, s.scriptPath, args...)
+	output, err := s.executor.CallString(ctx, s.scriptPath, args...)
+	countScriptCall(args, err)
+	return output, err
 }
 
 func (s *service) executeScript(ctx context.Context, args ...string) error {
-	return s.executor.Call(ctx, s.scriptPath, args...)
+	err := s.executor.Call(ctx, s.scriptPath, args...)
+	countScriptCall(args, err)
+	return err
 }
 
 func filterOutput(output string) string {
@@ -2,12 +2,14 @@ import axios, {AxiosResponse} from 'axios';
 import {format, formatISO} from 'date-fns';
 import FileDownload from 'js-file-download';
 import useSWR, {SWRResponse} from 'swr';
-import {ExponentialBackoff, Websocket, WebsocketBuilder} from 'websocket-ts';
-import {WEBSOCKET_BASE_URL} from './config';
+import {Websocket, WebsocketBuilder} from 'websocket-ts';
+import {KIOSK_LOCATION_ID, WEBSOCKET_BASE_URL} from './config';
 import {
   storeTokens,
   clearTokens,
+  getStoredAccessToken,
   getStoredRefreshToken,
+  isTokenExpired,
   setAuthHeader,
 } from './tokenService';
 
@@ -35,9 +37,39 @@ export type CheckInWithUser = CheckIn & {
   user: User;
 };
 
+export type CheckInDisplay = {
+  severity: 'ok' | 'warning' | 'error';
+  greeting: string;
+  message?: string;
+  user_name?: string;
+  visits_this_month: number;
+  visits_this_season: number;
+  streak_weeks: number;
+  milestone?: number;
+  sound: 'short-beep' | 'double-beep' | 'long-beep';
+  led: 'green-blink' | 'alternate-blink' | 'red-blink';
+};
+
+export type Announcement = {
+  id: number;
+  created_at: string;
+  title: string;
+  message?: string;
+  valid_from?: string;
+  valid_until?: string;
+  group?: string;
+  user_id?: number;
+};
+
 export type CheckInMessage = {
   rfid_uid: string;
   check_in?: CheckIn;
+  display?: CheckInDisplay;
+  notes?: Announcement[];
+};
+
+export type AnnouncementsMessage = {
+  announcements: Announcement[];
 };
 
 export type CheckInDate = {
@@ -82,6 +114,10 @@ export const isCheckInMessage = (message: any): message is CheckInMessage => {
   return (message as CheckInMessage).rfid_uid !== undefined;
 };
 
+export const isAnnouncementsMessage = (message: any): message is AnnouncementsMessage => {
+  return (message as AnnouncementsMessage).announcements !== undefined;
+};
+
 const fetcher = async (url: string) => {
   const result = await axios.get(url);
   return result.data;
@@ -117,7 +153,10 @@ export const refreshAccessToken = async (): Promise<BearerToken> => {
 
     return response.data;
   } catch (error) {
-    clearTokens();
+    // the tokens are kept while the backend is unreachable, e.g. during a restart
+    if (axios.isAxiosError(error) && error.response) {
+      clearTokens();
+    }
     throw error;
   }
 };
@@ -226,29 +265,81 @@ export const downloadUserCheckInList = async (userId: number) => {
   FileDownload(response.data, response.headers['x-filename'] ?? 'export.csv');
 };
 
+export type WebsocketConnection = {
+  close: () => void;
+};
+
+// the reconnects are delayed exponentially from 100ms up to 12.8s
+const WEBSOCKET_RETRY_DELAY = 100;
+const WEBSOCKET_MAX_RETRY_EXPONENT = 7;
+
 export const createWebsocket = (
   listener: (payload: any) => void
-): Websocket => {
-  console.log(`using websocket: ${WEBSOCKET_BASE_URL}/websocket`);
-  return new WebsocketBuilder(`${WEBSOCKET_BASE_URL}/websocket`)
-    .withBackoff(new ExponentialBackoff(100, 7))
-    .onOpen(() => {
-      console.log('opened');
-    })
-    .onClose((_, ev: Event) => {
-      console.log('closed' + JSON.stringify(ev));
-    })
-    .onError((_, ev: Event) => {
-      console.log('error:' + JSON.stringify(ev));
-    })
-    .onMessage((_, ev) => {
-      const payload = JSON.parse(ev.data);
-      listener(payload);
-    })
-    .onRetry(() => {
-      console.log('retry');
-    })
-    .build();
+): WebsocketConnection => {
+  const url =
+    KIOSK_LOCATION_ID !== ''
+      ? `${WEBSOCKET_BASE_URL}/websocket?location=${KIOSK_LOCATION_ID}`
+      : `${WEBSOCKET_BASE_URL}/websocket`;
+  console.log(`using websocket: ${url}`);
+
+  let websocket: Websocket | undefined;
+  let closed = false;
+  let retries = 0;
+
+  // the websocket is built on every (re)connect with the current token, as it
+  // expires and the kiosk may be opened before the login
+  const connect = async () => {
+    if (getStoredRefreshToken() && isTokenExpired()) {
+      await refreshAccessToken().catch(error => {
+        console.log('failed to refresh token: ' + error);
+      });
+    }
+    if (closed) {
+      return;
+    }
+
+    // browsers cannot set the authorization header of websockets, the token is passed as subprotocol instead
+    const token = getStoredAccessToken();
+    websocket = new WebsocketBuilder(url)
+      .withProtocols(token ? ['bearer', token] : [])
+      .onOpen(() => {
+        console.log('opened');
+        retries = 0;
+      })
+      .onClose((_, ev: Event) => {
+        console.log('closed' + JSON.stringify(ev));
+        reconnect();
+      })
+      .onError((_, ev: Event) => {
+        console.log('error:' + JSON.stringify(ev));
+      })
+      .onMessage((_, ev) => {
+        const payload = JSON.parse(ev.data);
+        listener(payload);
+      })
+      .build();
+  };
+
+  const reconnect = () => {
+    if (closed) {
+      return;
+    }
+    const delay =
+      WEBSOCKET_RETRY_DELAY *
+      2 ** Math.min(retries, WEBSOCKET_MAX_RETRY_EXPONENT);
+    retries++;
+    console.log('retry');
+    setTimeout(connect, delay);
+  };
+
+  connect();
+
+  return {
+    close: () => {
+      closed = true;
+      websocket?.close();
+    },
+  };
 };
 
 export const useWifiNetworks = (): SWRResponse<WifiNetwork[], Error> => {
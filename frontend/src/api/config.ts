@@ -7,6 +7,9 @@ export const WEBSOCKET_BASE_URL =
     ? API_BASE_URL.replace(/^http/, 'ws')
     : location.origin.replace(/^http/, 'ws');
 
+// kiosks only receive the taps of their location, if set
+export const KIOSK_LOCATION_ID = import.meta.env.VITE_KIOSK_LOCATION_ID || '';
+
 export const API_USER = import.meta.env.VITE_API_USER || '';
 export const API_PASSWORD = import.meta.env.VITE_API_PASSWORD || '';
 
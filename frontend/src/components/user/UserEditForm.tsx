@@ -68,15 +68,14 @@ export const UserEditForm: FC<Props> = ({user, groups, onSubmit}) => {
 
   const [groupOptions, setGroupOptions] = React.useState(groupsWithNull);
 
-  React.useMemo(
-    () =>
-      createWebsocket((payload: any) => {
-        if (isCheckInMessage(payload)) {
-          setValue('rfidUid', payload.rfid_uid);
-        }
-      }),
-    [setValue]
-  );
+  React.useEffect(() => {
+    const websocket = createWebsocket((payload: any) => {
+      if (isCheckInMessage(payload)) {
+        setValue('rfidUid', payload.rfid_uid);
+      }
+    });
+    return () => websocket.close();
+  }, [setValue]);
 
   React.useEffect(() => {
     setValue('role', isAdmin ? 'ADMIN' : 'USER');
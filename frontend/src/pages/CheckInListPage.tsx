@@ -35,24 +35,23 @@ export const CheckInListPage: FC = () => {
   const toast = useToast();
   const {data: checkIns, isLoading, error, mutate} = useCheckInList(date);
 
-  React.useMemo(
-    () =>
-      createWebsocket((payload: any) => {
-        if (isCheckInMessage(payload) && payload.check_in) {
-          if (
-            format(new Date(payload.check_in.date), 'yyyy-MM-dd') ===
-            format(date, 'yyyy-MM-dd')
-          ) {
-            mutate();
-          } else {
-            console.log(
-              `received checking for different date ${payload.check_in.date} != ${date}`
-            );
-          }
+  useEffect(() => {
+    const websocket = createWebsocket((payload: any) => {
+      if (isCheckInMessage(payload) && payload.check_in) {
+        if (
+          format(new Date(payload.check_in.date), 'yyyy-MM-dd') ===
+          format(date, 'yyyy-MM-dd')
+        ) {
+          mutate();
+        } else {
+          console.log(
+            `received checking for different date ${payload.check_in.date} != ${date}`
+          );
         }
-      }),
-    [date, mutate]
-  );
+      }
+    });
+    return () => websocket.close();
+  }, [date, mutate]);
 
   const handleDateChanged = useCallback(
     (date: Date) => {
@@ -43,23 +43,22 @@ export const UserCheckInListPage: FC = () => {
     mutate,
   } = useUserCheckInList(userId ? +userId : -1);
 
-  React.useMemo(
-    () =>
-      createWebsocket((payload: any) => {
-        if (isCheckInMessage(payload) && payload.check_in) {
-          if (userId && payload.check_in.user_id === +userId) {
-            mutate();
-          } else {
-            console.log(
-              `received checking for different user ${payload.check_in.user_id} != ${userId}`
-            );
-          }
+  useEffect(() => {
+    const websocket = createWebsocket((payload: any) => {
+      if (isCheckInMessage(payload) && payload.check_in) {
+        if (userId && payload.check_in.user_id === +userId) {
+          mutate();
         } else {
-          console.log(`received rfid without user: ${payload.rfid_uid}`);
+          console.log(
+            `received checking for different user ${payload.check_in.user_id} != ${userId}`
+          );
         }
-      }),
-    [mutate, userId]
-  );
+      } else {
+        console.log(`received rfid without user: ${payload.rfid_uid}`);
+      }
+    });
+    return () => websocket.close();
+  }, [mutate, userId]);
 
   const handleDownload = () => {
     downloadUserCheckInList(user!.id);
@@ -3,6 +3,7 @@
 import json
 import sys
 import os
+import socket
 import traceback
 from time import sleep
 
@@ -16,6 +17,9 @@ raspi = RaspiAccess()
 API_BASE_URL = os.getenv("API_BASEURL", "http://localhost:8080")
 API_USER = os.getenv("API_USER")
 API_PASSWORD = os.getenv("API_PASSWORD")
+# the backend records the checkIns at the location the reader is assigned to, an empty READER_ID (e.g. passed on
+# by docker compose) falls back to the hostname as well
+READER_ID = os.getenv("READER_ID") or socket.gethostname()
 
 if API_USER is None:
     print("env variable missing: API_USER")
@@ -118,7 +122,7 @@ def show_result(success):
 def post_rfid_id(id):
     print("rfid_uid:", id)
     headers = {"Content-type": "application/json"}
-    success, response = requests_call('post', f"{API_BASE_URL}/api/v1/checkins?rfid={id}", headers=headers)
+    success, response = requests_call('post', f"{API_BASE_URL}/api/v1/checkins?rfid={id}&reader={READER_ID}", headers=headers)
 
     if success:
         print(response.status_code)